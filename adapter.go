@@ -36,6 +36,39 @@ var defaultSettings = map[string]interface{}{
 	//entityValidator : Validator schema or a function to validate the incoming entity in `create` & 'insert' actions.
 	"entityValidator": nil,
 
+	//indexes : indexes created by the adapter on Connect. A list of {"fields": ["lastname", "-age"], "unique": true, "sparse": true, "ttl": 3600}. [Read more](#indexes).
+	"indexes": nil,
+
+	//fixtures : fixture files loaded on start. A path, a list of paths or a map with path and mode (empty or always). [Read more](#fixtures).
+	"fixtures": nil,
+
+	//fieldAccess : roles/scopes (from ctx.Meta) allowed to read and write each field. Example: {"salary": {"read": ["admin"], "write": ["admin"]}}
+	"fieldAccess": nil,
+
+	//permissions : record ownership and acl rules. `true` or a map with ownerField, aclField, userKey, aclWrite and bypassRoles. Default: `nil` (disabled)
+	"permissions": nil,
+
+	//encryptedFields : fields encrypted at rest. A list of fields or a map of field -> {"deterministic": true} to allow equality queries.
+	"encryptedFields": nil,
+
+	//keyProvider : KeyProvider with the keys used by encryptedFields.
+	"keyProvider": nil,
+
+	//statsInterval : interval (time.Duration or milliseconds) to publish the adapter stats as the <service>.stats event. Default: 0 (disabled)
+	"statsInterval": 0,
+
+	//timeout : deadline (time.Duration or milliseconds) of the adapter operations of each action, when the caller has no deadline. Also set per call with the timeout meta. Default: 0 (no deadline)
+	"timeout": 0,
+
+	//connectRetry : retry Connect with exponential backoff and reconnect when the connection drops. `true` or a map with retries, delay, maxDelay, factor and checkInterval. Default: `nil` (one attempt)
+	"connectRetry": nil,
+
+	//exportLimit : max number of records returned by the `export` action, which builds the export in memory. Default: 10000
+	"exportLimit": defaultExportLimit,
+
+	//tenancy : scope all actions to the tenant in ctx.Meta. `true` or a map with field, metaKey, required and perTable. Default: `nil` (disabled)
+	"tenancy": nil,
+
 	//db-adapter : database specific adaptor. Example mongodb-adaptor.
 	"db-adapter": NotDefinedAdapter{},
 }
@@ -53,7 +86,9 @@ type Adapter interface {
 	Insert(params moleculer.Payload) moleculer.Payload
 	Update(params moleculer.Payload) moleculer.Payload
 	UpdateById(id, update moleculer.Payload) moleculer.Payload
+	UpdateMany(params moleculer.Payload) moleculer.Payload
 	RemoveById(id moleculer.Payload) moleculer.Payload
+	RemoveMany(params moleculer.Payload) moleculer.Payload
 	RemoveAll() moleculer.Payload
 }
 
@@ -73,14 +108,47 @@ func settingsDefaults(settings map[string]interface{}) (fields []string, populat
 func transformResult(ctx moleculer.Context, params, result moleculer.Payload, getInstance func() *moleculer.ServiceSchema) moleculer.Payload {
 	instance := getInstance()
 	fields, populates := settingsDefaults(instance.Settings)
-	return populateFields(ctx, constrainFields(
+	return hideFields(ctx, populateFields(ctx, constrainFields(
 		result, params, fields,
-	), params, populates)
+	), params, populates), instance.Settings)
+}
+
+// scopedAction resolves the adapter for the caller, applying the tenancy, permissions and encryptedFields settings, before calling the action.
+// The errors of the action are returned as *Error. See ErrorCode.
+func scopedAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema, tenants *tenantAdapters, action func(Adapter, func() *moleculer.ServiceSchema) moleculer.ActionHandler) moleculer.ActionHandler {
+	handler := func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		// native queries are raw database queries, only accepted from Go code calling the adapter.
+		if params != nil && params.IsMap() && params.Get("nativeQuery").Exists() {
+			return payload.New(NewError(CodeValidation, "nativeQuery is not accepted by the actions!"))
+		}
+		operationContext, cancel := ActionContext(ctx, getInstance().Settings)
+		defer cancel()
+		scoped, err := resolveAdapter(ctx, adapter, getInstance(), tenants)
+		if err != nil {
+			return payload.New(err)
+		}
+		scoped = WithContext(scoped, operationContext)
+		scoped, err = permissionAdapter(ctx, scoped, getInstance())
+		if err != nil {
+			return payload.New(err)
+		}
+		scoped, err = encryptionAdapter(scoped, getInstance())
+		if err != nil {
+			return payload.New(err)
+		}
+		return action(scoped, getInstance)(ctx, params)
+	}
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		return typedResult(handler(ctx, params))
+	}
 }
 
 // findAction
 func findAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if err := checkReadAccess(ctx, params, getInstance().Settings); err != nil {
+			return payload.New(err)
+		}
 		return transformResult(ctx, params, adapter.Find(params), getInstance)
 	}
 }
@@ -88,22 +156,41 @@ func findAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) mo
 // findAndUpdateAction
 func findAndUpdateAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if err := checkReadAccess(ctx, params, getInstance().Settings); err != nil {
+			return payload.New(err)
+		}
+		if err := checkWriteAccess(ctx, params.Get("update"), getInstance().Settings); err != nil {
+			return payload.New(err)
+		}
 		return transformResult(ctx, params, adapter.FindAndUpdate(params), getInstance)
 	}
 }
 
+// countAction
+func countAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if err := checkReadAccess(ctx, params, getInstance().Settings); err != nil {
+			return payload.New(err)
+		}
+		return adapter.Count(params)
+	}
+}
+
 //createAction
 func createAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
 		if params == nil || !params.Exists() {
-			return payload.Error("params cannot be empty!")
+			return payload.New(NewError(CodeValidation, "params cannot be empty!"))
+		}
+		if err := checkWriteAccess(ctx, params, getInstance().Settings); err != nil {
+			return payload.New(err)
 		}
 		r := adapter.Insert(params)
 		if !r.IsError() {
 			event := getInstance().Name + ".created"
 			ctx.Broadcast(event, r.Get("id").String())
 		}
-		return r
+		return hideFields(ctx, r, getInstance().Settings)
 	}
 }
 
@@ -111,17 +198,21 @@ func createAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema)
 func updateAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
 		if params == nil || !params.Exists() {
-			return payload.Error("params cannot be empty!")
+			return payload.New(NewError(CodeValidation, "params cannot be empty!"))
 		}
 		if !params.Get("id").Exists() {
-			return payload.Error("id field required!") //TODO remove this after validator is added
+			return payload.New(NewError(CodeValidation, "id field required!")) //TODO remove this after validator is added
+		}
+		if err := checkWriteAccess(ctx, params.Remove("id"), getInstance().Settings); err != nil {
+			return payload.New(err)
 		}
 		r := adapter.UpdateById(params.Get("id"), params.Remove("id"))
 		if !r.IsError() {
 			event := getInstance().Name + ".updated"
-			ctx.Broadcast(event, r.Get("id").String())
+			// the requested id, since some adapters (e.g. Mongo) only return the counts
+			ctx.Broadcast(event, params.Get("id").String())
 		}
-		return r
+		return hideFields(ctx, r, getInstance().Settings)
 	}
 }
 
@@ -129,14 +220,14 @@ func updateAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema)
 func removeAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
 		if params == nil || !params.Exists() {
-			return payload.Error("params cannot be empty!")
+			return payload.New(NewError(CodeValidation, "params cannot be empty!"))
 		}
 		if !params.Get("id").Exists() {
-			return payload.Error("id field required!") //TODO remove this after validator is added
+			return payload.New(NewError(CodeValidation, "id field required!")) //TODO remove this after validator is added
 		}
 		r := adapter.RemoveById(params.Get("id"))
 		if r.IsError() {
-			return payload.Error("Could not remove record. Error: ", r.Error().Error())
+			return payload.New(WrapError("", r.Error(), "Could not remove record. Error: "))
 		}
 		event := getInstance().Name + ".removed"
 		ctx.Broadcast(event, params.Get("id").String())
@@ -144,9 +235,67 @@ func removeAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema)
 	}
 }
 
+//updateManyAction
+func updateManyAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if params == nil || !params.Exists() {
+			return payload.New(NewError(CodeValidation, "params cannot be empty!"))
+		}
+		if !params.Get("query").Exists() {
+			return payload.New(NewError(CodeValidation, "query field required!"))
+		}
+		if !params.Get("update").Exists() {
+			return payload.New(NewError(CodeValidation, "update field required!"))
+		}
+		if err := checkReadAccess(ctx, params, getInstance().Settings); err != nil {
+			return payload.New(err)
+		}
+		if err := checkWriteAccess(ctx, params.Get("update"), getInstance().Settings); err != nil {
+			return payload.New(err)
+		}
+		r := adapter.UpdateMany(params)
+		if !r.IsError() {
+			event := getInstance().Name + ".updatedMany"
+			ctx.Broadcast(event, map[string]interface{}{
+				"query":         params.Get("query").Value(),
+				"modifiedCount": r.Get("modifiedCount").Value(),
+			})
+		}
+		return r
+	}
+}
+
+//removeManyAction
+func removeManyAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if params == nil || !params.Exists() {
+			return payload.New(NewError(CodeValidation, "params cannot be empty!"))
+		}
+		if !params.Get("query").Exists() {
+			return payload.New(NewError(CodeValidation, "query field required!"))
+		}
+		if err := checkReadAccess(ctx, params, getInstance().Settings); err != nil {
+			return payload.New(err)
+		}
+		r := adapter.RemoveMany(params)
+		if r.IsError() {
+			return payload.New(WrapError("", r.Error(), "Could not remove records. Error: "))
+		}
+		event := getInstance().Name + ".removedMany"
+		ctx.Broadcast(event, map[string]interface{}{
+			"query":        params.Get("query").Value(),
+			"deletedCount": r.Get("deletedCount").Value(),
+		})
+		return r
+	}
+}
+
 // listAction
 func listAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if err := checkReadAccess(ctx, params, getInstance().Settings); err != nil {
+			return payload.New(err)
+		}
 		var rows moleculer.Payload
 		pageSize := getInstance().Settings["pageSize"].(int)
 		if params.Get("pageSize").Exists() {
@@ -176,11 +325,17 @@ func listAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) mo
 			total = adapter.Count(params)
 		}
 		wg.Wait()
+		if rows.IsError() {
+			return rows
+		}
+		if total.IsError() {
+			return total
+		}
 		totalPages := math.Floor(
 			(total.Float() + float64(pageSize) - 1.0) / float64(pageSize))
 
 		return map[string]interface{}{
-			"rows":       rows,
+			"rows":       hideFields(ctx, rows, getInstance().Settings),
 			"total":      total,
 			"page":       page,
 			"pageSize":   pageSize,
@@ -200,10 +355,10 @@ func getAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) mol
 		} else if params.Exists() && params.String() != "" {
 			result = adapter.FindById(params)
 		} else {
-			return payload.Error("Invalid parameter. Action get requires the parameter id or ids!")
+			return payload.New(NewError(CodeValidation, "Invalid parameter. Action get requires the parameter id or ids!"))
 		}
 		if result.IsError() {
-			return payload.Error("Could not get record. Error: ", result.Error().Error())
+			return payload.New(WrapError("", result.Error(), "Could not get record. Error: "))
 		}
 		return transformResult(ctx, params, result, getInstance)
 	}
@@ -215,9 +370,21 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 	getInstance := func() *moleculer.ServiceSchema {
 		return instance
 	}
+	tenants := &tenantAdapters{}
+	metrics := newStoreMetrics()
+	status := &health{}
+	var stopStats, stopConnect chan bool
+	events := []moleculer.Event{}
+	if cache, ok := adapter.(*CacheAdapter); ok && cache.Service != "" {
+		events = cacheEvents(cache)
+	}
+	if adapter != nil {
+		adapter = Wrap(adapter, Timing(metrics.record), requireReady(status))
+	}
 	return moleculer.Mixin{
 		Name:     "db-mixin",
 		Settings: defaultSettings,
+		Events:   events,
 		Created: func(svc moleculer.ServiceSchema, logger *log.Entry) {
 
 		},
@@ -227,21 +394,40 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 				settingsAdapter, exists := instance.Settings["db-adapter"]
 				if exists {
 					context.Logger().Info("db-mixin started - service: ", svc.Name, " -> adapter from settings!")
-					adapter = settingsAdapter.(Adapter)
+					adapter = Wrap(settingsAdapter.(Adapter), Timing(metrics.record), requireReady(status))
 				}
 			}
 			if adapter != nil {
 				context.Logger().Info("db-mixin started - service: ", svc.Name, " -> connecting")
 				adapter.Init(context.Logger().WithField("store", "adapter"), svc.Settings)
-				adapter.Connect()
-				context.Logger().Info("db-mixin started - service: ", svc.Name, " -> connected!")
+				stopConnect = make(chan bool)
+				connectAdapter(adapter, ParseRetryOptions(svc.Settings), status, context.Logger(), stopConnect, func() {
+					context.Logger().Info("db-mixin started - service: ", svc.Name, " -> connected!")
+					if err := seedFixtures(adapter, instance, context.Logger()); err != nil {
+						context.Logger().Error("db-mixin started - service: ", svc.Name, " -> could not load fixtures - error: ", err)
+					}
+				})
+			}
+			if interval := statsInterval(svc.Settings); interval > 0 {
+				stopStats = make(chan bool)
+				go publishStats(context, svc.Name, metrics, interval, stopStats)
 			}
 		},
 		Stopped: func(context moleculer.BrokerContext, svc moleculer.ServiceSchema) {
+			if stopConnect != nil {
+				close(stopConnect)
+				stopConnect = nil
+			}
+			status.setReady(false)
 			if adapter != nil {
 				context.Logger().Info("db-mixin stopped - service: ", svc.Name, " -> adapter.Disconnect()")
 				adapter.Disconnect()
 			}
+			tenants.disconnect(context.Logger())
+			if stopStats != nil {
+				close(stopStats)
+				stopStats = nil
+			}
 		},
 		Actions: []moleculer.Action{
 			//find action
@@ -264,7 +450,7 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 						query        map[string]interface{} `optional:"true"`
 					}{},
 				},
-				Handler: findAction(adapter, getInstance),
+				Handler: scopedAction(adapter, getInstance, tenants, findAction),
 			},
 			//count action
 			{
@@ -281,9 +467,7 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 						query        map[string]interface{} `optional:"true"`
 					}{},
 				},
-				Handler: func(ctx moleculer.Context, params moleculer.Payload) interface{} {
-					return adapter.Count(params)
-				},
+				Handler: scopedAction(adapter, getInstance, tenants, countAction),
 			},
 			//list action
 			{
@@ -305,7 +489,7 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 						query        map[string]interface{} `optional:"true"`
 					}{},
 				},
-				Handler: listAction(adapter, getInstance),
+				Handler: scopedAction(adapter, getInstance, tenants, listAction),
 			},
 			//get action
 			{
@@ -323,12 +507,12 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 						mapping  bool `optional:"true"`
 					}{},
 				},
-				Handler: getAction(adapter, getInstance),
+				Handler: scopedAction(adapter, getInstance, tenants, getAction),
 			},
 			//create action
 			{
 				Name:    "create",
-				Handler: createAction(adapter, getInstance),
+				Handler: scopedAction(adapter, getInstance, tenants, createAction),
 			},
 			//update action
 			{
@@ -338,7 +522,7 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 						id string
 					}{},
 				},
-				Handler: updateAction(adapter, getInstance),
+				Handler: scopedAction(adapter, getInstance, tenants, updateAction),
 			},
 			//remove action
 			{
@@ -348,7 +532,7 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 						id string
 					}{},
 				},
-				Handler: removeAction(adapter, getInstance),
+				Handler: scopedAction(adapter, getInstance, tenants, removeAction),
 			},
 			//findAndUpdate Action
 			{
@@ -367,7 +551,104 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 						query    map[string]interface{} `optional:"false"`
 					}{},
 				},
-				Handler: findAndUpdateAction(adapter, getInstance),
+				Handler: scopedAction(adapter, getInstance, tenants, findAndUpdateAction),
+			},
+			//updateMany Action
+			{
+				Name: "updateMany",
+				Settings: map[string]interface{}{
+					"cache": false,
+				},
+				Schema: moleculer.ObjectSchema{
+					struct {
+						search       string                 `optional:"true"`
+						searchFields []string               `optional:"true"`
+						update       map[string]interface{} `optional:"false"`
+						query        map[string]interface{} `optional:"false"`
+					}{},
+				},
+				Handler: scopedAction(adapter, getInstance, tenants, updateManyAction),
+			},
+			//removeMany Action
+			{
+				Name: "removeMany",
+				Settings: map[string]interface{}{
+					"cache": false,
+				},
+				Schema: moleculer.ObjectSchema{
+					struct {
+						search       string                 `optional:"true"`
+						searchFields []string               `optional:"true"`
+						query        map[string]interface{} `optional:"false"`
+					}{},
+				},
+				Handler: scopedAction(adapter, getInstance, tenants, removeManyAction),
+			},
+			//stats Action
+			{
+				Name: "stats",
+				Settings: map[string]interface{}{
+					"cache": false,
+				},
+				Schema: moleculer.ObjectSchema{
+					struct {
+						format string `optional:"true"`
+					}{},
+				},
+				Handler: statsAction(metrics, getInstance),
+			},
+			//rotateKeys Action
+			{
+				Name: "rotateKeys",
+				Settings: map[string]interface{}{
+					"cache": false,
+				},
+				Handler: scopedAction(adapter, getInstance, tenants, rotateKeysAction),
+			},
+			//export Action
+			{
+				Name: "export",
+				Settings: map[string]interface{}{
+					"cache": false,
+				},
+				Schema: moleculer.ObjectSchema{
+					struct {
+						query  map[string]interface{} `optional:"true"`
+						limit  int                    `optional:"true"`
+						offset int                    `optional:"true"`
+					}{},
+				},
+				Handler: scopedAction(adapter, getInstance, tenants, exportAction),
+			},
+			//import Action
+			{
+				Name: "import",
+				Settings: map[string]interface{}{
+					"cache": false,
+				},
+				Schema: moleculer.ObjectSchema{
+					struct {
+						data string
+						mode string `optional:"true"`
+					}{},
+				},
+				Handler: scopedAction(adapter, getInstance, tenants, importAction),
+			},
+			//indexes Action
+			{
+				Name: "indexes",
+				Settings: map[string]interface{}{
+					"cache": false,
+				},
+				Handler: indexesAction(adapter, getInstance),
+			},
+			//health Action
+			{
+				Name: "health",
+				Settings: map[string]interface{}{
+					"cache": false,
+				},
+				Handler: healthAction(adapter, status),
 			},
 		},
 	}
@@ -616,6 +616,571 @@ var _ = Describe("Moleculer DB Mixin", func() {
 
 })
 
+var _ = Describe("updateMany and removeMany actions", func() {
+	adapter := &MemoryAdapter{
+		Table:        "user",
+		SearchFields: []string{"name"},
+	}
+	ctx, delegates := contextAndDelegated("many-test", moleculer.Config{})
+	var broadCastReceived moleculer.BrokerContext
+	delegates.BroadcastEvent = func(context moleculer.BrokerContext) {
+		broadCastReceived = context
+	}
+	BeforeEach(func() {
+		broadCastReceived = nil
+		mocks.ConnectAndLoadUsers(adapter)
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+	getInstance := func() *moleculer.ServiceSchema { return &moleculer.ServiceSchema{Name: "user"} }
+
+	It("updateMany should fail when missing query or update params", func() {
+		updateMany := updateManyAction(adapter, getInstance)
+		r := updateMany(ctx.(moleculer.Context), payload.New(M{"update": M{"age": 1}})).(moleculer.Payload)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("query field required!"))
+
+		r = updateMany(ctx.(moleculer.Context), payload.New(M{"query": M{"name": "John"}})).(moleculer.Payload)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("update field required!"))
+	})
+
+	It("should reject nativeQuery from the action params", func() {
+		for _, action := range []func(Adapter, func() *moleculer.ServiceSchema) moleculer.ActionHandler{findAction, countAction, updateManyAction, removeManyAction} {
+			handler := scopedAction(adapter, getInstance, &tenantAdapters{}, action)
+			r := payload.New(handler(ctx.(moleculer.Context), payload.New(M{
+				"query":       M{"name": "John"},
+				"nativeQuery": "1) OR (1",
+				"update":      M{"lastname": "Doe"},
+			})))
+			Expect(IsValidation(r.Error())).Should(BeTrue())
+		}
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+		Expect(adapter.Count(payload.New(M{"query": M{"lastname": "Doe"}})).Int()).Should(Equal(0))
+	})
+
+	It("updateMany should update the matching records and broadcast the batch event", func() {
+		updateMany := updateManyAction(adapter, getInstance)
+		r := updateMany(ctx.(moleculer.Context), payload.New(M{
+			"query":  M{"name": "John"},
+			"update": M{"lastname": "Doe"},
+		})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+
+		time.Sleep(time.Millisecond * 100)
+		Expect(broadCastReceived).ShouldNot(BeNil())
+		Expect(broadCastReceived.EventName()).Should(Equal("user.updatedMany"))
+		Expect(broadCastReceived.Payload().Get("modifiedCount").Int()).Should(Equal(2))
+	})
+
+	It("update should broadcast the requested id when the adapter only returns the counts", func() {
+		counts := Wrap(adapter, func(call *Call, next Next) moleculer.Payload {
+			result := next(call)
+			if call.Operation == "UpdateById" && !result.IsError() {
+				return payload.New(M{"modifiedCount": 1, "matchedCount": 1})
+			}
+			return result
+		})
+		marie := adapter.Find(payload.New(M{"query": M{"name": "Marie"}})).First()
+		update := updateAction(counts, getInstance)
+		r := update(ctx.(moleculer.Context), payload.New(M{"id": marie.Get("id").String(), "age": 50})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+
+		time.Sleep(time.Millisecond * 100)
+		Expect(broadCastReceived).ShouldNot(BeNil())
+		Expect(broadCastReceived.EventName()).Should(Equal("user.updated"))
+		Expect(broadCastReceived.Payload().String()).Should(Equal(marie.Get("id").String()))
+	})
+
+	It("removeMany should remove the matching records and broadcast the batch event", func() {
+		removeMany := removeManyAction(adapter, getInstance)
+		r := removeMany(ctx.(moleculer.Context), payload.New(M{
+			"query": M{"age": 13},
+		})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(4))
+
+		time.Sleep(time.Millisecond * 100)
+		Expect(broadCastReceived).ShouldNot(BeNil())
+		Expect(broadCastReceived.EventName()).Should(Equal("user.removedMany"))
+		Expect(broadCastReceived.Payload().Get("deletedCount").Int()).Should(Equal(2))
+	})
+})
+
+var _ = Describe("tenancy", func() {
+	adapter := &MemoryAdapter{
+		Table:        "user",
+		SearchFields: []string{"name"},
+	}
+	brokerCtx, delegates := contextAndDelegated("tenancy-test", moleculer.Config{})
+	delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+	tenantCtx := func(tenant string) moleculer.Context {
+		meta := payload.Empty()
+		if tenant != "" {
+			meta = meta.Add("tenantId", tenant)
+		}
+		return brokerCtx.ChildActionContext("user.find", payload.Empty(), moleculer.Options{Meta: meta}).(moleculer.Context)
+	}
+	svc := &moleculer.ServiceSchema{Name: "user", Settings: map[string]interface{}{
+		"fields":    []string{"**"},
+		"populates": map[string]interface{}{},
+		"tenancy":   true,
+	}}
+	getInstance := func() *moleculer.ServiceSchema { return svc }
+	tenants := &tenantAdapters{}
+
+	var acme, globex moleculer.Payload
+	BeforeEach(func() {
+		mocks.ConnectAndLoadUsers(adapter)
+		create := scopedAction(adapter, getInstance, tenants, createAction)
+		acme = create(tenantCtx("acme"), payload.New(M{"name": "Wile", "age": 40})).(moleculer.Payload)
+		create(tenantCtx("acme"), payload.New(M{"name": "Road Runner", "age": 3}))
+		globex = create(tenantCtx("globex"), payload.New(M{"name": "Hank", "age": 50, "tenantId": "acme"})).(moleculer.Payload)
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+
+	It("should stamp the tenant on create and scope find and count", func() {
+		Expect(acme.Get("tenantId").String()).Should(Equal("acme"))
+		Expect(globex.Get("tenantId").String()).Should(Equal("globex"))
+
+		find := scopedAction(adapter, getInstance, tenants, findAction)
+		r := find(tenantCtx("acme"), payload.New(M{"query": M{"age": M{"$gt": 1}}})).(moleculer.Payload)
+		Expect(r.Len()).Should(Equal(2))
+
+		count := scopedAction(adapter, getInstance, tenants, countAction)
+		Expect(count(tenantCtx("globex"), payload.Empty()).(moleculer.Payload).Int()).Should(Equal(1))
+	})
+
+	It("should not get, update or remove records of another tenant", func() {
+		get := scopedAction(adapter, getInstance, tenants, getAction)
+		r := get(tenantCtx("globex"), payload.New(M{"id": acme.Get("id").String()})).(moleculer.Payload)
+		Expect(r.Get("name").Exists()).Should(BeFalse())
+		r = get(tenantCtx("acme"), payload.New(M{"id": acme.Get("id").String()})).(moleculer.Payload)
+		Expect(r.Get("name").String()).Should(Equal("Wile"))
+
+		update := scopedAction(adapter, getInstance, tenants, updateAction)
+		r = update(tenantCtx("globex"), payload.New(M{"id": acme.Get("id").String(), "age": 1})).(moleculer.Payload)
+		Expect(r.IsError()).Should(BeTrue())
+		r = update(tenantCtx("acme"), payload.New(M{"id": acme.Get("id").String(), "tenantId": "globex"})).(moleculer.Payload)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("tenant field tenantId can't be updated!"))
+
+		remove := scopedAction(adapter, getInstance, tenants, removeAction)
+		r = remove(tenantCtx("globex"), payload.New(M{"id": acme.Get("id").String()})).(moleculer.Payload)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(adapter.FindById(acme.Get("id")).Exists()).Should(BeTrue())
+	})
+
+	It("should reject native queries, which would bypass the tenant condition", func() {
+		scope := &tenantScope{adapter, "tenantId", "globex"}
+		bypass := payload.New(M{"nativeQuery": "1) OR (1", "update": M{"age": 1}})
+		for _, r := range []moleculer.Payload{
+			scope.Find(bypass),
+			scope.FindOne(bypass),
+			scope.Count(bypass),
+			scope.FindAndUpdate(bypass),
+			scope.UpdateMany(bypass),
+			scope.RemoveMany(bypass),
+		} {
+			Expect(IsValidation(r.Error())).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("nativeQuery is not supported with tenancy!"))
+		}
+		Expect(adapter.FindById(acme.Get("id")).Get("age").Int()).Should(Equal(40))
+	})
+
+	It("should reject calls without tenant", func() {
+		find := scopedAction(adapter, getInstance, tenants, findAction)
+		r := payload.New(find(tenantCtx(""), payload.Empty()))
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("tenant required! ctx.Meta.tenantId is missing."))
+	})
+
+	It("should use one table per tenant with perTable", func() {
+		perTable := &moleculer.ServiceSchema{Name: "user", Settings: map[string]interface{}{
+			"fields":    []string{"**"},
+			"populates": map[string]interface{}{},
+			"tenancy":   map[string]interface{}{"perTable": true},
+		}}
+		getPerTable := func() *moleculer.ServiceSchema { return perTable }
+		defer tenants.disconnect(brokerCtx.Logger())
+
+		create := scopedAction(adapter, getPerTable, tenants, createAction)
+		create(tenantCtx("acme"), payload.New(M{"name": "Wile"}))
+		create(tenantCtx("acme"), payload.New(M{"name": "Coyote"}))
+
+		count := scopedAction(adapter, getPerTable, tenants, countAction)
+		Expect(count(tenantCtx("acme"), payload.Empty()).(moleculer.Payload).Int()).Should(Equal(2))
+		Expect(count(tenantCtx("globex"), payload.Empty()).(moleculer.Payload).Int()).Should(Equal(0))
+
+		r := payload.New(count(tenantCtx("acme;drop"), payload.Empty()))
+		Expect(r.IsError()).Should(BeTrue())
+	})
+})
+
+var _ = Describe("field access", func() {
+	adapter := &MemoryAdapter{
+		Table:        "user",
+		SearchFields: []string{"name"},
+	}
+	brokerCtx, delegates := contextAndDelegated("field-access-test", moleculer.Config{})
+	delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+	rolesCtx := func(roles ...string) moleculer.Context {
+		return brokerCtx.ChildActionContext("user.find", payload.Empty(), moleculer.Options{
+			Meta: payload.New(M{"roles": roles}),
+		}).(moleculer.Context)
+	}
+	svc := &moleculer.ServiceSchema{Name: "user", Settings: map[string]interface{}{
+		"fields":    []string{"**"},
+		"populates": map[string]interface{}{},
+		"fieldAccess": map[string]interface{}{
+			"age":      map[string]interface{}{"read": []string{"admin", "hr"}, "write": "admin"},
+			"lastname": map[string]interface{}{"write": []string{"admin"}},
+		},
+	}}
+	getInstance := func() *moleculer.ServiceSchema { return svc }
+
+	BeforeEach(func() {
+		mocks.ConnectAndLoadUsers(adapter)
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+
+	It("should hide the fields the caller can't read", func() {
+		find := findAction(adapter, getInstance)
+		r := find(rolesCtx("user"), payload.New(M{"query": M{"name": "Marie"}})).(moleculer.Payload)
+		Expect(r.First().Get("name").String()).Should(Equal("Marie"))
+		Expect(r.First().Get("age").Exists()).Should(BeFalse())
+
+		r = find(rolesCtx("hr"), payload.New(M{"query": M{"name": "Marie"}})).(moleculer.Payload)
+		Expect(r.First().Get("age").Int()).Should(Equal(75))
+
+		list := listAction(adapter, getInstance)
+		rows := payload.New(list(rolesCtx(), payload.Empty())).Get("rows")
+		Expect(rows.Len()).Should(Equal(6))
+		Expect(rows.First().Get("age").Exists()).Should(BeFalse())
+	})
+
+	It("should reject queries, sorts and searches on the fields the caller can't read", func() {
+		params := []M{
+			{"query": M{"age": M{"$gt": 70}}},
+			{"query": M{"$or": []M{{"name": "Marie"}, {"$not": M{"age": 75}}}}},
+			{"sort": "-age"},
+			{"search": "75", "searchFields": []string{"name", "age"}},
+		}
+		for _, action := range []func(Adapter, func() *moleculer.ServiceSchema) moleculer.ActionHandler{findAction, countAction, listAction} {
+			for _, p := range params {
+				r := payload.New(action(adapter, getInstance)(rolesCtx("user"), payload.New(p)))
+				Expect(ErrorCode(r.Error())).Should(Equal(CodeForbidden))
+				Expect(r.Error().Error()).Should(Equal("Not allowed to read field: age"))
+			}
+		}
+		r := payload.New(removeManyAction(adapter, getInstance)(rolesCtx("user"), payload.New(M{"query": M{"age": 75}})))
+		Expect(ErrorCode(r.Error())).Should(Equal(CodeForbidden))
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+
+		r = payload.New(countAction(adapter, getInstance)(rolesCtx("hr"), payload.New(M{"query": M{"age": M{"$gt": 70}}})))
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Int()).Should(Equal(2))
+	})
+
+	It("should reject writes to protected fields", func() {
+		create := createAction(adapter, getInstance)
+		r := create(rolesCtx("hr"), payload.New(M{"name": "Bob", "age": 30})).(moleculer.Payload)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("Not allowed to write field: age"))
+
+		r = create(rolesCtx("admin"), payload.New(M{"name": "Bob", "age": 30})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("age").Int()).Should(Equal(30))
+
+		update := updateAction(adapter, getInstance)
+		u := update(rolesCtx("hr"), payload.New(M{"id": r.Get("id").String(), "$inc": M{"age": 1}})).(moleculer.Payload)
+		Expect(u.IsError()).Should(BeTrue())
+		u = update(rolesCtx("hr"), payload.New(M{"id": r.Get("id").String(), "name": "Robert"})).(moleculer.Payload)
+		Expect(u.Error()).Should(BeNil())
+		Expect(u.Get("name").String()).Should(Equal("Robert"))
+
+		updateMany := updateManyAction(adapter, getInstance)
+		u = updateMany(rolesCtx("hr"), payload.New(M{"query": M{"name": "John"}, "update": M{"lastname": "Doe"}})).(moleculer.Payload)
+		Expect(u.IsError()).Should(BeTrue())
+		Expect(u.Error().Error()).Should(Equal("Not allowed to write field: lastname"))
+	})
+
+	It("should hide the fields on export and reject the protected fields on import", func() {
+		data := exportAction(adapter, getInstance)(rolesCtx("user"), payload.Empty()).(string)
+		Expect(data).Should(ContainSubstring(`"Marie"`))
+		Expect(data).ShouldNot(ContainSubstring(`"age"`))
+
+		data = `{"$header": {"version": 1, "idField": "id"}}` + "\n" + `{"name": "Bob", "age": 30}` + "\n" + `{"name": "Ann"}` + "\n"
+		r := importAction(adapter, getInstance)(rolesCtx("hr"), payload.New(M{"data": data})).(moleculer.Payload)
+		Expect(r.Get("inserted").Int()).Should(Equal(1))
+		Expect(r.Get("failed").Int()).Should(Equal(1))
+		Expect(r.Get("errors").StringArray()[0]).Should(Equal("line 2: Not allowed to write field: age"))
+		Expect(adapter.Count(payload.New(M{"query": M{"name": "Bob"}})).Int()).Should(Equal(0))
+
+		r = importAction(adapter, getInstance)(rolesCtx("hr"), payload.New(M{"data": "{}", "mode": "merge"})).(moleculer.Payload)
+		Expect(IsValidation(r.Error())).Should(BeTrue())
+	})
+})
+
+var _ = Describe("permissions", func() {
+	adapter := &MemoryAdapter{
+		Table:        "user",
+		SearchFields: []string{"name"},
+	}
+	brokerCtx, delegates := contextAndDelegated("permissions-test", moleculer.Config{})
+	delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+	userCtx := func(user string, roles ...string) moleculer.Context {
+		return brokerCtx.ChildActionContext("doc.find", payload.Empty(), moleculer.Options{
+			Meta: payload.New(M{"userId": user, "roles": roles}),
+		}).(moleculer.Context)
+	}
+	svc := &moleculer.ServiceSchema{Name: "doc", Settings: map[string]interface{}{
+		"fields":      []string{"**"},
+		"populates":   map[string]interface{}{},
+		"permissions": map[string]interface{}{"bypassRoles": []string{"admin"}},
+	}}
+	getInstance := func() *moleculer.ServiceSchema { return svc }
+	tenants := &tenantAdapters{}
+
+	var shared moleculer.Payload
+	BeforeEach(func() {
+		mocks.ConnectAndLoadUsers(adapter)
+		create := scopedAction(adapter, getInstance, tenants, createAction)
+		create(userCtx("alice"), payload.New(M{"name": "Alice notes"}))
+		create(userCtx("alice"), payload.New(M{"name": "Alice todo", "ownerId": "bob"}))
+		shared = create(userCtx("bob"), payload.New(M{"name": "Bob plan", "acl": []string{"alice", "reviewer"}})).(moleculer.Payload)
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+
+	It("should set the owner on create and only find owned or shared records", func() {
+		Expect(shared.Get("ownerId").String()).Should(Equal("bob"))
+
+		count := scopedAction(adapter, getInstance, tenants, countAction)
+		Expect(count(userCtx("alice"), payload.Empty()).(moleculer.Payload).Int()).Should(Equal(3))
+		Expect(count(userCtx("bob"), payload.Empty()).(moleculer.Payload).Int()).Should(Equal(1))
+		Expect(count(userCtx("carol", "reviewer"), payload.Empty()).(moleculer.Payload).Int()).Should(Equal(1))
+		Expect(count(userCtx("carol"), payload.Empty()).(moleculer.Payload).Int()).Should(Equal(0))
+		Expect(count(userCtx("root", "admin"), payload.Empty()).(moleculer.Payload).Int()).Should(Equal(9))
+
+		get := scopedAction(adapter, getInstance, tenants, getAction)
+		r := get(userCtx("carol"), payload.New(M{"id": shared.Get("id").String()})).(moleculer.Payload)
+		Expect(r.Get("name").Exists()).Should(BeFalse())
+		r = get(userCtx("alice"), payload.New(M{"id": shared.Get("id").String()})).(moleculer.Payload)
+		Expect(r.Get("name").String()).Should(Equal("Bob plan"))
+	})
+
+	It("should reject native queries, which would bypass the permission filter", func() {
+		scope, err := permissionAdapter(userCtx("carol"), adapter, svc)
+		Expect(err).Should(BeNil())
+		bypass := payload.New(M{"nativeQuery": "1) OR (1", "update": M{"name": "Carol"}})
+		for _, r := range []moleculer.Payload{
+			scope.Find(bypass),
+			scope.FindOne(bypass),
+			scope.Count(bypass),
+			scope.FindAndUpdate(bypass),
+			scope.UpdateMany(bypass),
+			scope.RemoveMany(bypass),
+		} {
+			Expect(IsValidation(r.Error())).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("nativeQuery is not supported with permissions!"))
+		}
+		Expect(adapter.FindById(shared.Get("id")).Get("name").String()).Should(Equal("Bob plan"))
+	})
+
+	It("should only allow the owner to update and remove", func() {
+		update := scopedAction(adapter, getInstance, tenants, updateAction)
+		r := update(userCtx("alice"), payload.New(M{"id": shared.Get("id").String(), "name": "Mine"})).(moleculer.Payload)
+		Expect(r.IsError()).Should(BeTrue())
+		r = update(userCtx("bob"), payload.New(M{"id": shared.Get("id").String(), "name": "Bob plan v2"})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+
+		remove := scopedAction(adapter, getInstance, tenants, removeAction)
+		r = remove(userCtx("alice"), payload.New(M{"id": shared.Get("id").String()})).(moleculer.Payload)
+		Expect(r.IsError()).Should(BeTrue())
+
+		removeMany := scopedAction(adapter, getInstance, tenants, removeManyAction)
+		r = removeMany(userCtx("alice"), payload.New(M{"query": M{"name": M{"$like": "%"}}})).(moleculer.Payload)
+		Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+		Expect(adapter.FindById(shared.Get("id")).Get("name").String()).Should(Equal("Bob plan v2"))
+	})
+
+	It("should not let shared users take over the records or change the acl", func() {
+		svc.Settings["permissions"] = map[string]interface{}{"bypassRoles": []string{"admin"}, "aclWrite": true}
+		defer func() { svc.Settings["permissions"] = map[string]interface{}{"bypassRoles": []string{"admin"}} }()
+		id := shared.Get("id").String()
+
+		update := scopedAction(adapter, getInstance, tenants, updateAction)
+		r := update(userCtx("alice"), payload.New(M{"id": id, "name": "Shared plan"})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+		r = update(userCtx("alice"), payload.New(M{"id": id, "ownerId": "alice"})).(moleculer.Payload)
+		Expect(ErrorCode(r.Error())).Should(Equal(CodeForbidden))
+		r = update(userCtx("alice"), payload.New(M{"id": id, "$push": M{"acl": "carol"}})).(moleculer.Payload)
+		Expect(ErrorCode(r.Error())).Should(Equal(CodeForbidden))
+		r = update(userCtx("bob"), payload.New(M{"id": id, "ownerId": "carol"})).(moleculer.Payload)
+		Expect(ErrorCode(r.Error())).Should(Equal(CodeForbidden))
+		r = update(userCtx("bob"), payload.New(M{"id": id, "$push": M{"acl": "carol"}})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+
+		updateMany := scopedAction(adapter, getInstance, tenants, updateManyAction)
+		r = updateMany(userCtx("alice"), payload.New(M{"query": M{}, "update": M{"$set": M{"ownerId": "alice"}}})).(moleculer.Payload)
+		Expect(ErrorCode(r.Error())).Should(Equal(CodeForbidden))
+		r = updateMany(userCtx("alice"), payload.New(M{"query": M{}, "update": M{"acl": []string{"alice"}}})).(moleculer.Payload)
+		Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+		Expect(adapter.FindById(shared.Get("id")).Get("acl").StringArray()).Should(Equal([]string{"alice", "reviewer", "carol"}))
+		Expect(adapter.FindById(shared.Get("id")).Get("ownerId").String()).Should(Equal("bob"))
+	})
+
+	It("should reject calls without user", func() {
+		find := scopedAction(adapter, getInstance, tenants, findAction)
+		r := payload.New(find(userCtx(""), payload.Empty()))
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("user required! ctx.Meta.userId is missing."))
+	})
+})
+
+var _ = Describe("encrypted fields", func() {
+	adapter := &MemoryAdapter{
+		Table:        "user",
+		SearchFields: []string{"name"},
+	}
+	ctx, delegates := contextAndDelegated("encryption-test", moleculer.Config{})
+	delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+	keys := StaticKeys{Current: "k1", Keys: map[string][]byte{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+		"k2": []byte("abcdef0123456789abcdef0123456789"),
+	}}
+	svc := &moleculer.ServiceSchema{Name: "user", Settings: map[string]interface{}{
+		"fields":    []string{"**"},
+		"populates": map[string]interface{}{},
+		"encryptedFields": map[string]interface{}{
+			"nationalId": map[string]interface{}{"deterministic": true},
+			"token":      true,
+		},
+		"keyProvider": keys,
+	}}
+	getInstance := func() *moleculer.ServiceSchema { return svc }
+	tenants := &tenantAdapters{}
+
+	var created moleculer.Payload
+	BeforeEach(func() {
+		svc.Settings["keyProvider"] = keys
+		mocks.ConnectAndLoadUsers(adapter)
+		create := scopedAction(adapter, getInstance, tenants, createAction)
+		created = create(ctx.(moleculer.Context), payload.New(M{"name": "Bob", "nationalId": "123-45", "token": "secret"})).(moleculer.Payload)
+		create(ctx.(moleculer.Context), payload.New(M{"name": "Ann", "nationalId": "999-99", "token": "other"}))
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+
+	It("should store the fields encrypted and return them decrypted", func() {
+		Expect(created.Get("nationalId").String()).Should(Equal("123-45"))
+		Expect(created.Get("token").String()).Should(Equal("secret"))
+
+		raw := adapter.FindById(created.Get("id"))
+		Expect(raw.Get("nationalId").String()).Should(HavePrefix("enc:d:k1:"))
+		Expect(raw.Get("token").String()).Should(HavePrefix("enc:r:k1:"))
+		Expect(raw.Get("name").String()).Should(Equal("Bob"))
+
+		get := scopedAction(adapter, getInstance, tenants, getAction)
+		r := get(ctx.(moleculer.Context), payload.New(M{"id": created.Get("id").String()})).(moleculer.Payload)
+		Expect(r.Get("token").String()).Should(Equal("secret"))
+	})
+
+	It("should keep the type of non-string fields", func() {
+		svc.Settings["encryptedFields"] = map[string]interface{}{
+			"nationalId": map[string]interface{}{"deterministic": true},
+			"token":      true,
+			"pin":        map[string]interface{}{"deterministic": true},
+			"verified":   true,
+			"address":    true,
+		}
+		defer func() {
+			svc.Settings["encryptedFields"] = map[string]interface{}{
+				"nationalId": map[string]interface{}{"deterministic": true},
+				"token":      true,
+			}
+		}()
+		create := scopedAction(adapter, getInstance, tenants, createAction)
+		r := create(ctx.(moleculer.Context), payload.New(M{"name": "Eve", "pin": 1234, "verified": true, "address": M{"city": "Auckland"}})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+
+		raw := adapter.FindById(r.Get("id"))
+		Expect(raw.Get("pin").String()).Should(HavePrefix("enc:d:k1:"))
+		Expect(raw.Get("verified").String()).Should(HavePrefix("enc:r:k1:"))
+
+		get := scopedAction(adapter, getInstance, tenants, getAction)
+		r = get(ctx.(moleculer.Context), payload.New(M{"id": r.Get("id").String()})).(moleculer.Payload)
+		Expect(r.Get("pin").Int()).Should(Equal(1234))
+		Expect(r.Get("verified").Value()).Should(Equal(true))
+		Expect(r.Get("address").Get("city").String()).Should(Equal("Auckland"))
+
+		find := scopedAction(adapter, getInstance, tenants, findAction)
+		Expect(find(ctx.(moleculer.Context), payload.New(M{"query": M{"pin": 1234}})).(moleculer.Payload).Len()).Should(Equal(1))
+	})
+
+	It("should encrypt values that look encrypted", func() {
+		update := scopedAction(adapter, getInstance, tenants, updateAction)
+		forged := "enc:r:k1:forged"
+		r := update(ctx.(moleculer.Context), payload.New(M{"id": created.Get("id").String(), "token": forged})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("token").String()).Should(Equal(forged))
+		Expect(adapter.FindById(created.Get("id")).Get("token").String()).ShouldNot(Equal(forged))
+	})
+
+	It("should allow equality queries on deterministic fields only", func() {
+		find := scopedAction(adapter, getInstance, tenants, findAction)
+		r := find(ctx.(moleculer.Context), payload.New(M{"query": M{"nationalId": "123-45"}})).(moleculer.Payload)
+		Expect(r.Len()).Should(Equal(1))
+		Expect(r.First().Get("name").String()).Should(Equal("Bob"))
+
+		r = find(ctx.(moleculer.Context), payload.New(M{"query": M{"token": "secret"}})).(moleculer.Payload)
+		Expect(IsValidation(r.Error())).Should(BeTrue())
+
+		r = find(ctx.(moleculer.Context), payload.New(M{"query": M{"nationalId": M{"$gt": "1"}}})).(moleculer.Payload)
+		Expect(IsValidation(r.Error())).Should(BeTrue())
+	})
+
+	It("should fail with a validation error without key provider", func() {
+		delete(svc.Settings, "keyProvider")
+		find := scopedAction(adapter, getInstance, tenants, findAction)
+		r := payload.New(find(ctx.(moleculer.Context), payload.Empty()))
+		Expect(IsValidation(r.Error())).Should(BeTrue())
+	})
+
+	It("should re-encrypt the records with the current key on rotateKeys", func() {
+		svc.Settings["keyProvider"] = StaticKeys{Current: "k2", Keys: keys.Keys}
+		find := scopedAction(adapter, getInstance, tenants, findAction)
+		r := find(ctx.(moleculer.Context), payload.New(M{"query": M{"nationalId": "123-45"}})).(moleculer.Payload)
+		Expect(r.Len()).Should(Equal(1))
+
+		rotate := rotateKeysAction(adapter, getInstance)
+		r = rotate(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+		Expect(IsValidation(r.Error())).Should(BeTrue())
+
+		rotate = scopedAction(adapter, getInstance, tenants, rotateKeysAction)
+		r = rotate(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+
+		raw := adapter.FindById(created.Get("id"))
+		Expect(raw.Get("nationalId").String()).Should(HavePrefix("enc:d:k2:"))
+		Expect(raw.Get("token").String()).Should(HavePrefix("enc:r:k2:"))
+
+		r = find(ctx.(moleculer.Context), payload.New(M{"query": M{"nationalId": "123-45"}})).(moleculer.Payload)
+		Expect(r.First().Get("token").String()).Should(Equal("secret"))
+	})
+})
+
 func contextAndDelegated(nodeID string, config moleculer.Config) (moleculer.BrokerContext, *moleculer.BrokerDelegates) {
 	dl := test.DelegatesWithIdAndConfig(nodeID, config)
 	ctx := context.BrokerContext(dl)
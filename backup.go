@@ -0,0 +1,437 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// exportVersion is the version of the export format.
+var exportVersion = 1
+
+// exportHeaderKey is the field of the first line of an export, with the ExportHeader.
+var exportHeaderKey = "$header"
+
+var defaultExportPageSize = 100
+
+// defaultExportLimit is the max number of records returned by the export action, which builds the whole export in memory.
+var defaultExportLimit = 10000
+
+// ExportHeader is the first line of an export.
+type ExportHeader struct {
+	Version int    `json:"version"`
+	Service string `json:"service,omitempty"`
+	IDField string `json:"idField"`
+	// Count is the number of records when the export started.
+	Count int `json:"count"`
+	// Schema has the type of each field: string, integer, number, bool, date, list or map.
+	// Dates are exported as RFC 3339 strings and converted back on import.
+	Schema   map[string]string `json:"schema"`
+	Exported time.Time         `json:"exported"`
+}
+
+// ExportOptions are the options of Export.
+type ExportOptions struct {
+	// Query filters the exported records.
+	Query   map[string]interface{}
+	Service string
+	// IDField default: id
+	IDField string
+	// PageSize is the number of records read from the adapter at a time. Default: 100
+	PageSize int
+	// Offset is the number of records skipped, in id order.
+	Offset int
+	// Limit is the max number of records exported. Default: all
+	Limit int
+	// Progress is called after each page with the number of records exported and the total.
+	Progress func(done, total int)
+	// Transform returns the exported record, e.g. without the fields the caller can't read.
+	Transform func(record moleculer.Payload) moleculer.Payload
+}
+
+// ImportOptions are the options of Import.
+type ImportOptions struct {
+	// Mode upsert (default) updates the records with the same id and inserts the others.
+	// Mode replace reads and checks all records first, and only removes the existing records when they are all valid.
+	// The records are kept in memory until they are written.
+	Mode string
+	// IDField default: the idField of the export header
+	IDField string
+	// Progress is called every 100 records, and at the end, with the number of records imported and the total.
+	Progress func(done, total int)
+	// Check is called before each record is written. The records it rejects are counted as failed.
+	Check func(record moleculer.Payload) error
+}
+
+// ImportResult is the result of Import.
+type ImportResult struct {
+	Inserted int
+	Updated  int
+	Failed   int
+	// Errors has the first 10 errors.
+	Errors []string
+}
+
+// Map returns the result as a map.
+func (r ImportResult) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"inserted": r.Inserted,
+		"updated":  r.Updated,
+		"failed":   r.Failed,
+		"errors":   r.Errors,
+	}
+}
+
+// valueType returns the export schema type of the value.
+func valueType(value interface{}) string {
+	switch value.(type) {
+	case time.Time:
+		return "date"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float32, float64:
+		return "number"
+	case map[string]interface{}, moleculer.Payload:
+		return "map"
+	}
+	if payload.New(value).IsArray() {
+		return "list"
+	}
+	return ""
+}
+
+// schemaOf adds the types of the fields of the records to the schema.
+func schemaOf(schema map[string]string, records []moleculer.Payload) {
+	for _, record := range records {
+		for field, value := range record.RawMap() {
+			if _, exists := schema[field]; exists || value == nil {
+				continue
+			}
+			if t := valueType(value); t != "" {
+				schema[field] = t
+			}
+		}
+	}
+}
+
+// exportPage returns a page of records, sorted by id.
+func exportPage(adapter Adapter, opts ExportOptions, offset int) ([]moleculer.Payload, error) {
+	limit := opts.PageSize
+	if opts.Limit > 0 && opts.Limit-offset < limit {
+		limit = opts.Limit - offset
+	}
+	params := map[string]interface{}{
+		"sort":   opts.IDField,
+		"limit":  limit,
+		"offset": opts.Offset + offset,
+	}
+	if opts.Query != nil {
+		params["query"] = opts.Query
+	}
+	result := adapter.Find(payload.New(params))
+	if result.IsError() {
+		return nil, result.Error()
+	}
+	page := result.Array()
+	if opts.Transform != nil {
+		for i, record := range page {
+			page[i] = opts.Transform(record)
+		}
+	}
+	return page, nil
+}
+
+// Export writes the records of the adapter as NDJSON: the header line followed by one record per line.
+// Records are read in pages, so the export does not load all records in memory.
+// Returns the number of exported records.
+func Export(adapter Adapter, w io.Writer, opts ExportOptions) (int, error) {
+	if opts.IDField == "" {
+		opts.IDField = "id"
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultExportPageSize
+	}
+	countParams := payload.Empty()
+	if opts.Query != nil {
+		countParams = countParams.Add("query", opts.Query)
+	}
+	count := adapter.Count(countParams)
+	if count.IsError() {
+		return 0, count.Error()
+	}
+	page, err := exportPage(adapter, opts, 0)
+	if err != nil {
+		return 0, err
+	}
+	header := ExportHeader{
+		Version:  exportVersion,
+		Service:  opts.Service,
+		IDField:  opts.IDField,
+		Count:    count.Int(),
+		Schema:   map[string]string{},
+		Exported: time.Now().UTC(),
+	}
+	schemaOf(header.Schema, page)
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(map[string]interface{}{exportHeaderKey: header}); err != nil {
+		return 0, err
+	}
+	exported := 0
+	for len(page) > 0 {
+		for _, record := range page {
+			if err := encoder.Encode(record.RawMap()); err != nil {
+				return exported, err
+			}
+		}
+		exported += len(page)
+		if opts.Progress != nil {
+			opts.Progress(exported, header.Count)
+		}
+		if len(page) != opts.PageSize || exported == opts.Limit {
+			break
+		}
+		if page, err = exportPage(adapter, opts, exported); err != nil {
+			return exported, err
+		}
+	}
+	return exported, nil
+}
+
+// readHeader reads the header line of an export.
+func readHeader(line []byte) (ExportHeader, error) {
+	var first map[string]ExportHeader
+	header, ok := ExportHeader{}, false
+	if json.Unmarshal(line, &first) == nil {
+		header, ok = first[exportHeaderKey]
+	}
+	if !ok {
+		return header, NewError(CodeValidation, "Invalid export: the first line must be the header!")
+	}
+	if header.Version > exportVersion {
+		return header, NewError(CodeValidation, "Invalid export: unsupported version ", header.Version)
+	}
+	return header, nil
+}
+
+// importValue converts the value to the type of the schema.
+func importValue(value interface{}, fieldType string) interface{} {
+	switch fieldType {
+	case "date":
+		if s, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t
+			}
+		}
+	case "integer":
+		if n, ok := value.(float64); ok {
+			return int64(n)
+		}
+	}
+	return value
+}
+
+// importRecord inserts or updates the record.
+func importRecord(adapter Adapter, record map[string]interface{}, idField string, upsert bool) (updated bool, err error) {
+	id, hasID := record[idField]
+	if upsert && hasID && id != nil {
+		existing := adapter.FindById(payload.New(id))
+		if !existing.IsError() && existing.Exists() && existing.Get(idField).Exists() {
+			values := payload.New(record).Remove(idField)
+			result := adapter.UpdateById(payload.New(id), values)
+			if result.IsError() {
+				return true, result.Error()
+			}
+			return true, nil
+		}
+	}
+	result := adapter.Insert(payload.New(record))
+	if result.IsError() {
+		return false, result.Error()
+	}
+	return false, nil
+}
+
+// stagedRecord is a record read by Import in replace mode, written after all records were checked.
+type stagedRecord struct {
+	line   int
+	record map[string]interface{}
+}
+
+// readRecord parses a record line, converting the values to the types of the header schema, and checks it.
+func readRecord(text []byte, header ExportHeader, check func(record moleculer.Payload) error) (map[string]interface{}, error) {
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(text, &record); err != nil {
+		return nil, WrapError(CodeValidation, err)
+	}
+	for field, value := range record {
+		record[field] = importValue(value, header.Schema[field])
+	}
+	if check != nil {
+		if err := check(payload.New(record)); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// Import reads an export (NDJSON) and inserts or updates the records in the adapter.
+// Records are read one line at a time. Records that fail are counted in the result and do not stop the import,
+// except in replace mode, where an invalid or rejected record stops the import before any record is removed.
+// Adapters that generate ids (memory and Mongo) assign new ids to the inserted records.
+func Import(adapter Adapter, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	result := ImportResult{Errors: []string{}}
+	if opts.Mode == "" {
+		opts.Mode = "upsert"
+	}
+	if opts.Mode != "upsert" && opts.Mode != "replace" {
+		return result, NewError(CodeValidation, "Invalid import mode: "+opts.Mode)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return result, WrapError(CodeValidation, err)
+		}
+		return result, NewError(CodeValidation, "Invalid export: the first line must be the header!")
+	}
+	header, err := readHeader(scanner.Bytes())
+	if err != nil {
+		return result, err
+	}
+	idField := opts.IDField
+	if idField == "" {
+		idField = header.IDField
+	}
+	done := 0
+	write := func(line int, record map[string]interface{}, err error) {
+		updated := false
+		if err == nil {
+			updated, err = importRecord(adapter, record, idField, opts.Mode == "upsert")
+		}
+		done++
+		if err != nil {
+			result.Failed++
+			if len(result.Errors) < 10 {
+				result.Errors = append(result.Errors, fmt.Sprint("line ", line, ": ", err))
+			}
+		} else if updated {
+			result.Updated++
+		} else {
+			result.Inserted++
+		}
+		if opts.Progress != nil && done%100 == 0 {
+			opts.Progress(done, header.Count)
+		}
+	}
+	// replace only removes the existing records once all records were read and checked.
+	staged := []stagedRecord{}
+	line := 1
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		record, err := readRecord(text, header, opts.Check)
+		if opts.Mode == "replace" {
+			if err != nil {
+				return result, WrapError("", err, fmt.Sprint("Invalid record on line ", line, ", no records were removed. Error: "))
+			}
+			staged = append(staged, stagedRecord{line, record})
+			continue
+		}
+		write(line, record, err)
+	}
+	if err := scanner.Err(); err != nil {
+		return result, WrapError(CodeValidation, err)
+	}
+	if opts.Mode == "replace" {
+		if removed := adapter.RemoveAll(); removed != nil && removed.IsError() {
+			return result, removed.Error()
+		}
+		for _, item := range staged {
+			write(item.line, item.record, nil)
+		}
+	}
+	if opts.Progress != nil {
+		opts.Progress(done, header.Count)
+	}
+	return result, nil
+}
+
+// progressEvent emits the progress of an export/import as the <service>.<name> event.
+func progressEvent(ctx moleculer.Context, service, name string) func(done, total int) {
+	return func(done, total int) {
+		ctx.Emit(service+"."+name, map[string]interface{}{"done": done, "total": total})
+	}
+}
+
+// exportAction returns the records as NDJSON. Emits the <service>.exportProgress event.
+// The export is built in memory, so it returns at most exportLimit records (default: 10000), in id order.
+// The limit and offset params read the next records. The header count has the number of matching records.
+func exportAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		instance := getInstance()
+		if err := checkReadAccess(ctx, params, instance.Settings); err != nil {
+			return payload.New(err)
+		}
+		opts := ExportOptions{
+			Service:  instance.Name,
+			Progress: progressEvent(ctx, instance.Name, "exportProgress"),
+			Transform: func(record moleculer.Payload) moleculer.Payload {
+				return hideFields(ctx, record, instance.Settings)
+			},
+		}
+		if idField, ok := instance.Settings["idField"].(string); ok {
+			opts.IDField = idField
+		}
+		if params.Get("query").Exists() {
+			opts.Query = params.Get("query").RawMap()
+		}
+		opts.Limit = defaultExportLimit
+		if limit, ok := instance.Settings["exportLimit"].(int); ok {
+			opts.Limit = limit
+		}
+		if limit := params.Get("limit").Int(); limit > 0 && (limit < opts.Limit || opts.Limit <= 0) {
+			opts.Limit = limit
+		}
+		opts.Offset = params.Get("offset").Int()
+		var out strings.Builder
+		if _, err := Export(adapter, &out, opts); err != nil {
+			return payload.New(WrapError("", err, "Could not export records. Error: "))
+		}
+		return out.String()
+	}
+}
+
+// importAction imports the NDJSON in the data param. Emits the <service>.importProgress event.
+func importAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		instance := getInstance()
+		opts := ImportOptions{
+			Progress: progressEvent(ctx, instance.Name, "importProgress"),
+			Check: func(record moleculer.Payload) error {
+				return checkWriteAccess(ctx, record, instance.Settings)
+			},
+		}
+		if params.Get("mode").Exists() {
+			opts.Mode = params.Get("mode").String()
+		}
+		result, err := Import(adapter, strings.NewReader(params.Get("data").String()), opts)
+		if err != nil {
+			return payload.New(WrapError("", err, "Could not import records. Error: "))
+		}
+		return payload.New(result.Map())
+	}
+}
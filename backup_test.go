@@ -0,0 +1,149 @@
+package store
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Export and import", func() {
+
+	var source, target *MemoryAdapter
+	BeforeEach(func() {
+		source = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+		mocks.ConnectAndLoadUsers(source)
+		target = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+		target.Init(log.WithField("test", "import"), M{})
+		Expect(target.Connect()).Should(Succeed())
+	})
+	AfterEach(func() {
+		source.Disconnect()
+		target.Disconnect()
+	})
+
+	It("should export the header and one record per line", func() {
+		var out bytes.Buffer
+		progress := []int{}
+		count, err := Export(source, &out, ExportOptions{
+			Service:  "user",
+			PageSize: 4,
+			Progress: func(done, total int) { progress = append(progress, done, total) },
+		})
+		Expect(err).Should(BeNil())
+		Expect(count).Should(Equal(6))
+		Expect(progress).Should(Equal([]int{4, 6, 6, 6}))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(len(lines)).Should(Equal(7))
+		header, err := readHeader([]byte(lines[0]))
+		Expect(err).Should(BeNil())
+		Expect(header.Service).Should(Equal("user"))
+		Expect(header.IDField).Should(Equal("id"))
+		Expect(header.Count).Should(Equal(6))
+		Expect(header.Schema["name"]).Should(Equal("string"))
+		Expect(header.Schema["age"]).Should(Equal("integer"))
+
+		out.Reset()
+		count, err = Export(source, &out, ExportOptions{Query: M{"age": M{"$gt": 60}}})
+		Expect(err).Should(BeNil())
+		Expect(count).Should(Equal(2))
+	})
+
+	It("should import an export in replace and upsert modes", func() {
+		var out bytes.Buffer
+		_, err := Export(source, &out, ExportOptions{})
+		Expect(err).Should(BeNil())
+		export := out.String()
+
+		target.Insert(payload.New(M{"name": "Bob"}))
+		result, err := Import(target, strings.NewReader(export), ImportOptions{Mode: "replace"})
+		Expect(err).Should(BeNil())
+		Expect(result.Inserted).Should(Equal(6))
+		Expect(target.Count(payload.Empty()).Int()).Should(Equal(6))
+		marie := target.Find(payload.New(M{"query": M{"name": "Marie"}})).First()
+		Expect(marie.Get("age").Int()).Should(Equal(75))
+
+		result, err = Import(source, strings.NewReader(export), ImportOptions{})
+		Expect(err).Should(BeNil())
+		Expect(result.Updated).Should(Equal(6))
+		Expect(result.Inserted).Should(Equal(0))
+		Expect(source.Count(payload.Empty()).Int()).Should(Equal(6))
+	})
+
+	It("should reject invalid exports and count the failed records", func() {
+		_, err := Import(target, strings.NewReader(`{"name": "John"}`), ImportOptions{})
+		Expect(err.Error()).Should(Equal("Invalid export: the first line must be the header!"))
+
+		_, err = Import(target, strings.NewReader(`{"$header": {"version": 1}}`), ImportOptions{Mode: "merge"})
+		Expect(err.Error()).Should(Equal("Invalid import mode: merge"))
+
+		data := `{"$header": {"version": 1, "idField": "id"}}` + "\n" + `{"name": "John"}` + "\n" + `{"name": ` + "\n"
+		result, err := Import(target, strings.NewReader(data), ImportOptions{})
+		Expect(err).Should(BeNil())
+		Expect(result.Inserted).Should(Equal(1))
+		Expect(result.Failed).Should(Equal(1))
+		Expect(result.Errors[0]).Should(HavePrefix("line 3: "))
+	})
+
+	It("should not remove the records in replace mode when a record is invalid or rejected", func() {
+		target.Insert(payload.New(M{"name": "Bob"}))
+		data := `{"$header": {"version": 1, "idField": "id"}}` + "\n" + `{"name": "John"}` + "\n" + `{"name": ` + "\n"
+		_, err := Import(target, strings.NewReader(data), ImportOptions{Mode: "replace"})
+		Expect(IsValidation(err)).Should(BeTrue())
+		Expect(err.Error()).Should(HavePrefix("Invalid record on line 3, no records were removed."))
+		Expect(target.Count(payload.Empty()).Int()).Should(Equal(1))
+
+		data = `{"$header": {"version": 1, "idField": "id"}}` + "\n" + `{"name": "John"}` + "\n"
+		_, err = Import(target, strings.NewReader(data), ImportOptions{
+			Mode: "replace",
+			Check: func(record moleculer.Payload) error {
+				return NewError(CodeForbidden, "Not allowed to write field: name")
+			},
+		})
+		Expect(ErrorCode(err)).Should(Equal(CodeForbidden))
+		Expect(target.Count(payload.Empty()).Int()).Should(Equal(1))
+		Expect(target.Find(payload.Empty()).First().Get("name").String()).Should(Equal("Bob"))
+	})
+
+	It("should export at most limit records after offset", func() {
+		var out bytes.Buffer
+		count, err := Export(source, &out, ExportOptions{PageSize: 2, Offset: 1, Limit: 3})
+		Expect(err).Should(BeNil())
+		Expect(count).Should(Equal(3))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(len(lines)).Should(Equal(4))
+		header, err := readHeader([]byte(lines[0]))
+		Expect(err).Should(BeNil())
+		Expect(header.Count).Should(Equal(6))
+	})
+
+	It("export and import actions should use NDJSON data", func() {
+		ctx, _ := contextAndDelegated("export-test", moleculer.Config{})
+		getInstance := func() *moleculer.ServiceSchema {
+			return &moleculer.ServiceSchema{Name: "user", Settings: M{"idField": "id"}}
+		}
+		data := exportAction(source, getInstance)(ctx.(moleculer.Context), payload.Empty())
+		Expect(data).Should(ContainSubstring(`"$header"`))
+
+		r := importAction(target, getInstance)(ctx.(moleculer.Context), payload.New(M{"data": data, "mode": "replace"})).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("inserted").Int()).Should(Equal(6))
+
+		limited := getInstance()
+		limited.Settings["exportLimit"] = 4
+		data = exportAction(source, func() *moleculer.ServiceSchema { return limited })(ctx.(moleculer.Context), payload.New(M{"limit": 10}))
+		Expect(strings.Count(data.(string), "\n")).Should(Equal(5))
+		data = exportAction(source, func() *moleculer.ServiceSchema { return limited })(ctx.(moleculer.Context), payload.New(M{"limit": 2}))
+		Expect(strings.Count(data.(string), "\n")).Should(Equal(3))
+
+		r = importAction(target, getInstance)(ctx.(moleculer.Context), payload.New(M{"data": `{"name": "John"}`})).(moleculer.Payload)
+		Expect(IsValidation(r.Error())).Should(BeTrue())
+		Expect(r.Error().Error()).Should(HavePrefix("Could not import records. Error: Invalid export"))
+	})
+})
@@ -0,0 +1,495 @@
+package bolt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/dsl"
+	log "github.com/sirupsen/logrus"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Adapter stores the records in a bbolt database file, without cgo or an external server.
+// Each table is a bucket of JSON documents keyed by a sequential id. Queries, sort, offset and limit
+// are evaluated in process, using the declared indexes for the queries that match all their fields.
+type Adapter struct {
+	// Path of the database file. Adapters with the same path share the database.
+	Path  string
+	Table string
+	// Timeout waiting for the lock of the database file, held by other processes. Default: 1 second
+	Timeout time.Duration
+
+	db           *bbolt.DB
+	log          *log.Entry
+	settings     map[string]interface{}
+	idField      string
+	indexes      []store.Index
+	indexesError error
+}
+
+// database is an open database file and the number of connected adapters using it.
+type database struct {
+	db   *bbolt.DB
+	refs int
+}
+
+// databases are the open database files by path. bbolt locks the file, so the adapters share the database.
+var databases = map[string]*database{}
+var databasesMutex = &sync.Mutex{}
+
+// openDatabase opens the database file or returns the database already open.
+func openDatabase(path string, timeout time.Duration) (*bbolt.DB, error) {
+	databasesMutex.Lock()
+	defer databasesMutex.Unlock()
+	if open, exists := databases[path]; exists {
+		open.refs++
+		return open.db, nil
+	}
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	databases[path] = &database{db: db, refs: 1}
+	return db, nil
+}
+
+// closeDatabase closes the database file when no other adapter is using it.
+func closeDatabase(path string) error {
+	databasesMutex.Lock()
+	defer databasesMutex.Unlock()
+	open, exists := databases[path]
+	if !exists {
+		return nil
+	}
+	open.refs--
+	if open.refs > 0 {
+		return nil
+	}
+	delete(databases, path)
+	return open.db.Close()
+}
+
+func (a *Adapter) Init(log *log.Entry, settings map[string]interface{}) {
+	a.log = log
+	a.settings = settings
+	if a.Timeout == 0 {
+		a.Timeout = time.Second
+	}
+	a.loadSettings(settings)
+	a.indexes, a.indexesError = store.ParseIndexes(settings)
+}
+
+func (a *Adapter) loadSettings(settings map[string]interface{}) {
+	if idField, ok := settings["idField"].(string); ok {
+		a.idField = idField
+	} else {
+		a.idField = "id"
+	}
+	if path, ok := settings["path"].(string); ok {
+		a.Path = path
+	}
+}
+
+// ForTenant returns an adapter for the tenant, using its own bucket in the same file (e.g. users_acme).
+func (a *Adapter) ForTenant(tenant string) store.Adapter {
+	scoped := &Adapter{Path: a.Path, Table: a.Table + "_" + tenant, Timeout: a.Timeout}
+	scoped.Init(a.log, a.settings)
+	return scoped
+}
+
+func (a *Adapter) Connect() error {
+	if a.db != nil {
+		return nil
+	}
+	if a.indexesError != nil {
+		return a.indexesError
+	}
+	db, err := openDatabase(a.Path, a.Timeout)
+	if err != nil {
+		a.log.Error("Could not open bbolt database - error: ", err)
+		return store.WrapError(store.CodeUnavailable, err, "Could not open bbolt database - error: ")
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(a.Table)); err != nil {
+			return err
+		}
+		return a.createIndexes(tx)
+	})
+	if err != nil {
+		closeDatabase(a.Path)
+		a.log.Error("Could not create bucket - error: ", err)
+		return store.WrapError("", err, "Could not create bucket - error: ")
+	}
+	a.db = db
+	a.log.Info("Bolt adapter " + a.Table + " connected!")
+	return nil
+}
+
+func (a *Adapter) Disconnect() error {
+	if a.db == nil {
+		return nil
+	}
+	a.db = nil
+	if err := closeDatabase(a.Path); err != nil {
+		a.log.Error("Could not close bbolt database - error: ", err)
+		return errors.New(fmt.Sprint("Could not close bbolt database - error: ", err))
+	}
+	return nil
+}
+
+func notConnectedError() error {
+	return store.NewError(store.CodeUnavailable, "Bolt adapter not connected!")
+}
+
+// view runs fn in a read only transaction.
+func (a *Adapter) view(fn func(*bbolt.Tx) error) error {
+	db := a.db
+	if db == nil {
+		return notConnectedError()
+	}
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction. The changes are rolled back when fn returns an error.
+func (a *Adapter) update(fn func(*bbolt.Tx) error) error {
+	db := a.db
+	if db == nil {
+		return notConnectedError()
+	}
+	return db.Update(fn)
+}
+
+func (a *Adapter) bucket(tx *bbolt.Tx) *bbolt.Bucket {
+	return tx.Bucket([]byte(a.Table))
+}
+
+// get returns the key and the record of the id. record is nil when the id is not found.
+func (a *Adapter) get(tx *bbolt.Tx, id moleculer.Payload) ([]byte, map[string]interface{}, error) {
+	numeric, ok := parseId(id)
+	if !ok {
+		return nil, nil, nil
+	}
+	key := idKey(numeric)
+	data := a.bucket(tx).Get(key)
+	if data == nil {
+		return nil, nil, nil
+	}
+	record, err := decodeRecord(data)
+	return key, record, err
+}
+
+// put saves the record, updating the indexes. previous is the saved record, nil for new records.
+func (a *Adapter) put(tx *bbolt.Tx, key []byte, previous, record map[string]interface{}) error {
+	if previous != nil {
+		if err := a.removeIndexes(tx, key, previous); err != nil {
+			return err
+		}
+	}
+	if err := a.addIndexes(tx, key, record); err != nil {
+		return err
+	}
+	data, err := encodeRecord(record)
+	if err != nil {
+		return store.WrapError(store.CodeValidation, err, "Could not encode record - error: ")
+	}
+	return a.bucket(tx).Put(key, data)
+}
+
+// remove deletes the record and its index entries.
+func (a *Adapter) remove(tx *bbolt.Tx, key []byte, record map[string]interface{}) error {
+	if err := a.removeIndexes(tx, key, record); err != nil {
+		return err
+	}
+	return a.bucket(tx).Delete(key)
+}
+
+// findRecords returns the keys and the records matching the query and search params, in id order.
+func (a *Adapter) findRecords(tx *bbolt.Tx, params moleculer.Payload) ([][]byte, []map[string]interface{}, error) {
+	if params.Get("nativeQuery").Exists() {
+		return nil, nil, store.NewError(store.CodeValidation, "nativeQuery is not supported by the bolt adapter")
+	}
+	query, err := dsl.Parse(params.Get("query"))
+	if err != nil {
+		return nil, nil, store.WrapError(store.CodeValidation, err)
+	}
+	keys := [][]byte{}
+	records := []map[string]interface{}{}
+	check := func(key, data []byte) error {
+		record, err := decodeRecord(data)
+		if err != nil {
+			return err
+		}
+		if dsl.Match(query, record) && dsl.SearchMatch(params, record) {
+			keys = append(keys, key)
+			records = append(records, record)
+		}
+		return nil
+	}
+	bucket := a.bucket(tx)
+	if indexed, ok := a.queryIndex(tx, query); ok {
+		for _, key := range indexed {
+			if data := bucket.Get(key); data != nil {
+				if err := check(key, data); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		return keys, records, nil
+	}
+	err = bucket.ForEach(func(key, data []byte) error {
+		return check(append([]byte{}, key...), data)
+	})
+	return keys, records, err
+}
+
+// Find returns the records matching the params, applying sort, offset and limit.
+func (a *Adapter) Find(params moleculer.Payload) moleculer.Payload {
+	var records []map[string]interface{}
+	err := a.view(func(tx *bbolt.Tx) error {
+		var err error
+		_, records, err = a.findRecords(tx, params)
+		return err
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to find. Error: "))
+	}
+	return dsl.RecordsPayload(dsl.Paginate(records, params))
+}
+
+func (a *Adapter) FindOne(params moleculer.Payload) moleculer.Payload {
+	return a.Find(params.Add("limit", 1)).First()
+}
+
+func (a *Adapter) FindById(id moleculer.Payload) moleculer.Payload {
+	var record map[string]interface{}
+	err := a.view(func(tx *bbolt.Tx) error {
+		var err error
+		_, record, err = a.get(tx, id)
+		return err
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to find by id: ", id.String(), " Error: "))
+	}
+	if record == nil {
+		return payload.New(nil)
+	}
+	return payload.New(record)
+}
+
+func (a *Adapter) FindByIds(ids moleculer.Payload) moleculer.Payload {
+	if !ids.IsArray() {
+		return payload.New(store.NewError(store.CodeValidation, "FindByIds() only support lists!"))
+	}
+	list := []moleculer.Payload{}
+	for _, id := range ids.Array() {
+		list = append(list, a.FindById(id))
+	}
+	return payload.New(list)
+}
+
+// Count returns the number of records matching the query and search params.
+func (a *Adapter) Count(params moleculer.Payload) moleculer.Payload {
+	count := 0
+	err := a.view(func(tx *bbolt.Tx) error {
+		keys, _, err := a.findRecords(tx, params)
+		count = len(keys)
+		return err
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to count. Error: "))
+	}
+	return payload.New(count)
+}
+
+// Insert saves the record with the next id of the table. Records with an id (e.g. imported records) keep it.
+// Ids that are not positive integers are rejected with CodeValidation.
+func (a *Adapter) Insert(params moleculer.Payload) moleculer.Payload {
+	record := map[string]interface{}{}
+	for field, value := range params.RawMap() {
+		record[field] = value
+	}
+	if value := record[a.idField]; value != nil {
+		if _, valid := parseId(value); !valid {
+			return payload.New(store.NewError(store.CodeValidation, "Invalid id: ", value, ". Ids are positive integers.").WithData(map[string]interface{}{"id": value}))
+		}
+	}
+	err := a.update(func(tx *bbolt.Tx) error {
+		bucket := a.bucket(tx)
+		id, hasId := parseId(record[a.idField])
+		if hasId {
+			if bucket.Get(idKey(id)) != nil {
+				return store.NewError(store.CodeConflict, "Duplicate id: ", id).WithData(map[string]interface{}{"id": id})
+			}
+			if id > bucket.Sequence() {
+				if err := bucket.SetSequence(id); err != nil {
+					return err
+				}
+			}
+		} else {
+			next, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			id = next
+		}
+		record[a.idField] = int64(id)
+		return a.put(tx, idKey(id), nil, record)
+	})
+	if err != nil {
+		a.log.Error("Error on insert: ", err)
+		return payload.New(typedError(err, "Failed trying to insert. Error: "))
+	}
+	return payload.New(record)
+}
+
+func (a *Adapter) Update(params moleculer.Payload) moleculer.Payload {
+	id := params.Get(a.idField)
+	if !id.Exists() {
+		return payload.New(store.NewError(store.CodeValidation, "Cannot update record without id"))
+	}
+	return a.UpdateById(id, params.Remove(a.idField))
+}
+
+// applyUpdate returns the updated record, keeping its id.
+func (a *Adapter) applyUpdate(record map[string]interface{}, ops []dsl.UpdateOp) map[string]interface{} {
+	updated := dsl.Apply(record, ops)
+	updated[a.idField] = record[a.idField]
+	return updated
+}
+
+// UpdateById applies the update (fields and update operators) and returns the updated record.
+func (a *Adapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	ops, err := dsl.ParseUpdate(update)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Failed trying to update record. Invalid update: "))
+	}
+	var updated map[string]interface{}
+	err = a.update(func(tx *bbolt.Tx) error {
+		key, record, err := a.get(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return store.NewError(store.CodeNotFound, "Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()})
+		}
+		updated = a.applyUpdate(record, ops)
+		return a.put(tx, key, record, updated)
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to update record. Error: "))
+	}
+	return payload.New(updated)
+}
+
+// updateRecords applies the update on the records matching the params in a single transaction and returns the updated records.
+func (a *Adapter) updateRecords(params, update moleculer.Payload) ([]map[string]interface{}, error) {
+	ops, err := dsl.ParseUpdate(update)
+	if err != nil {
+		return nil, store.WrapError(store.CodeValidation, err, "Invalid update: ")
+	}
+	var updated []map[string]interface{}
+	err = a.update(func(tx *bbolt.Tx) error {
+		keys, records, err := a.findRecords(tx, params)
+		if err != nil {
+			return err
+		}
+		updated = make([]map[string]interface{}, len(records))
+		for i, record := range records {
+			updated[i] = a.applyUpdate(record, ops)
+			if err := a.put(tx, keys[i], record, updated[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// UpdateMany updates all records matching the query in a single transaction.
+func (a *Adapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	updated, err := a.updateRecords(params.Remove("update"), params.Get("update"))
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to update records. Error: "))
+	}
+	return payload.New(map[string]int{"modifiedCount": len(updated)})
+}
+
+// FindAndUpdate updates all records matching the query in a single transaction and returns the updated records.
+func (a *Adapter) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	updated, err := a.updateRecords(params.Remove("update"), params.Get("update"))
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to find and update. Error: "))
+	}
+	return dsl.RecordsPayload(updated)
+}
+
+func (a *Adapter) RemoveById(id moleculer.Payload) moleculer.Payload {
+	err := a.update(func(tx *bbolt.Tx) error {
+		key, record, err := a.get(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return store.NewError(store.CodeNotFound, "Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()})
+		}
+		return a.remove(tx, key, record)
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to remove record. Error: "))
+	}
+	return payload.New(map[string]int{"deletedCount": 1})
+}
+
+// RemoveMany removes all records matching the query in a single transaction.
+func (a *Adapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	deletedCount := 0
+	err := a.update(func(tx *bbolt.Tx) error {
+		keys, records, err := a.findRecords(tx, params)
+		if err != nil {
+			return err
+		}
+		for i, record := range records {
+			if err := a.remove(tx, keys[i], record); err != nil {
+				return err
+			}
+		}
+		deletedCount = len(records)
+		return nil
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to remove records. Error: "))
+	}
+	return payload.New(map[string]int{"deletedCount": deletedCount})
+}
+
+// RemoveAll recreates the buckets of the table and its indexes. The sequence of ids is kept.
+func (a *Adapter) RemoveAll() moleculer.Payload {
+	deletedCount := 0
+	err := a.update(func(tx *bbolt.Tx) error {
+		bucket := a.bucket(tx)
+		deletedCount = bucket.Stats().KeyN
+		sequence := bucket.Sequence()
+		names := [][]byte{[]byte(a.Table)}
+		for _, index := range a.indexes {
+			names = append(names, a.indexBucket(index))
+		}
+		for _, name := range names {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return a.bucket(tx).SetSequence(sequence)
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to remove all records. Error: "))
+	}
+	return payload.New(map[string]int{"deletedCount": deletedCount})
+}
@@ -0,0 +1,145 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+)
+
+// dateField is the field of the JSON object that stores a time.Time, so dates are decoded as dates. e.g. {"$date": "2020-01-02T15:04:05Z"}
+const dateField = "$date"
+
+// idKey returns the key of the id: the id as a big endian uint64, so the keys are sorted by id.
+func idKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// parseId returns the numeric id. ok is false when the id is not a positive integer.
+func parseId(id interface{}) (uint64, bool) {
+	if p, isPayload := id.(moleculer.Payload); isPayload {
+		id = p.Value()
+	}
+	switch v := id.(type) {
+	case int:
+		return uint64(v), v > 0
+	case int32:
+		return uint64(v), v > 0
+	case int64:
+		return uint64(v), v > 0
+	case uint64:
+		return v, v > 0
+	case float64:
+		return uint64(v), v > 0 && v == float64(uint64(v))
+	case string:
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		return parsed, err == nil && parsed > 0
+	}
+	return 0, false
+}
+
+// encodeValue returns the value with the dates as {"$date": ...} objects.
+func encodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case moleculer.Payload:
+		return encodeValue(v.Value())
+	case time.Time:
+		return map[string]interface{}{dateField: v.Format(time.RFC3339Nano)}
+	case map[string]interface{}:
+		encoded := make(map[string]interface{}, len(v))
+		for field, item := range v {
+			encoded[field] = encodeValue(item)
+		}
+		return encoded
+	case []interface{}:
+		encoded := make([]interface{}, len(v))
+		for i, item := range v {
+			encoded[i] = encodeValue(item)
+		}
+		return encoded
+	case []moleculer.Payload:
+		encoded := make([]interface{}, len(v))
+		for i, item := range v {
+			encoded[i] = encodeValue(item)
+		}
+		return encoded
+	}
+	return value
+}
+
+// decodeValue converts the JSON numbers to int64 (integers) or float64 and the {"$date": ...} objects to time.Time.
+func decodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if n, err := v.Int64(); err == nil {
+				return n
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		if date, ok := v[dateField].(string); ok && len(v) == 1 {
+			if t, err := time.Parse(time.RFC3339Nano, date); err == nil {
+				return t
+			}
+		}
+		for field, item := range v {
+			v[field] = decodeValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = decodeValue(item)
+		}
+		return v
+	}
+	return value
+}
+
+// encodeRecord returns the record as JSON.
+func encodeRecord(record map[string]interface{}) ([]byte, error) {
+	return json.Marshal(encodeValue(record))
+}
+
+// decodeRecord returns the record of the JSON document.
+func decodeRecord(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	record := map[string]interface{}{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	return decodeValue(record).(map[string]interface{}), nil
+}
+
+// indexValue returns the value as stored in the index keys, so values equal for the query language are the same entry:
+// numbers as float64 (13 and 13.0), dates in UTC and any other value as text.
+func indexValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case time.Time:
+		return []byte(v.UTC().Format(time.RFC3339Nano))
+	case int:
+		value = float64(v)
+	case int32:
+		value = float64(v)
+	case int64:
+		value = float64(v)
+	case uint:
+		value = float64(v)
+	case uint32:
+		value = float64(v)
+	case uint64:
+		value = float64(v)
+	case float32:
+		value = float64(v)
+	}
+	return []byte(fmt.Sprint(value))
+}
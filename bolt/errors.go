@@ -0,0 +1,21 @@
+package bolt
+
+import (
+	"github.com/moleculer-go/store"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// typedError returns the bbolt error as a *store.Error: closed databases and lock timeouts are unavailable.
+func typedError(err error, msgs ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	code := store.ErrorCode(err)
+	switch err {
+	case bbolt.ErrDatabaseNotOpen, bbolt.ErrTimeout:
+		code = store.CodeUnavailable
+	case bbolt.ErrDatabaseReadOnly, bbolt.ErrTxNotWritable:
+		code = store.CodeForbidden
+	}
+	return store.WrapError(code, err, msgs...)
+}
@@ -0,0 +1,16 @@
+package bolt
+
+import (
+	"github.com/moleculer-go/store"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Ping checks that the database is open and the bucket of the table exists.
+func (a *Adapter) Ping() error {
+	return a.view(func(tx *bbolt.Tx) error {
+		if a.bucket(tx) == nil {
+			return store.NewError(store.CodeUnavailable, "Bolt adapter - bucket not found: ", a.Table)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,169 @@
+package bolt
+
+import (
+	"bytes"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/dsl"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Each declared index has its own bucket (<table>_<index name>). The keys are the values of the
+// index fields (see indexValue), each followed by a 0 byte, and the id of the record. Lists are indexed by item.
+// Records without the fields are not indexed, so all indexes are sparse.
+
+// indexBucket returns the name of the bucket of the index.
+func (a *Adapter) indexBucket(index store.Index) []byte {
+	return []byte(a.Table + "_" + index.Name)
+}
+
+// indexPrefixes returns the key prefixes of the record in the index, one for each combination of list items.
+func indexPrefixes(index store.Index, record map[string]interface{}) [][]byte {
+	prefixes := [][]byte{{}}
+	for _, field := range index.Fields {
+		value, found := dsl.Lookup(record, field.Name)
+		if !found || value == nil {
+			return nil
+		}
+		items := []interface{}{value}
+		if list := payload.New(value); list.IsArray() {
+			items = []interface{}{}
+			for _, item := range list.Array() {
+				items = append(items, item.Value())
+			}
+		}
+		next := [][]byte{}
+		for _, prefix := range prefixes {
+			for _, item := range items {
+				next = append(next, append(append(append([]byte{}, prefix...), indexValue(item)...), 0))
+			}
+		}
+		prefixes = next
+	}
+	return prefixes
+}
+
+// prefixValues returns the values of the key prefix.
+func prefixValues(prefix []byte) []string {
+	values := []string{}
+	for _, value := range bytes.Split(prefix[:len(prefix)-1], []byte{0}) {
+		values = append(values, string(value))
+	}
+	return values
+}
+
+// removeIndexes removes the entries of the record from the index buckets.
+func (a *Adapter) removeIndexes(tx *bbolt.Tx, key []byte, record map[string]interface{}) error {
+	for _, index := range a.indexes {
+		prefixes := indexPrefixes(index, record)
+		bucket := tx.Bucket(a.indexBucket(index))
+		for _, prefix := range prefixes {
+			if err := bucket.Delete(append(prefix, key...)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addIndexes adds the entries of the record to the index buckets.
+// Returns a CodeConflict error when other record has the same values in a unique index.
+func (a *Adapter) addIndexes(tx *bbolt.Tx, key []byte, record map[string]interface{}) error {
+	for _, index := range a.indexes {
+		prefixes := indexPrefixes(index, record)
+		bucket := tx.Bucket(a.indexBucket(index))
+		for _, prefix := range prefixes {
+			if index.Unique {
+				cursor := bucket.Cursor()
+				for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+					if len(k) == len(prefix)+len(key) && !bytes.Equal(k[len(prefix):], key) {
+						values := prefixValues(prefix)
+						return store.NewError(store.CodeConflict, "Duplicate value for unique index ", index.Name, ": ", values).WithData(map[string]interface{}{"index": index.Name, "values": values})
+					}
+				}
+			}
+			if err := bucket.Put(append(prefix, key...), []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createIndexes creates the buckets of the declared indexes. New indexes are built from the existing records.
+func (a *Adapter) createIndexes(tx *bbolt.Tx) error {
+	for _, index := range a.indexes {
+		if index.TTL > 0 {
+			a.log.Warn("Bolt adapter ", a.Table, " - index ", index.Name, ": ttl is not supported by the bolt adapter.")
+		}
+		if tx.Bucket(a.indexBucket(index)) != nil {
+			continue
+		}
+		if _, err := tx.CreateBucket(a.indexBucket(index)); err != nil {
+			return err
+		}
+		built := []store.Index{index}
+		scoped := &Adapter{Table: a.Table, indexes: built}
+		err := tx.Bucket([]byte(a.Table)).ForEach(func(key, data []byte) error {
+			record, err := decodeRecord(data)
+			if err != nil {
+				return err
+			}
+			return scoped.addIndexes(tx, key, record)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryIndex returns the keys of the records with the values of an index, when the query matches
+// all fields of a declared index by equality. ok is false when no index can be used.
+func (a *Adapter) queryIndex(tx *bbolt.Tx, query dsl.Node) (keys [][]byte, ok bool) {
+	equals := map[string]interface{}{}
+	conditions := []dsl.Node{query}
+	if logical, isLogical := query.(dsl.Logical); isLogical && logical.Operator == dsl.And {
+		conditions = logical.Nodes
+	}
+	for _, node := range conditions {
+		if c, isCondition := node.(dsl.Condition); isCondition && c.Operator == dsl.Eq && c.Value != nil {
+			if !payload.New(c.Value).IsArray() && !payload.New(c.Value).IsMap() {
+				equals[c.Field] = c.Value
+			}
+		}
+	}
+	for _, index := range a.indexes {
+		prefix := []byte{}
+		for _, field := range index.Fields {
+			value, exists := equals[field.Name]
+			if !exists {
+				prefix = nil
+				break
+			}
+			prefix = append(append(prefix, indexValue(value)...), 0)
+		}
+		if prefix == nil {
+			continue
+		}
+		cursor := tx.Bucket(a.indexBucket(index)).Cursor()
+		for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+			if len(k) == len(prefix)+8 {
+				keys = append(keys, append([]byte{}, k[len(prefix):]...))
+			}
+		}
+		return keys, true
+	}
+	return nil, false
+}
+
+// Indexes returns the declared indexes.
+func (a *Adapter) Indexes() moleculer.Payload {
+	list := []map[string]interface{}{}
+	for _, index := range a.indexes {
+		list = append(list, index.Map())
+	}
+	return payload.New(list)
+}
@@ -0,0 +1,370 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+	log "github.com/sirupsen/logrus"
+)
+
+var defaultCacheSize = 1000
+var defaultCacheTTL = time.Minute
+
+// CacheAdapter caches the results of FindById, FindByIds and Find in a bounded LRU with TTL.
+// Writes through the adapter invalidate the affected entries.
+// When Service is set, the Mixin also invalidates the entries on the entity events
+// (created, updated, removed, updatedMany and removedMany) of the service, sent by other nodes.
+//
+//	store.Mixin(&store.CacheAdapter{Adapter: &sqlite.Adapter{...}, Service: "user"})
+type CacheAdapter struct {
+	Adapter Adapter
+	// Size is the max number of entries. Default: 1000
+	Size int
+	// TTL of the entries. Default: 1 minute
+	TTL     time.Duration
+	Service string
+
+	idField string
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	tenants []*CacheAdapter
+	// shared is the cache with the entries, when the adapter is bound to a context.
+	shared *CacheAdapter
+}
+
+type cacheEntry struct {
+	key   string
+	value moleculer.Payload
+	// ids of the records in a find result.
+	ids []string
+	// query of a find, nil when it can't be matched in process (e.g. search or nativeQuery).
+	query   dsl.Node
+	expires time.Time
+}
+
+func (c *CacheAdapter) Init(logger *log.Entry, settings map[string]interface{}) {
+	c.idField = "id"
+	if idField, ok := settings["idField"].(string); ok {
+		c.idField = idField
+	}
+	c.Adapter.Init(logger, settings)
+}
+
+func (c *CacheAdapter) Connect() error {
+	return c.Adapter.Connect()
+}
+
+func (c *CacheAdapter) Disconnect() error {
+	c.InvalidateAll()
+	return c.Adapter.Disconnect()
+}
+
+// ForTenant returns a cache for the tenant adapter. Returns nil when the adapter does not support tenants.
+func (c *CacheAdapter) ForTenant(tenant string) Adapter {
+	tenantAdapter, ok := c.Adapter.(TenantAdapter)
+	if !ok {
+		return nil
+	}
+	scoped := tenantAdapter.ForTenant(tenant)
+	if scoped == nil {
+		return nil
+	}
+	cache := &CacheAdapter{Adapter: scoped, Size: c.Size, TTL: c.TTL, idField: c.idField}
+	c.mutex.Lock()
+	c.tenants = append(c.tenants, cache)
+	c.mutex.Unlock()
+	return cache
+}
+
+// WithContext returns the cache with the adapter bound to the context. The entries are shared with this cache.
+func (c *CacheAdapter) WithContext(ctx context.Context) Adapter {
+	shared := c
+	if c.shared != nil {
+		shared = c.shared
+	}
+	return &CacheAdapter{Adapter: WithContext(c.Adapter, ctx), Size: c.Size, TTL: c.TTL, Service: c.Service, idField: c.idField, shared: shared}
+}
+
+// Indexes returns the indexes of the adapter, or nil when the adapter does not implement IndexAdapter.
+func (c *CacheAdapter) Indexes() moleculer.Payload {
+	if indexer, ok := c.Adapter.(IndexAdapter); ok {
+		return indexer.Indexes()
+	}
+	return nil
+}
+
+// Ping pings the adapter. See HealthAdapter.
+func (c *CacheAdapter) Ping() error {
+	return Ping(c.Adapter)
+}
+
+// PoolStats returns the pool usage of the adapter, or nil when the adapter does not implement PoolAdapter.
+func (c *CacheAdapter) PoolStats() map[string]interface{} {
+	if pool, ok := c.Adapter.(PoolAdapter); ok {
+		return pool.PoolStats()
+	}
+	return nil
+}
+
+// get returns the entry of the key, when it exists and is not expired.
+func (c *CacheAdapter) get(key string) *cacheEntry {
+	if c.shared != nil {
+		return c.shared.get(key)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, exists := c.entries[key]
+	if !exists {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+// put adds the entry, removing the least recently used entries when the cache is full.
+func (c *CacheAdapter) put(entry *cacheEntry) {
+	if c.shared != nil {
+		c.shared.put(entry)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]*list.Element{}
+		c.lru = list.New()
+	}
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+	size := c.Size
+	if size == 0 {
+		size = defaultCacheSize
+	}
+	entry.expires = time.Now().Add(ttl)
+	if element, exists := c.entries[entry.key]; exists {
+		c.lru.Remove(element)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func idKey(id string) string {
+	return "id:" + id
+}
+
+// Invalidate removes the entries affected by a change in the record of the id.
+// record is the record after the change, or nil when it is not known.
+// When removed is true, only the entries with the record are removed.
+func (c *CacheAdapter) Invalidate(id string, record moleculer.Payload, removed bool) {
+	if c.shared != nil {
+		c.shared.Invalidate(id, record, removed)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, element := range c.entries {
+		entry := element.Value.(*cacheEntry)
+		affected := key == idKey(id) || contains(entry.ids, id)
+		if !affected && entry.ids != nil && !removed {
+			affected = record == nil || entry.query == nil || !record.IsMap() || dsl.Match(entry.query, record.RawMap())
+		}
+		if affected {
+			c.lru.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+	for _, tenant := range c.tenants {
+		tenant.Invalidate(id, record, removed)
+	}
+}
+
+// InvalidateAll removes all entries.
+func (c *CacheAdapter) InvalidateAll() {
+	if c.shared != nil {
+		c.shared.InvalidateAll()
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru = list.New()
+	for _, tenant := range c.tenants {
+		tenant.InvalidateAll()
+	}
+}
+
+func (c *CacheAdapter) recordID(record moleculer.Payload) string {
+	return record.Get(c.idField).String()
+}
+
+// Find caches the result of the params.
+func (c *CacheAdapter) Find(params moleculer.Payload) moleculer.Payload {
+	key := "find:" + fmt.Sprint(params.Value())
+	if entry := c.get(key); entry != nil {
+		return entry.value
+	}
+	result := c.Adapter.Find(params)
+	if result.IsError() {
+		return result
+	}
+	var query dsl.Node
+	if !params.Get("search").Exists() && !params.Get("nativeQuery").Exists() {
+		query, _ = dsl.Parse(params.Get("query"))
+	}
+	ids := []string{}
+	result.ForEach(func(_ interface{}, record moleculer.Payload) bool {
+		ids = append(ids, c.recordID(record))
+		return true
+	})
+	c.put(&cacheEntry{key: key, value: result, ids: ids, query: query})
+	return result
+}
+
+func (c *CacheAdapter) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	result := c.Adapter.FindAndUpdate(params)
+	c.InvalidateAll()
+	return result
+}
+
+func (c *CacheAdapter) FindOne(params moleculer.Payload) moleculer.Payload {
+	return c.Adapter.FindOne(params)
+}
+
+// FindById caches the record of the id. Records not found are not cached.
+func (c *CacheAdapter) FindById(id moleculer.Payload) moleculer.Payload {
+	key := idKey(id.String())
+	if entry := c.get(key); entry != nil {
+		return entry.value
+	}
+	result := c.Adapter.FindById(id)
+	if !result.IsError() && result.Exists() {
+		c.put(&cacheEntry{key: key, value: result})
+	}
+	return result
+}
+
+// FindByIds returns the cached records and only loads the missing ones.
+func (c *CacheAdapter) FindByIds(ids moleculer.Payload) moleculer.Payload {
+	records := map[string]moleculer.Payload{}
+	missing := []string{}
+	for _, id := range ids.StringArray() {
+		if entry := c.get(idKey(id)); entry != nil {
+			records[id] = entry.value
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		result := c.Adapter.FindByIds(payload.New(missing))
+		if result.IsError() {
+			return result
+		}
+		result.ForEach(func(_ interface{}, record moleculer.Payload) bool {
+			if record.Exists() {
+				id := c.recordID(record)
+				records[id] = record
+				c.put(&cacheEntry{key: idKey(id), value: record})
+			}
+			return true
+		})
+	}
+	list := []moleculer.Payload{}
+	for _, id := range ids.StringArray() {
+		if record, exists := records[id]; exists {
+			list = append(list, record)
+		}
+	}
+	return payload.New(list)
+}
+
+func (c *CacheAdapter) Count(params moleculer.Payload) moleculer.Payload {
+	return c.Adapter.Count(params)
+}
+
+func (c *CacheAdapter) Insert(params moleculer.Payload) moleculer.Payload {
+	result := c.Adapter.Insert(params)
+	if !result.IsError() {
+		c.Invalidate(c.recordID(result), result, false)
+	}
+	return result
+}
+
+func (c *CacheAdapter) Update(params moleculer.Payload) moleculer.Payload {
+	return c.UpdateById(params.Get("id"), params.Remove("id"))
+}
+
+func (c *CacheAdapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	result := c.Adapter.UpdateById(id, update)
+	// adapters like mongo return the counts instead of the updated record, then all the finds are invalidated.
+	var record moleculer.Payload
+	if !result.IsError() && result.Get(c.idField).Exists() {
+		record = result
+	}
+	c.Invalidate(id.String(), record, false)
+	return result
+}
+
+func (c *CacheAdapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	result := c.Adapter.UpdateMany(params)
+	c.InvalidateAll()
+	return result
+}
+
+func (c *CacheAdapter) RemoveById(id moleculer.Payload) moleculer.Payload {
+	result := c.Adapter.RemoveById(id)
+	c.Invalidate(id.String(), nil, true)
+	return result
+}
+
+func (c *CacheAdapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	result := c.Adapter.RemoveMany(params)
+	c.InvalidateAll()
+	return result
+}
+
+func (c *CacheAdapter) RemoveAll() moleculer.Payload {
+	result := c.Adapter.RemoveAll()
+	c.InvalidateAll()
+	return result
+}
+
+// cacheEvents invalidates the cache on the entity events of the service, sent by all nodes.
+func cacheEvents(cache *CacheAdapter) []moleculer.Event {
+	invalidate := func(removed bool) moleculer.EventHandler {
+		return func(ctx moleculer.Context, params moleculer.Payload) {
+			if params == nil || params.String() == "" {
+				cache.InvalidateAll()
+				return
+			}
+			cache.Invalidate(params.String(), nil, removed)
+		}
+	}
+	invalidateAll := func(ctx moleculer.Context, params moleculer.Payload) {
+		cache.InvalidateAll()
+	}
+	return []moleculer.Event{
+		{Name: cache.Service + ".created", Handler: invalidate(false)},
+		{Name: cache.Service + ".updated", Handler: invalidate(false)},
+		{Name: cache.Service + ".removed", Handler: invalidate(true)},
+		{Name: cache.Service + ".updatedMany", Handler: invalidateAll},
+		{Name: cache.Service + ".removedMany", Handler: invalidateAll},
+	}
+}
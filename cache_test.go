@@ -0,0 +1,135 @@
+package store
+
+import (
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CacheAdapter", func() {
+	var calls map[string]int
+	var cache *CacheAdapter
+	var johnSnow, marie moleculer.Payload
+
+	BeforeEach(func() {
+		calls = map[string]int{}
+		counted := Wrap(&MemoryAdapter{Table: "user", SearchFields: []string{"name"}}, Timing(func(call *Call, result moleculer.Payload, duration time.Duration) {
+			calls[call.Operation]++
+		}))
+		cache = &CacheAdapter{Adapter: counted, Size: 10, TTL: time.Minute}
+		johnSnow, marie, _ = mocks.ConnectAndLoadUsers(cache)
+	})
+	AfterEach(func() {
+		cache.Disconnect()
+	})
+
+	It("should cache FindById and FindByIds", func() {
+		Expect(cache.FindById(marie.Get("id")).Get("name").String()).Should(Equal("Marie"))
+		Expect(cache.FindById(marie.Get("id")).Get("name").String()).Should(Equal("Marie"))
+		Expect(calls["FindById"]).Should(Equal(1))
+
+		r := cache.FindByIds(payload.New([]string{marie.Get("id").String(), johnSnow.Get("id").String()}))
+		Expect(r.Len()).Should(Equal(2))
+		Expect(r.First().Get("name").String()).Should(Equal("Marie"))
+		Expect(calls["FindByIds"]).Should(Equal(1))
+
+		cache.FindByIds(payload.New([]string{johnSnow.Get("id").String()}))
+		Expect(calls["FindByIds"]).Should(Equal(1))
+	})
+
+	It("should cache Find and invalidate only the affected queries", func() {
+		johns := payload.New(M{"query": M{"name": "John"}})
+		old := payload.New(M{"query": M{"age": M{"$gt": 60}}})
+		Expect(cache.Find(johns).Len()).Should(Equal(2))
+		Expect(cache.Find(old).Len()).Should(Equal(2))
+		cache.Find(johns)
+		Expect(calls["Find"]).Should(Equal(2))
+
+		// a new John only affects the johns query
+		cache.Insert(payload.New(M{"name": "John", "age": 30}))
+		Expect(cache.Find(johns).Len()).Should(Equal(3))
+		Expect(cache.Find(old).Len()).Should(Equal(2))
+		Expect(calls["Find"]).Should(Equal(3))
+
+		// marie is in the old query
+		cache.UpdateById(marie.Get("id"), payload.New(M{"age": 50}))
+		Expect(cache.Find(old).Len()).Should(Equal(1))
+		Expect(cache.Find(johns).Len()).Should(Equal(3))
+		Expect(calls["Find"]).Should(Equal(4))
+
+		cache.RemoveById(johnSnow.Get("id"))
+		Expect(cache.Find(johns).Len()).Should(Equal(2))
+		Expect(calls["Find"]).Should(Equal(5))
+		Expect(cache.FindById(johnSnow.Get("id")).Exists()).Should(BeFalse())
+	})
+
+	It("should invalidate all the finds when UpdateById does not return the updated record", func() {
+		counts := Wrap(&MemoryAdapter{Table: "user", SearchFields: []string{"name"}}, func(call *Call, next Next) moleculer.Payload {
+			result := next(call)
+			if call.Operation == "UpdateById" && !result.IsError() {
+				return payload.New(M{"modifiedCount": 1, "matchedCount": 1})
+			}
+			return result
+		})
+		cache.Disconnect()
+		cache = &CacheAdapter{Adapter: counts, Size: 10, TTL: time.Minute}
+		_, marie, _ = mocks.ConnectAndLoadUsers(cache)
+
+		old := payload.New(M{"query": M{"age": M{"$gt": 60}}})
+		Expect(cache.Find(old).Len()).Should(Equal(2))
+		cache.UpdateById(marie.Get("id"), payload.New(M{"age": 50}))
+		Expect(cache.Find(old).Len()).Should(Equal(1))
+	})
+
+	It("should evict the least recently used entries and expire entries after the TTL", func() {
+		cache.Size = 2
+		cache.FindById(johnSnow.Get("id"))
+		cache.FindById(marie.Get("id"))
+		cache.FindById(johnSnow.Get("id"))
+		cache.Find(payload.Empty())
+		Expect(calls["FindById"]).Should(Equal(2))
+
+		cache.FindById(johnSnow.Get("id"))
+		Expect(calls["FindById"]).Should(Equal(2))
+		cache.FindById(marie.Get("id"))
+		Expect(calls["FindById"]).Should(Equal(3))
+
+		cache.TTL = time.Millisecond
+		cache.InvalidateAll()
+		cache.FindById(johnSnow.Get("id"))
+		Expect(calls["FindById"]).Should(Equal(4))
+		time.Sleep(time.Millisecond * 5)
+		cache.FindById(johnSnow.Get("id"))
+		Expect(calls["FindById"]).Should(Equal(5))
+	})
+
+	It("should invalidate on the entity events of other nodes", func() {
+		cache.Service = "user"
+		events := map[string]moleculer.EventHandler{}
+		for _, event := range cacheEvents(cache) {
+			events[event.Name] = event.Handler
+		}
+		ctx, _ := contextAndDelegated("cache-test", moleculer.Config{})
+
+		cache.FindById(marie.Get("id"))
+		cache.Find(payload.New(M{"query": M{"name": "Peter"}}))
+		events["user.updated"](ctx.(moleculer.Context), payload.New(marie.Get("id").String()))
+		cache.FindById(marie.Get("id"))
+		cache.Find(payload.New(M{"query": M{"name": "Peter"}}))
+		Expect(calls["FindById"]).Should(Equal(2))
+		Expect(calls["Find"]).Should(Equal(2))
+
+		events["user.removedMany"](ctx.(moleculer.Context), payload.Empty())
+		cache.FindById(marie.Get("id"))
+		Expect(calls["FindById"]).Should(Equal(3))
+
+		// events without id (e.g. from nodes that broadcast the result of a Mongo update) clear the whole cache
+		events["user.updated"](ctx.(moleculer.Context), payload.New(""))
+		cache.FindById(marie.Get("id"))
+		Expect(calls["FindById"]).Should(Equal(4))
+	})
+})
@@ -0,0 +1,457 @@
+// Command store is the admin tool of the store services.
+// It runs the operations against the database of an adapter URI, or through a broker on a running service.
+//
+//	store count sqlite://users.db?table=users --query '{"age": {"$gt": 18}}'
+//	store find --service users --transporter nats://localhost:4222 --query '{"name": "John"}'
+//	store export mongodb://localhost:27017/store?collection=users --out users.ndjson
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/broker"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/sqlite"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	service     string
+	transporter string
+	logLevel    string
+	query       string
+	limit       int
+	offset      int
+	sortFields  string
+	out         string
+	in          string
+	mode        string
+	columns     []string
+	indexes     []string
+	format      string
+	state       string
+	batchSize   int
+	populates   []string
+	verify      bool
+)
+
+// target runs the operations on the adapter or on the service.
+type target struct {
+	adapter store.Adapter
+	bkr     *broker.ServiceBroker
+	service string
+}
+
+// open returns the target of the command: the service (--service) or the adapter of the URI argument.
+func open(args []string, settings map[string]interface{}) (*target, error) {
+	if service != "" {
+		config := &moleculer.Config{LogLevel: logLevel, Transporter: transporter}
+		bkr := broker.New(config)
+		bkr.Start()
+		if err := bkr.WaitFor(service); err != nil {
+			bkr.Stop()
+			return nil, err
+		}
+		return &target{bkr: bkr, service: service}, nil
+	}
+	if len(args) == 0 {
+		return nil, errors.New("Adapter URI or --service is required!")
+	}
+	declared, err := parseColumns()
+	if err != nil {
+		return nil, err
+	}
+	logger := log.WithField("store", "cli")
+	adapter, err := openAdapter(args[0], settings, declared, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &target{adapter: adapter}, nil
+}
+
+func (t *target) close() {
+	if t.bkr != nil {
+		t.bkr.Stop()
+	}
+	if t.adapter != nil {
+		t.adapter.Disconnect()
+	}
+}
+
+// call calls the action of the service.
+func (t *target) call(action string, params map[string]interface{}) moleculer.Payload {
+	return <-t.bkr.Call(t.service+"."+action, params)
+}
+
+func (t *target) remote() bool {
+	return t.bkr != nil
+}
+
+// queryParams returns the params with the --query, --limit, --offset and --sort flags.
+func queryParams() (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	if query != "" {
+		parsed := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(query), &parsed); err != nil {
+			return nil, errors.New("Invalid --query: " + err.Error())
+		}
+		params["query"] = parsed
+	}
+	if limit > 0 {
+		params["limit"] = limit
+	}
+	if offset > 0 {
+		params["offset"] = offset
+	}
+	if sortFields != "" {
+		params["sort"] = sortFields
+	}
+	return params, nil
+}
+
+// indexSettings returns the settings with the --index flags.
+func indexSettings() (map[string]interface{}, error) {
+	list := []map[string]interface{}{}
+	for _, index := range indexes {
+		parsed := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(index), &parsed); err != nil {
+			return nil, errors.New("Invalid --index: " + err.Error())
+		}
+		list = append(list, parsed)
+	}
+	return map[string]interface{}{"indexes": list}, nil
+}
+
+// printResult writes the result as JSON.
+func printResult(result moleculer.Payload) error {
+	if result.IsError() {
+		return result.Error()
+	}
+	if text, ok := result.Value().(string); ok {
+		fmt.Println(text)
+		return nil
+	}
+	data, err := json.MarshalIndent(result.Value(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// output returns the --out file or stdout.
+func output() (io.WriteCloser, error) {
+	if out == "" || out == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(out)
+}
+
+// input returns the --in file or stdin.
+func input() (io.ReadCloser, error) {
+	if in == "" || in == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(in)
+}
+
+// run opens the target and calls fn, closing the target after.
+func run(settings func() (map[string]interface{}, error), fn func(t *target) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		values := map[string]interface{}{}
+		if settings != nil {
+			var err error
+			if values, err = settings(); err != nil {
+				return err
+			}
+		}
+		t, err := open(args, values)
+		if err != nil {
+			return err
+		}
+		defer t.close()
+		return fn(t)
+	}
+}
+
+var countCmd = &cobra.Command{
+	Use:   "count [uri]",
+	Short: "Count the records matching the --query",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: run(nil, func(t *target) error {
+		params, err := queryParams()
+		if err != nil {
+			return err
+		}
+		if t.remote() {
+			return printResult(t.call("count", params))
+		}
+		return printResult(t.adapter.Count(payload.New(params)))
+	}),
+}
+
+var findCmd = &cobra.Command{
+	Use:   "find [uri]",
+	Short: "Find the records matching the --query",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: run(nil, func(t *target) error {
+		params, err := queryParams()
+		if err != nil {
+			return err
+		}
+		if t.remote() {
+			return printResult(t.call("find", params))
+		}
+		return printResult(t.adapter.Find(payload.New(params)))
+	}),
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [uri]",
+	Short: "Export the records matching the --query as NDJSON to --out (default: stdout)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: run(nil, func(t *target) error {
+		params, err := queryParams()
+		if err != nil {
+			return err
+		}
+		w, err := output()
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		if t.remote() {
+			result := t.call("export", params)
+			if result.IsError() {
+				return result.Error()
+			}
+			_, err = io.WriteString(w, result.String())
+			return err
+		}
+		if sqliteAdapter, ok := t.adapter.(*sqlite.Adapter); ok {
+			declared, _ := parseColumns()
+			if text := undeclaredText(sqliteAdapter, declared); len(text) > 0 {
+				return errors.New("Declare the type of the TEXT columns with --column name:type e.g. tags:[]string or name:string. Undeclared: " + strings.Join(text, ", "))
+			}
+		}
+		opts := store.ExportOptions{Progress: func(done, total int) {
+			fmt.Fprintln(os.Stderr, "exported", done, "of", total)
+		}}
+		if params["query"] != nil {
+			opts.Query = params["query"].(map[string]interface{})
+		}
+		_, err = store.Export(t.adapter, w, opts)
+		return err
+	}),
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [uri]",
+	Short: "Import the NDJSON export from --in (default: stdin)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: run(nil, func(t *target) error {
+		r, err := input()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if t.remote() {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return printResult(t.call("import", map[string]interface{}{"data": string(data), "mode": mode}))
+		}
+		result, err := store.Import(t.adapter, r, store.ImportOptions{Mode: mode, Progress: func(done, total int) {
+			fmt.Fprintln(os.Stderr, "imported", done, "of", total)
+		}})
+		if err != nil {
+			return err
+		}
+		return printResult(payload.New(result.Map()))
+	}),
+}
+
+// parseColumns parses the --column flags (name:type) into the columns of the SQLite adapter.
+func parseColumns() ([]sqlite.Column, error) {
+	declared := []sqlite.Column{}
+	for _, column := range columns {
+		parts := strings.SplitN(column, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("Invalid --column: " + column + ". Use name:type e.g. email:string")
+		}
+		declared = append(declared, sqlite.Column{Name: parts[0], Type: parts[1]})
+	}
+	return declared, nil
+}
+
+// copyOptions returns the options of the copy command. The service is the table (or collection) of the source URI.
+// --populate flags are field:action e.g. master:users.get
+func copyOptions(source string) (store.CopyOptions, error) {
+	opts := store.CopyOptions{State: state, BatchSize: batchSize, Populates: map[string]interface{}{}}
+	if parsed, err := url.Parse(source); err == nil {
+		opts.Service = parsed.Query().Get("table")
+		if opts.Service == "" {
+			opts.Service = parsed.Query().Get("collection")
+		}
+		if opts.Service == "" {
+			opts.Service = parsed.Host
+		}
+	}
+	for _, populate := range populates {
+		parts := strings.SplitN(populate, ":", 2)
+		if len(parts) != 2 {
+			return opts, errors.New("Invalid --populate: " + populate + ". Use field:action e.g. master:users.get")
+		}
+		opts.Populates[parts[0]] = parts[1]
+	}
+	opts.Progress = func(done, total int) {
+		fmt.Fprintln(os.Stderr, "copied", done, "of", total)
+	}
+	return opts, nil
+}
+
+var copyCmd = &cobra.Command{
+	Use:   "copy <source uri> <target uri>",
+	Short: "Copy all records from the source to the target adapter, mapping the ids and the --populate references",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := copyOptions(args[0])
+		if err != nil {
+			return err
+		}
+		logger := log.WithField("store", "cli")
+		source, err := openAdapter(args[0], map[string]interface{}{}, nil, logger)
+		if err != nil {
+			return err
+		}
+		defer source.Disconnect()
+		target, err := openAdapter(args[1], map[string]interface{}{}, nil, logger)
+		if err != nil {
+			return err
+		}
+		defer target.Disconnect()
+		result, err := store.Copy(source, target, opts)
+		if err != nil {
+			return err
+		}
+		report := payload.New(result.Map())
+		if verify {
+			verified, err := store.Verify(source, target, result.IDMap, opts)
+			if err != nil {
+				return err
+			}
+			report = report.Add("verify", verified.Map())
+		}
+		return printResult(report)
+	},
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <sqlite uri>",
+	Short: "Add the --column (name:type) columns missing in a SQLite table and list the applied migrations",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		adapter, err := parseAdapter(args[0])
+		if err != nil {
+			return err
+		}
+		sqliteAdapter, ok := adapter.(*sqlite.Adapter)
+		if !ok {
+			return errors.New("migrate is only supported by the SQLite adapter!")
+		}
+		if sqliteAdapter.Columns, err = parseColumns(); err != nil {
+			return err
+		}
+		sqliteAdapter.Init(log.WithField("store", "cli"), map[string]interface{}{})
+		if err := sqliteAdapter.Connect(); err != nil {
+			return err
+		}
+		defer sqliteAdapter.Disconnect()
+		return printResult(sqliteAdapter.AppliedMigrations())
+	},
+}
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex [uri]",
+	Short: "Create the --index indexes (JSON, same format as the indexes setting) and list the indexes",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: run(indexSettings, func(t *target) error {
+		if t.remote() {
+			if len(indexes) > 0 {
+				return errors.New("--index is not supported with --service: declare the indexes setting of the service")
+			}
+			return printResult(t.call("indexes", nil))
+		}
+		if indexer, ok := t.adapter.(store.IndexAdapter); ok {
+			return printResult(indexer.Indexes())
+		}
+		return errors.New("The adapter does not support indexes!")
+	}),
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [uri]",
+	Short: "Show the number of records and the indexes, or the operation stats of the --service",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: run(nil, func(t *target) error {
+		if t.remote() {
+			if format == "prometheus" {
+				return printResult(t.call("stats", map[string]interface{}{"format": format}))
+			}
+			return printResult(t.call("stats", nil))
+		}
+		stats := payload.Empty().Add("count", t.adapter.Count(payload.Empty()).Value())
+		if indexer, ok := t.adapter.(store.IndexAdapter); ok {
+			stats = stats.Add("indexes", indexer.Indexes().Value())
+		}
+		if sqliteAdapter, ok := t.adapter.(*sqlite.Adapter); ok {
+			stats = stats.Add("migrations", sqliteAdapter.AppliedMigrations().Value())
+		}
+		return printResult(stats)
+	}),
+}
+
+func main() {
+	root := &cobra.Command{
+		Use:          "store",
+		Short:        "Admin tool of the store services",
+		SilenceUsage: true,
+	}
+	root.PersistentFlags().StringVar(&service, "service", "", "run the operation through a broker on the running service, instead of the adapter URI")
+	root.PersistentFlags().StringVar(&transporter, "transporter", "", "transporter of the broker. e.g. nats://localhost:4222")
+	root.PersistentFlags().StringVar(&logLevel, "log-level", "error", "log level")
+
+	for _, cmd := range []*cobra.Command{countCmd, findCmd, exportCmd} {
+		cmd.Flags().StringVar(&query, "query", "", "query (JSON) e.g. '{\"age\": {\"$gt\": 18}}'")
+	}
+	findCmd.Flags().IntVar(&limit, "limit", 0, "max number of records")
+	findCmd.Flags().IntVar(&offset, "offset", 0, "number of records to skip")
+	findCmd.Flags().StringVar(&sortFields, "sort", "", "sort fields e.g. -age")
+	exportCmd.Flags().StringVar(&out, "out", "", "output file (default: stdout)")
+	exportCmd.Flags().StringArrayVar(&columns, "column", nil, "type name:type of a TEXT column of a SQLite table, required to export them (repeatable)")
+	importCmd.Flags().StringVar(&in, "in", "", "input file (default: stdin)")
+	importCmd.Flags().StringVar(&mode, "mode", "upsert", "upsert or replace")
+	migrateCmd.Flags().StringArrayVar(&columns, "column", nil, "declared column name:type (repeatable)")
+	reindexCmd.Flags().StringArrayVar(&indexes, "index", nil, "index (JSON) e.g. '{\"fields\": [\"email\"], \"unique\": true}' (repeatable)")
+	copyCmd.Flags().StringVar(&state, "state", "", "file with the copy state. An interrupted copy with the same file resumes")
+	copyCmd.Flags().IntVar(&batchSize, "batch", 100, "number of records read at a time")
+	copyCmd.Flags().StringArrayVar(&populates, "populate", nil, "reference field field:action e.g. master:users.get (repeatable)")
+	copyCmd.Flags().BoolVar(&verify, "verify", false, "verify the counts and checksums after the copy")
+	statsCmd.Flags().StringVar(&format, "format", "", "prometheus to get the service stats in the Prometheus text format")
+
+	root.AddCommand(countCmd, findCmd, exportCmd, importCmd, copyCmd, migrateCmd, reindexCmd, statsCmd)
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
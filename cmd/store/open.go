@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/bolt"
+	"github.com/moleculer-go/store/mongo"
+	"github.com/moleculer-go/store/redis"
+	"github.com/moleculer-go/store/sqlite"
+	log "github.com/sirupsen/logrus"
+)
+
+// parseAdapter returns the adapter of the URI. Supported URIs:
+//
+//	memory://users
+//	sqlite://path/to/file.db?table=users
+//	bolt://path/to/file.db?table=users
+//	mongodb://localhost:27017/database?collection=users
+//	redis://localhost:6379/0?table=users
+func parseAdapter(uri string) (store.Adapter, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.New("Invalid adapter URI: " + err.Error())
+	}
+	query := parsed.Query()
+	switch parsed.Scheme {
+	case "memory":
+		if parsed.Host == "" {
+			return nil, errors.New("Invalid adapter URI: the table is missing. e.g. memory://users")
+		}
+		return &store.MemoryAdapter{Table: parsed.Host}, nil
+	case "sqlite":
+		table := query.Get("table")
+		path := strings.TrimPrefix(uri, "sqlite://")
+		path = strings.SplitN(path, "?", 2)[0]
+		if table == "" || path == "" {
+			return nil, errors.New("Invalid adapter URI: the path or table is missing. e.g. sqlite://users.db?table=users")
+		}
+		return &sqlite.Adapter{URI: "file:" + path, Table: table}, nil
+	case "bolt":
+		table := query.Get("table")
+		path := strings.TrimPrefix(uri, "bolt://")
+		path = strings.SplitN(path, "?", 2)[0]
+		if table == "" || path == "" {
+			return nil, errors.New("Invalid adapter URI: the path or table is missing. e.g. bolt://users.db?table=users")
+		}
+		return &bolt.Adapter{Path: path, Table: table}, nil
+	case "mongodb", "mongodb+srv":
+		collection := query.Get("collection")
+		database := strings.TrimPrefix(parsed.Path, "/")
+		if collection == "" || database == "" {
+			return nil, errors.New("Invalid adapter URI: the database or collection is missing. e.g. mongodb://localhost:27017/store?collection=users")
+		}
+		query.Del("collection")
+		parsed.RawQuery = query.Encode()
+		parsed.Path = "/"
+		return &mongo.MongoAdapter{
+			MongoURL:   parsed.String(),
+			Database:   database,
+			Collection: collection,
+			Timeout:    10 * time.Second,
+		}, nil
+	case "redis", "rediss":
+		table := query.Get("table")
+		if table == "" {
+			return nil, errors.New("Invalid adapter URI: the table is missing. e.g. redis://localhost:6379/0?table=users")
+		}
+		query.Del("table")
+		parsed.RawQuery = query.Encode()
+		return &redis.Adapter{URL: parsed.String(), Table: table}, nil
+	}
+	return nil, errors.New("Invalid adapter URI: unsupported scheme " + parsed.Scheme + ". Use memory, sqlite, bolt, mongodb or redis.")
+}
+
+// openAdapter connects to the adapter of the URI.
+// SQLite tables are opened with the columns of the existing table, and the types of the declared columns.
+func openAdapter(uri string, settings map[string]interface{}, declared []sqlite.Column, logger *log.Entry) (store.Adapter, error) {
+	adapter, err := parseAdapter(uri)
+	if err != nil {
+		return nil, err
+	}
+	adapter.Init(logger, settings)
+	if err := adapter.Connect(); err != nil {
+		return nil, err
+	}
+	if sqliteAdapter, ok := adapter.(*sqlite.Adapter); ok && len(sqliteAdapter.Columns) == 0 {
+		if err := loadColumns(sqliteAdapter, declared); err != nil {
+			adapter.Disconnect()
+			return nil, err
+		}
+	}
+	return adapter, nil
+}
+
+// loadColumns reads the columns of the SQLite table and sets the types of the declared columns.
+func loadColumns(adapter *sqlite.Adapter, declared []sqlite.Column) error {
+	if err := adapter.LoadColumns(); err != nil {
+		return err
+	}
+	for _, column := range declared {
+		found := false
+		for i, loaded := range adapter.Columns {
+			if loaded.Name == column.Name {
+				adapter.Columns[i].Type = column.Type
+				found = true
+			}
+		}
+		if !found {
+			return errors.New("Invalid --column: " + column.Name + " is not a column of the table " + adapter.Table)
+		}
+	}
+	return nil
+}
+
+// undeclaredText returns the string columns of the SQLite adapter that are not declared.
+// LoadColumns types all TEXT columns as string, so their lists, maps and dates are read as text.
+func undeclaredText(adapter *sqlite.Adapter, declared []sqlite.Column) []string {
+	names := map[string]bool{}
+	for _, column := range declared {
+		names[column.Name] = true
+	}
+	undeclared := []string{}
+	for _, column := range adapter.Columns {
+		if column.Type == "string" && !names[column.Name] {
+			undeclared = append(undeclared, column.Name)
+		}
+	}
+	return undeclared
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/bolt"
+	"github.com/moleculer-go/store/mongo"
+	"github.com/moleculer-go/store/redis"
+	"github.com/moleculer-go/store/sqlite"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Adapter URI", func() {
+	logger := log.WithField("test", "cli")
+
+	It("should parse the memory, sqlite, bolt, mongodb and redis URIs", func() {
+		adapter, err := parseAdapter("memory://users")
+		Expect(err).Should(BeNil())
+		Expect(adapter.(*store.MemoryAdapter).Table).Should(Equal("users"))
+
+		adapter, err = parseAdapter("sqlite://data/users.db?table=users")
+		Expect(err).Should(BeNil())
+		Expect(adapter.(*sqlite.Adapter).URI).Should(Equal("file:data/users.db"))
+		Expect(adapter.(*sqlite.Adapter).Table).Should(Equal("users"))
+
+		adapter, err = parseAdapter("bolt://data/users.db?table=users")
+		Expect(err).Should(BeNil())
+		Expect(adapter.(*bolt.Adapter).Path).Should(Equal("data/users.db"))
+		Expect(adapter.(*bolt.Adapter).Table).Should(Equal("users"))
+
+		adapter, err = parseAdapter("mongodb://localhost:27017/store?collection=users&replicaSet=rs0")
+		Expect(err).Should(BeNil())
+		mongoAdapter := adapter.(*mongo.MongoAdapter)
+		Expect(mongoAdapter.MongoURL).Should(Equal("mongodb://localhost:27017/?replicaSet=rs0"))
+		Expect(mongoAdapter.Database).Should(Equal("store"))
+		Expect(mongoAdapter.Collection).Should(Equal("users"))
+
+		adapter, err = parseAdapter("redis://:secret@localhost:6379/2?table=users")
+		Expect(err).Should(BeNil())
+		Expect(adapter.(*redis.Adapter).URL).Should(Equal("redis://:secret@localhost:6379/2"))
+		Expect(adapter.(*redis.Adapter).Table).Should(Equal("users"))
+	})
+
+	It("should fail with invalid URIs", func() {
+		_, err := parseAdapter("memory://")
+		Expect(err).ShouldNot(BeNil())
+		_, err = parseAdapter("sqlite://users.db")
+		Expect(err).ShouldNot(BeNil())
+		_, err = parseAdapter("bolt://users.db")
+		Expect(err).ShouldNot(BeNil())
+		_, err = parseAdapter("mongodb://localhost:27017/store")
+		Expect(err).ShouldNot(BeNil())
+		_, err = parseAdapter("redis://localhost")
+		Expect(err).ShouldNot(BeNil())
+		_, err = parseAdapter("cassandra://localhost")
+		Expect(err.Error()).Should(ContainSubstring("unsupported scheme cassandra"))
+	})
+
+	It("should open an existing SQLite table with its columns", func() {
+		dir, err := ioutil.TempDir("", "cli")
+		Expect(err).Should(BeNil())
+		defer os.RemoveAll(dir)
+		uri := "sqlite://" + filepath.Join(dir, "users.db") + "?table=users"
+
+		created := &sqlite.Adapter{URI: "file:" + filepath.Join(dir, "users.db"), Table: "users", Columns: []sqlite.Column{
+			{Name: "name", Type: "string"},
+			{Name: "age", Type: "integer"},
+		}}
+		created.Init(logger, map[string]interface{}{})
+		Expect(created.Connect()).Should(Succeed())
+		created.Insert(payload.New(map[string]interface{}{"name": "John", "age": 25}))
+		created.Insert(payload.New(map[string]interface{}{"name": "Marie", "age": 75}))
+		created.Disconnect()
+
+		adapter, err := openAdapter(uri, map[string]interface{}{}, nil, logger)
+		Expect(err).Should(BeNil())
+		defer adapter.Disconnect()
+		Expect(adapter.(*sqlite.Adapter).Columns).Should(ContainElement(sqlite.Column{Name: "age", Type: "integer"}))
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(2))
+		found := adapter.Find(payload.New(map[string]interface{}{"query": map[string]interface{}{"age": map[string]interface{}{"$gt": 30}}}))
+		Expect(found.Len()).Should(Equal(1))
+		Expect(found.First().Get("name").String()).Should(Equal("Marie"))
+	})
+
+	It("should open a SQLite table with the types of the declared columns", func() {
+		dir, err := ioutil.TempDir("", "cli")
+		Expect(err).Should(BeNil())
+		defer os.RemoveAll(dir)
+		uri := "sqlite://" + filepath.Join(dir, "users.db") + "?table=users"
+
+		created := &sqlite.Adapter{URI: "file:" + filepath.Join(dir, "users.db"), Table: "users", Columns: []sqlite.Column{
+			{Name: "name", Type: "string"},
+			{Name: "tags", Type: "[]string"},
+		}}
+		created.Init(logger, map[string]interface{}{})
+		Expect(created.Connect()).Should(Succeed())
+		created.Insert(payload.New(map[string]interface{}{"name": "John", "tags": []string{"a", "b"}}))
+		created.Disconnect()
+
+		adapter, err := openAdapter(uri, map[string]interface{}{}, nil, logger)
+		Expect(err).Should(BeNil())
+		Expect(undeclaredText(adapter.(*sqlite.Adapter), nil)).Should(Equal([]string{"name", "tags"}))
+		adapter.Disconnect()
+
+		declared := []sqlite.Column{{Name: "tags", Type: "[]string"}}
+		adapter, err = openAdapter(uri, map[string]interface{}{}, declared, logger)
+		Expect(err).Should(BeNil())
+		defer adapter.Disconnect()
+		Expect(undeclaredText(adapter.(*sqlite.Adapter), declared)).Should(Equal([]string{"name"}))
+		Expect(adapter.FindOne(payload.Empty()).Get("tags").StringArray()).Should(Equal([]string{"a", "b"}))
+
+		_, err = openAdapter(uri, map[string]interface{}{}, []sqlite.Column{{Name: "email", Type: "string"}}, logger)
+		Expect(err.Error()).Should(ContainSubstring("email is not a column of the table users"))
+	})
+})
@@ -0,0 +1,104 @@
+package conformance_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/bolt"
+	"github.com/moleculer-go/store/conformance"
+	"github.com/moleculer-go/store/mongo"
+	"github.com/moleculer-go/store/redis"
+	"github.com/moleculer-go/store/sqlite"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func memoryAdapter() store.Adapter {
+	return &store.MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+}
+
+func sqliteAdapter() store.Adapter {
+	return &sqlite.Adapter{
+		URI:   "file:memory:?mode=memory",
+		Table: "user",
+		Columns: []sqlite.Column{
+			{Name: "name", Type: "string"},
+			{Name: "lastname", Type: "string"},
+			{Name: "email", Type: "string"},
+			{Name: "master", Type: "string"},
+			{Name: "age", Type: "integer"},
+			{Name: "friends", Type: "[]string"},
+		},
+	}
+}
+
+func boltAdapter() store.Adapter {
+	return &bolt.Adapter{Path: filepath.Join(boltDir, "conformance.db"), Table: "user"}
+}
+
+var boltDir, _ = ioutil.TempDir("", "conformance")
+
+func redisAdapter() store.Adapter {
+	return &redis.Adapter{URL: "redis://" + redisServer.Addr(), Table: "user"}
+}
+
+var redisServer, _ = miniredis.Run()
+
+// mongoHost is the MONGO_TEST_HOST env var (e.g. localhost:27017). The Mongo specs only run when it is set.
+var mongoHost = os.Getenv("MONGO_TEST_HOST")
+
+func mongoAdapter() store.Adapter {
+	return &mongo.MongoAdapter{
+		MongoURL:   "mongodb://" + mongoHost,
+		Database:   "conformance_tests",
+		Collection: "user",
+		Timeout:    5 * time.Second,
+	}
+}
+
+var _ = AfterSuite(func() {
+	os.RemoveAll(boltDir)
+	redisServer.Close()
+})
+
+var _ = conformance.Describe("memory adapter", memoryAdapter, conformance.Options{Skip: []string{conformance.Sort}})
+
+var _ = conformance.Describe("sqlite adapter", sqliteAdapter, conformance.Options{})
+
+var _ = conformance.Describe("bolt adapter", boltAdapter, conformance.Options{})
+
+var _ = conformance.Describe("redis adapter", redisAdapter, conformance.Options{})
+
+// Mongo UpdateById returns the counts (modifiedCount and matchedCount) instead of the updated record.
+var _ = mongoHost != "" && conformance.Describe("mongo adapter", mongoAdapter, conformance.Options{
+	Skip: []string{conformance.UpdateReturnsRecord},
+})
+
+// The elastic adapter is not in the suite: it does not implement store.Adapter (FindById, FindByIds, Count,
+// Update and FindAndUpdate are missing).
+
+var _ = Describe("Probe", func() {
+
+	It("should report the capabilities of the memory adapter", func() {
+		report, err := conformance.Probe(memoryAdapter, conformance.Options{Skip: []string{conformance.Pool}})
+		Expect(err).Should(Succeed())
+		Expect(report.Supports(conformance.Search)).Should(BeTrue())
+		Expect(report.Supports(conformance.UniqueIndexes)).Should(BeTrue())
+		Expect(report.Supports(conformance.TypedErrors)).Should(BeTrue())
+		Expect(report.Supports(conformance.Tenants)).Should(BeTrue())
+		Expect(report.Supports(conformance.Sort)).Should(BeFalse())
+		Expect(report[conformance.Pool]).Should(Equal(conformance.ErrSkipped))
+		Expect(report.String()).Should(ContainSubstring("search: yes"))
+	})
+
+	It("should fail when the adapter can't be connected", func() {
+		_, err := conformance.Probe(func() store.Adapter {
+			return &sqlite.Adapter{URI: "file:/unknown/folder/conformance.db", Table: "user"}
+		}, conformance.Options{})
+		Expect(err).ShouldNot(Succeed())
+	})
+})
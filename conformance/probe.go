@@ -0,0 +1,405 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// Capabilities that adapters may not support. Probe reports them. The suite skips their specs when they are in
+// Options.Skip, and fails them when they are not supported.
+const (
+	// Sort the sort param of Find.
+	Sort = "sort"
+	// Offset the offset param of Find.
+	Offset = "offset"
+	// Search the search and searchFields params of Find.
+	Search = "search"
+	// UpdateOperators $inc, $push... in UpdateById.
+	UpdateOperators = "updateOperators"
+	// UpdateMany UpdateMany with a query.
+	UpdateMany = "updateMany"
+	// RemoveMany RemoveMany with a query.
+	RemoveMany = "removeMany"
+	// FindAndUpdate FindAndUpdate with a query.
+	FindAndUpdate = "findAndUpdate"
+	// UniqueIndexes unique indexes of the indexes setting reject duplicated values.
+	UniqueIndexes = "uniqueIndexes"
+	// TypedErrors UpdateById and RemoveById of unknown ids return store.CodeNotFound.
+	TypedErrors = "typedErrors"
+	// UpdateReturnsRecord UpdateById returns the updated record (otherwise counts).
+	UpdateReturnsRecord = "updateReturnsRecord"
+	// Indexes the adapter implements store.IndexAdapter.
+	Indexes = "indexes"
+	// Tenants the adapter implements store.TenantAdapter (tenancy perTable).
+	Tenants = "tenants"
+	// Health the adapter implements store.HealthAdapter.
+	Health = "health"
+	// Pool the adapter implements store.PoolAdapter.
+	Pool = "pool"
+	// Context the adapter implements store.ContextAdapter.
+	Context = "context"
+)
+
+// Factory returns a new adapter, not initialized. The records of the suite have the fields
+// name, lastname, email, master (strings), age (integer) and friends (list of strings).
+// Adapters with a schema (e.g. SQLite columns) must declare them, and search on name.
+type Factory func() store.Adapter
+
+// Options of Probe and Describe.
+type Options struct {
+	// Settings passed to Init. The indexes setting is set to a sparse unique index on email, unless UniqueIndexes is skipped.
+	Settings map[string]interface{}
+	// Skip lists the capabilities the adapter does not support. They are not probed nor tested.
+	Skip []string
+	// Concurrency is the number of goroutines of the concurrency spec. Default: 10
+	Concurrency int
+	// Logger of the adapter. Default: logrus with the error level.
+	Logger *log.Entry
+}
+
+// ErrSkipped is the reason of the capabilities in Options.Skip.
+var ErrSkipped = errors.New("skipped")
+
+// Report is the result of Probe: the error of each capability is nil when supported, or the reason it is not supported.
+type Report map[string]error
+
+// Supports returns true when the capability was probed and is supported.
+func (r Report) Supports(capability string) bool {
+	err, probed := r[capability]
+	return probed && err == nil
+}
+
+// String returns one line per capability, in alphabetical order. e.g. sort: yes, offset: no - wrong records: 6
+func (r Report) String() string {
+	names := []string{}
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{}
+	for _, name := range names {
+		if err := r[name]; err != nil {
+			lines = append(lines, name+": no - "+err.Error())
+		} else {
+			lines = append(lines, name+": yes")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Map returns the capabilities and true when supported.
+func (r Report) Map() map[string]bool {
+	result := map[string]bool{}
+	for name := range r {
+		result[name] = r.Supports(name)
+	}
+	return result
+}
+
+// users are the records loaded before each probe and spec.
+type users struct {
+	johnSnow, marie, johnTravolta moleculer.Payload
+}
+
+// loadUsers removes all records and inserts the users.
+func loadUsers(adapter store.Adapter) (users, error) {
+	if r := adapter.RemoveAll(); r.IsError() {
+		return users{}, r.Error()
+	}
+	insert := func(record map[string]interface{}) (moleculer.Payload, error) {
+		r := adapter.Insert(payload.New(record))
+		if r.IsError() {
+			return nil, r.Error()
+		}
+		return r, nil
+	}
+	var u users
+	var err error
+	if u.johnSnow, err = insert(map[string]interface{}{"name": "John", "lastname": "Snow", "age": 25}); err != nil {
+		return u, err
+	}
+	if u.marie, err = insert(map[string]interface{}{"name": "Marie", "lastname": "Claire", "age": 75, "master": u.johnSnow.Get("id").String()}); err != nil {
+		return u, err
+	}
+	if u.johnTravolta, err = insert(map[string]interface{}{
+		"name":     "John",
+		"lastname": "Travolta",
+		"age":      65,
+		"master":   u.johnSnow.Get("id").String(),
+		"friends":  []string{u.johnSnow.Get("id").String(), u.marie.Get("id").String()},
+	}); err != nil {
+		return u, err
+	}
+	for _, record := range []map[string]interface{}{
+		{"name": "Julian", "lastname": "Assange", "age": 46},
+		{"name": "Peter", "lastname": "Pan", "age": 13},
+		{"name": "Stone", "lastname": "Man", "age": 13},
+	} {
+		if _, err = insert(record); err != nil {
+			return u, err
+		}
+	}
+	return u, nil
+}
+
+// settings returns the settings of the adapter.
+func (opts Options) settings() map[string]interface{} {
+	settings := map[string]interface{}{}
+	if !opts.skips(UniqueIndexes) {
+		settings["indexes"] = []map[string]interface{}{{"fields": []string{"email"}, "unique": true, "sparse": true}}
+	}
+	for key, value := range opts.Settings {
+		settings[key] = value
+	}
+	return settings
+}
+
+func (opts Options) skips(capability string) bool {
+	for _, skipped := range opts.Skip {
+		if skipped == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts Options) logger() *log.Entry {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	return logger.WithField("store", "conformance")
+}
+
+// connect creates, initializes and connects an adapter of the factory.
+func connect(factory Factory, opts Options) (store.Adapter, error) {
+	adapter := factory()
+	adapter.Init(opts.logger(), opts.settings())
+	if err := adapter.Connect(); err != nil {
+		return nil, err
+	}
+	return adapter, nil
+}
+
+// probe checks a capability on an adapter loaded with the users.
+type probe func(adapter store.Adapter, u users) error
+
+// count returns the number of records matching the query.
+func count(adapter store.Adapter, query map[string]interface{}) (int, error) {
+	r := adapter.Count(payload.New(map[string]interface{}{"query": query}))
+	if r.IsError() {
+		return 0, r.Error()
+	}
+	return r.Int(), nil
+}
+
+// ages returns the ages of the records of Find.
+func ages(adapter store.Adapter, params map[string]interface{}) ([]int, error) {
+	r := adapter.Find(payload.New(params))
+	if r.IsError() {
+		return nil, r.Error()
+	}
+	list := []int{}
+	for _, record := range r.Array() {
+		list = append(list, record.Get("age").Int())
+	}
+	return list, nil
+}
+
+var probes = map[string]probe{
+	Sort: func(adapter store.Adapter, u users) error {
+		ascending, err := ages(adapter, map[string]interface{}{"sort": "age"})
+		if err != nil {
+			return err
+		}
+		descending, err := ages(adapter, map[string]interface{}{"sort": "-age"})
+		if err != nil {
+			return err
+		}
+		if !sort.IntsAreSorted(ascending) || !sort.IsSorted(sort.Reverse(sort.IntSlice(descending))) {
+			return fmt.Errorf("wrong order: %v %v", ascending, descending)
+		}
+		return nil
+	},
+	Offset: func(adapter store.Adapter, u users) error {
+		list, err := ages(adapter, map[string]interface{}{"offset": 4})
+		if err == nil && len(list) != 2 {
+			err = fmt.Errorf("wrong records: %d", len(list))
+		}
+		return err
+	},
+	Search: func(adapter store.Adapter, u users) error {
+		list, err := ages(adapter, map[string]interface{}{"search": "John", "searchFields": []string{"name"}})
+		if err == nil && len(list) != 2 {
+			err = fmt.Errorf("wrong records: %d", len(list))
+		}
+		return err
+	},
+	UpdateOperators: func(adapter store.Adapter, u users) error {
+		if r := adapter.UpdateById(u.johnSnow.Get("id"), payload.New(map[string]interface{}{"$inc": map[string]interface{}{"age": 1}})); r.IsError() {
+			return r.Error()
+		}
+		if age := adapter.FindById(u.johnSnow.Get("id")).Get("age").Int(); age != 26 {
+			return fmt.Errorf("wrong age: %d", age)
+		}
+		return nil
+	},
+	UpdateMany: func(adapter store.Adapter, u users) error {
+		r := adapter.UpdateMany(payload.New(map[string]interface{}{
+			"query":  map[string]interface{}{"age": 13},
+			"update": map[string]interface{}{"lastname": "Kid"},
+		}))
+		if r.IsError() {
+			return r.Error()
+		}
+		kids, err := count(adapter, map[string]interface{}{"lastname": "Kid"})
+		if err == nil && kids != 2 {
+			err = fmt.Errorf("wrong records: %d", kids)
+		}
+		return err
+	},
+	RemoveMany: func(adapter store.Adapter, u users) error {
+		if r := adapter.RemoveMany(payload.New(map[string]interface{}{"query": map[string]interface{}{"age": 13}})); r.IsError() {
+			return r.Error()
+		}
+		total, err := count(adapter, nil)
+		if err == nil && total != 4 {
+			err = fmt.Errorf("wrong records: %d", total)
+		}
+		return err
+	},
+	FindAndUpdate: func(adapter store.Adapter, u users) error {
+		r := adapter.FindAndUpdate(payload.New(map[string]interface{}{
+			"query":  map[string]interface{}{"name": "Marie"},
+			"update": map[string]interface{}{"age": 76},
+		}))
+		if r.IsError() {
+			return r.Error()
+		}
+		if age := adapter.FindById(u.marie.Get("id")).Get("age").Int(); age != 76 {
+			return fmt.Errorf("wrong age: %d", age)
+		}
+		return nil
+	},
+	UniqueIndexes: func(adapter store.Adapter, u users) error {
+		record := payload.New(map[string]interface{}{"name": "Unique", "email": "unique@conformance.test"})
+		if r := adapter.Insert(record); r.IsError() {
+			return r.Error()
+		}
+		if r := adapter.Insert(record); !r.IsError() {
+			return errors.New("duplicated value inserted")
+		}
+		return nil
+	},
+	TypedErrors: func(adapter store.Adapter, u users) error {
+		removed := adapter.Insert(payload.New(map[string]interface{}{"name": "Removed"}))
+		if removed.IsError() {
+			return removed.Error()
+		}
+		if r := adapter.RemoveById(removed.Get("id")); r.IsError() {
+			return r.Error()
+		}
+		if err := adapter.UpdateById(removed.Get("id"), payload.New(map[string]interface{}{"age": 1})).Error(); !store.IsNotFound(err) {
+			return fmt.Errorf("UpdateById of an unknown id - error: %v", err)
+		}
+		if err := adapter.RemoveById(removed.Get("id")).Error(); !store.IsNotFound(err) {
+			return fmt.Errorf("RemoveById of an unknown id - error: %v", err)
+		}
+		return nil
+	},
+	UpdateReturnsRecord: func(adapter store.Adapter, u users) error {
+		r := adapter.UpdateById(u.marie.Get("id"), payload.New(map[string]interface{}{"age": 80}))
+		if r.IsError() {
+			return r.Error()
+		}
+		if r.Get("name").String() != "Marie" || r.Get("age").Int() != 80 {
+			return fmt.Errorf("returns: %v", r)
+		}
+		return nil
+	},
+	Indexes: func(adapter store.Adapter, u users) error {
+		if _, ok := adapter.(store.IndexAdapter); !ok {
+			return errors.New("store.IndexAdapter not implemented")
+		}
+		return nil
+	},
+	Tenants: func(adapter store.Adapter, u users) error {
+		tenantAdapter, ok := adapter.(store.TenantAdapter)
+		if !ok {
+			return errors.New("store.TenantAdapter not implemented")
+		}
+		if tenantAdapter.ForTenant("conformance") == nil {
+			return errors.New("ForTenant returns nil")
+		}
+		return nil
+	},
+	Health: func(adapter store.Adapter, u users) error {
+		if _, ok := adapter.(store.HealthAdapter); !ok {
+			return errors.New("store.HealthAdapter not implemented")
+		}
+		return store.Ping(adapter)
+	},
+	Pool: func(adapter store.Adapter, u users) error {
+		pool, ok := adapter.(store.PoolAdapter)
+		if !ok {
+			return errors.New("store.PoolAdapter not implemented")
+		}
+		if pool.PoolStats() == nil {
+			return errors.New("PoolStats returns nil")
+		}
+		return nil
+	},
+	Context: func(adapter store.Adapter, u users) error {
+		if _, ok := adapter.(store.ContextAdapter); !ok {
+			return errors.New("store.ContextAdapter not implemented")
+		}
+		r := store.WithContext(adapter, context.Background()).Count(payload.Empty())
+		if r.IsError() {
+			return r.Error()
+		}
+		return nil
+	},
+}
+
+// Probe connects an adapter of the factory and checks the capabilities it supports.
+// Returns an error when the adapter can't be connected or loaded with the records of the suite.
+func Probe(factory Factory, opts Options) (Report, error) {
+	adapter, err := connect(factory, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer adapter.Disconnect()
+	report := Report{}
+	for capability, check := range probes {
+		if opts.skips(capability) {
+			report[capability] = ErrSkipped
+			continue
+		}
+		u, err := loadUsers(adapter)
+		if err != nil {
+			return nil, err
+		}
+		report[capability] = runProbe(check, adapter, u)
+	}
+	adapter.RemoveAll()
+	return report, nil
+}
+
+// runProbe runs the probe, returning panics as errors.
+func runProbe(check probe, adapter store.Adapter, u users) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return check(adapter, u)
+}
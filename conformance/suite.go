@@ -0,0 +1,313 @@
+package conformance
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/broker"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type M map[string]interface{}
+
+// Describe registers the conformance specs of the adapters of the factory, to be run with ginkgo:
+//
+//	var _ = conformance.Describe("my adapter", func() store.Adapter {
+//		return &MyAdapter{Table: "user"}
+//	}, conformance.Options{})
+//
+// The specs of the capabilities in Options.Skip are skipped. The other capabilities must be supported (see Probe),
+// so a regression of the adapter fails the specs instead of skipping them.
+func Describe(name string, factory Factory, opts Options) bool {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+	return ginkgo.Describe("Conformance: "+name, func() {
+		var report Report
+		var adapter store.Adapter
+		var johnSnow, marie, johnTravolta moleculer.Payload
+
+		ginkgo.BeforeEach(func() {
+			if report == nil {
+				var err error
+				report, err = Probe(factory, opts)
+				Expect(err).Should(Succeed())
+			}
+			var err error
+			adapter, err = connect(factory, opts)
+			Expect(err).Should(Succeed())
+			u, err := loadUsers(adapter)
+			Expect(err).Should(Succeed())
+			johnSnow, marie, johnTravolta = u.johnSnow, u.marie, u.johnTravolta
+		})
+		ginkgo.AfterEach(func() {
+			adapter.RemoveAll()
+			adapter.Disconnect()
+		})
+
+		requires := func(capability string) {
+			if opts.skips(capability) {
+				ginkgo.Skip(capability + " skipped by Options.Skip")
+			}
+			Expect(report[capability]).Should(Succeed(), capability+" not supported. Add it to Options.Skip if the adapter does not support it")
+		}
+
+		find := func(params M) moleculer.Payload {
+			r := adapter.Find(payload.New(map[string]interface{}(params)))
+			Expect(r.Error()).Should(Succeed())
+			return r
+		}
+
+		lastnames := func(list moleculer.Payload) []string {
+			result := []string{}
+			for _, record := range list.Array() {
+				result = append(result, record.Get("lastname").String())
+			}
+			sort.Strings(result)
+			return result
+		}
+
+		ginkgo.It("should report the capabilities", func() {
+			fmt.Fprintf(ginkgo.GinkgoWriter, "%s capabilities:\n%s\n", name, report)
+			Expect(report).Should(HaveLen(len(probes)))
+		})
+
+		ginkgo.Describe("CRUD", func() {
+			ginkgo.It("should insert and find records by id", func() {
+				Expect(johnSnow.Get("id").Exists()).Should(BeTrue())
+				Expect(johnSnow.Get("name").String()).Should(Equal("John"))
+
+				r := adapter.FindById(marie.Get("id"))
+				Expect(r.Error()).Should(Succeed())
+				Expect(r.Get("lastname").String()).Should(Equal("Claire"))
+				Expect(r.Get("age").Int()).Should(Equal(75))
+				Expect(r.Get("master").String()).Should(Equal(johnSnow.Get("id").String()))
+
+				list := adapter.FindByIds(payload.New([]interface{}{
+					johnSnow.Get("id").Value(), johnTravolta.Get("id").Value(),
+				}))
+				Expect(list.Error()).Should(Succeed())
+				Expect(lastnames(list)).Should(Equal([]string{"Snow", "Travolta"}))
+			})
+
+			ginkgo.It("should update records by id", func() {
+				r := adapter.UpdateById(marie.Get("id"), payload.New(M{"age": 76, "lastname": "Claire II"}))
+				Expect(r.Error()).Should(Succeed())
+
+				r = adapter.FindById(marie.Get("id"))
+				Expect(r.Get("age").Int()).Should(Equal(76))
+				Expect(r.Get("lastname").String()).Should(Equal("Claire II"))
+				Expect(r.Get("name").String()).Should(Equal("Marie"))
+			})
+
+			ginkgo.It("should remove records by id", func() {
+				Expect(adapter.RemoveById(marie.Get("id")).Error()).Should(Succeed())
+				Expect(adapter.FindById(marie.Get("id")).Exists()).Should(BeFalse())
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(5))
+			})
+
+			ginkgo.It("should remove all records", func() {
+				Expect(adapter.RemoveAll().Error()).Should(Succeed())
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(0))
+				Expect(adapter.Find(payload.Empty()).Len()).Should(Equal(0))
+			})
+		})
+
+		ginkgo.Describe("Query DSL", func() {
+			queries := []struct {
+				query     M
+				lastnames []string
+			}{
+				{M{"name": "John"}, []string{"Snow", "Travolta"}},
+				{M{"age": M{"$gt": 46}}, []string{"Claire", "Travolta"}},
+				{M{"age": M{"$lte": 13}}, []string{"Man", "Pan"}},
+				{M{"lastname": M{"$in": []string{"Pan", "Snow", "Unknown"}}}, []string{"Pan", "Snow"}},
+				{M{"age": M{"$ne": 13}}, []string{"Assange", "Claire", "Snow", "Travolta"}},
+				{M{"age": M{"$nin": []int{13, 25}}}, []string{"Assange", "Claire", "Travolta"}},
+				{M{"age": M{"$exists": false}}, []string{"Doe"}},
+				{M{"age": M{"$not": M{"$lte": 25}}}, []string{"Assange", "Claire", "Doe", "Travolta"}},
+				{M{"$or": []M{{"name": "Marie"}, {"age": 46}}}, []string{"Assange", "Claire"}},
+				{M{"name": "John", "age": M{"$gte": 30}}, []string{"Travolta"}},
+				{M{"name": "Nobody"}, []string{}},
+			}
+
+			ginkgo.It("should find and count the records matching the queries", func() {
+				// a record without age: $ne and $nin only match the records with a value, as SQL and Mongo,
+				// while $not matches the records without the field, as Mongo
+				Expect(adapter.Insert(payload.New(M{"name": "Jane", "lastname": "Doe"})).Error()).Should(Succeed())
+				for _, q := range queries {
+					query := map[string]interface{}(q.query)
+					Expect(lastnames(find(M{"query": query}))).Should(Equal(q.lastnames), fmt.Sprint("query: ", query))
+
+					r := adapter.Count(payload.New(M{"query": query}))
+					Expect(r.Error()).Should(Succeed())
+					Expect(r.Int()).Should(Equal(len(q.lastnames)), fmt.Sprint("count: ", query))
+				}
+			})
+
+			ginkgo.It("should limit the records", func() {
+				Expect(find(M{"limit": 2}).Len()).Should(Equal(2))
+				Expect(find(M{"limit": 10, "query": M{"name": "John"}}).Len()).Should(Equal(2))
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+			})
+
+			ginkgo.It("should sort the records", func() {
+				requires(Sort)
+				list := find(M{"sort": "-age", "limit": 3})
+				Expect(list.Len()).Should(Equal(3))
+				Expect(list.First().Get("lastname").String()).Should(Equal("Claire"))
+				Expect(list.Array()[2].Get("lastname").String()).Should(Equal("Assange"))
+			})
+
+			ginkgo.It("should skip the records of the offset", func() {
+				requires(Offset)
+				if report.Supports(Sort) {
+					list := find(M{"sort": "age", "offset": 3, "limit": 2})
+					Expect(list.Len()).Should(Equal(2))
+					Expect(list.First().Get("lastname").String()).Should(Equal("Assange"))
+					Expect(list.Array()[1].Get("lastname").String()).Should(Equal("Travolta"))
+				}
+				Expect(find(M{"offset": 5}).Len()).Should(Equal(1))
+			})
+
+			ginkgo.It("should search the records", func() {
+				requires(Search)
+				Expect(lastnames(find(M{"search": "Marie", "searchFields": []string{"name"}}))).Should(Equal([]string{"Claire"}))
+			})
+		})
+
+		ginkgo.Describe("Updates", func() {
+			ginkgo.It("should update with operators", func() {
+				requires(UpdateOperators)
+				r := adapter.UpdateById(johnSnow.Get("id"), payload.New(M{"$inc": M{"age": 5}}))
+				Expect(r.Error()).Should(Succeed())
+				Expect(adapter.FindById(johnSnow.Get("id")).Get("age").Int()).Should(Equal(30))
+			})
+
+			ginkgo.It("should return the updated record", func() {
+				requires(UpdateReturnsRecord)
+				r := adapter.UpdateById(johnSnow.Get("id"), payload.New(M{"age": 26}))
+				Expect(r.Get("age").Int()).Should(Equal(26))
+				Expect(r.Get("lastname").String()).Should(Equal("Snow"))
+			})
+
+			ginkgo.It("should update many records", func() {
+				requires(UpdateMany)
+				r := adapter.UpdateMany(payload.New(M{"query": M{"name": "John"}, "update": M{"age": 50}}))
+				Expect(r.Error()).Should(Succeed())
+				Expect(lastnames(find(M{"query": M{"age": 50}}))).Should(Equal([]string{"Snow", "Travolta"}))
+			})
+
+			ginkgo.It("should remove many records", func() {
+				requires(RemoveMany)
+				r := adapter.RemoveMany(payload.New(M{"query": M{"name": "John"}}))
+				Expect(r.Error()).Should(Succeed())
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(4))
+			})
+
+			ginkgo.It("should find and update a record", func() {
+				requires(FindAndUpdate)
+				r := adapter.FindAndUpdate(payload.New(M{"query": M{"lastname": "Pan"}, "update": M{"age": 14}}))
+				Expect(r.Error()).Should(Succeed())
+				Expect(find(M{"query": M{"lastname": "Pan"}}).First().Get("age").Int()).Should(Equal(14))
+			})
+		})
+
+		ginkgo.Describe("Errors", func() {
+			ginkgo.It("should return not found for unknown ids", func() {
+				requires(TypedErrors)
+				Expect(adapter.RemoveById(marie.Get("id")).Error()).Should(Succeed())
+				Expect(store.IsNotFound(adapter.RemoveById(marie.Get("id")).Error())).Should(BeTrue())
+				Expect(store.IsNotFound(adapter.UpdateById(marie.Get("id"), payload.New(M{"age": 1})).Error())).Should(BeTrue())
+			})
+
+			ginkgo.It("should return conflict for duplicated values of unique indexes", func() {
+				requires(UniqueIndexes)
+				Expect(adapter.UpdateById(marie.Get("id"), payload.New(M{"email": "marie@conformance.test"})).Error()).Should(Succeed())
+				r := adapter.Insert(payload.New(M{"name": "Marie", "lastname": "Copy", "email": "marie@conformance.test"}))
+				Expect(r.IsError()).Should(BeTrue())
+				if report.Supports(TypedErrors) {
+					Expect(store.IsConflict(r.Error())).Should(BeTrue())
+				}
+			})
+
+			ginkgo.It("should return the invalid queries as errors", func() {
+				r := adapter.Find(payload.New(M{"query": M{"age": M{"$unknown": 1}}}))
+				Expect(r.IsError()).Should(BeTrue())
+				if report.Supports(TypedErrors) {
+					Expect(store.IsValidation(r.Error())).Should(BeTrue())
+				}
+			})
+		})
+
+		ginkgo.Describe("Concurrency", func() {
+			ginkgo.It("should insert and update records concurrently", func() {
+				var wg sync.WaitGroup
+				errs := make(chan error, opts.Concurrency*2)
+				for i := 0; i < opts.Concurrency; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						if r := adapter.Insert(payload.New(M{"name": "Clone", "lastname": fmt.Sprint("Clone ", i), "age": i})); r.IsError() {
+							errs <- r.Error()
+						}
+						if r := adapter.UpdateById(johnTravolta.Get("id"), payload.New(M{"age": 100 + i})); r.IsError() {
+							errs <- r.Error()
+						}
+					}(i)
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					Expect(err).Should(Succeed())
+				}
+				Expect(adapter.Count(payload.New(M{"query": M{"name": "Clone"}})).Int()).Should(Equal(opts.Concurrency))
+				age := adapter.FindById(johnTravolta.Get("id")).Get("age").Int()
+				Expect(age >= 100 && age < 100+opts.Concurrency).Should(BeTrue())
+			})
+		})
+
+		ginkgo.Describe("Mixin", func() {
+			var bkr *broker.ServiceBroker
+			ginkgo.BeforeEach(func() {
+				settings := opts.settings()
+				settings["populates"] = map[string]interface{}{"friends": "user.get", "master": "user.get"}
+				mixinAdapter := factory()
+				bkr = broker.New(&moleculer.Config{LogLevel: "error"})
+				bkr.Publish(moleculer.ServiceSchema{
+					Name:     "user",
+					Settings: settings,
+					Mixins:   []moleculer.Mixin{store.Mixin(mixinAdapter)},
+					Started: func(moleculer.BrokerContext, moleculer.ServiceSchema) {
+						u, err := loadUsers(mixinAdapter)
+						Expect(err).Should(Succeed())
+						johnSnow, marie, johnTravolta = u.johnSnow, u.marie, u.johnTravolta
+					},
+				})
+				bkr.Start()
+			})
+			ginkgo.AfterEach(func() {
+				bkr.Stop()
+			})
+
+			ginkgo.It("should populate the records", func() {
+				r := <-bkr.Call("user.get", M{"id": johnTravolta.Get("id").Value(), "populate": []string{"friends", "master"}})
+				Expect(r.Error()).Should(Succeed())
+				Expect(r.Get("master").Get("lastname").String()).Should(Equal("Snow"))
+				friends := r.Get("friends")
+				Expect(friends.Len()).Should(Equal(2))
+				Expect(lastnames(friends)).Should(Equal([]string{"Claire", "Snow"}))
+			})
+
+			ginkgo.It("should return typed errors from the actions", func() {
+				r := <-bkr.Call("user.update", M{"name": "Nobody"})
+				Expect(store.IsValidation(r.Error())).Should(BeTrue())
+			})
+		})
+	})
+}
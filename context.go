@@ -0,0 +1,249 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	log "github.com/sirupsen/logrus"
+)
+
+// ContextAdapter is implemented by the adapters that pass a context.Context to the database,
+// so the deadline and cancellation of the caller reach the database calls.
+type ContextAdapter interface {
+	// WithContext returns the adapter with the operations bound to the context.
+	WithContext(ctx context.Context) Adapter
+}
+
+// WithContext returns the adapter bound to the context.
+// Adapters that don't implement ContextAdapter are returned as they are.
+func WithContext(adapter Adapter, ctx context.Context) Adapter {
+	if contextAdapter, ok := adapter.(ContextAdapter); ok && ctx != nil {
+		return contextAdapter.WithContext(ctx)
+	}
+	return adapter
+}
+
+// AdapterV2 is the Adapter interface with a context.Context in each operation.
+// Use AdapterFromV2 to use an AdapterV2 in the Mixin, and AdapterToV2 to call an Adapter with a context.
+type AdapterV2 interface {
+	Init(*log.Entry, map[string]interface{})
+	Connect(ctx context.Context) error
+	Disconnect(ctx context.Context) error
+	Find(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	FindAndUpdate(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	FindOne(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	FindById(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	FindByIds(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	Count(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	Insert(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	Update(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	UpdateById(ctx context.Context, id, update moleculer.Payload) moleculer.Payload
+	UpdateMany(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	RemoveById(ctx context.Context, id moleculer.Payload) moleculer.Payload
+	RemoveMany(ctx context.Context, params moleculer.Payload) moleculer.Payload
+	RemoveAll(ctx context.Context) moleculer.Payload
+}
+
+// AdapterToV2 returns the AdapterV2 of an Adapter. Each operation is bound to its context with WithContext.
+// Adapters that don't implement ContextAdapter are not called when the context is done,
+// and the operation returns the context error (CodeTimeout) when the context is done before the adapter returns.
+func AdapterToV2(adapter Adapter) AdapterV2 {
+	if v2, ok := adapter.(*v2Adapter); ok {
+		return v2.adapter
+	}
+	return &v1Adapter{adapter}
+}
+
+// AdapterFromV2 returns the Adapter of an AdapterV2. The operations use context.Background(),
+// or the context of WithContext, so the Mixin passes the context of each action.
+func AdapterFromV2(adapter AdapterV2) Adapter {
+	return &v2Adapter{adapter, context.Background()}
+}
+
+type v1Adapter struct {
+	adapter Adapter
+}
+
+// run calls the operation on the adapter bound to the context.
+func (v *v1Adapter) run(ctx context.Context, operation func(adapter Adapter) moleculer.Payload) moleculer.Payload {
+	if err := ctx.Err(); err != nil {
+		return payload.New(WrapError(CodeTimeout, err))
+	}
+	bound := WithContext(v.adapter, ctx)
+	if _, ok := v.adapter.(ContextAdapter); ok || ctx.Done() == nil {
+		return operation(bound)
+	}
+	result := make(chan moleculer.Payload, 1)
+	go func() {
+		result <- operation(bound)
+	}()
+	select {
+	case r := <-result:
+		return r
+	case <-ctx.Done():
+		return payload.New(WrapError(CodeTimeout, ctx.Err()))
+	}
+}
+
+func (v *v1Adapter) Init(logger *log.Entry, settings map[string]interface{}) {
+	v.adapter.Init(logger, settings)
+}
+
+func (v *v1Adapter) Connect(ctx context.Context) error {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return errorResult(adapter.Connect()) }).Error()
+}
+
+func (v *v1Adapter) Disconnect(ctx context.Context) error {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return errorResult(adapter.Disconnect()) }).Error()
+}
+
+func (v *v1Adapter) Find(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.Find(params) })
+}
+
+func (v *v1Adapter) FindAndUpdate(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.FindAndUpdate(params) })
+}
+
+func (v *v1Adapter) FindOne(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.FindOne(params) })
+}
+
+func (v *v1Adapter) FindById(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.FindById(params) })
+}
+
+func (v *v1Adapter) FindByIds(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.FindByIds(params) })
+}
+
+func (v *v1Adapter) Count(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.Count(params) })
+}
+
+func (v *v1Adapter) Insert(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.Insert(params) })
+}
+
+func (v *v1Adapter) Update(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.Update(params) })
+}
+
+func (v *v1Adapter) UpdateById(ctx context.Context, id, update moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.UpdateById(id, update) })
+}
+
+func (v *v1Adapter) UpdateMany(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.UpdateMany(params) })
+}
+
+func (v *v1Adapter) RemoveById(ctx context.Context, id moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.RemoveById(id) })
+}
+
+func (v *v1Adapter) RemoveMany(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.RemoveMany(params) })
+}
+
+func (v *v1Adapter) RemoveAll(ctx context.Context) moleculer.Payload {
+	return v.run(ctx, func(adapter Adapter) moleculer.Payload { return adapter.RemoveAll() })
+}
+
+type v2Adapter struct {
+	adapter AdapterV2
+	ctx     context.Context
+}
+
+// WithContext returns the adapter with the operations called with the context.
+func (v *v2Adapter) WithContext(ctx context.Context) Adapter {
+	return &v2Adapter{v.adapter, ctx}
+}
+
+func (v *v2Adapter) Init(logger *log.Entry, settings map[string]interface{}) {
+	v.adapter.Init(logger, settings)
+}
+
+func (v *v2Adapter) Connect() error {
+	return v.adapter.Connect(v.ctx)
+}
+
+func (v *v2Adapter) Disconnect() error {
+	return v.adapter.Disconnect(v.ctx)
+}
+
+func (v *v2Adapter) Find(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.Find(v.ctx, params)
+}
+
+func (v *v2Adapter) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.FindAndUpdate(v.ctx, params)
+}
+
+func (v *v2Adapter) FindOne(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.FindOne(v.ctx, params)
+}
+
+func (v *v2Adapter) FindById(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.FindById(v.ctx, params)
+}
+
+func (v *v2Adapter) FindByIds(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.FindByIds(v.ctx, params)
+}
+
+func (v *v2Adapter) Count(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.Count(v.ctx, params)
+}
+
+func (v *v2Adapter) Insert(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.Insert(v.ctx, params)
+}
+
+func (v *v2Adapter) Update(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.Update(v.ctx, params)
+}
+
+func (v *v2Adapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	return v.adapter.UpdateById(v.ctx, id, update)
+}
+
+func (v *v2Adapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.UpdateMany(v.ctx, params)
+}
+
+func (v *v2Adapter) RemoveById(id moleculer.Payload) moleculer.Payload {
+	return v.adapter.RemoveById(v.ctx, id)
+}
+
+func (v *v2Adapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	return v.adapter.RemoveMany(v.ctx, params)
+}
+
+func (v *v2Adapter) RemoveAll() moleculer.Payload {
+	return v.adapter.RemoveAll(v.ctx)
+}
+
+// ActionContext returns the context.Context of the adapter operations of an action.
+// The deadline and cancellation come from the moleculer context, when it provides them (implements
+// context.Context or has a Context() context.Context method). Otherwise the deadline is the timeout
+// meta (milliseconds) of the call, or the timeout setting of the service. The cancel func must be called.
+func ActionContext(ctx moleculer.Context, settings map[string]interface{}) (context.Context, context.CancelFunc) {
+	var parent context.Context = context.Background()
+	switch source := ctx.(type) {
+	case context.Context:
+		parent = source
+	case interface{ Context() context.Context }:
+		parent = source.Context()
+	}
+	if _, hasDeadline := parent.Deadline(); !hasDeadline {
+		if meta := ctx.Meta(); meta != nil && meta.Get("timeout").Exists() && meta.Get("timeout").Int() > 0 {
+			return context.WithTimeout(parent, time.Duration(meta.Get("timeout").Int())*time.Millisecond)
+		}
+		if timeout, ok := durationSetting(settings["timeout"]); ok && timeout > 0 {
+			return context.WithTimeout(parent, timeout)
+		}
+	}
+	return context.WithCancel(parent)
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// metaContext is a moleculer context with the meta of the call.
+type metaContext struct {
+	moleculer.Context
+	meta moleculer.Payload
+}
+
+func (c metaContext) Meta() moleculer.Payload {
+	return c.meta
+}
+
+// slowAdapter waits before each Find.
+type slowAdapter struct {
+	*MemoryAdapter
+	delay time.Duration
+}
+
+func (adapter *slowAdapter) Find(params moleculer.Payload) moleculer.Payload {
+	time.Sleep(adapter.delay)
+	return adapter.MemoryAdapter.Find(params)
+}
+
+// contextRecorder is an AdapterV2 that records the context of the operations.
+type contextRecorder struct {
+	AdapterV2
+	contexts []context.Context
+}
+
+func (r *contextRecorder) Find(ctx context.Context, params moleculer.Payload) moleculer.Payload {
+	r.contexts = append(r.contexts, ctx)
+	return r.AdapterV2.Find(ctx, params)
+}
+
+var _ = Describe("Context", func() {
+
+	var adapter *MemoryAdapter
+	BeforeEach(func() {
+		adapter = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+		mocks.ConnectAndLoadUsers(adapter)
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+
+	It("AdapterToV2 should call the adapter and fail when the context is done", func() {
+		v2 := AdapterToV2(adapter)
+		johns := payload.New(M{"query": M{"name": "John"}})
+		Expect(v2.Find(context.Background(), johns).Len()).Should(Equal(2))
+		Expect(v2.Count(context.Background(), payload.Empty()).Int()).Should(Equal(6))
+
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := v2.Find(cancelled, johns)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(ErrorCode(r.Error())).Should(Equal(CodeTimeout))
+		Expect(r.Error().(*Error).Err).Should(Equal(context.Canceled))
+	})
+
+	It("AdapterToV2 should return when the deadline expires before the adapter", func() {
+		v2 := AdapterToV2(&slowAdapter{adapter, 200 * time.Millisecond})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		r := v2.Find(ctx, payload.Empty())
+		Expect(time.Since(start) < 200*time.Millisecond).Should(BeTrue())
+		Expect(IsTimeout(r.Error())).Should(BeTrue())
+		Expect(r.Error().(*Error).Err).Should(Equal(context.DeadlineExceeded))
+	})
+
+	It("AdapterFromV2 should pass the context of WithContext to the operations", func() {
+		recorder := &contextRecorder{AdapterV2: AdapterToV2(adapter)}
+		v1 := AdapterFromV2(recorder)
+		Expect(AdapterToV2(v1)).Should(BeIdenticalTo(recorder))
+
+		Expect(v1.Find(payload.Empty()).Len()).Should(Equal(6))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		Expect(WithContext(v1, ctx).Find(payload.Empty()).Len()).Should(Equal(6))
+		Expect(recorder.contexts).Should(Equal([]context.Context{context.Background(), ctx}))
+	})
+
+	It("WithContext should pass the context to the middlewares and keep the cache entries", func() {
+		var contexts []context.Context
+		wrapped := Wrap(adapter, func(call *Call, next Next) moleculer.Payload {
+			contexts = append(contexts, call.Context)
+			return next(call)
+		})
+		cache := &CacheAdapter{Adapter: wrapped, Size: 10, TTL: time.Minute}
+		cache.Init(log.WithField("test", "context"), M{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		Expect(WithContext(cache, ctx).Find(payload.Empty()).Len()).Should(Equal(6))
+		Expect(cache.Find(payload.Empty()).Len()).Should(Equal(6))
+		Expect(contexts).Should(Equal([]context.Context{ctx}))
+	})
+
+	It("scopedAction should pass the timeout to the adapter of the tenant", func() {
+		var deadlines []bool
+		wrapped := Wrap(adapter, func(call *Call, next Next) moleculer.Payload {
+			ok := false
+			if call.Context != nil {
+				_, ok = call.Context.Deadline()
+			}
+			deadlines = append(deadlines, ok)
+			return next(call)
+		})
+		brokerContext, _ := contextAndDelegated("context-test", moleculer.Config{})
+		svc := &moleculer.ServiceSchema{Name: "user", Settings: M{"tenancy": true, "timeout": 1000, "fields": []string{"**"}}}
+		find := scopedAction(wrapped, func() *moleculer.ServiceSchema { return svc }, &tenantAdapters{}, findAction)
+		ctx := metaContext{brokerContext.(moleculer.Context), payload.New(M{"tenantId": "t1"})}
+		r := find(ctx, payload.Empty()).(moleculer.Payload)
+		Expect(r.Error()).Should(BeNil())
+		Expect(deadlines).Should(Equal([]bool{true}))
+	})
+
+	It("ActionContext should use the timeout meta, then the timeout setting", func() {
+		brokerContext, _ := contextAndDelegated("context-test", moleculer.Config{})
+		ctx := brokerContext.(moleculer.Context)
+
+		operation, cancel := ActionContext(metaContext{ctx, payload.New(M{"timeout": 1000})}, M{"timeout": 60000})
+		deadline, ok := operation.Deadline()
+		cancel()
+		Expect(ok).Should(BeTrue())
+		Expect(time.Until(deadline) <= time.Second).Should(BeTrue())
+		Expect(operation.Err()).Should(Equal(context.Canceled))
+
+		operation, cancel = ActionContext(metaContext{ctx, payload.Empty()}, M{"timeout": 60000})
+		deadline, ok = operation.Deadline()
+		cancel()
+		Expect(ok).Should(BeTrue())
+		Expect(time.Until(deadline) > time.Second).Should(BeTrue())
+
+		operation, cancel = ActionContext(metaContext{ctx, nil}, M{"timeout": 0})
+		_, ok = operation.Deadline()
+		cancel()
+		Expect(ok).Should(BeFalse())
+	})
+})
@@ -0,0 +1,455 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// IDMap maps the ids of the source records (as strings) to the ids of the copied records.
+type IDMap map[string]interface{}
+
+// Get returns the id of the copied record.
+func (m IDMap) Get(sourceID interface{}) (interface{}, bool) {
+	id, ok := m[fmt.Sprint(sourceID)]
+	return id, ok
+}
+
+// CopyOptions are the options of Copy and Verify.
+type CopyOptions struct {
+	// Service is the name of the copied service. References (populates) to this service use the id map of the copy.
+	Service string
+	// IDField default: id
+	IDField string
+	// BatchSize is the number of records read from the source at a time. Default: 100
+	BatchSize int
+	// ID returns the id of the copied record, from the id of the source record.
+	// Default: nil, the target adapter assigns the ids.
+	// Only adapters that keep the inserted id use it (SQLite). Memory and Mongo always assign new ids.
+	ID func(sourceID interface{}) interface{}
+	// Populates is the populates setting of the service. The reference fields are rewritten with the id maps.
+	Populates map[string]interface{}
+	// IDMaps has the id maps of the other services, by service name, to rewrite the references to them.
+	IDMaps map[string]IDMap
+	// IgnoreFields are not copied and not verified.
+	IgnoreFields []string
+	// State is the file where the id map is saved, one record at a time.
+	// An interrupted copy with the same State resumes, skipping the records already copied.
+	// A record is saved after it is inserted, so without ID the record being copied when the copy was
+	// interrupted can be copied twice (at-least-once). With ID the records already in the target are skipped.
+	State string
+	// Progress is called after each batch with the number of records copied and the total.
+	Progress func(done, total int)
+}
+
+// CopyResult is the result of Copy.
+type CopyResult struct {
+	Copied int
+	// Skipped is the number of records copied before the copy was resumed.
+	Skipped int
+	Failed  int
+	// Unresolved is the number of references not found in the id maps. They keep the source id.
+	Unresolved int
+	// Errors has the first 10 errors.
+	Errors []string
+	IDMap  IDMap
+}
+
+// Map returns the result as a map.
+func (r CopyResult) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"copied":     r.Copied,
+		"skipped":    r.Skipped,
+		"failed":     r.Failed,
+		"unresolved": r.Unresolved,
+		"errors":     r.Errors,
+	}
+}
+
+func (r *CopyResult) addError(err string) {
+	if len(r.Errors) < 10 {
+		r.Errors = append(r.Errors, err)
+	}
+}
+
+// VerifyResult is the result of Verify.
+type VerifyResult struct {
+	SourceCount int
+	TargetCount int
+	// SourceChecksum and TargetChecksum do not depend on the order of the records or on the ids.
+	SourceChecksum string
+	TargetChecksum string
+	// Missing is the number of source records not found in the target.
+	Missing int
+	// Mismatched is the number of copied records with different values.
+	Mismatched int
+	// Errors has the first 10 differences.
+	Errors []string
+}
+
+// OK returns true when the counts and checksums of the source and target match.
+func (r VerifyResult) OK() bool {
+	return r.SourceCount == r.TargetCount && r.SourceChecksum == r.TargetChecksum && r.Missing == 0 && r.Mismatched == 0
+}
+
+// Map returns the result as a map.
+func (r VerifyResult) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"ok":             r.OK(),
+		"sourceCount":    r.SourceCount,
+		"targetCount":    r.TargetCount,
+		"sourceChecksum": r.SourceChecksum,
+		"targetChecksum": r.TargetChecksum,
+		"missing":        r.Missing,
+		"mismatched":     r.Mismatched,
+		"errors":         r.Errors,
+	}
+}
+
+func (opts CopyOptions) withDefaults() CopyOptions {
+	if opts.IDField == "" {
+		opts.IDField = "id"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultExportPageSize
+	}
+	return opts
+}
+
+// references returns the service of each reference field of the populates.
+func (opts CopyOptions) references() map[string]string {
+	refs := map[string]string{}
+	for field, config := range opts.Populates {
+		action := actionFromPopulate(config)
+		if i := strings.LastIndex(action, "."); i > 0 {
+			refs[field] = action[:i]
+		}
+	}
+	return refs
+}
+
+// ignored returns the fields not copied or verified: the IgnoreFields and the internal fields of the adapters.
+func (opts CopyOptions) ignored(adapters ...Adapter) map[string]bool {
+	ignored := map[string]bool{opts.IDField: true}
+	for _, field := range opts.IgnoreFields {
+		ignored[field] = true
+	}
+	for _, adapter := range adapters {
+		if _, ok := adapter.(*MemoryAdapter); ok {
+			ignored["all"] = true
+		}
+	}
+	return ignored
+}
+
+// mapReference returns the copied id of the reference value (an id or a list of ids).
+func mapReference(value interface{}, idMap IDMap) (interface{}, int) {
+	if value == nil {
+		return nil, 0
+	}
+	if list := payload.New(value); list.IsArray() {
+		mapped, unresolved := []interface{}{}, 0
+		for _, item := range list.Array() {
+			id, missing := mapReference(item.Value(), idMap)
+			mapped = append(mapped, id)
+			unresolved += missing
+		}
+		return mapped, unresolved
+	}
+	if id, ok := idMap.Get(value); ok {
+		return id, 0
+	}
+	return value, 1
+}
+
+// copyValues returns the values of the record to insert in the target, without the references to the copied service.
+func copyValues(record moleculer.Payload, ignored map[string]bool, refs map[string]string, opts CopyOptions) (map[string]interface{}, int) {
+	values, unresolved := map[string]interface{}{}, 0
+	for field, value := range record.RawMap() {
+		if ignored[field] {
+			continue
+		}
+		service, isRef := refs[field]
+		if isRef && service == opts.Service {
+			continue
+		}
+		if isRef {
+			mapped, missing := mapReference(value, opts.IDMaps[service])
+			values[field], unresolved = mapped, unresolved+missing
+			continue
+		}
+		values[field] = value
+	}
+	return values, unresolved
+}
+
+// selfReferences returns the references of the record to the copied service, mapped to the copied ids.
+func selfReferences(record moleculer.Payload, refs map[string]string, idMap IDMap, opts CopyOptions) (map[string]interface{}, int) {
+	values, unresolved := map[string]interface{}{}, 0
+	for field, service := range refs {
+		if service != opts.Service || !record.Get(field).Exists() {
+			continue
+		}
+		mapped, missing := mapReference(record.Get(field).Value(), idMap)
+		values[field], unresolved = mapped, unresolved+missing
+	}
+	return values, unresolved
+}
+
+// stateID converts the ids read from the state file: JSON numbers are integers.
+func stateID(id interface{}) interface{} {
+	if n, ok := id.(float64); ok && n == float64(int64(n)) {
+		return int64(n)
+	}
+	return id
+}
+
+// loadState reads the id map saved in the state file. Each line has the source and target ids of a copied record.
+func loadState(file string) (IDMap, error) {
+	idMap := IDMap{}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return idMap, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := struct {
+			Source interface{} `json:"source"`
+			Target interface{} `json:"target"`
+		}{}
+		// the last line is incomplete when the copy was interrupted while writing it
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.Source == nil {
+			continue
+		}
+		idMap[fmt.Sprint(stateID(entry.Source))] = stateID(entry.Target)
+	}
+	return idMap, scanner.Err()
+}
+
+// eachPage calls fn with the pages of records of the adapter, sorted by id.
+func eachPage(adapter Adapter, opts CopyOptions, fn func(page []moleculer.Payload) error) error {
+	exportOpts := ExportOptions{IDField: opts.IDField, PageSize: opts.BatchSize}
+	for offset := 0; ; offset += opts.BatchSize {
+		page, err := exportPage(adapter, exportOpts, offset)
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if len(page) != opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// Copy copies all records of the source adapter to the target adapter, in batches.
+// The target assigns new ids (see CopyOptions.ID) and the result has the id map from the source ids to the target ids.
+// The reference fields of the populates are rewritten with the id map of the copy (references to the same service)
+// or with the IDMaps of the other services, so other services can be copied after this one using its id map.
+// With a State file, an interrupted copy resumes where it stopped.
+func Copy(source, target Adapter, opts CopyOptions) (CopyResult, error) {
+	opts = opts.withDefaults()
+	result := CopyResult{Errors: []string{}, IDMap: IDMap{}}
+	if opts.State != "" {
+		idMap, err := loadState(opts.State)
+		if err != nil {
+			return result, errors.New("Could not read the copy state. Error: " + err.Error())
+		}
+		result.IDMap = idMap
+	}
+	var state *os.File
+	if opts.State != "" {
+		var err error
+		if state, err = os.OpenFile(opts.State, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			return result, errors.New("Could not write the copy state. Error: " + err.Error())
+		}
+		defer state.Close()
+	}
+	count := source.Count(payload.Empty())
+	if count.IsError() {
+		return result, count.Error()
+	}
+	total := count.Int()
+	ignored := opts.ignored(source)
+	refs := opts.references()
+	hasSelfReferences := false
+	for _, service := range refs {
+		hasSelfReferences = hasSelfReferences || service == opts.Service
+	}
+
+	saveState := func(sourceID, targetID interface{}) error {
+		result.IDMap[fmt.Sprint(sourceID)] = targetID
+		if state == nil {
+			return nil
+		}
+		line, _ := json.Marshal(map[string]interface{}{"source": sourceID, "target": targetID})
+		if _, err := state.Write(append(line, '\n')); err != nil {
+			return errors.New("Could not write the copy state. Error: " + err.Error())
+		}
+		return nil
+	}
+
+	done := 0
+	err := eachPage(source, opts, func(page []moleculer.Payload) error {
+		for _, record := range page {
+			done++
+			sourceID := record.Get(opts.IDField).Value()
+			if _, copied := result.IDMap.Get(sourceID); copied {
+				result.Skipped++
+				continue
+			}
+			values, unresolved := copyValues(record, ignored, refs, opts)
+			result.Unresolved += unresolved
+			if opts.ID != nil {
+				targetID := opts.ID(sourceID)
+				values[opts.IDField] = targetID
+				// the copy was interrupted after the insert of the record, before its state was written.
+				if state != nil && targetID != nil {
+					existing := target.FindById(payload.New(targetID))
+					if !existing.IsError() && existing.Exists() && existing.Get(opts.IDField).Exists() {
+						result.Skipped++
+						if err := saveState(sourceID, existing.Get(opts.IDField).Value()); err != nil {
+							return err
+						}
+						continue
+					}
+				}
+			}
+			inserted := target.Insert(payload.New(values))
+			if inserted.IsError() {
+				result.Failed++
+				result.addError(fmt.Sprint("id ", sourceID, ": ", inserted.Error()))
+				continue
+			}
+			result.Copied++
+			if err := saveState(sourceID, inserted.Get(opts.IDField).Value()); err != nil {
+				return err
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(done, total)
+		}
+		return nil
+	})
+	if err != nil || !hasSelfReferences {
+		return result, err
+	}
+
+	// references to the same service are set after all records are copied, when all ids are known.
+	err = eachPage(source, opts, func(page []moleculer.Payload) error {
+		for _, record := range page {
+			targetID, copied := result.IDMap.Get(record.Get(opts.IDField).Value())
+			if !copied {
+				continue
+			}
+			values, unresolved := selfReferences(record, refs, result.IDMap, opts)
+			if len(values) == 0 {
+				continue
+			}
+			result.Unresolved += unresolved
+			if updated := target.UpdateById(payload.New(targetID), payload.New(values)); updated.IsError() {
+				result.Failed++
+				result.addError(fmt.Sprint("id ", record.Get(opts.IDField).Value(), ": ", updated.Error()))
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// checksumValues returns the values compared by Verify: JSON values without the ignored fields and nil values.
+func checksumValues(values map[string]interface{}, ignored map[string]bool) map[string]interface{} {
+	filtered := map[string]interface{}{}
+	for field, value := range values {
+		if ignored[field] || value == nil {
+			continue
+		}
+		filtered[field] = value
+	}
+	normalized := map[string]interface{}{}
+	data, _ := json.Marshal(filtered)
+	json.Unmarshal(data, &normalized)
+	return normalized
+}
+
+// recordHash returns the hash of the values, with the keys sorted by encoding/json.
+func recordHash(values map[string]interface{}) uint64 {
+	data, _ := json.Marshal(values)
+	sum := sha256.Sum256(data)
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// Verify compares the source and target of a Copy: the counts, the values of each copied record
+// (with the references mapped to the copied ids) and the checksums of all records.
+func Verify(source, target Adapter, idMap IDMap, opts CopyOptions) (VerifyResult, error) {
+	opts = opts.withDefaults()
+	result := VerifyResult{Errors: []string{}}
+	ignored := opts.ignored(source, target)
+	refs := opts.references()
+	addError := func(err string) {
+		if len(result.Errors) < 10 {
+			result.Errors = append(result.Errors, err)
+		}
+	}
+
+	var sourceSum uint64
+	err := eachPage(source, opts, func(page []moleculer.Payload) error {
+		for _, record := range page {
+			result.SourceCount++
+			sourceID := record.Get(opts.IDField).Value()
+			values, _ := copyValues(record, ignored, refs, opts)
+			references, _ := selfReferences(record, refs, idMap, opts)
+			for field, value := range references {
+				values[field] = value
+			}
+			hash := recordHash(checksumValues(values, ignored))
+			sourceSum += hash
+
+			targetID, copied := idMap.Get(sourceID)
+			if !copied {
+				result.Missing++
+				addError(fmt.Sprint("id ", sourceID, ": not copied"))
+				continue
+			}
+			found := target.FindById(payload.New(targetID))
+			if found.IsError() || !found.Exists() || !found.Get(opts.IDField).Exists() {
+				result.Missing++
+				addError(fmt.Sprint("id ", sourceID, ": not found in the target with id ", targetID))
+				continue
+			}
+			if recordHash(checksumValues(found.RawMap(), ignored)) != hash {
+				result.Mismatched++
+				addError(fmt.Sprint("id ", sourceID, ": different values in the target with id ", targetID))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	var targetSum uint64
+	err = eachPage(target, opts, func(page []moleculer.Payload) error {
+		for _, record := range page {
+			result.TargetCount++
+			targetSum += recordHash(checksumValues(record.RawMap(), ignored))
+		}
+		return nil
+	})
+	result.SourceChecksum = fmt.Sprintf("%016x", sourceSum)
+	result.TargetChecksum = fmt.Sprintf("%016x", targetSum)
+	return result, err
+}
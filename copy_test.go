@@ -0,0 +1,161 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// failingAdapter fails the inserts after the first n.
+type failingAdapter struct {
+	*MemoryAdapter
+	n int
+}
+
+func (adapter *failingAdapter) Insert(params moleculer.Payload) moleculer.Payload {
+	if adapter.n == 0 {
+		return payload.Error("connection lost")
+	}
+	adapter.n--
+	return adapter.MemoryAdapter.Insert(params)
+}
+
+var _ = Describe("Copy", func() {
+
+	populates := M{"master": "user.get", "friends": "user.get"}
+	var source, target *MemoryAdapter
+	BeforeEach(func() {
+		source = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+		mocks.ConnectAndLoadUsers(source)
+		target = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+		target.Init(log.WithField("test", "copy"), M{})
+		Expect(target.Connect()).Should(Succeed())
+	})
+	AfterEach(func() {
+		source.Disconnect()
+		target.Disconnect()
+	})
+
+	It("should copy the records with new ids and rewrite the references", func() {
+		progress := []int{}
+		result, err := Copy(source, target, CopyOptions{
+			Service:   "user",
+			Populates: populates,
+			BatchSize: 4,
+			Progress:  func(done, total int) { progress = append(progress, done, total) },
+		})
+		Expect(err).Should(BeNil())
+		Expect(result.Copied).Should(Equal(6))
+		Expect(result.Unresolved).Should(Equal(0))
+		Expect(len(result.IDMap)).Should(Equal(6))
+		Expect(progress).Should(Equal([]int{4, 6, 6, 6}))
+
+		snow := source.Find(payload.New(M{"query": M{"lastname": "Snow"}})).First()
+		marie := source.Find(payload.New(M{"query": M{"name": "Marie"}})).First()
+		snowID, _ := result.IDMap.Get(snow.Get("id").Value())
+		marieID, _ := result.IDMap.Get(marie.Get("id").Value())
+		Expect(snowID).ShouldNot(Equal(snow.Get("id").Value()))
+
+		travolta := target.Find(payload.New(M{"query": M{"lastname": "Travolta"}})).First()
+		Expect(travolta.Get("master").String()).Should(Equal(snowID))
+		Expect(travolta.Get("friends").StringArray()).Should(Equal([]string{snowID.(string), marieID.(string)}))
+
+		verify, err := Verify(source, target, result.IDMap, CopyOptions{Service: "user", Populates: populates})
+		Expect(err).Should(BeNil())
+		Expect(verify.OK()).Should(BeTrue())
+		Expect(verify.SourceCount).Should(Equal(6))
+		Expect(verify.SourceChecksum).Should(Equal(verify.TargetChecksum))
+	})
+
+	It("should map the references to other services with their id maps", func() {
+		source.Insert(payload.New(M{"name": "Wendy", "company": "c1"}))
+		source.Insert(payload.New(M{"name": "Hook", "company": "c9"}))
+		result, err := Copy(source, target, CopyOptions{
+			Populates: M{"company": M{"action": "company.get"}},
+			IDMaps:    map[string]IDMap{"company": {"c1": 100}},
+		})
+		Expect(err).Should(BeNil())
+		Expect(result.Copied).Should(Equal(8))
+		Expect(result.Unresolved).Should(Equal(1))
+		Expect(target.Find(payload.New(M{"query": M{"name": "Wendy"}})).First().Get("company").Int()).Should(Equal(100))
+		Expect(target.Find(payload.New(M{"query": M{"name": "Hook"}})).First().Get("company").String()).Should(Equal("c9"))
+	})
+
+	It("should resume an interrupted copy from the state file", func() {
+		dir, err := ioutil.TempDir("", "copy")
+		Expect(err).Should(BeNil())
+		defer os.RemoveAll(dir)
+		opts := CopyOptions{Service: "user", Populates: populates, BatchSize: 2, State: filepath.Join(dir, "user.state")}
+
+		result, err := Copy(source, &failingAdapter{target, 3}, opts)
+		Expect(err).Should(BeNil())
+		Expect(result.Copied).Should(Equal(3))
+		Expect(result.Failed).Should(Equal(3))
+		Expect(result.Errors[0]).Should(ContainSubstring("connection lost"))
+
+		result, err = Copy(source, target, opts)
+		Expect(err).Should(BeNil())
+		Expect(result.Skipped).Should(Equal(3))
+		Expect(result.Copied).Should(Equal(3))
+		Expect(len(result.IDMap)).Should(Equal(6))
+		Expect(target.Count(payload.Empty()).Int()).Should(Equal(6))
+
+		verify, err := Verify(source, target, result.IDMap, opts)
+		Expect(err).Should(BeNil())
+		Expect(verify.OK()).Should(BeTrue())
+	})
+
+	It("should not insert again a record inserted before its state was written", func() {
+		dir, err := ioutil.TempDir("", "copy")
+		Expect(err).Should(BeNil())
+		defer os.RemoveAll(dir)
+		// john was inserted with the id of the copy when the copy was interrupted
+		john := source.Find(payload.New(M{"query": M{"name": "John"}, "sort": "id"})).First()
+		inserted := target.Insert(payload.New(john.Remove("id").RawMap()))
+		opts := CopyOptions{State: filepath.Join(dir, "user.state"), ID: func(sourceID interface{}) interface{} {
+			if sourceID == john.Get("id").Value() {
+				return inserted.Get("id").Value()
+			}
+			return nil
+		}}
+
+		result, err := Copy(source, target, opts)
+		Expect(err).Should(BeNil())
+		Expect(result.Skipped).Should(Equal(1))
+		Expect(result.Copied).Should(Equal(5))
+		Expect(target.Count(payload.Empty()).Int()).Should(Equal(6))
+		copied, _ := result.IDMap.Get(john.Get("id").Value())
+		Expect(copied).Should(Equal(inserted.Get("id").Value()))
+
+		state, err := loadState(opts.State)
+		Expect(err).Should(BeNil())
+		Expect(len(state)).Should(Equal(6))
+	})
+
+	It("verify should report the missing and different records", func() {
+		result, err := Copy(source, target, CopyOptions{Service: "user", Populates: populates})
+		Expect(err).Should(BeNil())
+
+		marie := source.Find(payload.New(M{"query": M{"name": "Marie"}})).First()
+		marieID, _ := result.IDMap.Get(marie.Get("id").Value())
+		target.UpdateById(payload.New(marieID), payload.New(M{"age": 76}))
+		peter := target.Find(payload.New(M{"query": M{"name": "Peter"}})).First()
+		target.RemoveById(peter.Get("id"))
+
+		verify, err := Verify(source, target, result.IDMap, CopyOptions{Service: "user", Populates: populates})
+		Expect(err).Should(BeNil())
+		Expect(verify.OK()).Should(BeFalse())
+		Expect(verify.SourceCount).Should(Equal(6))
+		Expect(verify.TargetCount).Should(Equal(5))
+		Expect(verify.Missing).Should(Equal(1))
+		Expect(verify.Mismatched).Should(Equal(1))
+		Expect(verify.SourceChecksum).ShouldNot(Equal(verify.TargetChecksum))
+	})
+})
@@ -0,0 +1,13 @@
+package dsl_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDsl(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DSL Suite")
+}
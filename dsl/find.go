@@ -0,0 +1,96 @@
+package dsl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// SearchMatch checks if any of the searchFields param is equal to the search param.
+// Records always match when there are no searchFields.
+func SearchMatch(params moleculer.Payload, record map[string]interface{}) bool {
+	searchFields := params.Get("searchFields")
+	if !searchFields.Exists() {
+		return true
+	}
+	fields := []string{searchFields.String()}
+	if searchFields.IsArray() {
+		fields = searchFields.StringArray()
+	}
+	search := params.Get("search").String()
+	for _, field := range fields {
+		if value, found := Lookup(record, field); found && fmt.Sprint(value) == search {
+			return true
+		}
+	}
+	return false
+}
+
+// SortFields returns the fields of the sort param. e.g. "-age name" or ["-age", "name"]
+func SortFields(param moleculer.Payload) []string {
+	if param.IsArray() {
+		return param.StringArray()
+	}
+	return strings.Fields(param.String())
+}
+
+// SortRecords sorts the records by the fields. Descending fields start with -
+// Records without the field come first, as NULL values in SQL.
+func SortRecords(records []map[string]interface{}, fields []string) {
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, field := range fields {
+			descending := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+			a, foundA := Lookup(records[i], field)
+			b, foundB := Lookup(records[j], field)
+			foundA, foundB = foundA && a != nil, foundB && b != nil
+			result := 0
+			switch {
+			case !foundA && !foundB:
+			case !foundA:
+				result = -1
+			case !foundB:
+				result = 1
+			default:
+				var comparable bool
+				if result, comparable = Compare(a, b); !comparable {
+					result = strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+				}
+			}
+			if result != 0 {
+				return (result < 0) != descending
+			}
+		}
+		return false
+	})
+}
+
+// Paginate applies the sort, offset and limit params to the records, for adapters that find the records in process.
+func Paginate(records []map[string]interface{}, params moleculer.Payload) []map[string]interface{} {
+	if sort := params.Get("sort"); sort.Exists() {
+		SortRecords(records, SortFields(sort))
+	}
+	if offset := params.Get("offset"); offset.Exists() {
+		if offset.Int() >= len(records) {
+			records = records[:0]
+		} else if offset.Int() > 0 {
+			records = records[offset.Int():]
+		}
+	}
+	if limit := params.Get("limit"); limit.Exists() && limit.Int() >= 0 && limit.Int() < len(records) {
+		records = records[:limit.Int()]
+	}
+	return records
+}
+
+// RecordsPayload returns the records as a list payload.
+func RecordsPayload(records []map[string]interface{}) moleculer.Payload {
+	list := make([]moleculer.Payload, len(records))
+	for i, record := range records {
+		list[i] = payload.New(record)
+	}
+	return payload.New(list)
+}
@@ -0,0 +1,49 @@
+package dsl_test
+
+import (
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Find helpers", func() {
+
+	records := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"name": "Marie", "age": 75},
+			{"name": "John", "age": 25},
+			{"name": "Anna"},
+			{"name": "Paul", "age": 25},
+		}
+	}
+	names := func(records []map[string]interface{}) []string {
+		list := []string{}
+		for _, record := range records {
+			list = append(list, record["name"].(string))
+		}
+		return list
+	}
+
+	It("should match the search param on the searchFields", func() {
+		record := map[string]interface{}{"name": "Marie", "age": 75}
+		Expect(dsl.SearchMatch(payload.New(M{"search": "75", "searchFields": []string{"name", "age"}}), record)).Should(BeTrue())
+		Expect(dsl.SearchMatch(payload.New(M{"search": "75", "searchFields": "name"}), record)).Should(BeFalse())
+		Expect(dsl.SearchMatch(payload.New(M{"search": "John"}), record)).Should(BeTrue())
+	})
+
+	It("should sort with the records without the field first", func() {
+		list := records()
+		dsl.SortRecords(list, dsl.SortFields(payload.New("age -name")))
+		Expect(names(list)).Should(Equal([]string{"Anna", "Paul", "John", "Marie"}))
+
+		dsl.SortRecords(list, dsl.SortFields(payload.New([]string{"-age", "name"})))
+		Expect(names(list)).Should(Equal([]string{"Marie", "John", "Paul", "Anna"}))
+	})
+
+	It("should apply sort, offset and limit", func() {
+		Expect(names(dsl.Paginate(records(), payload.New(M{"sort": "name", "offset": 1, "limit": 2})))).Should(Equal([]string{"John", "Marie"}))
+		Expect(names(dsl.Paginate(records(), payload.New(M{"offset": 10})))).Should(BeEmpty())
+		Expect(dsl.RecordsPayload(dsl.Paginate(records(), payload.New(M{"limit": 3}))).Len()).Should(Equal(3))
+	})
+})
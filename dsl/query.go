@@ -0,0 +1,528 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+)
+
+// Query operators accepted by the query param of all adapters.
+// Fields without an operator are handled as $eq.
+const (
+	Eq      = "$eq"
+	Ne      = "$ne"
+	Gt      = "$gt"
+	Gte     = "$gte"
+	Lt      = "$lt"
+	Lte     = "$lte"
+	In      = "$in"
+	Nin     = "$nin"
+	Between = "$between"
+	Like    = "$like"
+	Exists  = "$exists"
+	And     = "$and"
+	Or      = "$or"
+	Not     = "$not"
+)
+
+var queryOperators = []string{Eq, Ne, Gt, Gte, Lt, Lte, In, Nin, Between, Like, Exists, And, Or, Not}
+
+// aliases maps the operators of the old SQLite filter syntax to the query operators.
+// "not between" is handled as $not + $between.
+var aliases = map[string]string{
+	"=":       Eq,
+	"<>":      Ne,
+	"!=":      Ne,
+	">":       Gt,
+	">=":      Gte,
+	"<":       Lt,
+	"<=":      Lte,
+	"in":      In,
+	"not in":  Nin,
+	"like":    Like,
+	"and":     And,
+	"or":      Or,
+	"not":     Not,
+	"between": Between,
+}
+
+const notBetween = "not between"
+
+// Node is an expression of a parsed query.
+type Node interface {
+	isNode()
+}
+
+// Condition compares a field with a value.
+// Field can be a nested path (e.g. address.city).
+// Value is a []interface{} for $in and $nin, a pair for $between,
+// a bool for $exists and a string for $like.
+type Condition struct {
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+// Logical combines the nodes using $and or $or.
+type Logical struct {
+	Operator string
+	Nodes    []Node
+}
+
+// Negation negates the node.
+type Negation struct {
+	Node Node
+}
+
+func (Condition) isNode() {}
+func (Logical) isNode()   {}
+func (Negation) isNode()  {}
+
+// Expression is implemented by values that build query nodes (e.g. the q package builder),
+// so they can be used as the query param when adapters are called in process.
+type Expression interface {
+	Node() Node
+}
+
+// operator returns the query operator for the name, resolving aliases. Returns "" when name is not an operator.
+func operator(name string) string {
+	lower := strings.ToLower(name)
+	if op, ok := aliases[lower]; ok {
+		return op
+	}
+	if lower == notBetween {
+		return notBetween
+	}
+	for _, op := range queryOperators {
+		if op == lower {
+			return op
+		}
+	}
+	return ""
+}
+
+func isNullExpression(value moleculer.Payload) (exists, ok bool) {
+	s, isString := value.Value().(string)
+	if !isString {
+		return false, false
+	}
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "IS NULL":
+		return false, true
+	case "IS NOT NULL":
+		return true, true
+	}
+	return false, false
+}
+
+// all combines the nodes with $and, when there is more than one.
+func all(nodes []Node) Node {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return Logical{And, nodes}
+}
+
+// sortedEntries returns the entries of the map payload sorted by key,
+// so the translated queries are always the same for the same input.
+func sortedEntries(value moleculer.Payload) ([]string, map[string]moleculer.Payload, error) {
+	keys := []string{}
+	entries := map[string]moleculer.Payload{}
+	var err error
+	value.ForEach(func(key interface{}, item moleculer.Payload) bool {
+		name, ok := key.(string)
+		if !ok {
+			err = errors.New(fmt.Sprint("query key must be string! - key: ", key))
+			return false
+		}
+		keys = append(keys, name)
+		entries[name] = item
+		return true
+	})
+	sort.Strings(keys)
+	return keys, entries, err
+}
+
+// Parse parses the query into a tree of nodes. An empty query matches all records.
+// examples:
+// {"name": "John"} -> name $eq John
+// {"age": {"$gte": 18, "$lt": 65}, "address.city": "Auckland"}
+// {"$or": [{"name": "John"}, {"age": {"$between": [10, 20]}}]}
+// The old SQLite syntax is also supported: {"age": {">": 60}}, {"name": {"not in": [...]}}, {"email": "is null"}
+func Parse(query moleculer.Payload) (Node, error) {
+	if query == nil || !query.Exists() {
+		return Logical{And, []Node{}}, nil
+	}
+	if node, ok := query.Value().(Node); ok {
+		return node, nil
+	}
+	if expression, ok := query.Value().(Expression); ok {
+		return expression.Node(), nil
+	}
+	if !query.IsMap() {
+		return nil, errors.New("query must be a map")
+	}
+	keys, entries, err := sortedEntries(query)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{}
+	for _, key := range keys {
+		node, err := parseEntry(key, entries[key])
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return Logical{And, nodes}, nil
+	}
+	return all(nodes), nil
+}
+
+// parseEntry parses a top level entry of the query: a logical operator or a field.
+func parseEntry(name string, value moleculer.Payload) (Node, error) {
+	op := operator(name)
+	if op == And || op == Or {
+		if !value.IsArray() {
+			return nil, errors.New("Query operator " + name + " requires a list of queries")
+		}
+		nodes := []Node{}
+		for _, item := range value.Array() {
+			node, err := Parse(item)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
+		}
+		return Logical{op, nodes}, nil
+	}
+	if op == Not {
+		node, err := Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		return Negation{node}, nil
+	}
+	if op != "" || strings.HasPrefix(name, "$") {
+		return nil, errors.New("Invalid query operator: " + name)
+	}
+	return parseField(name, value)
+}
+
+// parseField parses the conditions of a field.
+// A map without operators is handled as nested fields: {"address": {"city": "X"}} -> address.city $eq X
+func parseField(field string, value moleculer.Payload) (Node, error) {
+	if exists, ok := isNullExpression(value); ok {
+		return Condition{field, Exists, exists}, nil
+	}
+	if value.Value() == nil {
+		return Condition{field, Exists, false}, nil
+	}
+	if !value.IsMap() {
+		return Condition{field, Eq, value.Value()}, nil
+	}
+	keys, entries, err := sortedEntries(value)
+	if err != nil {
+		return nil, err
+	}
+	operators := 0
+	for _, key := range keys {
+		if operator(key) != "" {
+			operators++
+		}
+	}
+	if operators > 0 && operators != len(keys) {
+		return nil, errors.New("Query field " + field + " mixes operators and nested fields")
+	}
+	nodes := []Node{}
+	for _, key := range keys {
+		var node Node
+		if operators > 0 {
+			node, err = parseOperator(field, key, entries[key])
+		} else {
+			node, err = parseField(field+"."+key, entries[key])
+		}
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return Condition{field, Eq, value.Value()}, nil
+	}
+	return all(nodes), nil
+}
+
+// parseOperator parses a single operator of a field.
+func parseOperator(field, name string, value moleculer.Payload) (Node, error) {
+	op := operator(name)
+	switch op {
+	case Not:
+		node, err := parseField(field, value)
+		if err != nil {
+			return nil, err
+		}
+		return Negation{node}, nil
+	case In, Nin:
+		if !value.IsArray() {
+			return nil, errors.New("Query operator " + name + " requires a list of values")
+		}
+		return Condition{field, op, values(value)}, nil
+	case Between, notBetween:
+		if !value.IsArray() || value.Len() != 2 {
+			return nil, errors.New("Query operator " + name + " requires a list with two values")
+		}
+		between := Condition{field, Between, values(value)}
+		if op == notBetween {
+			return Negation{between}, nil
+		}
+		return between, nil
+	case Exists:
+		return Condition{field, Exists, value.Bool()}, nil
+	case Like:
+		return Condition{field, Like, value.String()}, nil
+	case Eq, Ne, Gt, Gte, Lt, Lte:
+		return Condition{field, op, value.Value()}, nil
+	}
+	return nil, errors.New("Invalid query operator: " + name)
+}
+
+func values(list moleculer.Payload) []interface{} {
+	result := []interface{}{}
+	for _, item := range list.Array() {
+		result = append(result, item.Value())
+	}
+	return result
+}
+
+// Format converts the node back to a query map, that can be sent to remote services.
+func Format(node Node) map[string]interface{} {
+	switch n := node.(type) {
+	case Condition:
+		if n.Operator == Eq {
+			if _, isMap := n.Value.(map[string]interface{}); !isMap && n.Value != nil {
+				return map[string]interface{}{n.Field: n.Value}
+			}
+		}
+		return map[string]interface{}{n.Field: map[string]interface{}{n.Operator: n.Value}}
+	case Negation:
+		return map[string]interface{}{Not: Format(n.Node)}
+	case Logical:
+		if n.Operator == And && len(n.Nodes) == 0 {
+			return map[string]interface{}{}
+		}
+		list := []interface{}{}
+		for _, child := range n.Nodes {
+			list = append(list, Format(child))
+		}
+		return map[string]interface{}{n.Operator: list}
+	}
+	return map[string]interface{}{}
+}
+
+// Fields returns all fields used in the query.
+func Fields(node Node) []string {
+	fields := []string{}
+	switch n := node.(type) {
+	case Condition:
+		fields = append(fields, n.Field)
+	case Logical:
+		for _, child := range n.Nodes {
+			fields = append(fields, Fields(child)...)
+		}
+	case Negation:
+		fields = append(fields, Fields(n.Node)...)
+	}
+	return fields
+}
+
+// LikeToRegexp converts a $like pattern (% and _ wildcards) to a case insensitive regular expression.
+func LikeToRegexp(pattern string) string {
+	expr := "(?i)^"
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			expr += ".*"
+		case '_':
+			expr += "."
+		default:
+			expr += regexp.QuoteMeta(string(r))
+		}
+	}
+	return expr + "$"
+}
+
+// Lookup returns the value of the field in the record. Supports nested paths (e.g. address.city).
+func Lookup(record map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = record
+	for _, name := range strings.Split(path, ".") {
+		var m map[string]interface{}
+		switch v := current.(type) {
+		case map[string]interface{}:
+			m = v
+		case moleculer.Payload:
+			if !v.IsMap() {
+				return nil, false
+			}
+			m = v.RawMap()
+		default:
+			return nil, false
+		}
+		value, found := m[name]
+		if !found {
+			return nil, false
+		}
+		current = value
+	}
+	return current, true
+}
+
+// Match checks if the record matches the query.
+// Used by adapters that evaluate queries in process (e.g. MemoryAdapter).
+func Match(node Node, record map[string]interface{}) bool {
+	switch n := node.(type) {
+	case Logical:
+		if n.Operator == Or {
+			for _, child := range n.Nodes {
+				if Match(child, record) {
+					return true
+				}
+			}
+			return false
+		}
+		for _, child := range n.Nodes {
+			if !Match(child, record) {
+				return false
+			}
+		}
+		return true
+	case Negation:
+		return !Match(n.Node, record)
+	case Condition:
+		value, found := Lookup(record, n.Field)
+		if moleculerValue, ok := value.(moleculer.Payload); ok {
+			value = moleculerValue.Value()
+		}
+		return matchCondition(n, value, found && value != nil)
+	}
+	return false
+}
+
+func matchCondition(c Condition, value interface{}, found bool) bool {
+	switch c.Operator {
+	case Exists:
+		exists, _ := c.Value.(bool)
+		return found == exists
+	case Eq:
+		return found && anyItem(value, func(item interface{}) bool { return equal(item, c.Value) })
+	case Ne:
+		return found && !anyItem(value, func(item interface{}) bool { return equal(item, c.Value) })
+	case In:
+		return found && anyItem(value, func(item interface{}) bool { return contains(c.Value, item) })
+	case Nin:
+		return found && !anyItem(value, func(item interface{}) bool { return contains(c.Value, item) })
+	case Like:
+		pattern, _ := c.Value.(string)
+		matched, _ := regexp.MatchString(LikeToRegexp(pattern), fmt.Sprint(value))
+		return found && matched
+	case Between:
+		pair, _ := c.Value.([]interface{})
+		if !found || len(pair) != 2 {
+			return false
+		}
+		low, okLow := Compare(value, pair[0])
+		high, okHigh := Compare(value, pair[1])
+		return okLow && okHigh && low >= 0 && high <= 0
+	case Gt, Gte, Lt, Lte:
+		if !found {
+			return false
+		}
+		result, ok := Compare(value, c.Value)
+		if !ok {
+			return false
+		}
+		switch c.Operator {
+		case Gt:
+			return result > 0
+		case Gte:
+			return result >= 0
+		case Lt:
+			return result < 0
+		}
+		return result <= 0
+	}
+	return false
+}
+
+// anyItem checks the value, or each item when value is a list.
+func anyItem(value interface{}, check func(interface{}) bool) bool {
+	if list, ok := asList(value); ok {
+		for _, item := range list {
+			if check(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return check(value)
+}
+
+func contains(list, value interface{}) bool {
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		if equal(value, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if result, ok := Compare(a, b); ok {
+		return result == 0
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// Compare returns -1, 0 or 1 comparing a with b (numbers, times or strings). Returns false when the values are not comparable.
+// Used by adapters that sort in process (e.g. the bolt adapter).
+func Compare(a, b interface{}) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	}
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	if okA && okB {
+		return strings.Compare(sa, sb), true
+	}
+	return 0, false
+}
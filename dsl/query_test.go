@@ -0,0 +1,103 @@
+package dsl_test
+
+import (
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query language", func() {
+
+	parse := func(query M) dsl.Node {
+		node, err := dsl.Parse(payload.New(query))
+		Expect(err).Should(BeNil())
+		return node
+	}
+
+	Describe("Parse", func() {
+		It("should handle plain fields as $eq", func() {
+			Expect(parse(M{"name": "John"})).Should(Equal(dsl.Condition{"name", dsl.Eq, "John"}))
+		})
+
+		It("should combine multiple fields and operators with $and", func() {
+			Expect(parse(M{"age": M{"$gte": 18, "$lt": 65}, "name": "John"})).Should(Equal(dsl.Logical{dsl.And, []dsl.Node{
+				dsl.Logical{dsl.And, []dsl.Node{
+					dsl.Condition{"age", dsl.Gte, 18},
+					dsl.Condition{"age", dsl.Lt, 65},
+				}},
+				dsl.Condition{"name", dsl.Eq, "John"},
+			}}))
+		})
+
+		It("should parse the old SQLite operators", func() {
+			Expect(parse(M{"age": M{">": 60}})).Should(Equal(dsl.Condition{"age", dsl.Gt, 60}))
+			Expect(parse(M{"age": M{"not in": []int{1, 2}}})).Should(Equal(dsl.Condition{"age", dsl.Nin, []interface{}{1, 2}}))
+			Expect(parse(M{"letter": M{"not between": []string{"B", "M"}}})).Should(Equal(
+				dsl.Negation{dsl.Condition{"letter", dsl.Between, []interface{}{"B", "M"}}}))
+			Expect(parse(M{"email": "IS NULL"})).Should(Equal(dsl.Condition{"email", dsl.Exists, false}))
+			Expect(parse(M{"or": []M{{"name": "A"}, {"name": "B"}}})).Should(Equal(dsl.Logical{dsl.Or, []dsl.Node{
+				dsl.Condition{"name", dsl.Eq, "A"},
+				dsl.Condition{"name", dsl.Eq, "B"},
+			}}))
+		})
+
+		It("should parse nested fields", func() {
+			Expect(parse(M{"address": M{"city": "Auckland"}})).Should(Equal(dsl.Condition{"address.city", dsl.Eq, "Auckland"}))
+			Expect(parse(M{"address.city": M{"$like": "Auck%"}})).Should(Equal(dsl.Condition{"address.city", dsl.Like, "Auck%"}))
+		})
+
+		It("should fail with invalid queries", func() {
+			_, err := dsl.Parse(payload.New(M{"age": M{"$near": 10}}))
+			Expect(err.Error()).Should(Equal("Invalid query operator: $near"))
+
+			_, err = dsl.Parse(payload.New(M{"$where": "true"}))
+			Expect(err.Error()).Should(Equal("Invalid query operator: $where"))
+
+			_, err = dsl.Parse(payload.New(M{"age": M{"$between": []int{1}}}))
+			Expect(err.Error()).Should(Equal("Query operator $between requires a list with two values"))
+		})
+	})
+
+	Describe("Match", func() {
+		record := map[string]interface{}{
+			"name":    "John",
+			"age":     25,
+			"tags":    []string{"admin", "dev"},
+			"address": map[string]interface{}{"city": "Auckland"},
+		}
+
+		match := func(query M) bool {
+			return dsl.Match(parse(query), record)
+		}
+
+		It("should match comparisons", func() {
+			Expect(match(M{})).Should(BeTrue())
+			Expect(match(M{"name": "John"})).Should(BeTrue())
+			Expect(match(M{"age": M{"$gt": 20, "$lte": 25}})).Should(BeTrue())
+			Expect(match(M{"age": M{"$lt": 25}})).Should(BeFalse())
+			Expect(match(M{"age": M{"$between": []int{20, 30}}})).Should(BeTrue())
+			Expect(match(M{"age": M{"$ne": 25}})).Should(BeFalse())
+		})
+
+		It("should match lists, like and exists", func() {
+			Expect(match(M{"tags": "dev"})).Should(BeTrue())
+			Expect(match(M{"name": M{"$in": []string{"Peter", "John"}}})).Should(BeTrue())
+			Expect(match(M{"name": M{"$nin": []string{"Peter", "John"}}})).Should(BeFalse())
+			Expect(match(M{"name": M{"$like": "jo%"}})).Should(BeTrue())
+			Expect(match(M{"email": M{"$exists": false}})).Should(BeTrue())
+			Expect(match(M{"email": M{"$ne": "x"}})).Should(BeFalse())
+		})
+
+		It("should match nested fields and logical operators", func() {
+			Expect(match(M{"address.city": "Auckland"})).Should(BeTrue())
+			Expect(match(M{"$or": []M{{"name": "Peter"}, {"age": 25}}})).Should(BeTrue())
+			Expect(match(M{"$not": M{"name": "John"}})).Should(BeFalse())
+			Expect(match(M{"age": M{"$not": M{"$gt": 30}}})).Should(BeTrue())
+		})
+	})
+
+	It("LikeToRegexp should convert the wildcards", func() {
+		Expect(dsl.LikeToRegexp("J%n_s.")).Should(Equal("(?i)^J.*n.s\\.$"))
+	})
+})
@@ -0,0 +1,268 @@
+// Package dsl contains the portable update (and query) language shared by all adapters.
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+)
+
+// Update operators accepted by the update and findAndUpdate actions.
+// Fields without an operator are handled as $set.
+const (
+	Set   = "$set"
+	Inc   = "$inc"
+	Push  = "$push"
+	Pull  = "$pull"
+	Unset = "$unset"
+)
+
+var updateOperators = []string{Set, Inc, Push, Pull, Unset}
+
+// UpdateOp is a single field change of an update.
+type UpdateOp struct {
+	Operator string
+	Field    string
+	Value    interface{}
+}
+
+func isUpdateOperator(name string) bool {
+	for _, op := range updateOperators {
+		if op == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasOperators checks if the update uses any update operator.
+func HasOperators(update moleculer.Payload) bool {
+	found := false
+	update.ForEach(func(key interface{}, value moleculer.Payload) bool {
+		field, ok := key.(string)
+		found = ok && strings.HasPrefix(field, "$")
+		return !found
+	})
+	return found
+}
+
+// ParseUpdate splits the update into a list of field operations.
+// examples:
+// {"name": "John"} -> $set name = John
+// {"$inc": {"visits": 1}, "$push": {"tags": "new"}, "$unset": ["draft"]}
+func ParseUpdate(update moleculer.Payload) ([]UpdateOp, error) {
+	ops := []UpdateOp{}
+	var err error
+	update.ForEach(func(key interface{}, value moleculer.Payload) bool {
+		field, ok := key.(string)
+		if !ok {
+			err = errors.New(fmt.Sprint("update key must be string! - key: ", key))
+			return false
+		}
+		if !strings.HasPrefix(field, "$") {
+			ops = append(ops, UpdateOp{Set, field, value.Value()})
+			return true
+		}
+		if !isUpdateOperator(field) {
+			err = errors.New("Invalid update operator: " + field)
+			return false
+		}
+		if field == Unset && value.IsArray() {
+			for _, name := range value.StringArray() {
+				ops = append(ops, UpdateOp{Unset, name, nil})
+			}
+			return true
+		}
+		if !value.IsMap() {
+			err = errors.New("Update operator " + field + " requires a map of fields")
+			return false
+		}
+		value.ForEach(func(key interface{}, fvalue moleculer.Payload) bool {
+			name, ok := key.(string)
+			if !ok {
+				err = errors.New(fmt.Sprint("update field must be string! - operator: ", field, " field: ", key))
+				return false
+			}
+			ops = append(ops, UpdateOp{field, name, fvalue.Value()})
+			return true
+		})
+		return err == nil
+	})
+	return ops, err
+}
+
+// FormatUpdate converts the operations back to an update. $set operations are plain fields.
+func FormatUpdate(ops []UpdateOp) map[string]interface{} {
+	update := map[string]interface{}{}
+	for _, op := range ops {
+		switch op.Operator {
+		case Set:
+			update[op.Field] = op.Value
+		case Unset:
+			fields, _ := update[Unset].([]string)
+			update[Unset] = append(fields, op.Field)
+		default:
+			fields, ok := update[op.Operator].(map[string]interface{})
+			if !ok {
+				fields = map[string]interface{}{}
+				update[op.Operator] = fields
+			}
+			fields[op.Field] = op.Value
+		}
+	}
+	return update
+}
+
+// Apply applies the operations on a copy of the record and returns it.
+// Used by adapters that evaluate updates in process (e.g. MemoryAdapter).
+func Apply(record map[string]interface{}, ops []UpdateOp) map[string]interface{} {
+	result := make(map[string]interface{}, len(record))
+	for key, value := range record {
+		result[key] = value
+	}
+	for _, op := range ops {
+		switch op.Operator {
+		case Set:
+			result[op.Field] = op.Value
+		case Unset:
+			delete(result, op.Field)
+		case Inc:
+			result[op.Field] = increment(result[op.Field], op.Value)
+		case Push:
+			result[op.Field] = push(result[op.Field], op.Value)
+		case Pull:
+			result[op.Field] = pull(result[op.Field], op.Value)
+		}
+	}
+	return result
+}
+
+// toFloat converts numeric values to float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func isInteger(value interface{}) bool {
+	switch value.(type) {
+	case int, int32, int64, uint, uint32, uint64:
+		return true
+	}
+	return false
+}
+
+// increment adds by to the current value. Missing values start at zero.
+// The result stays an integer when both values are integers.
+func increment(current, by interface{}) interface{} {
+	if current == nil {
+		return by
+	}
+	a, okA := toFloat(current)
+	b, okB := toFloat(by)
+	if !okA || !okB {
+		return current
+	}
+	if isInteger(current) && isInteger(by) {
+		return int64(a) + int64(b)
+	}
+	return a + b
+}
+
+// push appends the value to the current list.
+func push(current, value interface{}) interface{} {
+	if current == nil {
+		if s, ok := value.(string); ok {
+			return []string{s}
+		}
+		return []interface{}{value}
+	}
+	if list, ok := current.([]string); ok {
+		if s, ok := value.(string); ok {
+			return append(append([]string{}, list...), s)
+		}
+	}
+	return append(toList(current), value)
+}
+
+// pull removes all entries equal to the value from the current list.
+func pull(current, value interface{}) interface{} {
+	if current == nil {
+		return current
+	}
+	if list, ok := current.([]string); ok {
+		result := []string{}
+		for _, item := range list {
+			if item != fmt.Sprint(value) {
+				result = append(result, item)
+			}
+		}
+		return result
+	}
+	result := []interface{}{}
+	for _, item := range toList(current) {
+		if fmt.Sprint(item) != fmt.Sprint(value) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// toList converts the value to a new []interface{}. Values that are not lists become a list with one item.
+func toList(value interface{}) []interface{} {
+	if list, ok := asList(value); ok {
+		return list
+	}
+	return []interface{}{value}
+}
+
+// asList converts the known list types to a new []interface{}.
+func asList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return append([]interface{}{}, v...), true
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return list, true
+	case []int:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return list, true
+	case []int64:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return list, true
+	case []float64:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return list, true
+	}
+	return nil, false
+}
@@ -0,0 +1,84 @@
+package dsl_test
+
+import (
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type M map[string]interface{}
+
+var _ = Describe("Update operators", func() {
+
+	It("should handle plain fields as $set", func() {
+		ops, err := dsl.ParseUpdate(payload.New(M{"name": "John"}))
+		Expect(err).Should(BeNil())
+		Expect(ops).Should(Equal([]dsl.UpdateOp{{dsl.Set, "name", "John"}}))
+	})
+
+	It("should parse the update operators", func() {
+		ops, err := dsl.ParseUpdate(payload.New(M{"$inc": M{"visits": 1}}))
+		Expect(err).Should(BeNil())
+		Expect(ops).Should(Equal([]dsl.UpdateOp{{dsl.Inc, "visits", 1}}))
+
+		ops, err = dsl.ParseUpdate(payload.New(M{"$unset": []string{"draft", "notes"}}))
+		Expect(err).Should(BeNil())
+		Expect(ops).Should(Equal([]dsl.UpdateOp{{dsl.Unset, "draft", nil}, {dsl.Unset, "notes", nil}}))
+	})
+
+	It("should fail with unknown operators", func() {
+		_, err := dsl.ParseUpdate(payload.New(M{"$rename": M{"a": "b"}}))
+		Expect(err).ShouldNot(BeNil())
+		Expect(err.Error()).Should(Equal("Invalid update operator: $rename"))
+	})
+
+	It("should fail with fields that are not strings", func() {
+		_, err := dsl.ParseUpdate(payload.New(M{"$inc": map[interface{}]interface{}{1: 2}}))
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("HasOperators should detect operators", func() {
+		Expect(dsl.HasOperators(payload.New(M{"name": "John"}))).Should(BeFalse())
+		Expect(dsl.HasOperators(payload.New(M{"$inc": M{"visits": 1}}))).Should(BeTrue())
+	})
+
+	It("FormatUpdate should convert the operations back to an update", func() {
+		Expect(dsl.FormatUpdate([]dsl.UpdateOp{
+			{dsl.Set, "name", "Jane"},
+			{dsl.Inc, "visits", 2},
+			{dsl.Unset, "draft", nil},
+			{dsl.Unset, "notes", nil},
+		})).Should(Equal(map[string]interface{}{
+			"name":   "Jane",
+			"$inc":   map[string]interface{}{"visits": 2},
+			"$unset": []string{"draft", "notes"},
+		}))
+	})
+
+	It("Apply should apply the operations on a copy of the record", func() {
+		record := map[string]interface{}{
+			"name":   "John",
+			"visits": 1,
+			"tags":   []string{"a", "b"},
+			"draft":  true,
+		}
+		result := dsl.Apply(record, []dsl.UpdateOp{
+			{dsl.Set, "name", "Jane"},
+			{dsl.Inc, "visits", 2},
+			{dsl.Push, "tags", "c"},
+			{dsl.Pull, "tags", "a"},
+			{dsl.Unset, "draft", nil},
+			{dsl.Inc, "likes", 1},
+		})
+		Expect(result["name"]).Should(Equal("Jane"))
+		Expect(result["visits"]).Should(Equal(int64(3)))
+		Expect(result["tags"]).Should(Equal([]string{"b", "c"}))
+		Expect(result["likes"]).Should(Equal(1))
+		_, hasDraft := result["draft"]
+		Expect(hasDraft).Should(BeFalse())
+
+		Expect(record["name"]).Should(Equal("John"))
+		Expect(record["tags"]).Should(Equal([]string{"a", "b"}))
+	})
+})
@@ -3,8 +3,8 @@ package elastic
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	elastic "github.com/elastic/go-elasticsearch/v7"
@@ -13,6 +13,8 @@ import (
 	"github.com/moleculer-go/moleculer/payload"
 	"github.com/moleculer-go/moleculer/serializer"
 	"github.com/moleculer-go/moleculer/util"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/dsl"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -26,7 +28,10 @@ type Adapter struct {
 	log        *log.Entry
 	settings   map[string]interface{}
 	mappings   map[string]interface{}
+	indexes    []store.Index
 	serializer serializer.Serializer
+	// ctx is the context of the requests. See WithContext.
+	ctx context.Context
 }
 
 func (a *Adapter) Init(log *log.Entry, settings map[string]interface{}) {
@@ -36,6 +41,21 @@ func (a *Adapter) Init(log *log.Entry, settings map[string]interface{}) {
 	a.serializer = serializer.CreateJSONSerializer(a.log)
 }
 
+// WithContext returns the adapter with the requests bound to the context.
+func (a *Adapter) WithContext(ctx context.Context) *Adapter {
+	bound := *a
+	bound.ctx = ctx
+	return &bound
+}
+
+// context returns the context of the requests: the context of WithContext or the background context.
+func (a *Adapter) context() context.Context {
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
 func (a *Adapter) loadSettings(settings map[string]interface{}) {
 	if uri, ok := settings["uris"].(string); ok {
 		a.URIs = strings.Split(uri, ",")
@@ -46,6 +66,54 @@ func (a *Adapter) loadSettings(settings map[string]interface{}) {
 	if mappings, ok := settings["mappings"].(map[string]interface{}); ok {
 		a.mappings = mappings
 	}
+	indexes, err := store.ParseIndexes(settings)
+	if err != nil {
+		a.log.Error("Invalid indexes setting - error: ", err)
+		return
+	}
+	a.indexes = indexes
+	a.addIndexMappings()
+}
+
+// addIndexMappings adds a keyword mapping for the index fields without a mapping.
+// Elastic indexes all fields, so the mapping makes them available for exact match and sorting.
+func (a *Adapter) addIndexMappings() {
+	if len(a.indexes) == 0 {
+		return
+	}
+	if a.mappings == nil {
+		a.mappings = map[string]interface{}{}
+	}
+	for _, index := range a.indexes {
+		if index.Unique || index.TTL > 0 {
+			a.log.Warn("Elastic adapter - index ", index.Name, ": unique and ttl are not supported by the Elastic adapter.")
+		}
+		for _, field := range index.Fields {
+			properties := a.mappings
+			for _, name := range strings.Split(field.Name, ".") {
+				if _, ok := properties["properties"].(map[string]interface{}); !ok {
+					properties["properties"] = map[string]interface{}{}
+				}
+				children := properties["properties"].(map[string]interface{})
+				if _, ok := children[name].(map[string]interface{}); !ok {
+					children[name] = map[string]interface{}{}
+				}
+				properties = children[name].(map[string]interface{})
+			}
+			if _, ok := properties["type"]; !ok && properties["properties"] == nil {
+				properties["type"] = "keyword"
+			}
+		}
+	}
+}
+
+// Indexes returns the indexes setting. Elastic indexes all fields of the mappings.
+func (a *Adapter) Indexes() moleculer.Payload {
+	list := []map[string]interface{}{}
+	for _, index := range a.indexes {
+		list = append(list, index.Map())
+	}
+	return payload.New(list)
 }
 
 func (a *Adapter) printClusterInfo() {
@@ -76,9 +144,13 @@ func (a *Adapter) printClusterInfo() {
 func (a *Adapter) Connect() error {
 	es, err := elastic.NewDefaultClient()
 	if err != nil {
-		return errors.New("Could not create client - error: " + err.Error())
+		return store.WrapError(store.CodeUnavailable, err, "Could not create client - error: ")
 	}
 	a.es = es
+	if err := a.Ping(); err != nil {
+		a.es = nil
+		return store.WrapError("", err, "Could not connect to Elastic - error: ")
+	}
 	a.printClusterInfo()
 	err = a.setupIndex()
 	return err
@@ -94,7 +166,7 @@ func (a *Adapter) createIndex() error {
 		Index: a.indexName,
 		Body:  strings.NewReader(a.serializer.PayloadToString(params)),
 	}
-	res, err := req.Do(context.Background(), a.es)
+	res, err := req.Do(a.context(), a.es)
 	r := a.handleResponse(res, err, "Error creating index: "+a.indexName)
 	if r.IsError() {
 		return r.Error()
@@ -117,7 +189,7 @@ func (a *Adapter) updateMappings() error {
 		Index: []string{a.indexName},
 		Body:  strings.NewReader(a.serializer.PayloadToString(payload.Empty().Add("properties", properties))),
 	}
-	res, err := req.Do(context.Background(), a.es)
+	res, err := req.Do(a.context(), a.es)
 	r := a.handleResponse(res, err, "Error updating mappings for index: "+a.indexName)
 	if r.IsError() {
 		a.log.Error(r.Error())
@@ -136,6 +208,22 @@ func (a *Adapter) setupIndex() error {
 	return err
 }
 
+// Ping calls the cluster health API. A red cluster is not available.
+func (a *Adapter) Ping() error {
+	if a.es == nil {
+		return store.NewError(store.CodeUnavailable, "Elastic adapter not connected!")
+	}
+	res, err := a.es.Cluster.Health(a.es.Cluster.Health.WithContext(a.context()))
+	r := a.handleResponse(res, err, "Error on cluster health")
+	if r.IsError() {
+		return r.Error()
+	}
+	if status := r.Get("status").String(); status == "red" {
+		return store.NewError(store.CodeUnavailable, "Elastic cluster status: "+status)
+	}
+	return nil
+}
+
 func (a *Adapter) Disconnect() error {
 	a.es = nil
 	return nil
@@ -144,7 +232,7 @@ func (a *Adapter) Disconnect() error {
 //handleResponse parse the elastic response
 func (a *Adapter) handleResponse(res *esapi.Response, err error, errorMsg string) moleculer.Payload {
 	if err != nil {
-		return payload.New(err)
+		return payload.New(transportError(err, errorMsg, " - error: "))
 	}
 	defer res.Body.Close()
 	r := a.serializer.ReaderToPayload(res.Body)
@@ -152,7 +240,7 @@ func (a *Adapter) handleResponse(res *esapi.Response, err error, errorMsg string
 		errorMsg = errorMsg + " - root cause: " + r.Get("error").Get("root_cause").First().Get("reason").String()
 		a.log.Error(errorMsg)
 		a.log.Trace("Error payload: ", r)
-		return payload.PayloadError(errorMsg, r)
+		return payload.New(store.NewError(statusCode(res.StatusCode), errorMsg).WithData(map[string]interface{}{"status": res.StatusCode, "response": r.Value()}))
 	}
 	return r
 }
@@ -165,8 +253,10 @@ func (a *Adapter) Insert(params moleculer.Payload) moleculer.Payload {
 		Body:       strings.NewReader(a.serializer.PayloadToString(params)),
 		Refresh:    "true",
 	}
-	res, err := req.Do(context.Background(), a.es)
-	a.handleResponse(res, err, "Error indexing documentID: "+req.DocumentID)
+	res, err := req.Do(a.context(), a.es)
+	if r := a.handleResponse(res, err, "Error indexing documentID: "+req.DocumentID); r.IsError() {
+		return r
+	}
 	return params.Add("documentID", req.DocumentID)
 }
 
@@ -179,7 +269,7 @@ func (a *Adapter) RemoveAll() moleculer.Payload {
 		  "match_all": {}
 		}}`),
 	}
-	res, err := req.Do(context.Background(), a.es)
+	res, err := req.Do(a.context(), a.es)
 	return a.handleResponse(res, err, "Error deleting docs by query")
 }
 
@@ -190,47 +280,216 @@ func (a *Adapter) RemoveById(id moleculer.Payload) moleculer.Payload {
 		DocumentID: id.String(),
 		Refresh:    "true",
 	}
-	res, err := req.Do(context.Background(), a.es)
+	res, err := req.Do(a.context(), a.es)
 	return a.handleResponse(res, err, "Error deleting docs by id: "+id.String())
 }
 
 func (adapter *Adapter) Update(params moleculer.Payload) moleculer.Payload {
 	id := params.Get("documentID")
 	if !id.Exists() {
-		return payload.Error("Cannot update record without documentID")
+		return payload.New(store.NewError(store.CodeValidation, "Cannot update record without documentID"))
 	}
 	return adapter.UpdateById(id, params.Remove("documentID"))
 }
 
 //UpdateById update document by id
+//when the update contains operators ($inc, $push...) it is applied with a script, otherwise as a partial doc.
 func (a *Adapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	body := payload.Empty().Add("doc", update)
+	if dsl.HasOperators(update) {
+		script, err := updateScript(update)
+		if err != nil {
+			return payload.New(store.WrapError(store.CodeValidation, err, "Invalid update - error: "))
+		}
+		body = payload.Empty().Add("script", script)
+	}
 	req := esapi.UpdateRequest{
 		Index:      a.indexName,
 		DocumentID: id.String(),
-		Body:       strings.NewReader(a.serializer.PayloadToString(payload.Empty().Add("doc", update))),
+		Body:       strings.NewReader(a.serializer.PayloadToString(body)),
 		Refresh:    "true",
 	}
-	res, err := req.Do(context.Background(), a.es)
+	res, err := req.Do(a.context(), a.es)
 	return a.handleResponse(res, err, "Error updating doc by id: "+id.String())
 }
 
-func parseSearchFields(params, query moleculer.Payload) moleculer.Payload {
+// updateScript create a painless script that applies the update operations to the document source.
+func updateScript(update moleculer.Payload) (moleculer.Payload, error) {
+	ops, err := dsl.ParseUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{}
+	params := map[string]interface{}{}
+	for i, op := range ops {
+		name := strings.Replace(op.Field, "'", "\\'", -1)
+		field := "ctx._source['" + name + "']"
+		param := "params.p" + strconv.Itoa(i)
+		params["p"+strconv.Itoa(i)] = op.Value
+		switch op.Operator {
+		case dsl.Unset:
+			lines = append(lines, "ctx._source.remove('"+name+"');")
+		case dsl.Inc:
+			lines = append(lines, "if ("+field+" == null) { "+field+" = "+param+"; } else { "+field+" += "+param+"; }")
+		case dsl.Push:
+			lines = append(lines, "if ("+field+" == null) { "+field+" = []; } "+field+".add("+param+");")
+		case dsl.Pull:
+			lines = append(lines, "if ("+field+" != null) { "+field+".removeIf(item -> item == "+param+"); }")
+		default:
+			lines = append(lines, field+" = "+param+";")
+		}
+	}
+	return payload.Empty().Add("source", strings.Join(lines, " ")).Add("params", params), nil
+}
+
+//UpdateMany update all documents matching the query using update by query
+func (a *Adapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	script, err := updateScript(params.Get("update"))
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid update - error: "))
+	}
+	filter, err := parseFilter(params)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid query - error: "))
+	}
+	refresh := true
+	body := payload.Empty().Add("query", filter.Get("query").Value()).Add("script", script)
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{a.indexName},
+		Body:      strings.NewReader(a.serializer.PayloadToString(body)),
+		Conflicts: "proceed",
+		Refresh:   &refresh,
+	}
+	res, err := req.Do(a.context(), a.es)
+	r := a.handleResponse(res, err, "Error updating docs by query")
+	if r.IsError() {
+		return r
+	}
+	return payload.Empty().Add("modifiedCount", r.Get("updated").Int()).Add("matchedCount", r.Get("total").Int())
+}
+
+//RemoveMany remove all documents matching the query using delete by query
+func (a *Adapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	filter, err := parseFilter(params)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid query - error: "))
+	}
+	refresh := true
+	body := payload.Empty().Add("query", filter.Get("query").Value())
+	req := esapi.DeleteByQueryRequest{
+		Index:     []string{a.indexName},
+		Body:      strings.NewReader(a.serializer.PayloadToString(body)),
+		Conflicts: "proceed",
+		Refresh:   &refresh,
+	}
+	res, err := req.Do(a.context(), a.es)
+	r := a.handleResponse(res, err, "Error deleting docs by query")
+	if r.IsError() {
+		return r
+	}
+	return payload.Empty().Add("deletedCount", r.Get("deleted").Int())
+}
+
+//parseSearchFields create the multi_match query for the search and searchFields params.
+func parseSearchFields(params moleculer.Payload) map[string]interface{} {
 	searchFields := params.Get("searchFields")
 	search := params.Get("search")
-	mm := payload.Empty()
+	mm := map[string]interface{}{}
 	if search.Exists() {
-		mm.Add("query", search.String())
+		mm["query"] = search.String()
 	}
 	if searchFields.Exists() {
-		fields := searchFields.StringArray()
-		mm.Add("fields", fields)
+		mm["fields"] = searchFields.StringArray()
 	}
-	if mm.Len() > 0 {
-		query = query.Add("multi_match", mm)
-	} else {
-		query = query.Add("match_all", payload.Empty())
+	if len(mm) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"multi_match": mm}
+}
+
+//likeToWildcard converts a $like pattern (% and _ wildcards) to an elastic wildcard pattern.
+func likeToWildcard(pattern string) string {
+	wildcard := ""
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			wildcard += "*"
+		case '_':
+			wildcard += "?"
+		case '*', '?', '\\':
+			wildcard += "\\" + string(r)
+		default:
+			wildcard += string(r)
+		}
 	}
-	return query
+	return wildcard
+}
+
+func boolQuery(clause string, queries ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"bool": map[string]interface{}{clause: queries}}
+}
+
+func existsQuery(field string) map[string]interface{} {
+	return map[string]interface{}{"exists": map[string]interface{}{"field": field}}
+}
+
+//conditionQuery translates a query condition to an elastic query.
+func conditionQuery(c dsl.Condition) map[string]interface{} {
+	switch c.Operator {
+	case dsl.Eq:
+		return map[string]interface{}{"term": map[string]interface{}{c.Field: c.Value}}
+	case dsl.Ne:
+		return map[string]interface{}{"bool": map[string]interface{}{
+			"filter":   []interface{}{existsQuery(c.Field)},
+			"must_not": []interface{}{map[string]interface{}{"term": map[string]interface{}{c.Field: c.Value}}},
+		}}
+	case dsl.In:
+		return map[string]interface{}{"terms": map[string]interface{}{c.Field: c.Value}}
+	case dsl.Nin:
+		return map[string]interface{}{"bool": map[string]interface{}{
+			"filter":   []interface{}{existsQuery(c.Field)},
+			"must_not": []interface{}{map[string]interface{}{"terms": map[string]interface{}{c.Field: c.Value}}},
+		}}
+	case dsl.Between:
+		pair, _ := c.Value.([]interface{})
+		if len(pair) != 2 {
+			return map[string]interface{}{"match_none": map[string]interface{}{}}
+		}
+		return map[string]interface{}{"range": map[string]interface{}{c.Field: map[string]interface{}{"gte": pair[0], "lte": pair[1]}}}
+	case dsl.Like:
+		pattern, _ := c.Value.(string)
+		return map[string]interface{}{"wildcard": map[string]interface{}{c.Field: likeToWildcard(pattern)}}
+	case dsl.Exists:
+		if exists, _ := c.Value.(bool); exists {
+			return existsQuery(c.Field)
+		}
+		return boolQuery("must_not", existsQuery(c.Field))
+	}
+	operator := strings.TrimPrefix(c.Operator, "$")
+	return map[string]interface{}{"range": map[string]interface{}{c.Field: map[string]interface{}{operator: c.Value}}}
+}
+
+//queryClause translates the query to an elastic query.
+func queryClause(node dsl.Node) map[string]interface{} {
+	switch n := node.(type) {
+	case dsl.Condition:
+		return conditionQuery(n)
+	case dsl.Negation:
+		return boolQuery("must_not", queryClause(n.Node))
+	case dsl.Logical:
+		if n.Operator == dsl.Or && len(n.Nodes) == 0 {
+			return map[string]interface{}{"match_none": map[string]interface{}{}}
+		}
+		queries := []interface{}{}
+		for _, child := range n.Nodes {
+			queries = append(queries, queryClause(child))
+		}
+		if n.Operator == dsl.Or {
+			return map[string]interface{}{"bool": map[string]interface{}{"should": queries, "minimum_should_match": 1}}
+		}
+		return boolQuery("filter", queries...)
+	}
+	return map[string]interface{}{"match_all": map[string]interface{}{}}
 }
 
 func parseQueryParams(params moleculer.Payload) moleculer.Payload {
@@ -297,14 +556,32 @@ func sortsFromStringArray(sort moleculer.Payload) map[string]string {
 	return sorts
 }
 
-func parseFilter(params moleculer.Payload) moleculer.Payload {
-	query := payload.Empty()
-	if params.Get("query").Exists() {
-		query = params.Get("query")
+//parseFilter create the search body for the query, nativeQuery, search and find options params.
+//the query uses the portable query language (see package dsl) and
+//nativeQuery is a raw elastic query.
+func parseFilter(params moleculer.Payload) (moleculer.Payload, error) {
+	node, err := dsl.Parse(params.Get("query"))
+	if err != nil {
+		return nil, err
+	}
+	filters := []interface{}{}
+	if logical, ok := node.(dsl.Logical); !ok || len(logical.Nodes) > 0 {
+		filters = append(filters, queryClause(node))
+	}
+	if params.Get("nativeQuery").Exists() {
+		filters = append(filters, params.Get("nativeQuery").Value())
+	}
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	search := parseSearchFields(params)
+	if len(filters) > 0 || search != nil {
+		clauses := map[string]interface{}{"filter": filters}
+		if search != nil {
+			clauses["must"] = []interface{}{search}
+		}
+		query = map[string]interface{}{"bool": clauses}
 	}
-	query = parseSearchFields(params, query)
 	queryParams := parseQueryParams(params)
-	return queryParams.Add("query", query)
+	return queryParams.Add("query", query), nil
 }
 
 func getHits(params, search moleculer.Payload) moleculer.Payload {
@@ -313,18 +590,22 @@ func getHits(params, search moleculer.Payload) moleculer.Payload {
 
 func (a *Adapter) Find(params moleculer.Payload) moleculer.Payload {
 
-	query := a.serializer.PayloadToString(parseFilter(params))
+	filter, err := parseFilter(params)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid query - error: "))
+	}
+	query := a.serializer.PayloadToString(filter)
 	a.log.Traceln("Find() params: ", params, "query: ", query)
 
 	res, err := a.es.Search(
-		a.es.Search.WithContext(context.Background()),
+		a.es.Search.WithContext(a.context()),
 		a.es.Search.WithIndex(a.indexName),
 		a.es.Search.WithBody(strings.NewReader(query)),
 		a.es.Search.WithTrackTotalHits(true),
 		a.es.Search.WithPretty(),
 	)
 	if err != nil {
-		return payload.New(err)
+		return payload.New(transportError(err, "error executing search - error: "))
 	}
 	defer res.Body.Close()
 	p := a.serializer.ReaderToPayload(res.Body)
@@ -332,7 +613,7 @@ func (a *Adapter) Find(params moleculer.Payload) moleculer.Payload {
 		//a.log.Error("error executing search - ", a.serializer.PayloadToString(p))
 		msg := "error executing search. root cause: " + p.Get("error").Get("root_cause").First().Get("reason").String()
 		a.log.Error(msg)
-		return payload.Error(msg)
+		return payload.New(store.NewError(statusCode(res.StatusCode), msg).WithData(map[string]interface{}{"status": res.StatusCode}))
 	}
 
 	a.log.Traceln("search result:")
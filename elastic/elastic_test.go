@@ -327,3 +327,32 @@ var _ = XDescribe("Elastic", func() {
 		Expect(r.First().Get("age").Int()).Should(Equal(38))
 	})
 })
+
+var _ = Describe("Query translation", func() {
+
+	It("parseFilter should translate the query language to elastic queries", func() {
+		out, err := parseFilter(payload.Empty().Add("query", map[string]interface{}{
+			"age":  map[string]interface{}{"$gte": 18},
+			"name": map[string]interface{}{"$like": "jo%"},
+		}))
+		Expect(err).Should(BeNil())
+		filter := out.Get("query").Get("bool").Get("filter").First().Get("bool").Get("filter").Array()
+		Expect(filter[0].Get("range").Get("age").Get("gte").Int()).Should(Equal(18))
+		Expect(filter[1].Get("wildcard").Get("name").String()).Should(Equal("jo*"))
+	})
+
+	It("parseFilter should use match_all for empty queries and multi_match for search", func() {
+		out, err := parseFilter(payload.Empty())
+		Expect(err).Should(BeNil())
+		Expect(out.Get("query").Get("match_all").Exists()).Should(BeTrue())
+
+		out, err = parseFilter(payload.Empty().Add("search", "John").Add("searchFields", []string{"name"}))
+		Expect(err).Should(BeNil())
+		Expect(out.Get("query").Get("bool").Get("must").First().Get("multi_match").Get("query").String()).Should(Equal("John"))
+	})
+
+	It("parseFilter should fail with invalid queries", func() {
+		_, err := parseFilter(payload.Empty().Add("query", map[string]interface{}{"$where": "true"}))
+		Expect(err).ShouldNot(BeNil())
+	})
+})
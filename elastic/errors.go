@@ -0,0 +1,36 @@
+package elastic
+
+import (
+	"net/http"
+
+	"github.com/moleculer-go/store"
+)
+
+// statusCode returns the store error code of an Elastic response status.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusNotFound:
+		return store.CodeNotFound
+	case http.StatusConflict:
+		return store.CodeConflict
+	case http.StatusBadRequest:
+		return store.CodeValidation
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return store.CodeForbidden
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return store.CodeTimeout
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return store.CodeUnavailable
+	}
+	return store.CodeInternal
+}
+
+// transportError returns the error of a request that did not reach Elastic as a *store.Error:
+// the context errors are timeouts and the others (e.g. connection refused) are unavailable.
+func transportError(err error, msgs ...interface{}) error {
+	code := store.ErrorCode(err)
+	if code == store.CodeInternal {
+		code = store.CodeUnavailable
+	}
+	return store.WrapError(code, err, msgs...)
+}
@@ -0,0 +1,539 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+	log "github.com/sirupsen/logrus"
+)
+
+// KeyProvider provides the keys used by the encryptedFields setting.
+// Keys must have 16, 24 or 32 bytes (AES-128, AES-192 or AES-256).
+type KeyProvider interface {
+	// Key returns the key of the id.
+	Key(id string) ([]byte, error)
+	// CurrentKeyID returns the id of the key used to encrypt new values.
+	CurrentKeyID() string
+	// KeyIDs returns the ids of all keys, including the current one.
+	KeyIDs() []string
+}
+
+// StaticKeys is a KeyProvider with a fixed set of keys (e.g. loaded from environment variables).
+type StaticKeys struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (s StaticKeys) Key(id string) ([]byte, error) {
+	key, exists := s.Keys[id]
+	if !exists {
+		return nil, errors.New("Unknown encryption key: " + id)
+	}
+	return key, nil
+}
+
+func (s StaticKeys) CurrentKeyID() string {
+	return s.Current
+}
+
+func (s StaticKeys) KeyIDs() []string {
+	ids := []string{}
+	for id := range s.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// encrypted values have the format enc:<mode>:<key id>:<base64 nonce + cipher text>
+// mode r uses a random nonce and mode d a nonce derived from the value (deterministic).
+var encryptedPrefix = "enc:"
+
+// encryptValue encrypts the value with AES-GCM using the key of the id.
+func encryptValue(keys KeyProvider, keyID string, deterministic bool, value string) (string, error) {
+	key, err := keys.Key(keyID)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	mode := "r"
+	if deterministic {
+		macKey := sha256.Sum256(append([]byte("deterministic:"), key...))
+		mac := hmac.New(sha256.New, macKey[:])
+		mac.Write([]byte(value))
+		copy(nonce, mac.Sum(nil))
+		mode = "d"
+	} else if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(keyID))
+	return encryptedPrefix + mode + ":" + keyID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// parseEncrypted splits an encrypted value. Returns false when the value is not encrypted.
+func parseEncrypted(value interface{}) (mode, keyID, data string, ok bool) {
+	text, isString := value.(string)
+	if !isString || !strings.HasPrefix(text, encryptedPrefix) {
+		return "", "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(text, encryptedPrefix), ":", 3)
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// encodeValue serializes the value as JSON before encryption, so numbers, booleans, lists and maps keep their type.
+func encodeValue(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeValue restores a value serialized by encodeValue.
+// Values encrypted before they were serialized as JSON are returned as strings.
+func decodeValue(plain string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(plain), &value); err != nil {
+		return plain
+	}
+	return value
+}
+
+// decryptValue decrypts a value created by encryptValue.
+func decryptValue(keys KeyProvider, value interface{}) (string, error) {
+	_, keyID, data, ok := parseEncrypted(value)
+	if !ok {
+		return "", errors.New("value is not encrypted")
+	}
+	key, err := keys.Key(keyID)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted value")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// parseEncryptedFields parses the encryptedFields setting. Returns the deterministic flag of each field.
+// "encryptedFields": []string{"token"}
+// "encryptedFields": map[string]interface{}{
+//	"nationalId": map[string]interface{}{"deterministic": true}, // allows equality queries
+//	"token":      true,
+// }
+func parseEncryptedFields(settings map[string]interface{}) map[string]bool {
+	fields := map[string]bool{}
+	switch setting := settings["encryptedFields"].(type) {
+	case []string:
+		for _, field := range setting {
+			fields[field] = false
+		}
+	case map[string]interface{}:
+		for field, value := range setting {
+			fields[field] = payload.New(value).Get("deterministic").Bool()
+		}
+	}
+	return fields
+}
+
+// encryptionAdapter returns the adapter encrypting and decrypting the encryptedFields.
+// When encryptedFields is not set, it returns the adapter itself.
+func encryptionAdapter(adapter Adapter, instance *moleculer.ServiceSchema) (Adapter, error) {
+	if instance == nil {
+		return adapter, nil
+	}
+	fields := parseEncryptedFields(instance.Settings)
+	if len(fields) == 0 {
+		return adapter, nil
+	}
+	keys, ok := instance.Settings["keyProvider"].(KeyProvider)
+	if !ok {
+		return nil, NewError(CodeValidation, "encryptedFields requires a keyProvider setting!")
+	}
+	return &encryptionScope{adapter, keys, fields}, nil
+}
+
+// encryptionScope wraps an adapter encrypting the fields on the way in and decrypting them on the way out.
+type encryptionScope struct {
+	adapter Adapter
+	keys    KeyProvider
+	// fields has the deterministic flag of each encrypted field.
+	fields map[string]bool
+}
+
+func (e *encryptionScope) Init(logger *log.Entry, settings map[string]interface{}) {
+	e.adapter.Init(logger, settings)
+}
+
+func (e *encryptionScope) Connect() error {
+	return e.adapter.Connect()
+}
+
+func (e *encryptionScope) Disconnect() error {
+	return e.adapter.Disconnect()
+}
+
+// encrypt encrypts the value of the field with the current key. Values from the caller are always encrypted,
+// even when they look encrypted, so callers can't store their own cipher texts.
+func (e *encryptionScope) encrypt(field string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	plain, err := encodeValue(value)
+	if err != nil {
+		return nil, WrapError(CodeValidation, err, "Could not encrypt field: "+field+" - error: ")
+	}
+	return encryptValue(e.keys, e.keys.CurrentKeyID(), e.fields[field], plain)
+}
+
+// encryptUpdate encrypts the values set in the record or update. Only $set and $unset are allowed for encrypted fields.
+func (e *encryptionScope) encryptUpdate(update moleculer.Payload) (moleculer.Payload, error) {
+	if update == nil || !update.Exists() {
+		return update, nil
+	}
+	ops, err := dsl.ParseUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if _, encrypted := e.fields[op.Field]; !encrypted {
+			continue
+		}
+		switch op.Operator {
+		case dsl.Set:
+			if ops[i].Value, err = e.encrypt(op.Field, op.Value); err != nil {
+				return nil, err
+			}
+		case dsl.Unset:
+		default:
+			return nil, NewError(CodeValidation, "Update operator "+op.Operator+" is not supported on encrypted field: "+op.Field)
+		}
+	}
+	return payload.New(dsl.FormatUpdate(ops)), nil
+}
+
+func (e *encryptionScope) decryptRecord(record moleculer.Payload) (moleculer.Payload, error) {
+	if record == nil || record.IsError() || !record.IsMap() {
+		return record, nil
+	}
+	for field := range e.fields {
+		value := record.Get(field).Value()
+		if _, _, _, encrypted := parseEncrypted(value); !encrypted {
+			continue
+		}
+		plain, err := decryptValue(e.keys, value)
+		if err != nil {
+			return nil, errors.New("Could not decrypt field: " + field + " - error: " + err.Error())
+		}
+		record = record.Add(field, decodeValue(plain))
+	}
+	return record, nil
+}
+
+// decrypt decrypts a single record or a list of records.
+func (e *encryptionScope) decrypt(result moleculer.Payload) moleculer.Payload {
+	if result == nil || result.IsError() || !result.Exists() {
+		return result
+	}
+	if !result.IsArray() {
+		record, err := e.decryptRecord(result)
+		if err != nil {
+			return payload.New(err)
+		}
+		return record
+	}
+	list := []moleculer.Payload{}
+	var err error
+	result.ForEach(func(_ interface{}, item moleculer.Payload) bool {
+		var record moleculer.Payload
+		record, err = e.decryptRecord(item)
+		list = append(list, record)
+		return err == nil
+	})
+	if err != nil {
+		return payload.New(err)
+	}
+	return payload.New(list)
+}
+
+// ciphers returns the value encrypted with each key, so equality queries match values of all keys.
+// Values encrypted before they were serialized as JSON are matched by their text.
+func (e *encryptionScope) ciphers(values []interface{}) ([]interface{}, error) {
+	list := []interface{}{}
+	for _, id := range e.keys.KeyIDs() {
+		for _, value := range values {
+			plain, err := encodeValue(value)
+			if err != nil {
+				return nil, WrapError(CodeValidation, err)
+			}
+			plains := []string{plain}
+			if text := fmt.Sprint(value); text != plain {
+				plains = append(plains, text)
+			}
+			for _, plain := range plains {
+				cipherText, err := encryptValue(e.keys, id, true, plain)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, cipherText)
+			}
+		}
+	}
+	return list, nil
+}
+
+// rewrite replaces the conditions on encrypted fields. Deterministic fields support $eq, $ne, $in, $nin and $exists.
+func (e *encryptionScope) rewrite(node dsl.Node) (dsl.Node, error) {
+	switch n := node.(type) {
+	case dsl.Condition:
+		deterministic, encrypted := e.fields[n.Field]
+		if !encrypted || n.Operator == dsl.Exists {
+			return n, nil
+		}
+		if !deterministic {
+			return nil, NewError(CodeValidation, "Can't query encrypted field: "+n.Field+" - use deterministic encryption to allow equality queries.")
+		}
+		switch n.Operator {
+		case dsl.Eq, dsl.Ne:
+			values, err := e.ciphers([]interface{}{n.Value})
+			if n.Operator == dsl.Eq {
+				return dsl.Condition{Field: n.Field, Operator: dsl.In, Value: values}, err
+			}
+			return dsl.Condition{Field: n.Field, Operator: dsl.Nin, Value: values}, err
+		case dsl.In, dsl.Nin:
+			items, _ := n.Value.([]interface{})
+			values, err := e.ciphers(items)
+			return dsl.Condition{Field: n.Field, Operator: n.Operator, Value: values}, err
+		}
+		return nil, NewError(CodeValidation, "Query operator "+n.Operator+" is not supported on encrypted field: "+n.Field)
+	case dsl.Negation:
+		child, err := e.rewrite(n.Node)
+		return dsl.Negation{Node: child}, err
+	case dsl.Logical:
+		nodes := []dsl.Node{}
+		for _, child := range n.Nodes {
+			rewritten, err := e.rewrite(child)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, rewritten)
+		}
+		return dsl.Logical{Operator: n.Operator, Nodes: nodes}, nil
+	}
+	return node, nil
+}
+
+// rewriteQuery replaces the conditions on encrypted fields in the query param.
+func (e *encryptionScope) rewriteQuery(params moleculer.Payload) (moleculer.Payload, error) {
+	if params == nil || !params.Get("query").Exists() {
+		return params, nil
+	}
+	node, err := dsl.Parse(params.Get("query"))
+	if err != nil {
+		return nil, err
+	}
+	node, err = e.rewrite(node)
+	if err != nil {
+		return nil, err
+	}
+	return params.Add("query", dsl.Format(node)), nil
+}
+
+// rewriteQueryAndUpdate rewrites the query and encrypts the update param.
+func (e *encryptionScope) rewriteQueryAndUpdate(params moleculer.Payload) (moleculer.Payload, error) {
+	params, err := e.rewriteQuery(params)
+	if err != nil {
+		return nil, err
+	}
+	update, err := e.encryptUpdate(params.Get("update"))
+	if err != nil {
+		return nil, err
+	}
+	if update != nil && update.Exists() {
+		params = params.Add("update", update)
+	}
+	return params, nil
+}
+
+func (e *encryptionScope) Find(params moleculer.Payload) moleculer.Payload {
+	params, err := e.rewriteQuery(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return e.decrypt(e.adapter.Find(params))
+}
+
+func (e *encryptionScope) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	params, err := e.rewriteQueryAndUpdate(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return e.decrypt(e.adapter.FindAndUpdate(params))
+}
+
+func (e *encryptionScope) FindOne(params moleculer.Payload) moleculer.Payload {
+	params, err := e.rewriteQuery(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return e.decrypt(e.adapter.FindOne(params))
+}
+
+func (e *encryptionScope) FindById(id moleculer.Payload) moleculer.Payload {
+	return e.decrypt(e.adapter.FindById(id))
+}
+
+func (e *encryptionScope) FindByIds(ids moleculer.Payload) moleculer.Payload {
+	return e.decrypt(e.adapter.FindByIds(ids))
+}
+
+func (e *encryptionScope) Count(params moleculer.Payload) moleculer.Payload {
+	params, err := e.rewriteQuery(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return e.adapter.Count(params)
+}
+
+func (e *encryptionScope) Insert(params moleculer.Payload) moleculer.Payload {
+	values, err := e.encryptUpdate(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return e.decrypt(e.adapter.Insert(values))
+}
+
+func (e *encryptionScope) Update(params moleculer.Payload) moleculer.Payload {
+	return e.UpdateById(params.Get("id"), params.Remove("id"))
+}
+
+func (e *encryptionScope) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	update, err := e.encryptUpdate(update)
+	if err != nil {
+		return payload.New(err)
+	}
+	return e.decrypt(e.adapter.UpdateById(id, update))
+}
+
+func (e *encryptionScope) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	params, err := e.rewriteQueryAndUpdate(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return e.adapter.UpdateMany(params)
+}
+
+func (e *encryptionScope) RemoveById(id moleculer.Payload) moleculer.Payload {
+	return e.adapter.RemoveById(id)
+}
+
+func (e *encryptionScope) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	params, err := e.rewriteQuery(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return e.adapter.RemoveMany(params)
+}
+
+func (e *encryptionScope) RemoveAll() moleculer.Payload {
+	return e.adapter.RemoveAll()
+}
+
+// rotateKeys re-encrypts the values that were not encrypted with the current key. Returns the number of records changed.
+func (e *encryptionScope) rotateKeys(idField string) (int, error) {
+	records := e.adapter.Find(payload.Empty())
+	if records.IsError() {
+		return 0, records.Error()
+	}
+	current := e.keys.CurrentKeyID()
+	modified := 0
+	var err error
+	records.ForEach(func(_ interface{}, record moleculer.Payload) bool {
+		update := map[string]interface{}{}
+		for field, deterministic := range e.fields {
+			value := record.Get(field).Value()
+			_, keyID, _, encrypted := parseEncrypted(value)
+			if !encrypted || keyID == current {
+				continue
+			}
+			var plain string
+			if plain, err = decryptValue(e.keys, value); err != nil {
+				err = errors.New("Could not decrypt field: " + field + " - error: " + err.Error())
+				return false
+			}
+			if update[field], err = encryptValue(e.keys, current, deterministic, plain); err != nil {
+				return false
+			}
+		}
+		if len(update) == 0 {
+			return true
+		}
+		r := e.adapter.UpdateById(record.Get(idField), payload.New(update))
+		if r.IsError() {
+			err = r.Error()
+			return false
+		}
+		modified++
+		return true
+	})
+	return modified, err
+}
+
+// rotateKeysAction re-encrypts the encryptedFields of all records with the current key of the keyProvider.
+func rotateKeysAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		scope, ok := adapter.(*encryptionScope)
+		if !ok {
+			return payload.New(NewError(CodeValidation, "rotateKeys requires the encryptedFields and keyProvider settings!"))
+		}
+		idField, ok := getInstance().Settings["idField"].(string)
+		if !ok {
+			idField = "id"
+		}
+		modified, err := scope.rotateKeys(idField)
+		if err != nil {
+			return payload.New(WrapError("", err, "Could not rotate keys. Error: "))
+		}
+		return payload.Empty().Add("modifiedCount", modified)
+	}
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// Codes of the typed errors returned by the adapters and the actions. The codes are stable, compare them instead of the messages.
+const (
+	// CodeNotFound the record does not exist. e.g. UpdateById or RemoveById with an unknown id.
+	CodeNotFound = "NOT_FOUND"
+	// CodeConflict the write conflicts with the existing records. e.g. duplicate value for a unique index.
+	CodeConflict = "CONFLICT"
+	// CodeValidation the params are invalid. e.g. missing id, invalid query or update.
+	CodeValidation = "VALIDATION"
+	// CodeForbidden the caller is not allowed to read or change the record or the field.
+	CodeForbidden = "FORBIDDEN"
+	// CodeUnavailable the database is not available. e.g. not connected, connection lost.
+	CodeUnavailable = "UNAVAILABLE"
+	// CodeTimeout the deadline of the operation expired or the operation was cancelled.
+	CodeTimeout = "TIMEOUT"
+	// CodeInternal any other error.
+	CodeInternal = "INTERNAL"
+)
+
+// Error is the typed error of the adapters and the actions. The error payloads of the actions contain an *Error:
+//
+//	r := <-bkr.Call("user.remove", map[string]interface{}{"id": id})
+//	if store.ErrorCode(r.Error()) == store.CodeNotFound { ... }
+type Error struct {
+	Code    string
+	Message string
+	// Data about the error. e.g. {"id": id} for CodeNotFound, {"index": name} for CodeConflict.
+	Data map[string]interface{}
+	// Err is the cause of the error, usually the driver error.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the cause of the error.
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Is returns true when target is an *Error with the same code.
+func (e *Error) Is(target error) bool {
+	other, ok := target.(*Error)
+	return ok && other.Code == e.Code
+}
+
+// WithData adds the data to the error and returns it.
+func (e *Error) WithData(data map[string]interface{}) *Error {
+	if e.Data == nil {
+		e.Data = map[string]interface{}{}
+	}
+	for key, value := range data {
+		e.Data[key] = value
+	}
+	return e
+}
+
+// Map returns the code, message and data of the error.
+func (e *Error) Map() map[string]interface{} {
+	result := map[string]interface{}{"code": e.Code, "message": e.Message}
+	if len(e.Data) > 0 {
+		result["data"] = e.Data
+	}
+	return result
+}
+
+// NewError returns a typed error with the message (the msgs joined as fmt.Sprint).
+func NewError(code string, msgs ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprint(msgs...)}
+}
+
+// WrapError returns a typed error caused by err, with the msgs followed by the message of err.
+// When code is empty the code of err is kept (see ErrorCode), as well as its data.
+func WrapError(code string, err error, msgs ...interface{}) *Error {
+	if code == "" {
+		code = ErrorCode(err)
+	}
+	wrapped := &Error{Code: code, Message: fmt.Sprint(append(msgs, err.Error())...), Err: err}
+	if typed, ok := asError(err); ok {
+		wrapped.WithData(typed.Data)
+	}
+	return wrapped
+}
+
+// unwrapError returns the cause of err, or nil when it has no cause.
+func unwrapError(err error) error {
+	switch cause := err.(type) {
+	case interface{ Unwrap() error }:
+		return cause.Unwrap()
+	case interface{ Cause() error }:
+		return cause.Cause()
+	}
+	return nil
+}
+
+// asError returns the first *Error in the chain of causes of err.
+func asError(err error) (*Error, bool) {
+	for ; err != nil; err = unwrapError(err) {
+		if typed, ok := err.(*Error); ok {
+			return typed, true
+		}
+	}
+	return nil, false
+}
+
+// ErrorCode returns the code of the error: the code of the first *Error in the chain of causes,
+// CodeTimeout for the context errors, CodeInternal for any other error and "" for nil.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	for cause := err; cause != nil; cause = unwrapError(cause) {
+		if typed, ok := cause.(*Error); ok {
+			return typed.Code
+		}
+		if cause == context.DeadlineExceeded || cause == context.Canceled {
+			return CodeTimeout
+		}
+	}
+	return CodeInternal
+}
+
+// IsNotFound returns true when the code of the error is CodeNotFound.
+func IsNotFound(err error) bool {
+	return ErrorCode(err) == CodeNotFound
+}
+
+// IsConflict returns true when the code of the error is CodeConflict.
+func IsConflict(err error) bool {
+	return ErrorCode(err) == CodeConflict
+}
+
+// IsValidation returns true when the code of the error is CodeValidation.
+func IsValidation(err error) bool {
+	return ErrorCode(err) == CodeValidation
+}
+
+// IsUnavailable returns true when the code of the error is CodeUnavailable.
+func IsUnavailable(err error) bool {
+	return ErrorCode(err) == CodeUnavailable
+}
+
+// IsTimeout returns true when the code of the error is CodeTimeout.
+func IsTimeout(err error) bool {
+	return ErrorCode(err) == CodeTimeout
+}
+
+// typedResult returns the result of an action with the error as an *Error, so all actions return typed errors.
+func typedResult(result interface{}) interface{} {
+	p, ok := result.(moleculer.Payload)
+	if !ok || p == nil || !p.IsError() {
+		return result
+	}
+	if _, typed := p.Error().(*Error); typed {
+		return p
+	}
+	return payload.New(WrapError("", p.Error()))
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Errors", func() {
+
+	It("should return the code of the errors", func() {
+		Expect(ErrorCode(nil)).Should(Equal(""))
+		Expect(ErrorCode(errors.New("boom"))).Should(Equal(CodeInternal))
+		Expect(ErrorCode(context.DeadlineExceeded)).Should(Equal(CodeTimeout))
+		Expect(IsTimeout(context.Canceled)).Should(BeTrue())
+
+		notFound := NewError(CodeNotFound, "Could not find record with id: ", 10).WithData(M{"id": 10})
+		Expect(notFound.Error()).Should(Equal("Could not find record with id: 10"))
+		Expect(IsNotFound(notFound)).Should(BeTrue())
+		Expect(notFound.Map()).Should(Equal(map[string]interface{}{
+			"code": CodeNotFound, "message": "Could not find record with id: 10", "data": map[string]interface{}{"id": 10},
+		}))
+
+		wrapped := WrapError("", notFound, "Could not remove record. Error: ")
+		Expect(wrapped.Code).Should(Equal(CodeNotFound))
+		Expect(wrapped.Error()).Should(Equal("Could not remove record. Error: Could not find record with id: 10"))
+		Expect(wrapped.Data).Should(Equal(map[string]interface{}{"id": 10}))
+		Expect(wrapped.Unwrap()).Should(BeIdenticalTo(notFound))
+
+		Expect(ErrorCode(WrapError(CodeUnavailable, context.DeadlineExceeded))).Should(Equal(CodeUnavailable))
+		Expect(ErrorCode(WrapError("", context.DeadlineExceeded))).Should(Equal(CodeTimeout))
+	})
+
+	Describe("memory adapter", func() {
+		var adapter *MemoryAdapter
+		var marie moleculer.Payload
+		BeforeEach(func() {
+			adapter = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+			adapter.Init(log.WithField("test", "errors"), M{"indexes": []M{{"fields": []string{"email"}, "unique": true}}})
+			Expect(adapter.Connect()).Should(Succeed())
+			marie = adapter.Insert(payload.New(M{"name": "Marie", "email": "marie@m.com"}))
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should return typed errors", func() {
+			r := adapter.Insert(payload.New(M{"name": "Marie 2", "email": "marie@m.com"}))
+			Expect(ErrorCode(r.Error())).Should(Equal(CodeConflict))
+			Expect(r.Error().(*Error).Data["index"]).Should(Equal("email"))
+
+			r = adapter.Find(payload.New(M{"query": M{"age": M{"$unknown": 1}}}))
+			Expect(ErrorCode(r.Error())).Should(Equal(CodeValidation))
+
+			r = adapter.UpdateById(payload.New("unknown"), payload.New(M{"age": 30}))
+			Expect(ErrorCode(r.Error())).Should(Equal(CodeNotFound))
+			Expect(r.Error().(*Error).Data).Should(Equal(map[string]interface{}{"id": "unknown"}))
+
+			r = adapter.RemoveById(payload.New("unknown"))
+			Expect(IsNotFound(r.Error())).Should(BeTrue())
+			Expect(adapter.RemoveById(marie.Get("id")).Get("deletedCount").Int()).Should(Equal(1))
+		})
+	})
+
+	Describe("actions", func() {
+		var adapter *MemoryAdapter
+		BeforeEach(func() {
+			adapter = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+			mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		svc := &moleculer.ServiceSchema{Name: "user", Settings: M{}}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		ctx, _ := contextAndDelegated("errors-test", moleculer.Config{})
+
+		It("should return the typed errors of the adapter", func() {
+			remove := scopedAction(adapter, getInstance, &tenantAdapters{}, removeAction)
+			r := remove(ctx.(moleculer.Context), payload.New(M{"id": "unknown"})).(moleculer.Payload)
+			Expect(IsNotFound(r.Error())).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("Could not remove record. Error: Could not find record with id: unknown"))
+
+			update := scopedAction(adapter, getInstance, &tenantAdapters{}, updateAction)
+			r = update(ctx.(moleculer.Context), payload.New(M{"name": "Nobody"})).(moleculer.Payload)
+			Expect(IsValidation(r.Error())).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("id field required!"))
+		})
+
+		It("should return the untyped errors as internal errors", func() {
+			failing := func(Adapter, func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+				return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+					return payload.Error("boom")
+				}
+			}
+			r := scopedAction(adapter, getInstance, &tenantAdapters{}, failing)(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+			Expect(ErrorCode(r.Error())).Should(Equal(CodeInternal))
+			Expect(r.Error().Error()).Should(Equal("boom"))
+		})
+
+		It("should fail with unavailable while the adapter is not connected", func() {
+			status := &health{}
+			wrapped := Wrap(adapter, requireReady(status))
+			Expect(IsUnavailable(wrapped.Count(payload.Empty()).Error())).Should(BeTrue())
+			Expect(IsUnavailable(wrapped.(IndexAdapter).Indexes().Error())).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+)
+
+// fieldRule has the roles/scopes allowed to read and write a field. Empty means everyone.
+type fieldRule struct {
+	read  []string
+	write []string
+}
+
+// parseFieldAccess parses the fieldAccess setting:
+// "fieldAccess": map[string]interface{}{
+//	"salary": map[string]interface{}{"read": []string{"admin", "hr"}, "write": []string{"hr"}},
+//	"email":  map[string]interface{}{"read": []string{"admin"}},
+// }
+func parseFieldAccess(settings map[string]interface{}) map[string]fieldRule {
+	config, ok := settings["fieldAccess"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	rules := map[string]fieldRule{}
+	for field, value := range config {
+		rule := payload.New(value)
+		rules[field] = fieldRule{stringList(rule.Get("read")), stringList(rule.Get("write"))}
+	}
+	return rules
+}
+
+// stringList accepts a single string or a list of strings.
+func stringList(value moleculer.Payload) []string {
+	if value == nil || !value.Exists() {
+		return nil
+	}
+	if value.IsArray() {
+		return value.StringArray()
+	}
+	return []string{value.String()}
+}
+
+// callerRoles returns the roles and scopes of the caller, from ctx.Meta.roles and ctx.Meta.scopes.
+func callerRoles(ctx moleculer.Context) []string {
+	if ctx == nil || ctx.Meta() == nil {
+		return nil
+	}
+	meta := ctx.Meta()
+	return append(stringList(meta.Get("roles")), stringList(meta.Get("scopes"))...)
+}
+
+// hasAnyRole checks if the caller has one of the required roles. No required roles means everyone.
+func hasAnyRole(required, roles []string) bool {
+	if len(required) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if contains(required, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// hideFields removes from the result the fields the caller is not allowed to read.
+func hideFields(ctx moleculer.Context, result moleculer.Payload, settings map[string]interface{}) moleculer.Payload {
+	rules := parseFieldAccess(settings)
+	if len(rules) == 0 || result == nil || result.IsError() || !result.Exists() {
+		return result
+	}
+	roles := callerRoles(ctx)
+	hidden := []string{}
+	for field, rule := range rules {
+		if !hasAnyRole(rule.read, roles) {
+			hidden = append(hidden, field)
+		}
+	}
+	if len(hidden) == 0 {
+		return result
+	}
+	if result.IsArray() {
+		list := []moleculer.Payload{}
+		result.ForEach(func(index interface{}, item moleculer.Payload) bool {
+			list = append(list, item.Remove(hidden...))
+			return true
+		})
+		return payload.New(list)
+	}
+	return result.Remove(hidden...)
+}
+
+// checkWriteAccess returns an error when the caller is not allowed to write one of the fields.
+// values can be a record (create) or an update with update operators.
+func checkWriteAccess(ctx moleculer.Context, values moleculer.Payload, settings map[string]interface{}) error {
+	rules := parseFieldAccess(settings)
+	if len(rules) == 0 || values == nil || !values.Exists() {
+		return nil
+	}
+	ops, err := dsl.ParseUpdate(values)
+	if err != nil {
+		return WrapError(CodeValidation, err)
+	}
+	roles := callerRoles(ctx)
+	for _, op := range ops {
+		field := strings.Split(op.Field, ".")[0]
+		if rule, ok := rules[field]; ok && !hasAnyRole(rule.write, roles) {
+			return NewError(CodeForbidden, "Not allowed to write field: "+field).WithData(map[string]interface{}{"field": field})
+		}
+	}
+	return nil
+}
+
+// checkReadAccess returns an error when the query, sort or searchFields params use a field the caller is not allowed to read,
+// since filtering by a hidden field reveals its value.
+func checkReadAccess(ctx moleculer.Context, params moleculer.Payload, settings map[string]interface{}) error {
+	rules := parseFieldAccess(settings)
+	if len(rules) == 0 || params == nil || !params.IsMap() {
+		return nil
+	}
+	fields := []string{}
+	if query := params.Get("query"); query.Exists() {
+		node, err := dsl.Parse(query)
+		if err != nil {
+			return WrapError(CodeValidation, err)
+		}
+		fields = append(fields, dsl.Fields(node)...)
+	}
+	if sort := params.Get("sort"); sort.Exists() {
+		for _, field := range dsl.SortFields(sort) {
+			fields = append(fields, strings.TrimPrefix(field, "-"))
+		}
+	}
+	fields = append(fields, stringList(params.Get("searchFields"))...)
+	roles := callerRoles(ctx)
+	for _, field := range fields {
+		field = strings.Split(field, ".")[0]
+		if rule, ok := rules[field]; ok && !hasAnyRole(rule.read, roles) {
+			return NewError(CodeForbidden, "Not allowed to read field: "+field).WithData(map[string]interface{}{"field": field})
+		}
+	}
+	return nil
+}
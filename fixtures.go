@@ -0,0 +1,382 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// fixtureKey is the field with the symbolic key of a fixture record.
+var fixtureKey = "$key"
+
+// fixtureRef is the field of a reference to another fixture record. e.g. {"$ref": "johnSnow"}
+var fixtureRef = "$ref"
+
+var fixtureExtensions = map[string]bool{".json": true, ".yaml": true, ".yml": true, ".ndjson": true, ".jsonl": true}
+
+// Fixtures are records read from fixture files, to be inserted in an adapter.
+//
+// A record can have a symbolic key in the $key field, and reference other records
+// with {"$ref": "key"}, which is replaced by the id of the referenced record when it is inserted.
+//
+//	- $key: johnSnow
+//	  name: John
+//	- name: Marie
+//	  master: {$ref: johnSnow}
+type Fixtures struct {
+	// IDField is the id field of the inserted records, used to resolve the references. Default: id
+	IDField string
+	records []map[string]interface{}
+}
+
+// ReadFixtures reads the fixture files. Directories are read in file name order (not recursive).
+// Supported formats: JSON (a list or one record), YAML (a list or one record) and NDJSON (one record per line).
+func ReadFixtures(paths ...string) (*Fixtures, error) {
+	fixtures := &Fixtures{IDField: "id"}
+	for _, path := range paths {
+		files, err := fixtureFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			records, err := readFixtureFile(file)
+			if err != nil {
+				return nil, errors.New(fmt.Sprint("Could not read fixture file: ", file, " - error: ", err))
+			}
+			fixtures.records = append(fixtures.records, records...)
+		}
+	}
+	return fixtures, nil
+}
+
+// LoadFixtures reads the fixture files and inserts the records in the adapter.
+// Returns the inserted records by their symbolic key.
+func LoadFixtures(adapter Adapter, paths ...string) (map[string]moleculer.Payload, error) {
+	fixtures, err := ReadFixtures(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return fixtures.Load(adapter)
+}
+
+// fixtureFiles returns the fixture files of the path.
+func fixtureFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && fixtureExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readFixtureFile(file string) ([]map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".ndjson", ".jsonl":
+		return readNDJSON(content)
+	case ".yaml", ".yml":
+		var value interface{}
+		if err := yaml.Unmarshal(content, &value); err != nil {
+			return nil, err
+		}
+		return fixtureRecords(yamlValue(value))
+	}
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return nil, err
+	}
+	return fixtureRecords(value)
+}
+
+func readNDJSON(content []byte) ([]map[string]interface{}, error) {
+	records := []map[string]interface{}{}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		record := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, errors.New(fmt.Sprint("line ", line, ": ", err))
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// fixtureRecords returns the records of a list or a single record.
+func fixtureRecords(value interface{}) ([]map[string]interface{}, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return []map[string]interface{}{value}, nil
+	case []interface{}:
+		records := []map[string]interface{}{}
+		for _, item := range value {
+			record, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(fmt.Sprint("Invalid fixture record: ", item))
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+	return nil, errors.New("Fixtures must be a list of records!")
+}
+
+// yamlValue converts the maps decoded by yaml (map[interface{}]interface{}) to map[string]interface{}.
+func yamlValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, item := range value {
+			m[fmt.Sprint(key)] = yamlValue(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range value {
+			value[i] = yamlValue(item)
+		}
+		return value
+	}
+	return value
+}
+
+// refs returns the references of the value.
+func refs(value interface{}) []string {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := value[fixtureRef].(string); ok && len(value) == 1 {
+			return []string{ref}
+		}
+		list := []string{}
+		for _, item := range value {
+			list = append(list, refs(item)...)
+		}
+		return list
+	case []interface{}:
+		list := []string{}
+		for _, item := range value {
+			list = append(list, refs(item)...)
+		}
+		return list
+	}
+	return nil
+}
+
+// resolveRefs replaces the references by the ids.
+func resolveRefs(value interface{}, ids map[string]interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := value[fixtureRef].(string); ok && len(value) == 1 {
+			return ids[ref]
+		}
+		m := map[string]interface{}{}
+		for key, item := range value {
+			m[key] = resolveRefs(item, ids)
+		}
+		return m
+	case []interface{}:
+		list := []interface{}{}
+		for _, item := range value {
+			list = append(list, resolveRefs(item, ids))
+		}
+		return list
+	}
+	return value
+}
+
+// validate checks the keys and references of the records, without inserting them.
+func (f *Fixtures) validate() error {
+	keys := map[string]bool{}
+	for _, record := range f.records {
+		if key, ok := record[fixtureKey].(string); ok {
+			if keys[key] {
+				return errors.New("Duplicated fixture key: " + key)
+			}
+			keys[key] = true
+		}
+	}
+	resolved := map[string]bool{}
+	pending := f.records
+	for len(pending) > 0 {
+		waiting := []map[string]interface{}{}
+		for _, record := range pending {
+			ready := true
+			for _, ref := range refs(record) {
+				if !keys[ref] {
+					return errors.New("Fixture reference not found: " + ref)
+				}
+				if !resolved[ref] {
+					ready = false
+				}
+			}
+			if !ready {
+				waiting = append(waiting, record)
+				continue
+			}
+			if key, ok := record[fixtureKey].(string); ok {
+				resolved[key] = true
+			}
+		}
+		if len(waiting) == len(pending) {
+			return errors.New("Circular fixture references!")
+		}
+		pending = waiting
+	}
+	return nil
+}
+
+// Load inserts the records in the adapter. Records are inserted after the records they reference.
+// Returns the inserted records by their symbolic key.
+func (f *Fixtures) Load(adapter Adapter) (map[string]moleculer.Payload, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	idField := f.IDField
+	if idField == "" {
+		idField = "id"
+	}
+	inserted := map[string]moleculer.Payload{}
+	ids := map[string]interface{}{}
+	pending := f.records
+	for len(pending) > 0 {
+		waiting := []map[string]interface{}{}
+		for _, record := range pending {
+			ready := true
+			for _, ref := range refs(record) {
+				if _, resolved := ids[ref]; !resolved {
+					ready = false
+				}
+			}
+			if !ready {
+				waiting = append(waiting, record)
+				continue
+			}
+			values := resolveRefs(record, ids).(map[string]interface{})
+			key, hasKey := values[fixtureKey].(string)
+			delete(values, fixtureKey)
+			result := adapter.Insert(payload.New(values))
+			if result.IsError() {
+				return nil, errors.New(fmt.Sprint("Could not insert fixture: ", values, " - error: ", result.Error()))
+			}
+			if hasKey {
+				inserted[key] = result
+				ids[key] = result.Get(idField).Value()
+			}
+		}
+		if len(waiting) == len(pending) {
+			return nil, errors.New("Circular fixture references!")
+		}
+		pending = waiting
+	}
+	return inserted, nil
+}
+
+// fixturesConfig is the fixtures setting.
+type fixturesConfig struct {
+	paths []string
+	// mode empty loads the fixtures when the adapter has no records, always removes all records and loads the fixtures.
+	mode string
+}
+
+// parseFixtures parses the fixtures setting. Accepts a path, a list of paths or a map with path(s) and mode.
+func parseFixtures(settings map[string]interface{}) *fixturesConfig {
+	value := settings["fixtures"]
+	if value == nil {
+		return nil
+	}
+	config := &fixturesConfig{mode: "empty"}
+	setting := payload.New(value)
+	if setting.IsMap() {
+		if setting.Get("mode").Exists() {
+			config.mode = setting.Get("mode").String()
+		}
+		setting = setting.Get("path")
+	}
+	if setting.IsArray() {
+		config.paths = setting.StringArray()
+	} else if setting.Exists() {
+		config.paths = []string{setting.String()}
+	}
+	return config
+}
+
+// seedFixtures loads the fixtures setting on start. The records are inserted with the encryptedFields setting.
+func seedFixtures(adapter Adapter, instance *moleculer.ServiceSchema, logger *log.Entry) error {
+	settings := instance.Settings
+	config := parseFixtures(settings)
+	if config == nil || len(config.paths) == 0 {
+		return nil
+	}
+	adapter, err := encryptionAdapter(adapter, instance)
+	if err != nil {
+		return err
+	}
+	// the fixtures are read and validated before the "always" mode removes the records.
+	fixtures, err := ReadFixtures(config.paths...)
+	if err != nil {
+		return err
+	}
+	if err := fixtures.validate(); err != nil {
+		return err
+	}
+	switch config.mode {
+	case "empty":
+		count := adapter.Count(payload.Empty())
+		if count.IsError() {
+			return count.Error()
+		}
+		if count.Int() > 0 {
+			logger.Debug("fixtures not loaded - the store is not empty.")
+			return nil
+		}
+	case "always":
+		if result := adapter.RemoveAll(); result != nil && result.IsError() {
+			return result.Error()
+		}
+	default:
+		return errors.New("Invalid fixtures mode: " + config.mode)
+	}
+	if idField, ok := settings["idField"].(string); ok {
+		fixtures.IDField = idField
+	}
+	inserted, err := fixtures.Load(adapter)
+	if err != nil {
+		return err
+	}
+	logger.Info("fixtures loaded - ", len(fixtures.records), " records, ", len(inserted), " with keys.")
+	return nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Fixtures", func() {
+
+	var adapter *MemoryAdapter
+	BeforeEach(func() {
+		adapter = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+		adapter.Init(log.WithField("test", "fixtures"), M{})
+		Expect(adapter.Connect()).Should(Succeed())
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+
+	service := func(settings M) *moleculer.ServiceSchema {
+		return &moleculer.ServiceSchema{Name: "user", Settings: settings}
+	}
+
+	writeFixture := func(dir, name, content string) string {
+		file := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(file, []byte(content), 0644)).Should(Succeed())
+		return file
+	}
+
+	It("should load the fixtures of a directory and resolve the references", func() {
+		records, err := LoadFixtures(adapter, "testdata/fixtures")
+		Expect(err).Should(BeNil())
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+		Expect(len(records)).Should(Equal(4))
+
+		johnSnow := records["johnSnow"]
+		Expect(johnSnow.Get("lastname").String()).Should(Equal("Snow"))
+		Expect(records["marie"].Get("master").String()).Should(Equal(johnSnow.Get("id").String()))
+		Expect(records["marie"].Get("$key").Exists()).Should(BeFalse())
+		Expect(records["julian"].Get("master").String()).Should(Equal(records["peter"].Get("id").String()))
+
+		travolta := adapter.Find(payload.New(M{"query": M{"lastname": "Travolta"}})).First()
+		Expect(travolta.Get("friends").StringArray()).Should(Equal([]string{
+			johnSnow.Get("id").String(),
+			records["marie"].Get("id").String(),
+		}))
+	})
+
+	It("should fail with missing and circular references", func() {
+		dir, err := ioutil.TempDir("", "fixtures")
+		Expect(err).Should(BeNil())
+		defer os.RemoveAll(dir)
+
+		_, err = LoadFixtures(adapter, writeFixture(dir, "missing.ndjson", `{"name": "John", "master": {"$ref": "nobody"}}`))
+		Expect(err.Error()).Should(Equal("Fixture reference not found: nobody"))
+
+		circular := writeFixture(dir, "circular.yaml", "- {$key: a, master: {$ref: b}}\n- {$key: b, master: {$ref: a}}\n")
+		_, err = LoadFixtures(adapter, circular)
+		Expect(err.Error()).Should(Equal("Circular fixture references!"))
+
+		_, err = LoadFixtures(adapter, writeFixture(dir, "invalid.json", `"John"`))
+		Expect(err.Error()).Should(ContainSubstring("Fixtures must be a list of records!"))
+	})
+
+	It("should seed the fixtures setting when the store is empty or always", func() {
+		logger := log.WithField("test", "fixtures")
+		Expect(seedFixtures(adapter, service(M{"fixtures": "testdata/fixtures/3-users.ndjson"}), logger)).Should(Succeed())
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(1))
+
+		Expect(seedFixtures(adapter, service(M{"fixtures": "testdata/fixtures/3-users.ndjson"}), logger)).Should(Succeed())
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(1))
+
+		settings := M{"fixtures": M{"path": []string{"testdata/fixtures"}, "mode": "always"}}
+		Expect(seedFixtures(adapter, service(settings), logger)).Should(Succeed())
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+
+		Expect(seedFixtures(adapter, service(M{"fixtures": M{"path": "testdata/fixtures", "mode": "never"}}), logger)).ShouldNot(Succeed())
+	})
+
+	It("should not remove the records in the always mode when the fixtures are invalid", func() {
+		dir, err := ioutil.TempDir("", "fixtures")
+		Expect(err).Should(BeNil())
+		defer os.RemoveAll(dir)
+		logger := log.WithField("test", "fixtures")
+		Expect(seedFixtures(adapter, service(M{"fixtures": "testdata/fixtures"}), logger)).Should(Succeed())
+
+		circular := writeFixture(dir, "circular.yaml", "- {$key: a, master: {$ref: b}}\n- {$key: b, master: {$ref: a}}\n")
+		err = seedFixtures(adapter, service(M{"fixtures": M{"path": circular, "mode": "always"}}), logger)
+		Expect(err.Error()).Should(Equal("Circular fixture references!"))
+		err = seedFixtures(adapter, service(M{"fixtures": M{"path": filepath.Join(dir, "missing.json"), "mode": "always"}}), logger)
+		Expect(err).ShouldNot(BeNil())
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+	})
+
+	It("should seed the fixtures with the encrypted fields", func() {
+		keys := StaticKeys{Current: "k1", Keys: map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}}
+		settings := M{
+			"fixtures":        "testdata/fixtures/3-users.ndjson",
+			"encryptedFields": map[string]interface{}{"lastname": true},
+			"keyProvider":     keys,
+		}
+		Expect(seedFixtures(adapter, service(settings), log.WithField("test", "fixtures"))).Should(Succeed())
+		Expect(adapter.FindOne(payload.Empty()).Get("lastname").String()).Should(HavePrefix("enc:r:k1:"))
+
+		delete(settings, "keyProvider")
+		Expect(IsValidation(seedFixtures(adapter, service(settings), log.WithField("test", "fixtures")))).Should(BeTrue())
+	})
+})
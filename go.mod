@@ -4,7 +4,9 @@ go 1.12
 
 require (
 	crawshaw.io/sqlite v0.2.0
+	github.com/alicebob/miniredis/v2 v2.11.4
 	github.com/elastic/go-elasticsearch/v7 v7.6.0
+	github.com/go-redis/redis/v7 v7.4.1
 	github.com/hashicorp/go-memdb v1.0.1
 	github.com/moleculer-go/cupaloy/v2 v2.5.2
 	github.com/moleculer-go/moleculer v0.3.2
@@ -12,5 +14,7 @@ require (
 	github.com/onsi/gomega v1.19.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.3
+	go.etcd.io/bbolt v1.3.6
 	go.mongodb.org/mongo-driver v1.5.2
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// HealthAdapter is implemented by the adapters that can check the connection to the database.
+type HealthAdapter interface {
+	// Ping returns an error when the database is not available.
+	Ping() error
+}
+
+// PoolAdapter is implemented by the adapters with a connection pool.
+type PoolAdapter interface {
+	// PoolStats returns the size of the pool and the connections in use.
+	PoolStats() map[string]interface{}
+}
+
+// Ping checks the connection of the adapter. Adapters that don't implement HealthAdapter are always available.
+func Ping(adapter Adapter) error {
+	if adapter == nil {
+		return NewError(CodeUnavailable, "No adapter!")
+	}
+	if pinger, ok := adapter.(HealthAdapter); ok {
+		return pinger.Ping()
+	}
+	return nil
+}
+
+// health keeps the connection state and the last error of the adapter, reported by the health action.
+type health struct {
+	mutex       sync.Mutex
+	ready       bool
+	lastError   error
+	lastErrorAt time.Time
+}
+
+// setReady marks the adapter as connected or not.
+func (h *health) setReady(ready bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.ready = ready
+}
+
+// isReady returns true when the adapter is connected.
+func (h *health) isReady() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.ready
+}
+
+// fail records the error.
+func (h *health) fail(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastError = err
+	h.lastErrorAt = time.Now()
+}
+
+// check pings the adapter and returns the status (up or down), the latency in milliseconds, the pool usage and the last error.
+// The status is down while the adapter is not connected.
+func (h *health) check(adapter Adapter) map[string]interface{} {
+	result := map[string]interface{}{"status": "down"}
+	if h.isReady() {
+		start := time.Now()
+		err := Ping(adapter)
+		result["latency"] = milliseconds(time.Since(start))
+		if err == nil {
+			result["status"] = "up"
+		} else {
+			h.fail(err)
+		}
+	}
+	if pool, ok := adapter.(PoolAdapter); ok {
+		if stats := pool.PoolStats(); stats != nil {
+			result["pool"] = stats
+		}
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.lastError != nil {
+		result["lastError"] = h.lastError.Error()
+		result["lastErrorAt"] = h.lastErrorAt
+	}
+	return result
+}
+
+// healthAction pings the adapter. Use the status (up or down) to gate the readiness of the service.
+func healthAction(adapter Adapter, h *health) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		return payload.New(h.check(adapter))
+	}
+}
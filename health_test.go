@@ -0,0 +1,69 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// pingAdapter fails the ping with err.
+type pingAdapter struct {
+	*MemoryAdapter
+	err error
+}
+
+func (adapter *pingAdapter) Ping() error {
+	return adapter.err
+}
+
+func (adapter *pingAdapter) PoolStats() map[string]interface{} {
+	return map[string]interface{}{"size": 4, "inUse": 1}
+}
+
+var _ = Describe("Health", func() {
+
+	It("memory adapter should be available when connected", func() {
+		adapter := &MemoryAdapter{Table: "user"}
+		adapter.Init(log.WithField("test", "health"), M{})
+		Expect(Ping(adapter)).ShouldNot(Succeed())
+		Expect(adapter.Connect()).Should(Succeed())
+		Expect(Ping(adapter)).Should(Succeed())
+		Expect(Ping(Wrap(adapter, Timing(newStoreMetrics().record)))).Should(Succeed())
+		adapter.Disconnect()
+		Expect(Ping(&CacheAdapter{Adapter: adapter})).ShouldNot(Succeed())
+	})
+
+	It("health action should report the status, pool and last error", func() {
+		adapter := &pingAdapter{MemoryAdapter: &MemoryAdapter{Table: "user"}}
+		wrapped := Wrap(adapter, Timing(newStoreMetrics().record))
+		status := &health{ready: true}
+		ctx, _ := contextAndDelegated("health-test", moleculer.Config{})
+		action := healthAction(wrapped, status)
+
+		result := action(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+		Expect(result.Get("status").String()).Should(Equal("up"))
+		Expect(result.Get("latency").Float() >= 0).Should(BeTrue())
+		Expect(result.Get("pool").Get("size").Int()).Should(Equal(4))
+		Expect(result.Get("lastError").Exists()).Should(BeFalse())
+
+		adapter.err = errors.New("connection refused")
+		result = action(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+		Expect(result.Get("status").String()).Should(Equal("down"))
+		Expect(result.Get("lastError").String()).Should(Equal("connection refused"))
+
+		adapter.err = nil
+		status.fail(errors.New("could not connect"))
+		result = action(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+		Expect(result.Get("status").String()).Should(Equal("up"))
+		Expect(result.Get("lastError").String()).Should(Equal("could not connect"))
+
+		status.setReady(false)
+		result = action(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+		Expect(result.Get("status").String()).Should(Equal("down"))
+		Expect(result.Get("latency").Exists()).Should(BeFalse())
+	})
+})
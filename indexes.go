@@ -0,0 +1,180 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// IndexAdapter is implemented by the adapters that create the indexes setting on Connect.
+type IndexAdapter interface {
+	// Indexes returns the indexes of the table/collection.
+	Indexes() moleculer.Payload
+}
+
+// Index is an index declared in the indexes setting.
+type Index struct {
+	// Name of the index. Default: the field names joined by _ e.g. lastname_age
+	Name   string
+	Fields []IndexField
+	Unique bool
+	// Sparse indexes skip the records without the fields.
+	Sparse bool
+	// TTL removes the records when the (date) field is older than the TTL. Supported by the Mongo and Redis adapters.
+	TTL time.Duration
+}
+
+// IndexField is a field of the index. Descending fields are declared with a - prefix. e.g. -age
+type IndexField struct {
+	Name       string
+	Descending bool
+}
+
+var validIndexName = regexp.MustCompile("^[A-Za-z0-9_]+$")
+var validIndexField = regexp.MustCompile("^[A-Za-z0-9_]+(\\.[A-Za-z0-9_]+)*$")
+
+// FieldNames returns the names of the fields of the index.
+func (index Index) FieldNames() []string {
+	names := []string{}
+	for _, field := range index.Fields {
+		names = append(names, field.Name)
+	}
+	return names
+}
+
+// Map returns the index as a map, in the format of the indexes setting.
+func (index Index) Map() map[string]interface{} {
+	fields := []string{}
+	for _, field := range index.Fields {
+		if field.Descending {
+			fields = append(fields, "-"+field.Name)
+		} else {
+			fields = append(fields, field.Name)
+		}
+	}
+	return map[string]interface{}{
+		"name":   index.Name,
+		"fields": fields,
+		"unique": index.Unique,
+		"sparse": index.Sparse,
+		"ttl":    int64(index.TTL.Seconds()),
+	}
+}
+
+// parseIndexFields parses the field list. Descending fields start with -
+func parseIndexFields(names []string) []IndexField {
+	fields := []IndexField{}
+	for _, name := range names {
+		if strings.HasPrefix(name, "-") {
+			fields = append(fields, IndexField{Name: name[1:], Descending: true})
+		} else {
+			fields = append(fields, IndexField{Name: name})
+		}
+	}
+	return fields
+}
+
+// indexTTL returns the ttl of the index. Accepts a time.Duration or the number of seconds.
+func indexTTL(value moleculer.Payload) time.Duration {
+	if duration, ok := value.Value().(time.Duration); ok {
+		return duration
+	}
+	if value.Exists() {
+		return time.Duration(value.Int64()) * time.Second
+	}
+	return 0
+}
+
+// parseIndex parses an index of the indexes setting.
+// Example: {"fields": ["lastname", "-age"], "unique": true, "sparse": true, "ttl": 3600}
+func parseIndex(value moleculer.Payload) Index {
+	fields := value.Get("fields")
+	names := []string{}
+	if fields.IsArray() {
+		names = fields.StringArray()
+	} else if fields.Exists() {
+		names = []string{fields.String()}
+	}
+	return Index{
+		Name:   value.Get("name").String(),
+		Fields: parseIndexFields(names),
+		Unique: value.Get("unique").Exists() && value.Get("unique").Bool(),
+		Sparse: value.Get("sparse").Exists() && value.Get("sparse").Bool(),
+		TTL:    indexTTL(value.Get("ttl")),
+	}
+}
+
+// validateIndex checks the fields and sets the default name.
+func validateIndex(index Index) (Index, error) {
+	if len(index.Fields) == 0 {
+		return index, errors.New("Invalid index: fields is required!")
+	}
+	for _, field := range index.Fields {
+		if !validIndexField.MatchString(field.Name) {
+			return index, errors.New("Invalid index field: " + field.Name)
+		}
+	}
+	if index.Name == "" {
+		index.Name = strings.Replace(strings.Join(index.FieldNames(), "_"), ".", "_", -1)
+	}
+	if !validIndexName.MatchString(index.Name) {
+		return index, errors.New("Invalid index name: " + index.Name)
+	}
+	if index.TTL > 0 && len(index.Fields) > 1 {
+		return index, errors.New(fmt.Sprint("Invalid index ", index.Name, ": ttl indexes must have a single field!"))
+	}
+	return index, nil
+}
+
+// ParseIndexes returns the indexes of the indexes setting. Accepts a list of Index or a list of maps.
+func ParseIndexes(settings map[string]interface{}) ([]Index, error) {
+	indexes := []Index{}
+	switch value := settings["indexes"].(type) {
+	case nil:
+		return indexes, nil
+	case []Index:
+		indexes = append(indexes, value...)
+	default:
+		list := payload.New(value)
+		if !list.IsArray() {
+			return nil, errors.New("Invalid indexes setting! It must be a list.")
+		}
+		list.ForEach(func(_ interface{}, item moleculer.Payload) bool {
+			indexes = append(indexes, parseIndex(item))
+			return true
+		})
+	}
+	for i, index := range indexes {
+		valid, err := validateIndex(index)
+		if err != nil {
+			return nil, err
+		}
+		indexes[i] = valid
+	}
+	return indexes, nil
+}
+
+// indexesAction lists the indexes of the adapter. Adapters that don't implement IndexAdapter list the indexes setting.
+func indexesAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if indexer, ok := adapter.(IndexAdapter); ok {
+			if result := indexer.Indexes(); result != nil {
+				return result
+			}
+		}
+		indexes, err := ParseIndexes(getInstance().Settings)
+		if err != nil {
+			return payload.New(err)
+		}
+		list := []map[string]interface{}{}
+		for _, index := range indexes {
+			list = append(list, index.Map())
+		}
+		return payload.New(list)
+	}
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Indexes", func() {
+
+	It("should parse the indexes setting", func() {
+		indexes, err := ParseIndexes(M{"indexes": []M{
+			{"fields": []string{"lastname", "-age"}, "unique": true},
+			{"fields": "address.city", "sparse": true},
+			{"name": "expiry", "fields": []string{"created"}, "ttl": 3600},
+		}})
+		Expect(err).Should(BeNil())
+		Expect(len(indexes)).Should(Equal(3))
+		Expect(indexes[0].Name).Should(Equal("lastname_age"))
+		Expect(indexes[0].Fields).Should(Equal([]IndexField{{"lastname", false}, {"age", true}}))
+		Expect(indexes[0].Unique).Should(BeTrue())
+		Expect(indexes[1].Name).Should(Equal("address_city"))
+		Expect(indexes[1].Sparse).Should(BeTrue())
+		Expect(indexes[2].Name).Should(Equal("expiry"))
+		Expect(indexes[2].TTL).Should(Equal(time.Hour))
+
+		indexes, err = ParseIndexes(M{"indexes": []Index{{Fields: []IndexField{{Name: "email"}}, Unique: true}}})
+		Expect(err).Should(BeNil())
+		Expect(indexes[0].Name).Should(Equal("email"))
+		Expect(indexes[0].Map()["fields"]).Should(Equal([]string{"email"}))
+
+		indexes, err = ParseIndexes(M{})
+		Expect(err).Should(BeNil())
+		Expect(indexes).Should(BeEmpty())
+	})
+
+	It("should reject invalid indexes", func() {
+		_, err := ParseIndexes(M{"indexes": []M{{"unique": true}}})
+		Expect(err.Error()).Should(Equal("Invalid index: fields is required!"))
+
+		_, err = ParseIndexes(M{"indexes": []M{{"fields": []string{"name; DROP TABLE users"}}}})
+		Expect(err.Error()).Should(Equal("Invalid index field: name; DROP TABLE users"))
+
+		_, err = ParseIndexes(M{"indexes": []M{{"fields": []string{"a", "b"}, "ttl": 60}}})
+		Expect(err.Error()).Should(Equal("Invalid index a_b: ttl indexes must have a single field!"))
+
+		_, err = ParseIndexes(M{"indexes": "name"})
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	Describe("MemoryAdapter indexes", func() {
+		var adapter *MemoryAdapter
+		BeforeEach(func() {
+			adapter = &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+			adapter.Init(log.WithField("test", "indexes"), M{"indexes": []M{
+				{"fields": []string{"lastname"}, "unique": true},
+				{"fields": []string{"name", "age"}},
+			}})
+			Expect(adapter.Connect()).Should(Succeed())
+			mocks.LoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should reject duplicated values of unique indexes", func() {
+			r := adapter.Insert(payload.New(M{"name": "Jon", "lastname": "Snow"}))
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(r.Error().Error()).Should(ContainSubstring("Duplicate value for unique index lastname"))
+
+			r = adapter.Insert(payload.New(M{"name": "Jon"}))
+			Expect(r.Error()).Should(BeNil())
+
+			marie := adapter.Find(payload.New(M{"query": M{"name": "Marie"}})).First()
+			r = adapter.UpdateById(marie.Get("id"), payload.New(M{"lastname": "Pan"}))
+			Expect(r.IsError()).Should(BeTrue())
+			r = adapter.UpdateById(marie.Get("id"), payload.New(M{"lastname": "Curie"}))
+			Expect(r.Error()).Should(BeNil())
+		})
+
+		It("should find using the compound index", func() {
+			query, err := dsl.Parse(payload.New(M{"name": "John", "age": 65}))
+			Expect(err).Should(BeNil())
+			index, args := adapter.queryIndex(query)
+			Expect(index).Should(Equal("index_name_age"))
+			Expect(args).Should(Equal([]interface{}{"John", "65"}))
+
+			r := adapter.Find(payload.New(M{"query": M{"name": "John", "age": 65}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("lastname").String()).Should(Equal("Travolta"))
+		})
+
+		It("should search multiple searchFields", func() {
+			r := adapter.Find(payload.New(M{"searchFields": []string{"name", "lastname"}, "search": "Pan"}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+
+			r = adapter.Find(payload.New(M{"searchFields": []string{"name", "lastname"}, "search": "Stone"}))
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("lastname").String()).Should(Equal("Man"))
+
+			r = adapter.FindOne(payload.New(M{"searchFields": []string{"lastname"}, "search": "Claire"}))
+			Expect(r.Get("name").String()).Should(Equal("Marie"))
+		})
+
+		It("indexes action should list the indexes", func() {
+			ctx, _ := contextAndDelegated("indexes-test", moleculer.Config{})
+			action := indexesAction(Wrap(adapter), func() *moleculer.ServiceSchema { return &moleculer.ServiceSchema{Name: "user"} })
+			r := action(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.First().Get("name").String()).Should(Equal("lastname"))
+			Expect(r.First().Get("unique").Bool()).Should(BeTrue())
+		})
+	})
+})
@@ -9,6 +9,7 @@ import (
 	"github.com/moleculer-go/moleculer"
 	"github.com/moleculer-go/moleculer/payload"
 	"github.com/moleculer-go/moleculer/util"
+	"github.com/moleculer-go/store/dsl"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,10 +19,170 @@ type MemoryAdapter struct {
 	Table        string
 	db           *memdb.MemDB
 	logger       *log.Entry
+	settings     map[string]interface{}
+	indexes      []Index
+	indexesError error
 }
 
 func (adapter *MemoryAdapter) Init(logger *log.Entry, settings map[string]interface{}) {
 	adapter.logger = logger
+	adapter.settings = settings
+	adapter.indexes, adapter.indexesError = ParseIndexes(settings)
+	for _, index := range adapter.indexes {
+		if index.TTL > 0 {
+			logger.Warn("MemoryAdapter - index ", index.Name, ": ttl is not supported by the memory adapter.")
+		}
+	}
+}
+
+// ForTenant returns a memory adapter for the tenant, using its own table.
+func (adapter *MemoryAdapter) ForTenant(tenant string) Adapter {
+	scoped := &MemoryAdapter{SearchFields: adapter.SearchFields, Table: adapter.Table + "_" + tenant}
+	scoped.Init(adapter.logger, adapter.settings)
+	return scoped
+}
+
+// indexName is the name of the memdb index of a declared index.
+func indexName(index Index) string {
+	return "index_" + index.Name
+}
+
+// indexSchema returns the memdb index of a declared index.
+// Records without the fields are not indexed (memory indexes are always sparse).
+func indexSchema(index Index) *memdb.IndexSchema {
+	var indexer memdb.Indexer
+	if len(index.Fields) == 1 {
+		indexer = &PayloadIndex{Field: index.Fields[0].Name, AllowMissing: true}
+	} else {
+		compound := &memdb.CompoundIndex{}
+		for _, field := range index.Fields {
+			compound.Indexes = append(compound.Indexes, &PayloadIndex{Field: field.Name, AllowMissing: true})
+		}
+		indexer = compound
+	}
+	return &memdb.IndexSchema{
+		Name:         indexName(index),
+		Unique:       index.Unique,
+		AllowMissing: true,
+		Indexer:      indexer,
+	}
+}
+
+// Ping returns an error when the adapter is not connected.
+func (adapter *MemoryAdapter) Ping() error {
+	if adapter.db == nil {
+		return NewError(CodeUnavailable, "Memory adapter not connected!")
+	}
+	return nil
+}
+
+// Indexes returns the declared indexes.
+func (adapter *MemoryAdapter) Indexes() moleculer.Payload {
+	list := []map[string]interface{}{}
+	for _, index := range adapter.indexes {
+		list = append(list, index.Map())
+	}
+	return payload.New(list)
+}
+
+// indexArgs returns the values of the index fields in the record. ok is false when the record is missing a field.
+func indexArgs(index Index, record moleculer.Payload) (args []interface{}, ok bool) {
+	for _, field := range index.Fields {
+		value := record.Get(field.Name)
+		if !value.Exists() {
+			return nil, false
+		}
+		args = append(args, value.String())
+	}
+	return args, true
+}
+
+// checkUnique checks that no other record has the same values in the unique indexes.
+func (adapter *MemoryAdapter) checkUnique(tx *memdb.Txn, record moleculer.Payload) error {
+	for _, index := range adapter.indexes {
+		if !index.Unique {
+			continue
+		}
+		args, ok := indexArgs(index, record)
+		if !ok {
+			continue
+		}
+		existing, err := tx.First(adapter.Table, indexName(index), args...)
+		if err != nil {
+			return err
+		}
+		if existing != nil && payload.New(existing).Get("id").String() != record.Get("id").String() {
+			return NewError(CodeConflict, "Duplicate value for unique index ", index.Name, ": ", args).WithData(map[string]interface{}{"index": index.Name, "values": args})
+		}
+	}
+	return nil
+}
+
+// queryIndex returns a declared index with all fields matched by equality in the query, and the values to lookup.
+func (adapter *MemoryAdapter) queryIndex(query dsl.Node) (string, []interface{}) {
+	equals := map[string]interface{}{}
+	conditions := []dsl.Node{query}
+	if logical, ok := query.(dsl.Logical); ok && logical.Operator == dsl.And {
+		conditions = logical.Nodes
+	}
+	for _, node := range conditions {
+		if c, ok := node.(dsl.Condition); ok && c.Operator == dsl.Eq && c.Value != nil {
+			equals[c.Field] = c.Value
+		}
+	}
+	for _, index := range adapter.indexes {
+		args := []interface{}{}
+		for _, field := range index.Fields {
+			value, exists := equals[field.Name]
+			if !exists {
+				break
+			}
+			args = append(args, payload.New(value).String())
+		}
+		if len(args) == len(index.Fields) {
+			return indexName(index), args
+		}
+	}
+	return "", nil
+}
+
+// searchRecords returns the records where any of the searchFields is equal to search.
+// Fields without an index are compared while scanning all records.
+func (adapter *MemoryAdapter) searchRecords(tx *memdb.Txn, searchFields []string, search string) ([]interface{}, error) {
+	records := []interface{}{}
+	found := map[string]bool{}
+	for _, field := range searchFields {
+		index, value := field, search
+		if field != "all" && field != "id" && !containsField(adapter.SearchFields, field) {
+			index, value = "all", "*"
+		}
+		results, err := tx.Get(adapter.Table, index, value)
+		if err != nil {
+			return nil, err
+		}
+		for item := results.Next(); item != nil; item = results.Next() {
+			record := payload.New(item)
+			if index != field && record.Get(field).String() != search {
+				continue
+			}
+			id := record.Get("id").String()
+			if found[id] {
+				continue
+			}
+			found[id] = true
+			records = append(records, item)
+		}
+	}
+	return records, nil
+}
+
+func containsField(fields []string, field string) bool {
+	for _, item := range fields {
+		if item == field {
+			return true
+		}
+	}
+	return false
 }
 
 func (adapter *MemoryAdapter) generateSchema() *memdb.DBSchema {
@@ -47,6 +208,9 @@ func (adapter *MemoryAdapter) generateSchema() *memdb.DBSchema {
 			}
 		}
 	}
+	for _, index := range adapter.indexes {
+		Indexes[indexName(index)] = indexSchema(index)
+	}
 	return &memdb.DBSchema{
 		Tables: map[string]*memdb.TableSchema{
 			adapter.Table: &memdb.TableSchema{
@@ -58,6 +222,9 @@ func (adapter *MemoryAdapter) generateSchema() *memdb.DBSchema {
 }
 
 func (adapter *MemoryAdapter) Connect() error {
+	if adapter.indexesError != nil {
+		return adapter.indexesError
+	}
 	schema := adapter.generateSchema()
 	db, err := memdb.NewMemDB(schema)
 	if err != nil {
@@ -92,7 +259,29 @@ func (adapter *MemoryAdapter) FindAndUpdate(param moleculer.Payload) moleculer.P
 	return payload.New(result)
 }
 
+// Find returns the records matching the params, applying offset and limit.
 func (adapter *MemoryAdapter) Find(params moleculer.Payload) moleculer.Payload {
+	tx := adapter.db.Txn(false)
+	defer tx.Abort()
+	items, err := adapter.findRecords(tx, params)
+	if err != nil {
+		return payload.New(WrapError("", err, "Failed trying to find. Error: "))
+	}
+	if offset := params.Get("offset"); offset.Exists() {
+		if offset.Int() >= len(items) {
+			items = []moleculer.Payload{}
+		} else if offset.Int() > 0 {
+			items = items[offset.Int():]
+		}
+	}
+	if limit := params.Get("limit"); limit.Exists() && limit.Int() >= 0 && limit.Int() < len(items) {
+		items = items[:limit.Int()]
+	}
+	return payload.New(items)
+}
+
+// findRecords return the records matching the search and query params, using the transaction provided.
+func (adapter *MemoryAdapter) findRecords(tx *memdb.Txn, params moleculer.Payload) ([]moleculer.Payload, error) {
 	searchFields := []string{"all"}
 	search := "*"
 	if params.Get("searchFields").Exists() {
@@ -101,35 +290,53 @@ func (adapter *MemoryAdapter) Find(params moleculer.Payload) moleculer.Payload {
 	if params.Get("search").Exists() {
 		search = params.Get("search").String()
 	}
-
-	indexName := strings.Join(searchFields, "-")
-	tx := adapter.db.Txn(false)
-	defer tx.Abort()
-	results, err := tx.Get(adapter.Table, indexName, search)
+	if params.Get("nativeQuery").Exists() {
+		return nil, NewError(CodeValidation, "nativeQuery is not supported by the memory adapter")
+	}
+	query, err := dsl.Parse(params.Get("query"))
 	if err != nil {
-		return payload.Error("Failed trying to find. Error: ", err.Error())
+		return nil, WrapError(CodeValidation, err)
+	}
+
+	var values []interface{}
+	if index, args := adapter.queryIndex(query); index != "" && search == "*" {
+		results, err := tx.Get(adapter.Table, index, args...)
+		if err != nil {
+			return nil, err
+		}
+		for value := results.Next(); value != nil; value = results.Next() {
+			values = append(values, value)
+		}
+	} else {
+		values, err = adapter.searchRecords(tx, searchFields, search)
+		if err != nil {
+			return nil, err
+		}
 	}
 	items := []moleculer.Payload{}
-	for {
-		value := results.Next()
-		if value == nil {
-			break
+	for _, value := range values {
+		item := payload.New(value)
+		if !dsl.Match(query, item.RawMap()) {
+			continue
 		}
-		items = append(items, payload.New(value))
+		items = append(items, item)
 	}
-	return payload.New(items)
+	return items, nil
 }
 
 func (adapter *MemoryAdapter) FindOne(params moleculer.Payload) moleculer.Payload {
-	indexName := strings.Join(params.Get("searchFields").StringArray(), "-")
+	searchFields := params.Get("searchFields").StringArray()
 	search := params.Get("search").String()
 	tx := adapter.db.Txn(false)
 	defer tx.Abort()
-	result, err := tx.First(adapter.Table, indexName, search)
+	results, err := adapter.searchRecords(tx, searchFields, search)
 	if err != nil {
-		return payload.Error("Failed trying to findOne. searchFields: ", indexName, " search: ", search, " Error: ", err.Error())
+		return payload.New(WrapError("", err, "Failed trying to findOne. searchFields: ", strings.Join(searchFields, ", "), " search: ", search, " Error: "))
 	}
-	return payload.New(result)
+	if len(results) == 0 {
+		return payload.New(nil)
+	}
+	return payload.New(results[0])
 }
 
 func (adapter *MemoryAdapter) FindById(params moleculer.Payload) moleculer.Payload {
@@ -160,38 +367,87 @@ func (adapter *MemoryAdapter) Insert(params moleculer.Payload) moleculer.Payload
 		"all": "*",
 	})
 	tx := adapter.db.Txn(true)
-	err := tx.Insert(adapter.Table, params)
+	err := adapter.checkUnique(tx, params)
+	if err == nil {
+		err = tx.Insert(adapter.Table, params)
+	}
 	if err != nil {
 		defer tx.Abort()
-		return payload.Error("Failed trying to Insert. Error: ", err.Error())
+		return payload.New(WrapError("", err, "Failed trying to Insert. Error: "))
 	}
 	defer tx.Commit()
 	return params
 }
 
 func (adapter *MemoryAdapter) Update(params moleculer.Payload) moleculer.Payload {
-	one := adapter.FindById(params.Get("id"))
-	if !one.IsError() && one.Exists() {
-		tx := adapter.db.Txn(true)
+	return adapter.UpdateById(params.Get("id"), params.Remove("id"))
+}
+
+// UpdateById update the record, applying the update operators ($set, $inc, $push, $pull and $unset).
+func (adapter *MemoryAdapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	ops, err := dsl.ParseUpdate(update)
+	if err != nil {
+		return payload.New(WrapError(CodeValidation, err, "Failed trying to update record. Invalid update: "))
+	}
+	// the record is read in the write transaction, so concurrent updates apply on top of each other.
+	tx := adapter.db.Txn(true)
+	item, err := tx.First(adapter.Table, "id", id.String())
+	if err != nil {
+		defer tx.Abort()
+		return payload.New(WrapError("", err, "Failed trying to update record. source error: "))
+	}
+	if item != nil {
+		one := payload.New(item)
 		err := tx.Delete(adapter.Table, one.Value())
 		if err != nil {
 			defer tx.Abort()
-			return payload.Error("Failed trying to update record. source error: ", err.Error())
+			return payload.New(WrapError("", err, "Failed trying to update record. source error: "))
+		}
+		rec := payload.New(dsl.Apply(one.RawMap(), ops))
+		err = adapter.checkUnique(tx, rec)
+		if err == nil {
+			err = tx.Insert(adapter.Table, rec)
 		}
-		rec := one.AddMany(params.RawMap())
-		err = tx.Insert(adapter.Table, rec)
 		if err != nil {
 			defer tx.Abort()
-			return payload.Error("Failed trying to update record. source error: ", err.Error())
+			return payload.New(WrapError("", err, "Failed trying to update record. source error: "))
 		}
 		defer tx.Commit()
 		return rec
 	}
-	return payload.Error("Failed trying to update record. Could not find record with id: ", params.Get("id").String())
+	tx.Abort()
+	return payload.New(NewError(CodeNotFound, "Failed trying to update record. Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()}))
 }
 
-func (adapter *MemoryAdapter) UpdateById(id, params moleculer.Payload) moleculer.Payload {
-	return adapter.Update(params.Add("id", id))
+// UpdateMany update all records matching the query in a single transaction.
+func (adapter *MemoryAdapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	ops, err := dsl.ParseUpdate(params.Get("update"))
+	if err != nil {
+		return payload.New(WrapError(CodeValidation, err, "Failed trying to update records. Invalid update: "))
+	}
+	tx := adapter.db.Txn(true)
+	records, err := adapter.findRecords(tx, params.Remove("update"))
+	if err != nil {
+		defer tx.Abort()
+		return payload.New(WrapError("", err, "Failed trying to update records. source error: "))
+	}
+	for _, record := range records {
+		if err := tx.Delete(adapter.Table, record.Value()); err != nil {
+			defer tx.Abort()
+			return payload.New(WrapError("", err, "Failed trying to update records. source error: "))
+		}
+		updated := payload.New(dsl.Apply(record.RawMap(), ops))
+		if err := adapter.checkUnique(tx, updated); err != nil {
+			defer tx.Abort()
+			return payload.New(WrapError("", err, "Failed trying to update records. source error: "))
+		}
+		if err := tx.Insert(adapter.Table, updated); err != nil {
+			defer tx.Abort()
+			return payload.New(WrapError("", err, "Failed trying to update records. source error: "))
+		}
+	}
+	defer tx.Commit()
+	return payload.Empty().Add("modifiedCount", len(records))
 }
 
 func (adapter *MemoryAdapter) RemoveById(params moleculer.Payload) moleculer.Payload {
@@ -201,12 +457,33 @@ func (adapter *MemoryAdapter) RemoveById(params moleculer.Payload) moleculer.Pay
 		err := tx.Delete(adapter.Table, one.Value())
 		if err != nil {
 			defer tx.Abort()
-			return payload.Error("Failed trying to removed record. source error: ", err.Error())
+			return payload.New(WrapError("", err, "Failed trying to removed record. source error: "))
 		}
 		defer tx.Commit()
 		return payload.Empty().Add("deletedCount", 1)
 	}
-	return nil
+	if one.IsError() {
+		return one
+	}
+	return payload.New(NewError(CodeNotFound, "Could not find record with id: ", params.String()).WithData(map[string]interface{}{"id": params.Value()}))
+}
+
+// RemoveMany remove all records matching the query in a single transaction.
+func (adapter *MemoryAdapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	tx := adapter.db.Txn(true)
+	records, err := adapter.findRecords(tx, params)
+	if err != nil {
+		defer tx.Abort()
+		return payload.New(WrapError("", err, "Failed trying to remove records. source error: "))
+	}
+	for _, record := range records {
+		if err := tx.Delete(adapter.Table, record.Value()); err != nil {
+			defer tx.Abort()
+			return payload.New(WrapError("", err, "Failed trying to remove records. source error: "))
+		}
+	}
+	defer tx.Commit()
+	return payload.Empty().Add("deletedCount", len(records))
 }
 
 func (adapter *MemoryAdapter) RemoveAll() moleculer.Payload {
@@ -222,6 +499,8 @@ func (adapter *MemoryAdapter) RemoveAll() moleculer.Payload {
 type PayloadIndex struct {
 	Field     string
 	Lowercase bool
+	// AllowMissing skips the records without the field, instead of failing.
+	AllowMissing bool
 }
 
 func (s *PayloadIndex) FromArgs(args ...interface{}) ([]byte, error) {
@@ -253,6 +532,9 @@ func (s *PayloadIndex) FromObject(obj interface{}) (bool, []byte, error) {
 		p = payload.New(m)
 	}
 	if !p.Get(s.Field).Exists() {
+		if s.AllowMissing {
+			return false, nil, nil
+		}
 		return false, nil, errors.New(fmt.Sprint("Field `", s.Field, "` not found!"))
 	}
 	svalue := p.Get(s.Field).String()
@@ -2,6 +2,7 @@ package store
 
 import (
 	"os"
+	"sync"
 
 	"github.com/moleculer-go/cupaloy/v2"
 	"github.com/moleculer-go/moleculer"
@@ -40,6 +41,28 @@ var _ = Describe("MemoryAdapter", func() {
 		Expect(snap.SnapshotMulti("Find()", r.Remove("id", "friends", "master").Sort("lastname"))).Should(Succeed())
 	})
 
+	It("Find() should filter using the query language", func() {
+		r := adapter.Find(payload.New(map[string]interface{}{
+			"query": map[string]interface{}{"age": map[string]interface{}{"$gt": 60}},
+		}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Len()).Should(Equal(2))
+
+		r = adapter.Find(payload.New(map[string]interface{}{
+			"query": map[string]interface{}{"$or": []map[string]interface{}{
+				{"name": map[string]interface{}{"$like": "jul%"}},
+				{"age": map[string]interface{}{"$between": []int{10, 20}}},
+			}},
+		}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Len()).Should(Equal(3))
+
+		r = adapter.Find(payload.New(map[string]interface{}{
+			"query": map[string]interface{}{"age": map[string]interface{}{"$near": 10}},
+		}))
+		Expect(r.IsError()).Should(BeTrue())
+	})
+
 	It("FindById() should return one matching records by ID", func() {
 		r := adapter.FindById(johnSnow.Get("id"))
 		Expect(r.Error()).Should(BeNil())
@@ -74,6 +97,39 @@ var _ = Describe("MemoryAdapter", func() {
 		Expect(r.Get("age").Int()).Should(Equal(67))
 	})
 
+	It("Update() should apply update operators", func() {
+		r := adapter.UpdateById(johnSnow.Get("id"), payload.New(map[string]interface{}{
+			"$inc":  map[string]interface{}{"age": 5},
+			"$push": map[string]interface{}{"friends": "123"},
+		}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("age").Int()).Should(Equal(30))
+		Expect(r.Get("friends").StringArray()).Should(Equal([]string{"123"}))
+
+		r = adapter.UpdateById(johnSnow.Get("id"), payload.New(map[string]interface{}{
+			"$pull":  map[string]interface{}{"friends": "123"},
+			"$unset": []string{"lastname"},
+		}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("friends").Len()).Should(Equal(0))
+		Expect(r.Get("lastname").Exists()).Should(BeFalse())
+	})
+
+	It("Update() should not lose concurrent $inc updates", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				adapter.UpdateById(johnSnow.Get("id"), payload.New(map[string]interface{}{
+					"$inc": map[string]interface{}{"age": 1},
+				}))
+			}()
+		}
+		wg.Wait()
+		Expect(adapter.FindById(johnSnow.Get("id")).Get("age").Int()).Should(Equal(45))
+	})
+
 	It("Insert() should insert new records", func() {
 		r := adapter.Insert(payload.New(map[string]interface{}{
 			"name":     "Julio",
@@ -92,6 +148,32 @@ var _ = Describe("MemoryAdapter", func() {
 		Expect(snap.SnapshotMulti("Insert()", r.Remove("id"))).Should(Succeed())
 	})
 
+	It("UpdateMany() should update all records matching the query", func() {
+		r := adapter.UpdateMany(payload.New(map[string]interface{}{
+			"query":  map[string]interface{}{"name": "John"},
+			"update": map[string]interface{}{"age": 70},
+		}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+
+		Expect(adapter.FindById(johnSnow.Get("id")).Get("age").Int()).Should(Equal(70))
+		Expect(adapter.FindById(johnTravolta.Get("id")).Get("age").Int()).Should(Equal(70))
+		Expect(adapter.Count(payload.New(map[string]interface{}{
+			"query": map[string]interface{}{"age": 70},
+		})).Int()).Should(Equal(2))
+	})
+
+	It("RemoveMany() should remove all records matching the query", func() {
+		r := adapter.RemoveMany(payload.New(map[string]interface{}{
+			"query": map[string]interface{}{"age": 13},
+		}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+
+		total := adapter.Count(payload.Empty())
+		Expect(total.Int()).Should(Equal(4))
+	})
+
 	It("RemoveAll() should remove all records and return total of removed items", func() {
 		total := adapter.Count(payload.Empty())
 		Expect(total.Int()).Should(Equal(6))
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// latencyBuckets are the upper bounds (in seconds) of the latency histogram.
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// operationStats has the counters and the latency histogram of one adapter operation.
+type operationStats struct {
+	count   int64
+	errors  int64
+	rows    int64
+	total   time.Duration
+	max     time.Duration
+	buckets []int64
+}
+
+// storeMetrics collects the stats of the adapter operations of a service.
+type storeMetrics struct {
+	mutex      sync.Mutex
+	operations map[string]*operationStats
+}
+
+func newStoreMetrics() *storeMetrics {
+	return &storeMetrics{operations: map[string]*operationStats{}}
+}
+
+// rowsOf returns the number of records in the result.
+func rowsOf(result moleculer.Payload) int64 {
+	if result == nil || result.IsError() || !result.Exists() {
+		return 0
+	}
+	if result.IsArray() {
+		return int64(result.Len())
+	}
+	if result.IsMap() {
+		return 1
+	}
+	return 0
+}
+
+// record is used with the Timing middleware.
+func (m *storeMetrics) record(call *Call, result moleculer.Payload, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	stats, exists := m.operations[call.Operation]
+	if !exists {
+		stats = &operationStats{buckets: make([]int64, len(latencyBuckets))}
+		m.operations[call.Operation] = stats
+	}
+	stats.count++
+	if result != nil && result.IsError() {
+		stats.errors++
+	} else if strings.HasPrefix(call.Operation, "Find") {
+		stats.rows += rowsOf(result)
+	}
+	stats.total += duration
+	if duration > stats.max {
+		stats.max = duration
+	}
+	for i, bound := range latencyBuckets {
+		if duration.Seconds() <= bound {
+			stats.buckets[i]++
+		}
+	}
+}
+
+func (m *storeMetrics) sortedOperations() []string {
+	names := []string{}
+	for name := range m.operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+// snapshot returns the stats of all operations.
+func (m *storeMetrics) snapshot(service string) map[string]interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	operations := map[string]interface{}{}
+	for _, name := range m.sortedOperations() {
+		stats := m.operations[name]
+		histogram := []map[string]interface{}{}
+		for i, bound := range latencyBuckets {
+			histogram = append(histogram, map[string]interface{}{"le": bound, "count": stats.buckets[i]})
+		}
+		operations[name] = map[string]interface{}{
+			"count":     stats.count,
+			"errors":    stats.errors,
+			"rows":      stats.rows,
+			"totalMs":   milliseconds(stats.total),
+			"avgMs":     milliseconds(stats.total) / float64(stats.count),
+			"maxMs":     milliseconds(stats.max),
+			"histogram": histogram,
+		}
+	}
+	return map[string]interface{}{
+		"service":    service,
+		"operations": operations,
+	}
+}
+
+// prometheus returns the stats in the Prometheus text format.
+func (m *storeMetrics) prometheus(service string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var out strings.Builder
+	counters := []struct {
+		name, help string
+		value      func(*operationStats) int64
+	}{
+		{"store_operations_total", "Number of adapter operations.", func(s *operationStats) int64 { return s.count }},
+		{"store_operation_errors_total", "Number of adapter operations that failed.", func(s *operationStats) int64 { return s.errors }},
+		{"store_rows_returned_total", "Number of records returned by find operations.", func(s *operationStats) int64 { return s.rows }},
+	}
+	for _, counter := range counters {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+		for _, name := range m.sortedOperations() {
+			fmt.Fprintf(&out, "%s{service=%q,operation=%q} %d\n", counter.name, service, name, counter.value(m.operations[name]))
+		}
+	}
+	metric := "store_operation_duration_seconds"
+	fmt.Fprintf(&out, "# HELP %s Duration of adapter operations.\n# TYPE %s histogram\n", metric, metric)
+	for _, name := range m.sortedOperations() {
+		stats := m.operations[name]
+		labels := fmt.Sprintf("service=%q,operation=%q", service, name)
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(&out, "%s_bucket{%s,le=\"%v\"} %d\n", metric, labels, bound, stats.buckets[i])
+		}
+		fmt.Fprintf(&out, "%s_bucket{%s,le=\"+Inf\"} %d\n", metric, labels, stats.count)
+		fmt.Fprintf(&out, "%s_sum{%s} %v\n", metric, labels, stats.total.Seconds())
+		fmt.Fprintf(&out, "%s_count{%s} %d\n", metric, labels, stats.count)
+	}
+	return out.String()
+}
+
+// statsInterval returns the statsInterval setting. Accepts a time.Duration or the number of milliseconds.
+func statsInterval(settings map[string]interface{}) time.Duration {
+	interval, _ := durationSetting(settings["statsInterval"])
+	return interval
+}
+
+// publishStats emits the <service>.stats event at every interval, until stop is closed.
+func publishStats(context moleculer.BrokerContext, service string, metrics *storeMetrics, interval time.Duration, stop chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			context.Emit(service+".stats", metrics.snapshot(service))
+		case <-stop:
+			return
+		}
+	}
+}
+
+// statsAction returns the stats of the adapter operations. Use the param format: "prometheus" for the text format.
+func statsAction(metrics *storeMetrics, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		service := getInstance().Name
+		if params != nil && params.Get("format").String() == "prometheus" {
+			return metrics.prometheus(service)
+		}
+		return payload.New(metrics.snapshot(service))
+	}
+}
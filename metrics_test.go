@@ -0,0 +1,76 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store metrics", func() {
+
+	It("should count operations, errors, rows and latency", func() {
+		metrics := newStoreMetrics()
+		metrics.record(&Call{Operation: "Find"}, payload.New([]M{{"name": "a"}, {"name": "b"}}), 3*time.Millisecond)
+		metrics.record(&Call{Operation: "Find"}, payload.New(errors.New("failed")), 20*time.Millisecond)
+		metrics.record(&Call{Operation: "Count"}, payload.New(2), time.Second*10)
+
+		stats := payload.New(metrics.snapshot("user"))
+		Expect(stats.Get("service").String()).Should(Equal("user"))
+		find := stats.Get("operations").Get("Find")
+		Expect(find.Get("count").Int()).Should(Equal(2))
+		Expect(find.Get("errors").Int()).Should(Equal(1))
+		Expect(find.Get("rows").Int()).Should(Equal(2))
+		Expect(find.Get("maxMs").Float()).Should(Equal(20.0))
+		Expect(find.Get("avgMs").Float()).Should(Equal(11.5))
+		histogram := find.Get("histogram").Array()
+		Expect(histogram[0].Get("le").Float()).Should(Equal(0.001))
+		Expect(histogram[0].Get("count").Int()).Should(Equal(0))
+		Expect(histogram[1].Get("count").Int()).Should(Equal(1))
+		Expect(histogram[3].Get("count").Int()).Should(Equal(2))
+		count := stats.Get("operations").Get("Count").Get("histogram").Array()
+		Expect(count[len(count)-1].Get("count").Int()).Should(Equal(0))
+	})
+
+	It("should export the Prometheus text format", func() {
+		metrics := newStoreMetrics()
+		metrics.record(&Call{Operation: "Find"}, payload.New([]M{{"name": "a"}}), 3*time.Millisecond)
+		text := metrics.prometheus("user")
+		Expect(text).Should(ContainSubstring("# TYPE store_operations_total counter\n"))
+		Expect(text).Should(ContainSubstring(`store_operations_total{service="user",operation="Find"} 1` + "\n"))
+		Expect(text).Should(ContainSubstring(`store_rows_returned_total{service="user",operation="Find"} 1` + "\n"))
+		Expect(text).Should(ContainSubstring(`store_operation_duration_seconds_bucket{service="user",operation="Find",le="0.001"} 0` + "\n"))
+		Expect(text).Should(ContainSubstring(`store_operation_duration_seconds_bucket{service="user",operation="Find",le="0.005"} 1` + "\n"))
+		Expect(text).Should(ContainSubstring(`store_operation_duration_seconds_bucket{service="user",operation="Find",le="+Inf"} 1` + "\n"))
+		Expect(text).Should(ContainSubstring(`store_operation_duration_seconds_count{service="user",operation="Find"} 1` + "\n"))
+	})
+
+	It("should parse the statsInterval setting as a duration or milliseconds, as the JSON numbers", func() {
+		Expect(statsInterval(M{"statsInterval": 2 * time.Second})).Should(Equal(2 * time.Second))
+		Expect(statsInterval(M{"statsInterval": 1500})).Should(Equal(1500 * time.Millisecond))
+		Expect(statsInterval(M{"statsInterval": 1500.0})).Should(Equal(1500 * time.Millisecond))
+		Expect(statsInterval(M{"statsInterval": 0.5})).Should(Equal(500 * time.Microsecond))
+		Expect(statsInterval(M{})).Should(Equal(time.Duration(0)))
+	})
+
+	It("stats action should return the stats of the adapter operations", func() {
+		metrics := newStoreMetrics()
+		adapter := Wrap(&MemoryAdapter{Table: "user", SearchFields: []string{"name"}}, Timing(metrics.record))
+		mocks.ConnectAndLoadUsers(adapter)
+		defer adapter.Disconnect()
+		adapter.Find(payload.New(M{"query": M{"name": "John"}}))
+
+		ctx, _ := contextAndDelegated("stats-test", moleculer.Config{})
+		stats := statsAction(metrics, func() *moleculer.ServiceSchema { return &moleculer.ServiceSchema{Name: "user"} })
+		r := stats(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+		Expect(r.Get("operations").Get("Insert").Get("count").Int()).Should(Equal(6))
+		Expect(r.Get("operations").Get("Find").Get("rows").Int()).Should(Equal(2))
+
+		text := stats(ctx.(moleculer.Context), payload.New(M{"format": "prometheus"})).(string)
+		Expect(text).Should(ContainSubstring(`store_operations_total{service="user",operation="Insert"} 6`))
+	})
+})
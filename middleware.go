@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	log "github.com/sirupsen/logrus"
+)
+
+// Call is an adapter operation passing through the middlewares.
+type Call struct {
+	// Operation is the name of the adapter method. e.g. Find, UpdateById
+	Operation string
+	// Params of the operation. UpdateById params are {"id": id, "update": update}
+	Params  moleculer.Payload
+	Started time.Time
+	// Context of the operation. nil when the adapter is not bound to a context. See WithContext.
+	Context context.Context
+}
+
+// Next calls the next middleware in the chain or the adapter.
+type Next func(call *Call) moleculer.Payload
+
+// Middleware decorates the adapter operations. It must call next to continue the chain.
+type Middleware func(call *Call, next Next) moleculer.Payload
+
+// Wrap returns an adapter that passes all operations through the middlewares, in order.
+//
+//	adapter := store.Wrap(&sqlite.Adapter{...}, store.Recovery(), store.Logging(logger))
+func Wrap(adapter Adapter, middlewares ...Middleware) Adapter {
+	return &wrappedAdapter{adapter, middlewares, nil}
+}
+
+type wrappedAdapter struct {
+	adapter     Adapter
+	middlewares []Middleware
+	ctx         context.Context
+}
+
+// run executes the operation through the middlewares.
+func (w *wrappedAdapter) run(operation string, params moleculer.Payload) moleculer.Payload {
+	call := &Call{Operation: operation, Params: params, Started: time.Now(), Context: w.ctx}
+	return w.next(0)(call)
+}
+
+func (w *wrappedAdapter) next(index int) Next {
+	if index == len(w.middlewares) {
+		return w.invoke
+	}
+	return func(call *Call) moleculer.Payload {
+		return w.middlewares[index](call, w.next(index+1))
+	}
+}
+
+// invoke calls the adapter method of the operation.
+func (w *wrappedAdapter) invoke(call *Call) moleculer.Payload {
+	params := call.Params
+	switch call.Operation {
+	case "Connect":
+		return errorResult(w.adapter.Connect())
+	case "Disconnect":
+		return errorResult(w.adapter.Disconnect())
+	case "Find":
+		return w.adapter.Find(params)
+	case "FindAndUpdate":
+		return w.adapter.FindAndUpdate(params)
+	case "FindOne":
+		return w.adapter.FindOne(params)
+	case "FindById":
+		return w.adapter.FindById(params)
+	case "FindByIds":
+		return w.adapter.FindByIds(params)
+	case "Count":
+		return w.adapter.Count(params)
+	case "Insert":
+		return w.adapter.Insert(params)
+	case "Update":
+		return w.adapter.Update(params)
+	case "UpdateById":
+		return w.adapter.UpdateById(params.Get("id"), params.Get("update"))
+	case "UpdateMany":
+		return w.adapter.UpdateMany(params)
+	case "RemoveById":
+		return w.adapter.RemoveById(params)
+	case "RemoveMany":
+		return w.adapter.RemoveMany(params)
+	case "RemoveAll":
+		return w.adapter.RemoveAll()
+	case "Indexes":
+		if indexer, ok := w.adapter.(IndexAdapter); ok {
+			return indexer.Indexes()
+		}
+	}
+	return payload.New(NewError(CodeValidation, "Invalid adapter operation: ", call.Operation))
+}
+
+func errorResult(err error) moleculer.Payload {
+	if err != nil {
+		return payload.New(err)
+	}
+	return payload.Empty()
+}
+
+func (w *wrappedAdapter) Init(logger *log.Entry, settings map[string]interface{}) {
+	w.adapter.Init(logger, settings)
+}
+
+func (w *wrappedAdapter) Connect() error {
+	return w.run("Connect", payload.Empty()).Error()
+}
+
+func (w *wrappedAdapter) Disconnect() error {
+	return w.run("Disconnect", payload.Empty()).Error()
+}
+
+func (w *wrappedAdapter) Find(params moleculer.Payload) moleculer.Payload {
+	return w.run("Find", params)
+}
+
+func (w *wrappedAdapter) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	return w.run("FindAndUpdate", params)
+}
+
+func (w *wrappedAdapter) FindOne(params moleculer.Payload) moleculer.Payload {
+	return w.run("FindOne", params)
+}
+
+func (w *wrappedAdapter) FindById(id moleculer.Payload) moleculer.Payload {
+	return w.run("FindById", id)
+}
+
+func (w *wrappedAdapter) FindByIds(ids moleculer.Payload) moleculer.Payload {
+	return w.run("FindByIds", ids)
+}
+
+func (w *wrappedAdapter) Count(params moleculer.Payload) moleculer.Payload {
+	return w.run("Count", params)
+}
+
+func (w *wrappedAdapter) Insert(params moleculer.Payload) moleculer.Payload {
+	return w.run("Insert", params)
+}
+
+func (w *wrappedAdapter) Update(params moleculer.Payload) moleculer.Payload {
+	return w.run("Update", params)
+}
+
+func (w *wrappedAdapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	return w.run("UpdateById", payload.Empty().Add("id", id).Add("update", update))
+}
+
+func (w *wrappedAdapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	return w.run("UpdateMany", params)
+}
+
+func (w *wrappedAdapter) RemoveById(id moleculer.Payload) moleculer.Payload {
+	return w.run("RemoveById", id)
+}
+
+func (w *wrappedAdapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	return w.run("RemoveMany", params)
+}
+
+func (w *wrappedAdapter) RemoveAll() moleculer.Payload {
+	return w.run("RemoveAll", payload.Empty())
+}
+
+// ForTenant wraps the tenant adapter with the same middlewares. Returns nil when the adapter does not support tenants.
+func (w *wrappedAdapter) ForTenant(tenant string) Adapter {
+	tenantAdapter, ok := w.adapter.(TenantAdapter)
+	if !ok {
+		return nil
+	}
+	scoped := tenantAdapter.ForTenant(tenant)
+	if scoped == nil {
+		return nil
+	}
+	return Wrap(scoped, w.middlewares...)
+}
+
+// Indexes returns the indexes of the adapter through the middlewares, or nil when the adapter does not implement IndexAdapter.
+func (w *wrappedAdapter) Indexes() moleculer.Payload {
+	if _, ok := w.adapter.(IndexAdapter); !ok {
+		return nil
+	}
+	return w.run("Indexes", payload.Empty())
+}
+
+// WithContext returns the wrapped adapter bound to the context. See ContextAdapter.
+func (w *wrappedAdapter) WithContext(ctx context.Context) Adapter {
+	return &wrappedAdapter{WithContext(w.adapter, ctx), w.middlewares, ctx}
+}
+
+// Ping pings the adapter. See HealthAdapter.
+func (w *wrappedAdapter) Ping() error {
+	return Ping(w.adapter)
+}
+
+// PoolStats returns the pool usage of the adapter, or nil when the adapter does not implement PoolAdapter.
+func (w *wrappedAdapter) PoolStats() map[string]interface{} {
+	if pool, ok := w.adapter.(PoolAdapter); ok {
+		return pool.PoolStats()
+	}
+	return nil
+}
+
+// Logging logs each operation with its params, duration and error.
+// Successful operations are logged at debug level and failures at error level.
+func Logging(logger *log.Entry) Middleware {
+	return func(call *Call, next Next) moleculer.Payload {
+		result := next(call)
+		entry := logger.WithFields(log.Fields{
+			"operation": call.Operation,
+			"params":    call.Params,
+			"duration":  time.Since(call.Started),
+		})
+		if result != nil && result.IsError() {
+			entry.WithField("error", result.Error()).Error("adapter operation failed")
+		} else {
+			entry.Debug("adapter operation")
+		}
+		return result
+	}
+}
+
+// Timing calls record with the duration and result of each operation. e.g. to collect metrics.
+func Timing(record func(call *Call, result moleculer.Payload, duration time.Duration)) Middleware {
+	return func(call *Call, next Next) moleculer.Payload {
+		result := next(call)
+		record(call, result, time.Since(call.Started))
+		return result
+	}
+}
+
+// Recovery converts panics in the operation into error results.
+func Recovery() Middleware {
+	return func(call *Call, next Next) (result moleculer.Payload) {
+		defer func() {
+			if err := recover(); err != nil {
+				result = payload.New(NewError(CodeInternal, "Adapter operation ", call.Operation, " panicked - error: ", err))
+			}
+		}()
+		return next(call)
+	}
+}
+
+// SlowLog logs a warning for operations that take longer than the threshold.
+func SlowLog(logger *log.Entry, threshold time.Duration) Middleware {
+	return func(call *Call, next Next) moleculer.Payload {
+		result := next(call)
+		if duration := time.Since(call.Started); duration > threshold {
+			logger.WithFields(log.Fields{
+				"operation": call.Operation,
+				"params":    call.Params,
+				"duration":  duration,
+			}).Warn("slow adapter operation")
+		}
+		return result
+	}
+}
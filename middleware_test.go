@@ -0,0 +1,102 @@
+package store
+
+import (
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// panicAdapter panics on Count, to test the Recovery middleware.
+type panicAdapter struct {
+	MemoryAdapter
+}
+
+func (adapter *panicAdapter) Count(params moleculer.Payload) moleculer.Payload {
+	panic("count failed")
+}
+
+var _ = Describe("Adapter middlewares", func() {
+
+	It("should call the middlewares in order around the adapter operations", func() {
+		calls := []string{}
+		trace := func(name string) Middleware {
+			return func(call *Call, next Next) moleculer.Payload {
+				calls = append(calls, name+" before "+call.Operation)
+				result := next(call)
+				calls = append(calls, name+" after "+call.Operation)
+				return result
+			}
+		}
+		adapter := Wrap(&MemoryAdapter{Table: "user", SearchFields: []string{"name"}}, trace("outer"), trace("inner"))
+		adapter.Init(log.WithField("", ""), map[string]interface{}{})
+		mocks.ConnectAndLoadUsers(adapter)
+		defer adapter.Disconnect()
+
+		calls = []string{}
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+		Expect(calls).Should(Equal([]string{"outer before Count", "inner before Count", "inner after Count", "outer after Count"}))
+	})
+
+	It("should pass the id and update of UpdateById as params", func() {
+		var params moleculer.Payload
+		memory := &MemoryAdapter{Table: "user", SearchFields: []string{"name"}}
+		adapter := Wrap(memory, func(call *Call, next Next) moleculer.Payload {
+			params = call.Params
+			return next(call)
+		})
+		mocks.ConnectAndLoadUsers(adapter)
+		defer adapter.Disconnect()
+
+		marie := adapter.Find(payload.New(M{"query": M{"name": "Marie"}})).First()
+		r := adapter.UpdateById(marie.Get("id"), payload.New(M{"age": 76}))
+		Expect(r.Get("age").Int()).Should(Equal(76))
+		Expect(params.Get("id").String()).Should(Equal(marie.Get("id").String()))
+		Expect(params.Get("update").Get("age").Int()).Should(Equal(76))
+	})
+
+	It("Timing should record the duration and result of each operation", func() {
+		operations := map[string]int{}
+		adapter := Wrap(&MemoryAdapter{Table: "user", SearchFields: []string{"name"}}, Timing(func(call *Call, result moleculer.Payload, duration time.Duration) {
+			Expect(duration).Should(BeNumerically(">", 0))
+			operations[call.Operation] = operations[call.Operation] + 1
+		}))
+		mocks.ConnectAndLoadUsers(adapter)
+		defer adapter.Disconnect()
+		adapter.Find(payload.Empty())
+		Expect(operations["Connect"]).Should(Equal(1))
+		Expect(operations["Insert"]).Should(Equal(6))
+		Expect(operations["Find"]).Should(Equal(1))
+	})
+
+	It("Recovery should return panics as errors", func() {
+		adapter := Wrap(&panicAdapter{MemoryAdapter{Table: "user"}}, Recovery())
+		r := adapter.Count(payload.Empty())
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("Adapter operation Count panicked - error: count failed"))
+	})
+
+	It("Logging and SlowLog should log the operations", func() {
+		logger, hook := test.NewNullLogger()
+		logger.SetLevel(log.DebugLevel)
+		entry := log.NewEntry(logger)
+		adapter := Wrap(&MemoryAdapter{Table: "user", SearchFields: []string{"name"}}, Logging(entry), SlowLog(entry, 0))
+		adapter.Connect()
+		defer adapter.Disconnect()
+		hook.Reset()
+
+		adapter.Count(payload.Empty())
+		Expect(hook.Entries).Should(HaveLen(2))
+		Expect(hook.Entries[0].Message).Should(Equal("slow adapter operation"))
+		Expect(hook.Entries[1].Message).Should(Equal("adapter operation"))
+		Expect(hook.Entries[1].Data["operation"]).Should(Equal("Count"))
+
+		adapter.Update(payload.New(M{"id": "missing", "name": "John"}))
+		Expect(hook.LastEntry().Level).Should(Equal(log.ErrorLevel))
+	})
+})
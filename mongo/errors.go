@@ -0,0 +1,35 @@
+package mongo
+
+import (
+	"github.com/moleculer-go/store"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// typedError returns the Mongo error as a *store.Error: duplicate keys are conflicts, server selection
+// and network errors are unavailable, timeouts are timeouts and ErrNoDocuments is not found.
+func typedError(err error, msgs ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return store.WrapError(errorCode(err), err, msgs...)
+}
+
+// errorCode returns the store error code of the Mongo error.
+func errorCode(err error) string {
+	switch err.(type) {
+	case topology.ServerSelectionError, topology.ConnectionError:
+		return store.CodeUnavailable
+	}
+	switch {
+	case err == mongo.ErrNoDocuments:
+		return store.CodeNotFound
+	case err == mongo.ErrClientDisconnected, mongo.IsNetworkError(err):
+		return store.CodeUnavailable
+	case mongo.IsDuplicateKeyError(err):
+		return store.CodeConflict
+	case mongo.IsTimeout(err):
+		return store.CodeTimeout
+	}
+	return store.ErrorCode(err)
+}
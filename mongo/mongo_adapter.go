@@ -9,6 +9,8 @@ import (
 
 	"github.com/moleculer-go/moleculer"
 	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/dsl"
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -27,11 +29,30 @@ type MongoAdapter struct {
 	coll       *mongo.Collection
 	logger     *log.Entry
 	mutex      *sync.Mutex
+	settings   map[string]interface{}
+	indexes    []store.Index
+	indexesErr error
+	// ctx is the context of the operations. See WithContext.
+	ctx context.Context
 }
 
 func (adapter *MongoAdapter) Init(logger *log.Entry, settings map[string]interface{}) {
 	adapter.logger = logger
 	adapter.mutex = &sync.Mutex{}
+	adapter.settings = settings
+	adapter.indexes, adapter.indexesErr = store.ParseIndexes(settings)
+}
+
+// ForTenant returns an adapter for the tenant, using its own collection (e.g. users_acme).
+func (adapter *MongoAdapter) ForTenant(tenant string) store.Adapter {
+	scoped := &MongoAdapter{
+		MongoURL:   adapter.MongoURL,
+		Timeout:    adapter.Timeout,
+		Database:   adapter.Database,
+		Collection: adapter.Collection + "_" + tenant,
+	}
+	scoped.Init(adapter.logger, adapter.settings)
+	return scoped
 }
 
 // Connect connect to mongo, stores the client and the collection.
@@ -40,44 +61,167 @@ func (adapter *MongoAdapter) Connect() error {
 		return nil
 	}
 	adapter.logger.Debug("MongoAdapter Connect() MongoURL: ", adapter.MongoURL)
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), adapter.Timeout)
+	defer cancel()
 	var err error
 	adapter.client, err = mongo.Connect(ctx, options.Client().ApplyURI(adapter.MongoURL))
 	if err != nil {
 		adapter.logger.Error("MongoAdapter Connect() error on connect() - error: ", err)
-		return err
+		return store.WrapError(store.CodeUnavailable, err)
 	}
 	err = adapter.client.Ping(ctx, readpref.Primary())
 	if err != nil {
 		adapter.logger.Error("MongoAdapter Connect() error on ping - error: ", err)
-		return err
+		adapter.client.Disconnect(ctx)
+		adapter.client = nil
+		return store.WrapError(store.CodeUnavailable, err)
+	}
+	// coll is only set once the indexes exist, so a failed Connect is retried instead of running without unique indexes.
+	coll := adapter.client.Database(adapter.Database).Collection(adapter.Collection)
+	err = adapter.createIndexes(ctx, coll)
+	if err != nil {
+		adapter.logger.Error("MongoAdapter Connect() error creating indexes - error: ", err)
+		adapter.client.Disconnect(ctx)
+		adapter.client = nil
+		return typedError(err)
 	}
-	adapter.coll = adapter.client.Database(adapter.Database).Collection(adapter.Collection)
+	adapter.coll = coll
 	adapter.logger.Debug("MongoAdapter Connected !")
 	return nil
 }
 
-func (adapter *MongoAdapter) checkConnected() {
-	var start = time.Now()
-	for {
-		if adapter.coll != nil || time.Since(start) >= adapter.Timeout {
-			return
+// indexModels returns the index models of the indexes setting.
+func indexModels(indexes []store.Index) []mongo.IndexModel {
+	models := []mongo.IndexModel{}
+	for _, index := range indexes {
+		keys := bson.D{}
+		for _, field := range index.Fields {
+			direction := 1
+			if field.Descending {
+				direction = -1
+			}
+			keys = append(keys, bson.E{Key: field.Name, Value: direction})
 		}
-		time.Sleep(time.Millisecond * 100)
+		opts := options.Index().SetName(index.Name).SetUnique(index.Unique).SetSparse(index.Sparse)
+		if index.TTL > 0 {
+			opts.SetExpireAfterSeconds(int32(index.TTL.Seconds()))
+		}
+		models = append(models, mongo.IndexModel{Keys: keys, Options: opts})
 	}
+	return models
+}
+
+// createIndexes creates the indexes declared in the indexes setting in the collection.
+func (adapter *MongoAdapter) createIndexes(ctx context.Context, coll *mongo.Collection) error {
+	if adapter.indexesErr != nil {
+		return adapter.indexesErr
+	}
+	if len(adapter.indexes) == 0 {
+		return nil
+	}
+	_, err := coll.Indexes().CreateMany(ctx, indexModels(adapter.indexes))
+	return err
+}
+
+type indexSpecification struct {
+	Name               string `bson:"name"`
+	Key                bson.D `bson:"key"`
+	Unique             bool   `bson:"unique"`
+	Sparse             bool   `bson:"sparse"`
+	ExpireAfterSeconds int64  `bson:"expireAfterSeconds"`
+}
+
+// Indexes returns the indexes of the collection.
+func (adapter *MongoAdapter) Indexes() moleculer.Payload {
 	if adapter.coll == nil {
-		panic("Adapter not connected!")
+		return payload.New(store.NewError(store.CodeUnavailable, "Mongo adapter not connected!"))
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
+	cursor, err := adapter.coll.Indexes().List(ctx)
+	if err != nil {
+		return payload.New(typedError(err))
+	}
+	defer cursor.Close(ctx)
+	list := []map[string]interface{}{}
+	for cursor.Next(ctx) {
+		var spec indexSpecification
+		if err := cursor.Decode(&spec); err != nil {
+			return payload.New(typedError(err))
+		}
+		fields := []string{}
+		for _, key := range spec.Key {
+			if strings.HasPrefix(fmt.Sprint(key.Value), "-") {
+				fields = append(fields, "-"+key.Key)
+			} else {
+				fields = append(fields, key.Key)
+			}
+		}
+		list = append(list, map[string]interface{}{
+			"name":   spec.Name,
+			"fields": fields,
+			"unique": spec.Unique,
+			"sparse": spec.Sparse,
+			"ttl":    spec.ExpireAfterSeconds,
+		})
+	}
+	return payload.New(list)
+}
+
+// WithContext returns the adapter with the operations bound to the context: they are cancelled with the context,
+// and its deadline applies when it is earlier than Timeout.
+func (adapter *MongoAdapter) WithContext(ctx context.Context) store.Adapter {
+	bound := *adapter
+	bound.ctx = ctx
+	return &bound
+}
+
+// operationContext returns the context of an operation: the context of WithContext (default: background) with the Timeout.
+func (adapter *MongoAdapter) operationContext() (context.Context, context.CancelFunc) {
+	parent := adapter.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, adapter.Timeout)
+}
+
+// checkConnected waits up to the Timeout for the connection. Returns an unavailable error when it is not connected.
+func (adapter *MongoAdapter) checkConnected() error {
+	start := time.Now()
+	for adapter.coll == nil {
+		if time.Since(start) >= adapter.Timeout {
+			return store.NewError(store.CodeUnavailable, "Mongo adapter not connected!")
+		}
+		time.Sleep(time.Millisecond * 100)
 	}
+	return nil
+}
+
+// Ping pings the primary of the Mongo deployment.
+func (adapter *MongoAdapter) Ping() error {
+	if adapter.client == nil || adapter.coll == nil {
+		return store.NewError(store.CodeUnavailable, "Mongo adapter not connected!")
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
+	return typedError(adapter.client.Ping(ctx, readpref.Primary()))
 }
 
 // Disconnect disconnects from mongo.
 func (adapter *MongoAdapter) Disconnect() error {
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), adapter.Timeout)
+	defer cancel()
 	adapter.coll = nil
-	return adapter.client.Disconnect(ctx)
+	if adapter.client == nil {
+		return nil
+	}
+	client := adapter.client
+	adapter.client = nil
+	return client.Disconnect(ctx)
 }
 
-func parseSearchFields(params, query moleculer.Payload) moleculer.Payload {
+//parseSearchFields create the filter for the search and searchFields params.
+func parseSearchFields(params moleculer.Payload) bson.M {
 	searchFields := params.Get("searchFields")
 	search := params.Get("search")
 	searchValue := ""
@@ -87,18 +231,16 @@ func parseSearchFields(params, query moleculer.Payload) moleculer.Payload {
 	if searchFields.Exists() {
 		fields := searchFields.StringArray()
 		if len(fields) == 1 {
-			query = query.Add(fields[0], searchValue)
+			return bson.M{fields[0]: searchValue}
 		} else if len(fields) > 1 {
-			or := payload.EmptyList()
+			or := bson.A{}
 			for _, field := range fields {
-				bm := bson.M{}
-				bm[field] = searchValue
-				or = or.AddItem(bm)
+				or = append(or, bson.M{field: searchValue})
 			}
-			query = query.Add("$or", or)
+			return bson.M{"$or": or}
 		}
 	}
-	return query
+	return nil
 }
 
 func parseFindOptions(params moleculer.Payload) *options.FindOptions {
@@ -140,14 +282,22 @@ func parseFindOneAndUpdateOptions(params moleculer.Payload) *options.FindOneAndU
 }
 
 func sortEntry(entry string) primitive.E {
-	item := primitive.E{entry, 1}
+	item := primitive.E{sortField(entry), 1}
 	if strings.Index(entry, "-") == 0 {
 		entry = strings.Replace(entry, "-", "", 1)
-		item = primitive.E{entry, -1}
+		item = primitive.E{sortField(entry), -1}
 	}
 	return item
 }
 
+// sortField returns the mongo field to sort. id is stored in _id.
+func sortField(field string) string {
+	if field == "id" {
+		return "_id"
+	}
+	return field
+}
+
 func sortsFromString(sort moleculer.Payload) primitive.D {
 	parts := strings.Split(strings.Trim(sort.String(), " "), " ")
 	if len(parts) > 1 {
@@ -175,25 +325,136 @@ func sortsFromStringArray(sort moleculer.Payload) bson.D {
 	return sorts
 }
 
-func parseFilter(params moleculer.Payload) bson.M {
-	query := payload.Empty()
-	if params.Get("query").Exists() {
-		query = params.Get("query")
+// objectId converts the hex ids to primitive.ObjectID. Other values are returned as they are.
+func objectId(value interface{}) interface{} {
+	if hex, isString := value.(string); isString {
+		if id, err := primitive.ObjectIDFromHex(hex); err == nil {
+			return id
+		}
 	}
-	query = parseSearchFields(params, query)
-	return query.Bson()
+	return value
 }
 
-func (adapter *MongoAdapter) openCursor(params moleculer.Payload) (*mongo.Cursor, context.Context, error) {
-	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	filter := parseFilter(params)
+// idCondition maps the id field of the condition to _id, as sortField does, converting the ids to primitive.ObjectID.
+func idCondition(c dsl.Condition) dsl.Condition {
+	if c.Field != "id" {
+		return c
+	}
+	c.Field = "_id"
+	if list, isList := c.Value.([]interface{}); isList {
+		ids := make([]interface{}, len(list))
+		for i, item := range list {
+			ids[i] = objectId(item)
+		}
+		c.Value = ids
+		return c
+	}
+	c.Value = objectId(c.Value)
+	return c
+}
+
+//conditionFilter translates a query condition to a mongo filter.
+func conditionFilter(c dsl.Condition) bson.M {
+	c = idCondition(c)
+	switch c.Operator {
+	case dsl.Eq:
+		return bson.M{c.Field: c.Value}
+	case dsl.Ne:
+		return bson.M{c.Field: bson.M{"$nin": bson.A{c.Value, nil}}}
+	case dsl.In:
+		list, _ := c.Value.([]interface{})
+		return bson.M{c.Field: bson.M{"$in": list}}
+	case dsl.Nin:
+		list, _ := c.Value.([]interface{})
+		return bson.M{c.Field: bson.M{"$nin": append(append([]interface{}{}, list...), nil)}}
+	case dsl.Between:
+		pair, _ := c.Value.([]interface{})
+		if len(pair) != 2 {
+			return bson.M{c.Field: bson.M{"$in": bson.A{}}}
+		}
+		return bson.M{c.Field: bson.M{"$gte": pair[0], "$lte": pair[1]}}
+	case dsl.Like:
+		pattern, _ := c.Value.(string)
+		return bson.M{c.Field: bson.M{"$regex": dsl.LikeToRegexp(pattern)}}
+	case dsl.Exists:
+		if exists, _ := c.Value.(bool); exists {
+			return bson.M{c.Field: bson.M{"$ne": nil}}
+		}
+		return bson.M{c.Field: nil}
+	}
+	return bson.M{c.Field: bson.M{c.Operator: c.Value}}
+}
+
+//queryFilter translates the query to a mongo filter.
+func queryFilter(node dsl.Node) bson.M {
+	switch n := node.(type) {
+	case dsl.Condition:
+		return conditionFilter(n)
+	case dsl.Negation:
+		return bson.M{"$nor": bson.A{queryFilter(n.Node)}}
+	case dsl.Logical:
+		if len(n.Nodes) == 0 {
+			if n.Operator == dsl.Or {
+				return bson.M{"_id": bson.M{"$in": bson.A{}}}
+			}
+			return bson.M{}
+		}
+		if len(n.Nodes) == 1 {
+			return queryFilter(n.Nodes[0])
+		}
+		list := bson.A{}
+		for _, child := range n.Nodes {
+			list = append(list, queryFilter(child))
+		}
+		return bson.M{n.Operator: list}
+	}
+	return bson.M{}
+}
+
+//parseFilter create the mongo filter for the query, nativeQuery and search params.
+//the query uses the portable query language (see package dsl) and
+//nativeQuery is a raw mongo filter.
+func parseFilter(params moleculer.Payload) (bson.M, error) {
+	node, err := dsl.Parse(params.Get("query"))
+	if err != nil {
+		return nil, err
+	}
+	filters := bson.A{}
+	if query := queryFilter(node); len(query) > 0 {
+		filters = append(filters, query)
+	}
+	if params.Get("nativeQuery").Exists() {
+		filters = append(filters, params.Get("nativeQuery").Bson())
+	}
+	if search := parseSearchFields(params); search != nil {
+		filters = append(filters, search)
+	}
+	if len(filters) == 0 {
+		return bson.M{}, nil
+	}
+	if len(filters) == 1 {
+		return filters[0].(bson.M), nil
+	}
+	return bson.M{"$and": filters}, nil
+}
+
+func (adapter *MongoAdapter) openCursor(params moleculer.Payload) (*mongo.Cursor, context.Context, context.CancelFunc, error) {
+	if err := adapter.checkConnected(); err != nil {
+		return nil, nil, nil, err
+	}
+	ctx, cancel := adapter.operationContext()
+	filter, err := parseFilter(params)
+	if err != nil {
+		cancel()
+		return nil, nil, nil, store.WrapError(store.CodeValidation, err, "Invalid query - error: ")
+	}
 	opts := parseFindOptions(params)
 	cursor, err := adapter.coll.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, nil, err
+		cancel()
+		return nil, nil, nil, typedError(err)
 	}
-	return cursor, ctx, nil
+	return cursor, ctx, cancel, nil
 }
 
 // applyTransforms apply a list of transformations on the value param.
@@ -211,13 +472,13 @@ func cursorToPayload(ctx context.Context, cursor *mongo.Cursor, transform ...fun
 		var item bson.M
 		err := cursor.Decode(&item)
 		if err != nil {
-			return payload.New(err)
+			return payload.New(typedError(err))
 		}
 		transformed := applyTransforms(item, transform...)
 		list = append(list, payload.New(transformed))
 	}
 	if err := cursor.Err(); err != nil {
-		return payload.New(err)
+		return payload.New(typedError(err))
 	}
 	return payload.New(list)
 }
@@ -233,59 +494,96 @@ func idTransform(bm bson.M) bson.M {
 	return bm
 }
 
+// updateValues translate the update to mongo update operators.
+// plain fields are wrapped in $set, $inc, $push, $pull and $unset are passed through.
+func updateValues(update moleculer.Payload) (bson.M, error) {
+	ops, err := dsl.ParseUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	values := bson.M{}
+	for _, op := range ops {
+		fields, exists := values[op.Operator].(bson.M)
+		if !exists {
+			fields = bson.M{}
+			values[op.Operator] = fields
+		}
+		if op.Operator == dsl.Unset {
+			fields[op.Field] = ""
+		} else {
+			fields[op.Field] = op.Value
+		}
+	}
+	return values, nil
+}
+
 func (adapter *MongoAdapter) FindAndUpdate(param moleculer.Payload) moleculer.Payload {
 	update := param.Get("update")
 	param = param.Remove("update")
 
-	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	filter := parseFilter(param)
+	if err := adapter.checkConnected(); err != nil {
+		return payload.New(err)
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
+	filter, err := parseFilter(param)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid query - error: "))
+	}
 	opts := parseFindOneAndUpdateOptions(param)
 
-	updateValues := payload.Empty().Add("$set", update).Bson()
-	r := adapter.coll.FindOneAndUpdate(ctx, filter, updateValues, opts)
+	values, err := updateValues(update)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid update - error: "))
+	}
+	r := adapter.coll.FindOneAndUpdate(ctx, filter, values, opts)
 	var item bson.M
-	err := r.Decode(&item)
+	err = r.Decode(&item)
 	if err != nil {
-		return payload.New(err)
+		return payload.New(typedError(err))
 	}
 	transformed := applyTransforms(item, idTransform)
 
 	if err := r.Err(); err != nil {
-		return payload.New(err)
+		return payload.New(typedError(err))
 	}
 	return payload.New(transformed)
 }
 
 // Find search the data store with the params provided.
 func (adapter *MongoAdapter) Find(params moleculer.Payload) moleculer.Payload {
-	cursor, ctx, err := adapter.openCursor(params)
+	cursor, ctx, cancel, err := adapter.openCursor(params)
 	if err != nil {
 		return payload.New(err)
 	}
+	defer cancel()
 	defer cursor.Close(ctx)
 	return cursorToPayload(ctx, cursor, idTransform)
 }
 
 func (adapter *MongoAdapter) FindOne(params moleculer.Payload) moleculer.Payload {
 	params = params.Add("limit", 1)
-	return adapter.Find(params).First()
+	result := adapter.Find(params)
+	if result.IsError() {
+		return result
+	}
+	return result.First()
 }
 
 func (adapter *MongoAdapter) FindById(params moleculer.Payload) moleculer.Payload {
 	objId, err := primitive.ObjectIDFromHex(params.String())
 	if err != nil {
-		return payload.Error("Invalid id error: ", err)
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid id error: "))
 	}
-	filter := payload.New(bson.M{
-		"query": bson.M{"_id": objId},
+	filter := payload.New(map[string]interface{}{
+		"query": map[string]interface{}{"_id": objId},
 	})
 	return adapter.FindOne(filter)
 }
 
 func (adapter *MongoAdapter) FindByIds(params moleculer.Payload) moleculer.Payload {
 	if !params.IsArray() {
-		return payload.Error("FindByIds() only support lists!  --> !params.IsArray()")
+		return payload.New(store.NewError(store.CodeValidation, "FindByIds() only support lists!  --> !params.IsArray()"))
 	}
 	r := payload.EmptyList()
 	params.ForEach(func(idx interface{}, id moleculer.Payload) bool {
@@ -297,23 +595,32 @@ func (adapter *MongoAdapter) FindByIds(params moleculer.Payload) moleculer.Paylo
 
 // Count count the number of records for the given filter.
 func (adapter *MongoAdapter) Count(params moleculer.Payload) moleculer.Payload {
-	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	filter := parseFilter(params)
+	if err := adapter.checkConnected(); err != nil {
+		return payload.New(err)
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
+	filter, err := parseFilter(params)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid query - error: "))
+	}
 	count, err := adapter.coll.CountDocuments(ctx, filter)
 	if err != nil {
-		return payload.New(err)
+		return payload.New(typedError(err))
 	}
 	return payload.New(count)
 }
 
 func (adapter *MongoAdapter) Insert(params moleculer.Payload) moleculer.Payload {
-	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
+	if err := adapter.checkConnected(); err != nil {
+		return payload.New(err)
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
 	values := params.Bson()
 	res, err := adapter.coll.InsertOne(ctx, values)
 	if err != nil {
-		return payload.Error("Error while trying to insert record. Error: ", err.Error())
+		return payload.New(typedError(err, "Error while trying to insert record. Error: "))
 	}
 	return params.Add("id", res.InsertedID.(primitive.ObjectID).Hex())
 }
@@ -321,46 +628,104 @@ func (adapter *MongoAdapter) Insert(params moleculer.Payload) moleculer.Payload
 func (adapter *MongoAdapter) Update(params moleculer.Payload) moleculer.Payload {
 	id := params.Get("id")
 	if !id.Exists() {
-		return payload.Error("Cannot update record without id")
+		return payload.New(store.NewError(store.CodeValidation, "Cannot update record without id"))
 	}
 	return adapter.UpdateById(id, params.Remove("id"))
 }
 
 func (adapter *MongoAdapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
-	adapter.checkConnected()
+	if err := adapter.checkConnected(); err != nil {
+		return payload.New(err)
+	}
 	objId, err := primitive.ObjectIDFromHex(id.String())
 	if err != nil {
-		return payload.Error("Cannot update record without id - error: ", err)
+		return payload.New(store.WrapError(store.CodeValidation, err, "Cannot update record without id - error: "))
 	}
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	values := payload.Empty().Add("$set", update).Bson()
+	values, err := updateValues(update)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid update - error: "))
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
 	ur, uerr := adapter.coll.UpdateOne(ctx, bson.M{"_id": objId}, values)
 	if uerr != nil {
-		return payload.Error("Cannot update record - error: ", uerr)
+		return payload.New(typedError(uerr, "Cannot update record - error: "))
+	}
+	if ur.MatchedCount == 0 {
+		return payload.New(store.NewError(store.CodeNotFound, "Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()}))
+	}
+	return payload.Empty().Add("modifiedCount", ur.ModifiedCount).Add("matchedCount", ur.MatchedCount)
+}
+
+// UpdateMany update all documents matching the filter.
+func (adapter *MongoAdapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	if err := adapter.checkConnected(); err != nil {
+		return payload.New(err)
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
+	filter, err := parseFilter(params)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid query - error: "))
+	}
+	values, err := updateValues(params.Get("update"))
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid update - error: "))
+	}
+	ur, err := adapter.coll.UpdateMany(ctx, filter, values)
+	if err != nil {
+		return payload.New(typedError(err, "Cannot update records - error: "))
 	}
 	return payload.Empty().Add("modifiedCount", ur.ModifiedCount).Add("matchedCount", ur.MatchedCount)
 }
 
 func (adapter *MongoAdapter) RemoveById(id moleculer.Payload) moleculer.Payload {
-	adapter.checkConnected()
+	if err := adapter.checkConnected(); err != nil {
+		return payload.New(err)
+	}
 	objId, err := primitive.ObjectIDFromHex(id.String())
 	if err != nil {
-		return payload.Error("Cannot update record without id - error: ", err)
+		return payload.New(store.WrapError(store.CodeValidation, err, "Cannot update record without id - error: "))
 	}
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
 	dr, uerr := adapter.coll.DeleteOne(ctx, bson.M{"_id": objId})
 	if uerr != nil {
-		return payload.Error("Cannot update record - error: ", uerr)
+		return payload.New(typedError(uerr, "Cannot update record - error: "))
+	}
+	if dr.DeletedCount == 0 {
+		return payload.New(store.NewError(store.CodeNotFound, "Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()}))
+	}
+	return payload.Empty().Add("deletedCount", dr.DeletedCount)
+}
+
+// RemoveMany remove all documents matching the filter.
+func (adapter *MongoAdapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	if err := adapter.checkConnected(); err != nil {
+		return payload.New(err)
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
+	filter, err := parseFilter(params)
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Invalid query - error: "))
+	}
+	dr, err := adapter.coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return payload.New(typedError(err, "Cannot remove records - error: "))
 	}
 	return payload.Empty().Add("deletedCount", dr.DeletedCount)
 }
 
 func (adapter *MongoAdapter) RemoveAll() moleculer.Payload {
-	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
+	if err := adapter.checkConnected(); err != nil {
+		return payload.New(err)
+	}
+	ctx, cancel := adapter.operationContext()
+	defer cancel()
 	res, err := adapter.coll.DeleteMany(ctx, bson.M{})
 	if err != nil {
-		return payload.Error("Error while trying to remove all records. Error: ", err.Error())
+		return payload.New(typedError(err, "Error while trying to remove all records. Error: "))
 	}
 	return payload.Empty().Add("deletedCount", res.DeletedCount)
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/moleculer-go/cupaloy/v2"
 	"github.com/moleculer-go/moleculer"
 	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
 	"github.com/moleculer-go/store/mocks"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -52,6 +53,20 @@ var _ = Describe("Mongo Adapter", func() {
 		adapter.Disconnect()
 	})
 
+	It("should not connect while the indexes can't be created", func() {
+		// the users have duplicated names, so the unique index fails
+		indexed := &MongoAdapter{
+			MongoURL:   mongoTestsHost,
+			Timeout:    2 * time.Second,
+			Database:   "mongo_adapter_tests",
+			Collection: "user",
+		}
+		indexed.Init(log.WithField("test", "adapter"), M{"indexes": []M{{"fields": []string{"name"}, "unique": true}}})
+		Expect(indexed.Connect()).ShouldNot(Succeed())
+		Expect(indexed.Connect()).ShouldNot(Succeed())
+		Expect(store.IsUnavailable(indexed.Indexes().Error())).Should(BeTrue())
+	})
+
 	Describe("Count", func() {
 		It("should count the number of records properly", func() {
 			result := adapter.Count(payload.New(M{}))
@@ -174,6 +189,29 @@ var _ = Describe("Mongo Adapter", func() {
 			Expect(r.Len()).Should(Equal(3))
 		})
 
+		It("should translate the portable query operators", func() {
+			r := adapter.Find(payload.New(M{
+				"query": M{
+					"age":  M{"$between": []int{40, 70}},
+					"name": M{"$like": "j%"},
+				},
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(2))
+
+			r = adapter.Find(payload.New(M{
+				"query": M{"$not": M{"age": M{"<": 20}}},
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(4))
+
+			r = adapter.Find(payload.New(M{
+				"nativeQuery": M{"age": M{"$mod": []int{5, 0}}},
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(3))
+		})
+
 	})
 
 	Describe("FindById", func() {
@@ -191,6 +229,16 @@ var _ = Describe("Mongo Adapter", func() {
 			Expect(result.Get("lastname").String()).Should(Equal(marie.Get("lastname").String()))
 			Expect(result.Get("age").Int()).Should(Equal(marie.Get("age").Int()))
 		})
+
+		It("should find the records by id with the query language", func() {
+			r := adapter.Find(payload.New(M{"query": M{"id": johnSnow.Get("id").String()}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("lastname").String()).Should(Equal("Snow"))
+
+			r = adapter.Find(payload.New(M{"query": M{"id": M{"$in": []string{johnSnow.Get("id").String(), marie.Get("id").String()}}}}))
+			Expect(r.Len()).Should(Equal(2))
+		})
 	})
 
 	Describe("FindOne", func() {
@@ -245,6 +293,18 @@ var _ = Describe("Mongo Adapter", func() {
 			Expect(result.Len()).Should(Equal(totalRecords - 1))
 		})
 
+		It("RemoveMany should remove all records matching the query", func() {
+			result := adapter.RemoveMany(payload.New(M{
+				"query": M{"age": 13},
+			}))
+			Expect(result.Error()).Should(BeNil())
+			Expect(result.Get("deletedCount").Int()).Should(Equal(2))
+
+			result = adapter.Find(payload.New(M{}))
+			Expect(result.Error()).Should(BeNil())
+			Expect(result.Len()).Should(Equal(totalRecords - 2))
+		})
+
 		It("RemoveAll should removed all records", func() {
 			result := adapter.RemoveAll()
 			Expect(result.Exists()).Should(BeTrue())
@@ -276,6 +336,18 @@ var _ = Describe("Mongo Adapter", func() {
 			Expect(result.Get("house").String()).Should(Equal("Spark"))
 		})
 
+		It("UpdateMany should update all records matching the query", func() {
+			result := adapter.UpdateMany(payload.New(M{
+				"query":  M{"name": "John"},
+				"update": M{"house": "Stark"},
+			}))
+			Expect(result.Error()).Should(BeNil())
+			Expect(result.Get("modifiedCount").Int()).Should(Equal(2))
+
+			result = adapter.Count(payload.New(M{"query": M{"house": "Stark"}}))
+			Expect(result.Int()).Should(Equal(2))
+		})
+
 		It("UpdateById should update record", func() {
 			result := adapter.UpdateById(johnSnow.Get("id"), payload.New(M{
 				"age": 120,
@@ -304,3 +376,32 @@ var _ = Describe("Mongo Adapter", func() {
 	})
 
 })
+
+var _ = Describe("Mongo Adapter not connected", func() {
+	adapter := mongoAdapter("mongo_adapter_tests", "user")
+	adapter.Timeout = 100 * time.Millisecond
+
+	It("Indexes should fail with unavailable", func() {
+		Expect(store.IsUnavailable(adapter.Indexes().Error())).Should(BeTrue())
+	})
+
+	It("the operations should fail with unavailable after the timeout", func() {
+		id := payload.New("5ea4a1a5c6d1f2a3b4c5d6e7")
+		results := []moleculer.Payload{
+			adapter.Find(payload.Empty()),
+			adapter.FindOne(payload.Empty()),
+			adapter.FindById(id),
+			adapter.Count(payload.Empty()),
+			adapter.Insert(payload.New(M{"name": "John"})),
+			adapter.UpdateById(id, payload.New(M{"age": 1})),
+			adapter.UpdateMany(payload.New(M{"query": M{}, "update": M{"age": 1}})),
+			adapter.FindAndUpdate(payload.New(M{"query": M{}, "update": M{"age": 1}})),
+			adapter.RemoveById(id),
+			adapter.RemoveMany(payload.New(M{"query": M{}})),
+			adapter.RemoveAll(),
+		}
+		for _, result := range results {
+			Expect(store.IsUnavailable(result.Error())).Should(BeTrue())
+		}
+	})
+})
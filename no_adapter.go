@@ -42,3 +42,9 @@ func (adapter *NotDefinedAdapter) UpdateById(params moleculer.Payload) moleculer
 func (adapter *NotDefinedAdapter) RemoveById(params moleculer.Payload) moleculer.Payload {
 	panic(msg)
 }
+func (adapter *NotDefinedAdapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	panic(msg)
+}
+func (adapter *NotDefinedAdapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	panic(msg)
+}
@@ -0,0 +1,338 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+	log "github.com/sirupsen/logrus"
+)
+
+// permissionsConfig is parsed from the permissions setting:
+// "permissions": true -> use defaults
+// "permissions": map[string]interface{}{
+//	"ownerField":  "ownerId",          // record field with the owner. Default: ownerId
+//	"aclField":    "acl",              // record field with the user ids/roles the record is shared with. Default: acl
+//	"userKey":     "userId",           // ctx.Meta key with the caller id. Default: userId
+//	"aclWrite":    false,              // the acl also allows update and remove. Default: false (read only)
+//	"bypassRoles": []string{"admin"},  // roles allowed to access all records. Default: none
+// }
+type permissionsConfig struct {
+	ownerField  string
+	aclField    string
+	userKey     string
+	aclWrite    bool
+	bypassRoles []string
+}
+
+// parsePermissions returns nil when the permissions setting is not enabled.
+func parsePermissions(settings map[string]interface{}) *permissionsConfig {
+	value, exists := settings["permissions"]
+	if !exists || value == nil {
+		return nil
+	}
+	config := &permissionsConfig{ownerField: "ownerId", aclField: "acl", userKey: "userId"}
+	switch setting := value.(type) {
+	case bool:
+		if !setting {
+			return nil
+		}
+	case map[string]interface{}:
+		if ownerField, ok := setting["ownerField"].(string); ok && ownerField != "" {
+			config.ownerField = ownerField
+		}
+		if aclField, ok := setting["aclField"].(string); ok && aclField != "" {
+			config.aclField = aclField
+		}
+		if userKey, ok := setting["userKey"].(string); ok && userKey != "" {
+			config.userKey = userKey
+		}
+		if aclWrite, ok := setting["aclWrite"].(bool); ok {
+			config.aclWrite = aclWrite
+		}
+		config.bypassRoles = stringList(payload.New(setting["bypassRoles"]))
+	default:
+		return nil
+	}
+	return config
+}
+
+// permissionAdapter returns the adapter restricted to the records the caller can access.
+// When permissions are not enabled, or the caller has a bypass role, it returns the adapter itself.
+func permissionAdapter(ctx moleculer.Context, adapter Adapter, instance *moleculer.ServiceSchema) (Adapter, error) {
+	if instance == nil {
+		return adapter, nil
+	}
+	config := parsePermissions(instance.Settings)
+	if config == nil {
+		return adapter, nil
+	}
+	roles := callerRoles(ctx)
+	if len(config.bypassRoles) > 0 && hasAnyRole(config.bypassRoles, roles) {
+		return adapter, nil
+	}
+	user := payload.New(nil)
+	if meta := ctx.Meta(); meta != nil {
+		user = meta.Get(config.userKey)
+	}
+	if !user.Exists() || user.String() == "" {
+		return nil, NewError(CodeValidation, "user required! ctx.Meta."+config.userKey+" is missing.")
+	}
+	return &permissionScope{adapter, config, user.String(), append([]string{user.String()}, roles...)}, nil
+}
+
+// permissionScope wraps an adapter restricting the operations to the records the user owns or are shared with the user.
+type permissionScope struct {
+	adapter Adapter
+	config  *permissionsConfig
+	user    string
+	// grants are the user id and roles matched against the acl of the records.
+	grants []string
+}
+
+func (p *permissionScope) Init(logger *log.Entry, settings map[string]interface{}) {
+	p.adapter.Init(logger, settings)
+}
+
+func (p *permissionScope) Connect() error {
+	return p.adapter.Connect()
+}
+
+func (p *permissionScope) Disconnect() error {
+	return p.adapter.Disconnect()
+}
+
+func (p *permissionScope) ownerFilter() map[string]interface{} {
+	return map[string]interface{}{p.config.ownerField: p.user}
+}
+
+func (p *permissionScope) sharedFilter() map[string]interface{} {
+	grants := []interface{}{}
+	for _, grant := range p.grants {
+		grants = append(grants, grant)
+	}
+	return map[string]interface{}{"$or": []interface{}{
+		p.ownerFilter(),
+		map[string]interface{}{p.config.aclField: map[string]interface{}{"$in": grants}},
+	}}
+}
+
+// writeFilter returns the filter of the records the user can update or remove.
+func (p *permissionScope) writeFilter() map[string]interface{} {
+	if p.config.aclWrite {
+		return p.sharedFilter()
+	}
+	return p.ownerFilter()
+}
+
+// scope adds the filter to the query of the params.
+// Native queries are rejected, since the filter can't be added to them.
+func (p *permissionScope) scope(params moleculer.Payload, filter map[string]interface{}) (moleculer.Payload, error) {
+	if params == nil {
+		params = payload.Empty()
+	}
+	if params.Get("nativeQuery").Exists() {
+		return nil, NewError(CodeValidation, "nativeQuery is not supported with permissions!")
+	}
+	query := params.Get("query")
+	if query.Exists() {
+		filter = map[string]interface{}{"$and": []interface{}{query.Value(), filter}}
+	}
+	return params.Add("query", filter), nil
+}
+
+func (p *permissionScope) owns(record moleculer.Payload) bool {
+	return record.Get(p.config.ownerField).Exists() && record.Get(p.config.ownerField).String() == p.user
+}
+
+func (p *permissionScope) shared(record moleculer.Payload) bool {
+	for _, entry := range stringList(record.Get(p.config.aclField)) {
+		if contains(p.grants, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *permissionScope) canRead(record moleculer.Payload) bool {
+	return record != nil && !record.IsError() && record.Exists() && (p.owns(record) || p.shared(record))
+}
+
+func (p *permissionScope) canWrite(record moleculer.Payload) bool {
+	return record != nil && !record.IsError() && record.Exists() && (p.owns(record) || (p.config.aclWrite && p.shared(record)))
+}
+
+// checkWrite returns an error when the user can't update or remove the record of the id.
+func (p *permissionScope) checkWrite(id moleculer.Payload) (moleculer.Payload, moleculer.Payload) {
+	record := p.adapter.FindById(id)
+	if record.IsError() {
+		return nil, record
+	}
+	if !record.Exists() {
+		return nil, payload.New(NewError(CodeNotFound, "Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()}))
+	}
+	if !p.canWrite(record) {
+		return nil, payload.New(NewError(CodeForbidden, "Permission denied to change record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()}))
+	}
+	return record, nil
+}
+
+// changes checks if the update operation changes the field or one of its nested fields.
+func changes(op dsl.UpdateOp, field string) bool {
+	return op.Field == field || strings.HasPrefix(op.Field, field+".")
+}
+
+// checkUpdate rejects updates that change the owner field. changesAcl is true when the update changes
+// the acl field, which only the owner of the record can do.
+func (p *permissionScope) checkUpdate(update moleculer.Payload) (changesAcl bool, err error) {
+	if update == nil || !update.Exists() {
+		return false, nil
+	}
+	ops, err := dsl.ParseUpdate(update)
+	if err != nil {
+		return false, WrapError(CodeValidation, err)
+	}
+	for _, op := range ops {
+		if changes(op, p.config.ownerField) {
+			return false, NewError(CodeForbidden, "owner field "+p.config.ownerField+" can't be updated!").WithData(map[string]interface{}{"field": p.config.ownerField})
+		}
+		changesAcl = changesAcl || changes(op, p.config.aclField)
+	}
+	return changesAcl, nil
+}
+
+// updateFilter returns the filter of the records the user can change with the update:
+// only the owned records when the update changes the acl.
+func (p *permissionScope) updateFilter(update moleculer.Payload) (map[string]interface{}, error) {
+	changesAcl, err := p.checkUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	if changesAcl {
+		return p.ownerFilter(), nil
+	}
+	return p.writeFilter(), nil
+}
+
+func (p *permissionScope) Find(params moleculer.Payload) moleculer.Payload {
+	scoped, err := p.scope(params, p.sharedFilter())
+	if err != nil {
+		return payload.New(err)
+	}
+	return p.adapter.Find(scoped)
+}
+
+func (p *permissionScope) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	filter, err := p.updateFilter(params.Get("update"))
+	if err != nil {
+		return payload.New(err)
+	}
+	scoped, err := p.scope(params, filter)
+	if err != nil {
+		return payload.New(err)
+	}
+	return p.adapter.FindAndUpdate(scoped)
+}
+
+func (p *permissionScope) FindOne(params moleculer.Payload) moleculer.Payload {
+	scoped, err := p.scope(params, p.sharedFilter())
+	if err != nil {
+		return payload.New(err)
+	}
+	return p.adapter.FindOne(scoped)
+}
+
+// FindById returns an empty payload when the user can't read the record.
+func (p *permissionScope) FindById(id moleculer.Payload) moleculer.Payload {
+	record := p.adapter.FindById(id)
+	if record.IsError() || p.canRead(record) {
+		return record
+	}
+	return payload.New(nil)
+}
+
+// FindByIds only returns the records the user can read.
+func (p *permissionScope) FindByIds(ids moleculer.Payload) moleculer.Payload {
+	result := p.adapter.FindByIds(ids)
+	if result.IsError() {
+		return result
+	}
+	list := []moleculer.Payload{}
+	result.ForEach(func(_ interface{}, record moleculer.Payload) bool {
+		if p.canRead(record) {
+			list = append(list, record)
+		}
+		return true
+	})
+	return payload.New(list)
+}
+
+func (p *permissionScope) Count(params moleculer.Payload) moleculer.Payload {
+	scoped, err := p.scope(params, p.sharedFilter())
+	if err != nil {
+		return payload.New(err)
+	}
+	return p.adapter.Count(scoped)
+}
+
+// Insert sets the user as the owner of the record.
+func (p *permissionScope) Insert(params moleculer.Payload) moleculer.Payload {
+	return p.adapter.Insert(params.Add(p.config.ownerField, p.user))
+}
+
+func (p *permissionScope) Update(params moleculer.Payload) moleculer.Payload {
+	return p.UpdateById(params.Get("id"), params.Remove("id"))
+}
+
+// UpdateById rejects changes of the owner field, and of the acl field when the user doesn't own the record.
+func (p *permissionScope) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	changesAcl, err := p.checkUpdate(update)
+	if err != nil {
+		return payload.New(err)
+	}
+	record, denied := p.checkWrite(id)
+	if denied != nil {
+		return denied
+	}
+	if changesAcl && !p.owns(record) {
+		return payload.New(NewError(CodeForbidden, "Only the owner can change the ", p.config.aclField, " of record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value(), "field": p.config.aclField}))
+	}
+	return p.adapter.UpdateById(id, update)
+}
+
+func (p *permissionScope) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	filter, err := p.updateFilter(params.Get("update"))
+	if err != nil {
+		return payload.New(err)
+	}
+	scoped, err := p.scope(params, filter)
+	if err != nil {
+		return payload.New(err)
+	}
+	return p.adapter.UpdateMany(scoped)
+}
+
+func (p *permissionScope) RemoveById(id moleculer.Payload) moleculer.Payload {
+	if _, denied := p.checkWrite(id); denied != nil {
+		return denied
+	}
+	return p.adapter.RemoveById(id)
+}
+
+func (p *permissionScope) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	scoped, err := p.scope(params, p.writeFilter())
+	if err != nil {
+		return payload.New(err)
+	}
+	return p.adapter.RemoveMany(scoped)
+}
+
+// RemoveAll only removes the records the user can remove.
+func (p *permissionScope) RemoveAll() moleculer.Payload {
+	scoped, err := p.scope(payload.Empty(), p.writeFilter())
+	if err != nil {
+		return payload.New(err)
+	}
+	return p.adapter.RemoveMany(scoped)
+}
@@ -0,0 +1,258 @@
+// Package q is a fluent builder for the params of the find, list and count actions.
+//
+//	params := q.Where(q.Field("age").Gt(60).And(q.Field("name").Like("Jo%"))).
+//		Sort("-age").Limit(10).Params()
+//	bkr.Call("user.find", params)
+package q
+
+import (
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+)
+
+// Expr is a query expression. It implements dsl.Expression, so it can be used
+// directly as the query param when adapters are called in process.
+type Expr struct {
+	node dsl.Node
+}
+
+// Node returns the query node of the expression.
+func (e Expr) Node() dsl.Node {
+	if e.node == nil {
+		return dsl.Logical{Operator: dsl.And, Nodes: []dsl.Node{}}
+	}
+	return e.node
+}
+
+// Map returns the expression in the query language format.
+func (e Expr) Map() map[string]interface{} {
+	return dsl.Format(e.Node())
+}
+
+// And combines the expression with the others using $and.
+func (e Expr) And(others ...Expr) Expr {
+	return And(append([]Expr{e}, others...)...)
+}
+
+// Or combines the expression with the others using $or.
+func (e Expr) Or(others ...Expr) Expr {
+	return Or(append([]Expr{e}, others...)...)
+}
+
+func combine(operator string, exprs []Expr) Expr {
+	nodes := []dsl.Node{}
+	for _, expr := range exprs {
+		if expr.node != nil {
+			nodes = append(nodes, expr.node)
+		}
+	}
+	if len(nodes) == 1 {
+		return Expr{nodes[0]}
+	}
+	return Expr{dsl.Logical{Operator: operator, Nodes: nodes}}
+}
+
+// And matches when all expressions match.
+func And(exprs ...Expr) Expr {
+	return combine(dsl.And, exprs)
+}
+
+// Or matches when any of the expressions match.
+func Or(exprs ...Expr) Expr {
+	return combine(dsl.Or, exprs)
+}
+
+// Not matches when the expression does not match.
+func Not(expr Expr) Expr {
+	return Expr{dsl.Negation{Node: expr.Node()}}
+}
+
+// FieldRef is a field of the records. Nested fields use dotted paths (e.g. address.city).
+type FieldRef struct {
+	name string
+}
+
+// Field returns a reference to the field, used to create conditions.
+func Field(name string) FieldRef {
+	return FieldRef{name}
+}
+
+func (f FieldRef) condition(operator string, value interface{}) Expr {
+	return Expr{dsl.Condition{Field: f.name, Operator: operator, Value: value}}
+}
+
+// Eq matches when the field is equal to the value.
+func (f FieldRef) Eq(value interface{}) Expr {
+	if value == nil {
+		return f.Missing()
+	}
+	return f.condition(dsl.Eq, value)
+}
+
+// Ne matches when the field has a value different from the value.
+func (f FieldRef) Ne(value interface{}) Expr {
+	return f.condition(dsl.Ne, value)
+}
+
+// Gt matches when the field is greater than the value.
+func (f FieldRef) Gt(value interface{}) Expr {
+	return f.condition(dsl.Gt, value)
+}
+
+// Gte matches when the field is greater than or equal to the value.
+func (f FieldRef) Gte(value interface{}) Expr {
+	return f.condition(dsl.Gte, value)
+}
+
+// Lt matches when the field is less than the value.
+func (f FieldRef) Lt(value interface{}) Expr {
+	return f.condition(dsl.Lt, value)
+}
+
+// Lte matches when the field is less than or equal to the value.
+func (f FieldRef) Lte(value interface{}) Expr {
+	return f.condition(dsl.Lte, value)
+}
+
+// In matches when the field is equal to one of the values.
+func (f FieldRef) In(values ...interface{}) Expr {
+	return f.condition(dsl.In, values)
+}
+
+// Nin matches when the field has a value that is not one of the values.
+func (f FieldRef) Nin(values ...interface{}) Expr {
+	return f.condition(dsl.Nin, values)
+}
+
+// Between matches when the field is between low and high (inclusive).
+func (f FieldRef) Between(low, high interface{}) Expr {
+	return f.condition(dsl.Between, []interface{}{low, high})
+}
+
+// Like matches the field with a like pattern. % matches any text and _ one character.
+func (f FieldRef) Like(pattern string) Expr {
+	return f.condition(dsl.Like, pattern)
+}
+
+// Exists matches when the field has a value.
+func (f FieldRef) Exists() Expr {
+	return f.condition(dsl.Exists, true)
+}
+
+// Missing matches when the field has no value.
+func (f FieldRef) Missing() Expr {
+	return f.condition(dsl.Exists, false)
+}
+
+// Query holds the params of the find, list and count actions.
+type Query struct {
+	filter       Expr
+	search       string
+	searchFields []string
+	sort         []string
+	fields       []string
+	populate     []string
+	limit        int
+	offset       int
+	page         int
+	pageSize     int
+}
+
+// Where creates a query with the expressions. Multiple expressions are combined using $and.
+func Where(exprs ...Expr) *Query {
+	return (&Query{}).Where(exprs...)
+}
+
+// Where adds the expressions to the query filter using $and.
+func (query *Query) Where(exprs ...Expr) *Query {
+	query.filter = And(append([]Expr{query.filter}, exprs...)...)
+	return query
+}
+
+// Search searches the text in the fields.
+func (query *Query) Search(text string, fields ...string) *Query {
+	query.search = text
+	query.searchFields = fields
+	return query
+}
+
+// Sort sets the sort fields. Use - for descending order (e.g. -age).
+func (query *Query) Sort(fields ...string) *Query {
+	query.sort = fields
+	return query
+}
+
+// Fields sets the fields returned.
+func (query *Query) Fields(fields ...string) *Query {
+	query.fields = fields
+	return query
+}
+
+// Populate sets the fields to populate.
+func (query *Query) Populate(fields ...string) *Query {
+	query.populate = fields
+	return query
+}
+
+// Limit sets the max number of records returned by find.
+func (query *Query) Limit(limit int) *Query {
+	query.limit = limit
+	return query
+}
+
+// Offset sets the number of records skipped by find.
+func (query *Query) Offset(offset int) *Query {
+	query.offset = offset
+	return query
+}
+
+// Page sets the page and page size used by list.
+func (query *Query) Page(page, pageSize int) *Query {
+	query.page = page
+	query.pageSize = pageSize
+	return query
+}
+
+// Map returns the params as a map.
+func (query *Query) Map() map[string]interface{} {
+	params := map[string]interface{}{}
+	if query.filter.node != nil {
+		params["query"] = query.filter.Map()
+	}
+	if query.search != "" {
+		params["search"] = query.search
+	}
+	if len(query.searchFields) > 0 {
+		params["searchFields"] = query.searchFields
+	}
+	if len(query.sort) > 0 {
+		params["sort"] = strings.Join(query.sort, " ")
+	}
+	if len(query.fields) > 0 {
+		params["fields"] = query.fields
+	}
+	if len(query.populate) > 0 {
+		params["populate"] = query.populate
+	}
+	if query.limit > 0 {
+		params["limit"] = query.limit
+	}
+	if query.offset > 0 {
+		params["offset"] = query.offset
+	}
+	if query.page > 0 {
+		params["page"] = query.page
+	}
+	if query.pageSize > 0 {
+		params["pageSize"] = query.pageSize
+	}
+	return params
+}
+
+// Params returns the params for the find, list and count actions or the adapter methods.
+func (query *Query) Params() moleculer.Payload {
+	return payload.New(query.Map())
+}
@@ -0,0 +1,13 @@
+package q_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestQ(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Query Builder Suite")
+}
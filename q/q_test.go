@@ -0,0 +1,78 @@
+package q_test
+
+import (
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/dsl"
+	"github.com/moleculer-go/store/mocks"
+	"github.com/moleculer-go/store/q"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type M map[string]interface{}
+
+var _ = Describe("Query builder", func() {
+
+	It("should build conditions and logical expressions", func() {
+		expr := q.Field("age").Gt(60).And(q.Field("name").Like("Jo%"))
+		Expect(expr.Node()).Should(Equal(dsl.Logical{Operator: dsl.And, Nodes: []dsl.Node{
+			dsl.Condition{Field: "age", Operator: dsl.Gt, Value: 60},
+			dsl.Condition{Field: "name", Operator: dsl.Like, Value: "Jo%"},
+		}}))
+
+		expr = q.Or(q.Field("name").Eq("John"), q.Not(q.Field("age").Between(10, 20)))
+		Expect(expr.Node()).Should(Equal(dsl.Logical{Operator: dsl.Or, Nodes: []dsl.Node{
+			dsl.Condition{Field: "name", Operator: dsl.Eq, Value: "John"},
+			dsl.Negation{Node: dsl.Condition{Field: "age", Operator: dsl.Between, Value: []interface{}{10, 20}}},
+		}}))
+
+		Expect(q.Field("email").Eq(nil).Node()).Should(Equal(dsl.Condition{Field: "email", Operator: dsl.Exists, Value: false}))
+	})
+
+	It("should produce the params of find, list and count", func() {
+		params := q.Where(q.Field("age").Gte(18), q.Field("tags").In("a", "b")).
+			Search("John", "name").
+			Sort("-age", "name").
+			Fields("name", "age").
+			Populate("friends").
+			Limit(10).
+			Offset(5).
+			Map()
+		Expect(params).Should(Equal(map[string]interface{}{
+			"query": map[string]interface{}{dsl.And: []interface{}{
+				map[string]interface{}{"age": map[string]interface{}{dsl.Gte: 18}},
+				map[string]interface{}{"tags": map[string]interface{}{dsl.In: []interface{}{"a", "b"}}},
+			}},
+			"search":       "John",
+			"searchFields": []string{"name"},
+			"sort":         "-age name",
+			"fields":       []string{"name", "age"},
+			"populate":     []string{"friends"},
+			"limit":        10,
+			"offset":       5,
+		}))
+
+		Expect(q.Where().Page(2, 20).Map()).Should(Equal(map[string]interface{}{"page": 2, "pageSize": 20}))
+	})
+
+	It("the query map should parse back to the same expression", func() {
+		expr := q.Not(q.Field("address.city").Eq("Auckland")).Or(q.Field("age").Missing())
+		node, err := dsl.Parse(payload.New(expr.Map()))
+		Expect(err).Should(BeNil())
+		Expect(node).Should(Equal(expr.Node()))
+	})
+
+	It("adapters should take the builder params and expressions directly", func() {
+		adapter := &store.MemoryAdapter{Table: "user"}
+		mocks.ConnectAndLoadUsers(adapter)
+		defer adapter.Disconnect()
+
+		r := adapter.Find(q.Where(q.Field("age").Gt(60)).Sort("name").Params())
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Len()).Should(Equal(2))
+
+		r = adapter.Count(payload.New(M{"query": q.Field("age").Eq(13).Or(q.Field("name").Eq("Marie"))}))
+		Expect(r.Int()).Should(Equal(3))
+	})
+})
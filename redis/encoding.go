@@ -0,0 +1,166 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+)
+
+// dateField is the field of the JSON object that stores a time.Time, so dates are decoded as dates. e.g. {"$date": "2020-01-02T15:04:05Z"}
+const dateField = "$date"
+
+// parseId returns the numeric id. ok is false when the id is not a positive integer.
+func parseId(id interface{}) (uint64, bool) {
+	if p, isPayload := id.(moleculer.Payload); isPayload {
+		id = p.Value()
+	}
+	switch v := id.(type) {
+	case int:
+		return uint64(v), v > 0
+	case int32:
+		return uint64(v), v > 0
+	case int64:
+		return uint64(v), v > 0
+	case uint64:
+		return v, v > 0
+	case float64:
+		return uint64(v), v > 0 && v == float64(uint64(v))
+	case string:
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		return parsed, err == nil && parsed > 0
+	}
+	return 0, false
+}
+
+// idString returns the id as used in the keys and the members of the sorted sets.
+func idString(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
+
+// encodeValue returns the value with the dates as {"$date": ...} objects.
+func encodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case moleculer.Payload:
+		return encodeValue(v.Value())
+	case time.Time:
+		return map[string]interface{}{dateField: v.Format(time.RFC3339Nano)}
+	case map[string]interface{}:
+		encoded := make(map[string]interface{}, len(v))
+		for field, item := range v {
+			encoded[field] = encodeValue(item)
+		}
+		return encoded
+	case []interface{}:
+		encoded := make([]interface{}, len(v))
+		for i, item := range v {
+			encoded[i] = encodeValue(item)
+		}
+		return encoded
+	case []moleculer.Payload:
+		encoded := make([]interface{}, len(v))
+		for i, item := range v {
+			encoded[i] = encodeValue(item)
+		}
+		return encoded
+	}
+	return value
+}
+
+// decodeValue converts the JSON numbers to int64 (integers) or float64 and the {"$date": ...} objects to time.Time.
+func decodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if n, err := v.Int64(); err == nil {
+				return n
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		if date, ok := v[dateField].(string); ok && len(v) == 1 {
+			if t, err := time.Parse(time.RFC3339Nano, date); err == nil {
+				return t
+			}
+		}
+		for field, item := range v {
+			v[field] = decodeValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = decodeValue(item)
+		}
+		return v
+	}
+	return value
+}
+
+// encodeRecord returns the record as JSON.
+func encodeRecord(record map[string]interface{}) (string, error) {
+	data, err := json.Marshal(encodeValue(record))
+	return string(data), err
+}
+
+// decodeRecord returns the record of the JSON string.
+func decodeRecord(data string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.UseNumber()
+	record := map[string]interface{}{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	return decodeValue(record).(map[string]interface{}), nil
+}
+
+// indexValue returns the value as stored in the index members, so values equal for the query language are the same member:
+// numbers as float64 (13 and 13.0), dates in UTC and any other value as text.
+func indexValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case int:
+		value = float64(v)
+	case int32:
+		value = float64(v)
+	case int64:
+		value = float64(v)
+	case uint:
+		value = float64(v)
+	case uint32:
+		value = float64(v)
+	case uint64:
+		value = float64(v)
+	case float32:
+		value = float64(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// ttlValue returns the time to live of the value: a time.Duration or the number of seconds.
+// ok is false when the value is not a duration or a number.
+func ttlValue(value interface{}) (time.Duration, bool) {
+	if p, isPayload := value.(moleculer.Payload); isPayload {
+		value = p.Value()
+	}
+	switch v := value.(type) {
+	case time.Duration:
+		return v, true
+	case int:
+		return time.Duration(v) * time.Second, true
+	case int32:
+		return time.Duration(v) * time.Second, true
+	case int64:
+		return time.Duration(v) * time.Second, true
+	case float32:
+		return time.Duration(float64(v) * float64(time.Second)), true
+	case float64:
+		return time.Duration(v * float64(time.Second)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"net"
+	"strings"
+
+	goredis "github.com/go-redis/redis/v7"
+	"github.com/moleculer-go/store"
+)
+
+// typedError returns the Redis error as a *store.Error: network errors, closed clients and servers
+// loading or without a master are unavailable, network timeouts are timeouts.
+func typedError(err error, msgs ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return store.WrapError(errorCode(err), err, msgs...)
+}
+
+// errorCode returns the store error code of the Redis error.
+func errorCode(err error) string {
+	code := store.ErrorCode(err)
+	if code != store.CodeInternal {
+		return code
+	}
+	if netErr, ok := err.(net.Error); ok {
+		if netErr.Timeout() {
+			return store.CodeTimeout
+		}
+		return store.CodeUnavailable
+	}
+	if _, ok := err.(goredis.Error); ok {
+		for _, prefix := range []string{"LOADING ", "READONLY ", "MASTERDOWN ", "CLUSTERDOWN ", "TRYAGAIN "} {
+			if strings.HasPrefix(err.Error(), prefix) {
+				return store.CodeUnavailable
+			}
+		}
+		return code
+	}
+	switch err.Error() {
+	case "redis: client is closed", "redis: connection pool timeout":
+		return store.CodeUnavailable
+	}
+	return code
+}
@@ -0,0 +1,25 @@
+package redis
+
+// Ping checks the connection with the Redis server.
+func (a *Adapter) Ping() error {
+	if a.client == nil {
+		return notConnectedError()
+	}
+	return typedError(a.client.Ping().Err(), "Redis adapter - ping failed: ")
+}
+
+// PoolStats returns the connections of the pool of the client and its hits, misses and timeouts.
+func (a *Adapter) PoolStats() map[string]interface{} {
+	if a.client == nil {
+		return map[string]interface{}{}
+	}
+	stats := a.client.PoolStats()
+	return map[string]interface{}{
+		"hits":       stats.Hits,
+		"misses":     stats.Misses,
+		"timeouts":   stats.Timeouts,
+		"totalConns": stats.TotalConns,
+		"idleConns":  stats.IdleConns,
+		"staleConns": stats.StaleConns,
+	}
+}
@@ -0,0 +1,208 @@
+package redis
+
+import (
+	"strings"
+
+	goredis "github.com/go-redis/redis/v7"
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/dsl"
+)
+
+// Each declared index is a sorted set (<table>:index:<index name>) with score 0, so the members are sorted
+// by value and queried with ZRANGEBYLEX. The members are the values of the index fields (see indexValue),
+// each followed by a 0 byte, and the id of the record. Lists are indexed by item.
+// Records without the fields are not indexed, so all indexes are sparse.
+
+// indexKey returns the key of the sorted set of the index.
+func (a *Adapter) indexKey(index store.Index) string {
+	return a.key("index", index.Name)
+}
+
+// indexPrefixes returns the member prefixes of the record in the index, one for each combination of list items.
+func indexPrefixes(index store.Index, record map[string]interface{}) []string {
+	prefixes := []string{""}
+	for _, field := range index.Fields {
+		value, found := dsl.Lookup(record, field.Name)
+		if !found || value == nil {
+			return nil
+		}
+		items := []interface{}{value}
+		if list := payload.New(value); list.IsArray() {
+			items = []interface{}{}
+			for _, item := range list.Array() {
+				items = append(items, item.Value())
+			}
+		}
+		next := []string{}
+		for _, prefix := range prefixes {
+			for _, item := range items {
+				next = append(next, prefix+indexValue(item)+"\x00")
+			}
+		}
+		prefixes = next
+	}
+	return prefixes
+}
+
+// prefixValues returns the values of the member prefix.
+func prefixValues(prefix string) []string {
+	return strings.Split(prefix[:len(prefix)-1], "\x00")
+}
+
+// indexMembers returns the members of the record in the index.
+func indexMembers(index store.Index, id string, record map[string]interface{}) []interface{} {
+	members := []interface{}{}
+	for _, prefix := range indexPrefixes(index, record) {
+		members = append(members, prefix+id)
+	}
+	return members
+}
+
+// rangeIds returns the ids of the members of the index with the prefix.
+func rangeIds(client goredis.Cmdable, key, prefix string) ([]string, error) {
+	members, err := client.ZRangeByLex(key, &goredis.ZRangeBy{Min: "[" + prefix, Max: "[" + prefix + "\xff"}).Result()
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, member := range members {
+		if id := member[len(prefix):]; !strings.Contains(id, "\x00") {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// checkUnique returns a CodeConflict error when other record has the same values in a unique index.
+// The members of expired records are ignored and returned as stale, by index key, to be removed with the changes.
+func (a *Adapter) checkUnique(tx *goredis.Tx, id string, record map[string]interface{}) (map[string][]interface{}, error) {
+	stale := map[string][]interface{}{}
+	for _, index := range a.indexes {
+		if !index.Unique {
+			continue
+		}
+		key := a.indexKey(index)
+		for _, prefix := range indexPrefixes(index, record) {
+			ids, err := rangeIds(tx, key, prefix)
+			if err != nil {
+				return nil, err
+			}
+			for _, other := range ids {
+				if other == id {
+					continue
+				}
+				exists, err := tx.Exists(a.recordKey(other)).Result()
+				if err != nil {
+					return nil, err
+				}
+				if exists == 0 {
+					stale[key] = append(stale[key], prefix+other)
+					continue
+				}
+				values := prefixValues(prefix)
+				return nil, store.NewError(store.CodeConflict, "Duplicate value for unique index ", index.Name, ": ", values).WithData(map[string]interface{}{"index": index.Name, "values": values})
+			}
+		}
+	}
+	return stale, nil
+}
+
+// buildIndexes adds the existing records to the indexes not built yet. The names of the built indexes are kept in <table>:indexes.
+func (a *Adapter) buildIndexes() error {
+	built, err := a.client.SMembers(a.key("indexes")).Result()
+	if err != nil {
+		return err
+	}
+	pending := []store.Index{}
+	for _, index := range a.indexes {
+		if !contains(built, index.Name) {
+			pending = append(pending, index)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	ids, records, err := a.allRecords()
+	if err != nil {
+		return err
+	}
+	for _, index := range pending {
+		unique := map[string]string{}
+		members := []*goredis.Z{}
+		for i, record := range records {
+			for _, prefix := range indexPrefixes(index, record) {
+				if other, exists := unique[prefix]; index.Unique && exists && other != ids[i] {
+					values := prefixValues(prefix)
+					return store.NewError(store.CodeConflict, "Duplicate value for unique index ", index.Name, ": ", values).WithData(map[string]interface{}{"index": index.Name, "values": values})
+				}
+				unique[prefix] = ids[i]
+				members = append(members, &goredis.Z{Member: prefix + ids[i]})
+			}
+		}
+		_, err := a.client.TxPipelined(func(pipe goredis.Pipeliner) error {
+			pipe.Del(a.indexKey(index))
+			if len(members) > 0 {
+				pipe.ZAdd(a.indexKey(index), members...)
+			}
+			pipe.SAdd(a.key("indexes"), index.Name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// queryIndex returns the key of the index and the member prefix of the values, when the query matches
+// all fields of a declared index by equality. ok is false when no index can be used.
+func (a *Adapter) queryIndex(query dsl.Node) (key, prefix string, ok bool) {
+	equals := map[string]interface{}{}
+	conditions := []dsl.Node{query}
+	if logical, isLogical := query.(dsl.Logical); isLogical && logical.Operator == dsl.And {
+		conditions = logical.Nodes
+	}
+	for _, node := range conditions {
+		if c, isCondition := node.(dsl.Condition); isCondition && c.Operator == dsl.Eq && c.Value != nil {
+			if !payload.New(c.Value).IsArray() && !payload.New(c.Value).IsMap() {
+				equals[c.Field] = c.Value
+			}
+		}
+	}
+	for _, index := range a.indexes {
+		prefix := ""
+		for _, field := range index.Fields {
+			value, exists := equals[field.Name]
+			if !exists {
+				prefix = ""
+				break
+			}
+			prefix += indexValue(value) + "\x00"
+		}
+		if prefix == "" {
+			continue
+		}
+		return a.indexKey(index), prefix, true
+	}
+	return "", "", false
+}
+
+// Indexes returns the declared indexes.
+func (a *Adapter) Indexes() moleculer.Payload {
+	list := []map[string]interface{}{}
+	for _, index := range a.indexes {
+		list = append(list, index.Map())
+	}
+	return payload.New(list)
+}
@@ -0,0 +1,727 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis/v7"
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/dsl"
+	log "github.com/sirupsen/logrus"
+)
+
+// Adapter stores the records in Redis as JSON strings (<table>:<id>) with an optional time to live.
+// The ids of the table are kept in a sorted set (<table>:ids) and the declared indexes in sorted sets of
+// their values (see indexes.go). Queries, sort, offset and limit are evaluated in process, using the
+// declared indexes for the queries that match all their fields.
+// Expired records leave their id and index members behind, which are removed when found by later queries.
+type Adapter struct {
+	// URL of the Redis server, e.g. redis://:password@localhost:6379/0. Ignored when Options is set.
+	URL string
+	// Options of the Redis client, e.g. to set the pool size or the timeouts.
+	Options *goredis.Options
+	Table   string
+	// Prefix of the keys of the table, e.g. "app:" for app:users:1. Default: no prefix
+	Prefix string
+	// TTL is the time to live of the records. Default: 0, the records don't expire.
+	TTL time.Duration
+	// TTLField is the field of inserts and updates with the time to live of the record, in seconds or as
+	// a time.Duration. It is not saved with the record. 0 removes the expiration. Default: "$ttl"
+	TTLField string
+
+	client       *goredis.Client
+	log          *log.Entry
+	settings     map[string]interface{}
+	idField      string
+	indexes      []store.Index
+	indexesError error
+}
+
+// maxAttempts is the number of times a write transaction is retried when the watched keys change.
+const maxAttempts = 100
+
+// removeExpired removes the members of the sorted set (KEYS[1]) whose record key does not exist anymore.
+// ARGV are pairs of member and record key.
+var removeExpired = goredis.NewScript(`
+for i = 1, #ARGV, 2 do
+	if redis.call("EXISTS", ARGV[i + 1]) == 0 then
+		redis.call("ZREM", KEYS[1], ARGV[i])
+	end
+end
+return 0`)
+
+// raiseSequence sets the sequence of ids (KEYS[1]) to ARGV[1] when it is lower, so imported ids are not generated again.
+var raiseSequence = goredis.NewScript(`
+if tonumber(redis.call("GET", KEYS[1]) or "0") < tonumber(ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 0`)
+
+func (a *Adapter) Init(log *log.Entry, settings map[string]interface{}) {
+	a.log = log
+	a.settings = settings
+	if a.TTLField == "" {
+		a.TTLField = "$ttl"
+	}
+	a.loadSettings(settings)
+	a.indexes, a.indexesError = store.ParseIndexes(settings)
+}
+
+func (a *Adapter) loadSettings(settings map[string]interface{}) {
+	if idField, ok := settings["idField"].(string); ok {
+		a.idField = idField
+	} else {
+		a.idField = "id"
+	}
+	if url, ok := settings["url"].(string); ok {
+		a.URL = url
+	}
+	if ttl, ok := ttlValue(settings["ttl"]); ok {
+		a.TTL = ttl
+	}
+}
+
+// WithContext returns a copy of the adapter running the commands with the context.
+func (a *Adapter) WithContext(ctx context.Context) store.Adapter {
+	bound := *a
+	if a.client != nil {
+		bound.client = a.client.WithContext(ctx)
+	}
+	return &bound
+}
+
+// ForTenant returns an adapter for the tenant, using its own keys (e.g. users_acme:1).
+func (a *Adapter) ForTenant(tenant string) store.Adapter {
+	scoped := &Adapter{
+		URL:      a.URL,
+		Options:  a.Options,
+		Table:    a.Table + "_" + tenant,
+		Prefix:   a.Prefix,
+		TTL:      a.TTL,
+		TTLField: a.TTLField,
+	}
+	scoped.Init(a.log, a.settings)
+	return scoped
+}
+
+func (a *Adapter) Connect() error {
+	if a.client != nil {
+		return nil
+	}
+	if a.indexesError != nil {
+		return a.indexesError
+	}
+	options := a.Options
+	if options == nil {
+		url := a.URL
+		if url == "" {
+			url = "redis://localhost:6379"
+		}
+		parsed, err := goredis.ParseURL(url)
+		if err != nil {
+			return store.WrapError(store.CodeValidation, err, "Invalid Redis URL - error: ")
+		}
+		options = parsed
+	}
+	client := goredis.NewClient(options)
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		a.log.Error("Could not connect to Redis - error: ", err)
+		return store.WrapError(store.CodeUnavailable, err, "Could not connect to Redis - error: ")
+	}
+	a.client = client
+	if err := a.buildIndexes(); err != nil {
+		a.client = nil
+		client.Close()
+		a.log.Error("Could not build indexes - error: ", err)
+		return typedError(err, "Could not build indexes - error: ")
+	}
+	a.log.Info("Redis adapter " + a.Table + " connected!")
+	return nil
+}
+
+func (a *Adapter) Disconnect() error {
+	if a.client == nil {
+		return nil
+	}
+	client := a.client
+	a.client = nil
+	if err := client.Close(); err != nil {
+		a.log.Error("Could not close Redis client - error: ", err)
+		return errors.New(fmt.Sprint("Could not close Redis client - error: ", err))
+	}
+	return nil
+}
+
+func notConnectedError() error {
+	return store.NewError(store.CodeUnavailable, "Redis adapter not connected!")
+}
+
+// key returns the key of the table with the parts. e.g. users:ids
+func (a *Adapter) key(parts ...string) string {
+	return a.Prefix + a.Table + ":" + strings.Join(parts, ":")
+}
+
+func (a *Adapter) recordKey(id string) string {
+	return a.key(id)
+}
+
+func (a *Adapter) idsKey() string {
+	return a.key("ids")
+}
+
+func (a *Adapter) sequenceKey() string {
+	return a.key("seq")
+}
+
+// getRecord returns the record of the id, nil when not found or expired.
+func (a *Adapter) getRecord(client goredis.Cmdable, id string) (map[string]interface{}, error) {
+	data, err := client.Get(a.recordKey(id)).Result()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecord(data)
+}
+
+// load returns the records of the ids, in the same order. expired are the ids without record.
+func (a *Adapter) load(ids []string) (found []string, records []map[string]interface{}, expired []string, err error) {
+	if len(ids) == 0 {
+		return nil, nil, nil, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = a.recordKey(id)
+	}
+	values, err := a.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		record, err := decodeRecord(data)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		found = append(found, ids[i])
+		records = append(records, record)
+	}
+	return found, records, expired, nil
+}
+
+// removeExpired removes the members of the expired ids from the sorted set. prefix is the member prefix of index entries.
+func (a *Adapter) removeExpired(key, prefix string, expired []string) {
+	if len(expired) == 0 {
+		return
+	}
+	args := []interface{}{}
+	for _, id := range expired {
+		args = append(args, prefix+id, a.recordKey(id))
+	}
+	if err := removeExpired.Run(a.client, []string{key}, args...).Err(); err != nil && err != goredis.Nil {
+		a.log.Warn("Redis adapter ", a.Table, " - could not remove expired records from ", key, " - error: ", err)
+	}
+}
+
+// allRecords returns the ids and the records of the table, in id order.
+func (a *Adapter) allRecords() ([]string, []map[string]interface{}, error) {
+	ids, err := a.client.ZRange(a.idsKey(), 0, -1).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	found, records, expired, err := a.load(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	a.removeExpired(a.idsKey(), "", expired)
+	return found, records, nil
+}
+
+// watch runs fn in a transaction watching the record and the unique indexes, so the changes of records
+// or unique values by other clients restart it. fn must queue the changes with tx.TxPipelined.
+func (a *Adapter) watch(id string, fn func(tx *goredis.Tx) error) error {
+	if a.client == nil {
+		return notConnectedError()
+	}
+	keys := []string{a.recordKey(id)}
+	for _, index := range a.indexes {
+		if index.Unique {
+			keys = append(keys, a.indexKey(index))
+		}
+	}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err := a.client.Watch(fn, keys...)
+		if err != goredis.TxFailedErr {
+			return err
+		}
+	}
+	return store.NewError(store.CodeConflict, "Record ", id, " changed by other clients ", maxAttempts, " times, giving up").WithData(map[string]interface{}{"id": id})
+}
+
+// expiration returns the time to live of the record: the ttl of the changes, the remaining time to live
+// of the saved record or the TTL of the adapter, limited by the ttl indexes. 0 is no expiration.
+func (a *Adapter) expiration(tx *goredis.Tx, id string, previous, record map[string]interface{}, ttl interface{}) (time.Duration, error) {
+	expiration := a.TTL
+	if ttl != nil {
+		var ok bool
+		if expiration, ok = ttlValue(ttl); !ok {
+			return 0, store.NewError(store.CodeValidation, "Invalid ", a.TTLField, ": ", ttl).WithData(map[string]interface{}{"field": a.TTLField})
+		}
+	} else if previous != nil {
+		remaining, err := tx.PTTL(a.recordKey(id)).Result()
+		if err != nil {
+			return 0, err
+		}
+		expiration = remaining
+	}
+	if expiration < 0 {
+		expiration = 0
+	}
+	for _, index := range a.indexes {
+		if index.TTL <= 0 {
+			continue
+		}
+		value, _ := dsl.Lookup(record, index.Fields[0].Name)
+		if date, isDate := value.(time.Time); isDate {
+			until := time.Until(date.Add(index.TTL))
+			if until < time.Millisecond {
+				until = time.Millisecond
+			}
+			if expiration == 0 || until < expiration {
+				expiration = until
+			}
+		}
+	}
+	return expiration, nil
+}
+
+// save queues the record, its index members and its id in the transaction. previous is the saved record,
+// nil for new records. ttl is the ttl field of the changes, nil to keep the expiration of the saved record.
+func (a *Adapter) save(tx *goredis.Tx, id string, previous, record map[string]interface{}, ttl interface{}) error {
+	stale, err := a.checkUnique(tx, id, record)
+	if err != nil {
+		return err
+	}
+	expiration, err := a.expiration(tx, id, previous, record, ttl)
+	if err != nil {
+		return err
+	}
+	data, err := encodeRecord(record)
+	if err != nil {
+		return store.WrapError(store.CodeValidation, err, "Could not encode record - error: ")
+	}
+	score, _ := strconv.ParseFloat(id, 64)
+	_, err = tx.TxPipelined(func(pipe goredis.Pipeliner) error {
+		for key, members := range stale {
+			pipe.ZRem(key, members...)
+		}
+		for _, index := range a.indexes {
+			if previous != nil {
+				if members := indexMembers(index, id, previous); len(members) > 0 {
+					pipe.ZRem(a.indexKey(index), members...)
+				}
+			}
+			added := []*goredis.Z{}
+			for _, member := range indexMembers(index, id, record) {
+				added = append(added, &goredis.Z{Member: member})
+			}
+			if len(added) > 0 {
+				pipe.ZAdd(a.indexKey(index), added...)
+			}
+		}
+		pipe.Set(a.recordKey(id), data, expiration)
+		pipe.ZAdd(a.idsKey(), &goredis.Z{Score: score, Member: id})
+		return nil
+	})
+	return err
+}
+
+// remove queues the removal of the record, its index members and its id in the transaction.
+func (a *Adapter) remove(tx *goredis.Tx, id string, record map[string]interface{}) error {
+	_, err := tx.TxPipelined(func(pipe goredis.Pipeliner) error {
+		for _, index := range a.indexes {
+			if members := indexMembers(index, id, record); len(members) > 0 {
+				pipe.ZRem(a.indexKey(index), members...)
+			}
+		}
+		pipe.Del(a.recordKey(id))
+		pipe.ZRem(a.idsKey(), id)
+		return nil
+	})
+	return err
+}
+
+// parseQuery returns the query of the params.
+func parseQuery(params moleculer.Payload) (dsl.Node, error) {
+	if params.Get("nativeQuery").Exists() {
+		return nil, store.NewError(store.CodeValidation, "nativeQuery is not supported by the redis adapter")
+	}
+	query, err := dsl.Parse(params.Get("query"))
+	if err != nil {
+		return nil, store.WrapError(store.CodeValidation, err)
+	}
+	return query, nil
+}
+
+// sortIds sorts the ids in numeric order.
+func sortIds(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.ParseUint(ids[i], 10, 64)
+		b, _ := strconv.ParseUint(ids[j], 10, 64)
+		return a < b
+	})
+}
+
+// findRecords returns the ids and the records matching the query and search params, in id order.
+func (a *Adapter) findRecords(params moleculer.Payload) ([]string, []map[string]interface{}, error) {
+	if a.client == nil {
+		return nil, nil, notConnectedError()
+	}
+	query, err := parseQuery(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	var found []string
+	var records []map[string]interface{}
+	if key, prefix, ok := a.queryIndex(query); ok {
+		ids, err := rangeIds(a.client, key, prefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		sortIds(ids)
+		var expired []string
+		if found, records, expired, err = a.load(ids); err != nil {
+			return nil, nil, err
+		}
+		a.removeExpired(key, prefix, expired)
+		a.removeExpired(a.idsKey(), "", expired)
+	} else if found, records, err = a.allRecords(); err != nil {
+		return nil, nil, err
+	}
+	ids := []string{}
+	matching := []map[string]interface{}{}
+	for i, record := range records {
+		if dsl.Match(query, record) && dsl.SearchMatch(params, record) {
+			ids = append(ids, found[i])
+			matching = append(matching, record)
+		}
+	}
+	return ids, matching, nil
+}
+
+// Find returns the records matching the params, applying sort, offset and limit.
+func (a *Adapter) Find(params moleculer.Payload) moleculer.Payload {
+	_, records, err := a.findRecords(params)
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to find. Error: "))
+	}
+	return dsl.RecordsPayload(dsl.Paginate(records, params))
+}
+
+func (a *Adapter) FindOne(params moleculer.Payload) moleculer.Payload {
+	return a.Find(params.Add("limit", 1)).First()
+}
+
+func (a *Adapter) FindById(id moleculer.Payload) moleculer.Payload {
+	if a.client == nil {
+		return payload.New(notConnectedError())
+	}
+	numeric, ok := parseId(id)
+	if !ok {
+		return payload.New(nil)
+	}
+	record, err := a.getRecord(a.client, idString(numeric))
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to find by id: ", id.String(), " Error: "))
+	}
+	if record == nil {
+		return payload.New(nil)
+	}
+	return payload.New(record)
+}
+
+func (a *Adapter) FindByIds(ids moleculer.Payload) moleculer.Payload {
+	if !ids.IsArray() {
+		return payload.New(store.NewError(store.CodeValidation, "FindByIds() only support lists!"))
+	}
+	list := []moleculer.Payload{}
+	for _, id := range ids.Array() {
+		list = append(list, a.FindById(id))
+	}
+	return payload.New(list)
+}
+
+// Count returns the number of records matching the query and search params.
+func (a *Adapter) Count(params moleculer.Payload) moleculer.Payload {
+	ids, _, err := a.findRecords(params)
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to count. Error: "))
+	}
+	return payload.New(len(ids))
+}
+
+// ttlChange returns the ttl field of the changes, nil when not set.
+func (a *Adapter) ttlChange(changes moleculer.Payload) interface{} {
+	if ttl := changes.Get(a.TTLField); ttl.Exists() {
+		return ttl.Value()
+	}
+	return nil
+}
+
+// Insert saves the record with the next id of the table. Records with an id (e.g. imported records) keep it.
+// Ids that are not positive integers are rejected with CodeValidation.
+// The ttl field sets the time to live of the record, instead of the TTL of the adapter.
+func (a *Adapter) Insert(params moleculer.Payload) moleculer.Payload {
+	if a.client == nil {
+		return payload.New(notConnectedError())
+	}
+	ttl := a.ttlChange(params)
+	record := map[string]interface{}{}
+	for field, value := range params.Remove(a.TTLField).RawMap() {
+		record[field] = value
+	}
+	numeric, hasId := parseId(record[a.idField])
+	if value := record[a.idField]; value != nil && !hasId {
+		return payload.New(store.NewError(store.CodeValidation, "Invalid id: ", value, ". Ids are positive integers.").WithData(map[string]interface{}{"id": value}))
+	}
+	if hasId {
+		if err := raiseSequence.Run(a.client, []string{a.sequenceKey()}, numeric).Err(); err != nil && err != goredis.Nil {
+			return payload.New(typedError(err, "Failed trying to insert. Error: "))
+		}
+	} else {
+		next, err := a.client.Incr(a.sequenceKey()).Result()
+		if err != nil {
+			return payload.New(typedError(err, "Failed trying to insert. Error: "))
+		}
+		numeric = uint64(next)
+	}
+	id := idString(numeric)
+	record[a.idField] = int64(numeric)
+	err := a.watch(id, func(tx *goredis.Tx) error {
+		exists, err := tx.Exists(a.recordKey(id)).Result()
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return store.NewError(store.CodeConflict, "Duplicate id: ", id).WithData(map[string]interface{}{"id": numeric})
+		}
+		return a.save(tx, id, nil, record, ttl)
+	})
+	if err != nil {
+		a.log.Error("Error on insert: ", err)
+		return payload.New(typedError(err, "Failed trying to insert. Error: "))
+	}
+	return payload.New(record)
+}
+
+func (a *Adapter) Update(params moleculer.Payload) moleculer.Payload {
+	id := params.Get(a.idField)
+	if !id.Exists() {
+		return payload.New(store.NewError(store.CodeValidation, "Cannot update record without id"))
+	}
+	return a.UpdateById(id, params.Remove(a.idField))
+}
+
+// applyUpdate returns the updated record, keeping its id.
+func (a *Adapter) applyUpdate(record map[string]interface{}, ops []dsl.UpdateOp) map[string]interface{} {
+	updated := dsl.Apply(record, ops)
+	updated[a.idField] = record[a.idField]
+	return updated
+}
+
+func notFoundError(id moleculer.Payload) error {
+	return store.NewError(store.CodeNotFound, "Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()})
+}
+
+// UpdateById applies the update (fields and update operators) and returns the updated record.
+// The ttl field changes the time to live of the record, otherwise the record keeps its expiration.
+func (a *Adapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	ttl := a.ttlChange(update)
+	ops, err := dsl.ParseUpdate(update.Remove(a.TTLField))
+	if err != nil {
+		return payload.New(store.WrapError(store.CodeValidation, err, "Failed trying to update record. Invalid update: "))
+	}
+	numeric, ok := parseId(id)
+	if !ok {
+		return payload.New(typedError(notFoundError(id), "Failed trying to update record. Error: "))
+	}
+	var updated map[string]interface{}
+	err = a.watch(idString(numeric), func(tx *goredis.Tx) error {
+		record, err := a.getRecord(tx, idString(numeric))
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return notFoundError(id)
+		}
+		updated = a.applyUpdate(record, ops)
+		return a.save(tx, idString(numeric), record, updated, ttl)
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to update record. Error: "))
+	}
+	return payload.New(updated)
+}
+
+// updateRecords applies the update on the records matching the params and returns the updated records.
+// Each record is updated in its own transaction, skipping the records changed to not match the query meanwhile.
+func (a *Adapter) updateRecords(params, update moleculer.Payload) ([]map[string]interface{}, error) {
+	ttl := a.ttlChange(update)
+	ops, err := dsl.ParseUpdate(update.Remove(a.TTLField))
+	if err != nil {
+		return nil, store.WrapError(store.CodeValidation, err, "Invalid update: ")
+	}
+	query, err := parseQuery(params)
+	if err != nil {
+		return nil, err
+	}
+	ids, _, err := a.findRecords(params)
+	if err != nil {
+		return nil, err
+	}
+	updated := []map[string]interface{}{}
+	for _, id := range ids {
+		err := a.watch(id, func(tx *goredis.Tx) error {
+			record, err := a.getRecord(tx, id)
+			if err != nil || record == nil || !dsl.Match(query, record) || !dsl.SearchMatch(params, record) {
+				return err
+			}
+			changed := a.applyUpdate(record, ops)
+			if err := a.save(tx, id, record, changed, ttl); err != nil {
+				return err
+			}
+			updated = append(updated, changed)
+			return nil
+		})
+		if err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// UpdateMany updates all records matching the query.
+func (a *Adapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	updated, err := a.updateRecords(params.Remove("update"), params.Get("update"))
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to update records. Error: "))
+	}
+	return payload.New(map[string]int{"modifiedCount": len(updated)})
+}
+
+// FindAndUpdate updates all records matching the query and returns the updated records.
+func (a *Adapter) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	updated, err := a.updateRecords(params.Remove("update"), params.Get("update"))
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to find and update. Error: "))
+	}
+	return dsl.RecordsPayload(updated)
+}
+
+func (a *Adapter) RemoveById(id moleculer.Payload) moleculer.Payload {
+	numeric, ok := parseId(id)
+	if !ok {
+		return payload.New(typedError(notFoundError(id), "Failed trying to remove record. Error: "))
+	}
+	err := a.watch(idString(numeric), func(tx *goredis.Tx) error {
+		record, err := a.getRecord(tx, idString(numeric))
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return notFoundError(id)
+		}
+		return a.remove(tx, idString(numeric), record)
+	})
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to remove record. Error: "))
+	}
+	return payload.New(map[string]int{"deletedCount": 1})
+}
+
+// RemoveMany removes all records matching the query, each one in its own transaction.
+func (a *Adapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	query, err := parseQuery(params)
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to remove records. Error: "))
+	}
+	ids, _, err := a.findRecords(params)
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to remove records. Error: "))
+	}
+	deletedCount := 0
+	for _, id := range ids {
+		err := a.watch(id, func(tx *goredis.Tx) error {
+			record, err := a.getRecord(tx, id)
+			if err != nil || record == nil || !dsl.Match(query, record) || !dsl.SearchMatch(params, record) {
+				return err
+			}
+			if err := a.remove(tx, id, record); err != nil {
+				return err
+			}
+			deletedCount++
+			return nil
+		})
+		if err != nil {
+			return payload.New(typedError(err, "Failed trying to remove records. Error: "))
+		}
+	}
+	return payload.New(map[string]int{"deletedCount": deletedCount})
+}
+
+// RemoveAll deletes the records, the ids and the indexes of the table. The sequence of ids is kept.
+func (a *Adapter) RemoveAll() moleculer.Payload {
+	if a.client == nil {
+		return payload.New(typedError(notConnectedError(), "Failed trying to remove all records. Error: "))
+	}
+	deletedCount := 0
+	fn := func(tx *goredis.Tx) error {
+		ids, err := tx.ZRange(a.idsKey(), 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		records := []string{}
+		for _, id := range ids {
+			records = append(records, a.recordKey(id))
+		}
+		existing := int64(0)
+		if len(records) > 0 {
+			if existing, err = tx.Exists(records...).Result(); err != nil {
+				return err
+			}
+		}
+		keys := append(records, a.idsKey())
+		for _, index := range a.indexes {
+			keys = append(keys, a.indexKey(index))
+		}
+		_, err = tx.TxPipelined(func(pipe goredis.Pipeliner) error {
+			pipe.Del(keys...)
+			return nil
+		})
+		deletedCount = int(existing)
+		return err
+	}
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err = a.client.Watch(fn, a.idsKey()); err != goredis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil {
+		return payload.New(typedError(err, "Failed trying to remove all records. Error: "))
+	}
+	return payload.New(map[string]int{"deletedCount": deletedCount})
+}
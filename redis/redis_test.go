@@ -0,0 +1,592 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+type M map[string]interface{}
+
+func must(e error) {
+	if e != nil {
+		panic(e)
+	}
+}
+
+var _ = Describe("Redis", func() {
+
+	log.SetLevel(log.ErrorLevel)
+	var server *miniredis.Miniredis
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		must(err)
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	connect := func(table string, settings M) *Adapter {
+		adapter := &Adapter{URL: "redis://" + server.Addr(), Table: table}
+		adapter.Init(log.WithField("", ""), settings)
+		Expect(adapter.Connect()).Should(Succeed())
+		return adapter
+	}
+
+	It("should create, init connect and disconnect adapter", func() {
+		adapter := &Adapter{URL: "redis://" + server.Addr(), Table: "session"}
+		adapter.Init(log.WithField("", ""), M{})
+		Expect(adapter.log).ShouldNot(BeNil())
+		Expect(adapter.Connect()).Should(Succeed())
+		Expect(adapter.Disconnect()).Should(Succeed())
+	})
+
+	It("should create an adapter with custom idField", func() {
+		adapter := connect("session", M{"idField": "customIdField"})
+		defer adapter.Disconnect()
+		rec := adapter.Insert(payload.New(M{"code": "asdasd"}))
+		Expect(rec.Get("customIdField").Int()).Should(Equal(1))
+		Expect(adapter.FindById(payload.New(1)).Get("code").String()).Should(Equal("asdasd"))
+	})
+
+	It("should keep the records after reconnecting and share the server between tables", func() {
+		users := connect("users", M{})
+		events := connect("events", M{})
+		users.Insert(payload.New(M{"name": "Marie"}))
+		events.Insert(payload.New(M{"name": "login"}))
+		Expect(users.Disconnect()).Should(Succeed())
+		Expect(events.Count(payload.Empty()).Int()).Should(Equal(1))
+		Expect(events.Disconnect()).Should(Succeed())
+
+		users = connect("users", M{})
+		defer users.Disconnect()
+		Expect(users.Find(payload.Empty()).First().Get("name").String()).Should(Equal("Marie"))
+		Expect(users.Insert(payload.New(M{"name": "John"})).Get("id").Int()).Should(Equal(2))
+	})
+
+	Describe("Insert, find, delete", func() {
+		var adapter *Adapter
+		var marie moleculer.Payload
+		BeforeEach(func() {
+			adapter = connect("users", M{})
+			marie = adapter.Insert(payload.New(M{
+				"name":    "Marie",
+				"email":   "marie@jane.com",
+				"number":  5.44444,
+				"integer": 200,
+			}))
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should insert a record", func() {
+			r := adapter.Insert(payload.New(M{
+				"name":    "John",
+				"email":   "john@snow.com",
+				"number":  15.5,
+				"integer": 10,
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("id").Int()).Should(Equal(2))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(2))
+		})
+
+		It("should find a record using query", func() {
+			r := adapter.Find(payload.New(M{"query": M{"name": "Marie"}}))
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("id").Int()).Should(Equal(1))
+			Expect(r.First().Get("email").String()).Should(Equal("marie@jane.com"))
+			Expect(r.First().Get("number").Float()).Should(Equal(float64(5.44444)))
+			Expect(r.First().Get("integer").Int()).Should(Equal(200))
+		})
+
+		It("should find one record", func() {
+			r := adapter.FindOne(payload.New(M{"query": M{"name": "Marie"}}))
+			Expect(r.Get("id").Int()).Should(Equal(1))
+			Expect(r.Get("name").String()).Should(Equal("Marie"))
+		})
+
+		It("should FindByIds", func() {
+			r := adapter.Insert(payload.New(M{"name": "Mountain", "email": "mountain@dew.com"}))
+			list := adapter.FindByIds(payload.EmptyList().AddItem(1).AddItem(r.Get("id")))
+			Expect(list.Len()).Should(Equal(2))
+			Expect(list.First().Get("name").String()).Should(Equal("Marie"))
+			Expect(list.Array()[1].Get("id").Int()).Should(Equal(2))
+			Expect(list.Array()[1].Get("email").String()).Should(Equal("mountain@dew.com"))
+		})
+
+		It("should update a record", func() {
+			r := adapter.Update(payload.New(M{"id": 1, "email": "changed@mail.com"}))
+			Expect(r.Get("email").String()).Should(Equal("changed@mail.com"))
+			Expect(r.Get("name").String()).Should(Equal("Marie"))
+		})
+
+		It("should updateById a record", func() {
+			r := adapter.UpdateById(payload.New(1), payload.New(M{
+				"name":    "Vick",
+				"number":  456756.45676,
+				"integer": 21321322,
+			}))
+			Expect(r.Get("id").Int()).Should(Equal(1))
+			Expect(r.Get("name").String()).Should(Equal("Vick"))
+			Expect(r.Get("number").Float()).Should(Equal(456756.45676))
+			Expect(adapter.FindById(payload.New(1)).Get("integer").Int()).Should(Equal(21321322))
+		})
+
+		It("should delete a record", func() {
+			r := adapter.RemoveById(marie.Get("id"))
+			Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(0))
+			Expect(adapter.FindById(marie.Get("id")).Exists()).Should(BeFalse())
+		})
+	})
+
+	It("should save and restore complex map data", func() {
+		adapter := connect("events", M{})
+		defer adapter.Disconnect()
+		adapter.Insert(payload.New(M{
+			"eventId": "0001",
+			"content": map[string]interface{}{
+				"name":    "John",
+				"address": map[string]interface{}{"street": "tamara tce", "number": 500},
+			},
+		}))
+		ev := adapter.Find(payload.Empty()).First()
+		Expect(ev.Get("eventId").String()).Should(Equal("0001"))
+		Expect(ev.Get("content").Get("address").Get("street").String()).Should(Equal("tamara tce"))
+		Expect(ev.Get("content").Get("address").Get("number").Int()).Should(Equal(500))
+	})
+
+	Describe("Update operators", func() {
+		var adapter *Adapter
+		BeforeEach(func() {
+			adapter = connect("operators", M{})
+			adapter.Insert(payload.New(M{"name": "Marie", "visits": 1, "tags": []string{"a", "b"}}))
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should $inc, $push, $pull and $unset fields", func() {
+			r := adapter.UpdateById(payload.New(1), payload.New(M{"$inc": M{"visits": 2}, "$push": M{"tags": "c"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("visits").Int()).Should(Equal(3))
+			Expect(r.Get("tags").StringArray()).Should(Equal([]string{"a", "b", "c"}))
+
+			r = adapter.UpdateById(payload.New(1), payload.New(M{"name": "Marie Claire", "$pull": M{"tags": "b"}, "$unset": []string{"visits"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("tags").StringArray()).Should(Equal([]string{"a", "c"}))
+			Expect(r.Get("visits").Exists()).Should(BeFalse())
+			Expect(adapter.FindById(payload.New(1)).Get("name").String()).Should(Equal("Marie Claire"))
+		})
+
+		It("should match items of lists", func() {
+			adapter.Insert(payload.New(M{"name": "John", "tags": []string{"b", "c"}}))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": "b"}})).Int()).Should(Equal(2))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": M{"$in": []string{"a", "x"}}}})).Int()).Should(Equal(1))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": M{"$nin": []string{"a", "b"}}}})).Int()).Should(Equal(0))
+		})
+
+		It("should apply operators on findAndUpdate", func() {
+			r := adapter.FindAndUpdate(payload.New(M{
+				"query":  M{"name": "Marie"},
+				"update": M{"$inc": M{"visits": 10}},
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.First().Get("visits").Int()).Should(Equal(11))
+		})
+	})
+
+	Describe("Find options", func() {
+		var adapter *Adapter
+		BeforeEach(func() {
+			adapter = connect("testFind", M{})
+			for _, user := range []map[string]string{
+				{"name": "Jackson", "email": "Jackson@five.com"},
+				{"name": "Michael", "email": "michael@jackson.com"},
+				{"name": "Mario", "email": "mario@silva.com"},
+				{"name": "Anderson", "email": "Zabib"},
+				{"name": "Connor", "email": "connor@mc.com"},
+				{"name": "Zabib", "email": "zabib@nmgv.com"},
+			} {
+				adapter.Insert(payload.New(user))
+			}
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should Find with limit and offset", func() {
+			Expect(adapter.Find(payload.New(M{"limit": 2})).Len()).Should(Equal(2))
+			Expect(adapter.Find(payload.New(M{"limit": 5})).Len()).Should(Equal(5))
+
+			r := adapter.Find(payload.New(M{"offset": 1, "limit": 2}))
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.Array()[0].Get("id").Int()).Should(Equal(2))
+			Expect(r.Array()[1].Get("id").Int()).Should(Equal(3))
+
+			r = adapter.Find(payload.New(M{"offset": 4, "limit": 5}))
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.Array()[1].Get("id").Int()).Should(Equal(6))
+			Expect(adapter.Find(payload.New(M{"offset": 6})).Len()).Should(Equal(0))
+		})
+
+		It("should Find with sort", func() {
+			r := adapter.Find(payload.New(M{"sort": "name"}))
+			Expect(r.Len()).Should(Equal(6))
+			Expect(r.Array()[0].Get("name").String()).Should(Equal("Anderson"))
+			Expect(r.Array()[1].Get("name").String()).Should(Equal("Connor"))
+
+			r = adapter.Find(payload.New(M{"sort": "-name"}))
+			Expect(r.Array()[0].Get("name").String()).Should(Equal("Zabib"))
+			Expect(r.Array()[1].Get("name").String()).Should(Equal("Michael"))
+
+			r = adapter.Find(payload.New(M{"sort": "-id name"}))
+			Expect(r.Array()[0].Get("name").String()).Should(Equal("Zabib"))
+			Expect(r.Array()[1].Get("name").String()).Should(Equal("Connor"))
+
+			r = adapter.Find(payload.New(M{"sort": []string{"name"}, "offset": 4, "limit": 1}))
+			Expect(r.First().Get("name").String()).Should(Equal("Michael"))
+		})
+
+		It("should Find with searchFields", func() {
+			r := adapter.Find(payload.New(M{"search": "Zabib", "searchFields": []string{"name", "email"}}))
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.Array()[0].Get("name").String()).Should(Equal("Anderson"))
+			Expect(r.Array()[1].Get("name").String()).Should(Equal("Zabib"))
+		})
+
+		It("should UpdateMany and RemoveMany records matching the query", func() {
+			r := adapter.UpdateMany(payload.New(M{
+				"query":  M{"name": M{"in": []string{"Mario", "Zabib"}}},
+				"update": M{"email": "changed@mail.com"},
+			}))
+			Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+			Expect(adapter.Count(payload.New(M{"query": M{"email": "changed@mail.com"}})).Int()).Should(Equal(2))
+
+			r = adapter.RemoveMany(payload.New(M{"query": M{"name": M{"like": "M%"}}}))
+			Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(4))
+		})
+
+		It("should RemoveAll remove all records and keep the id sequence", func() {
+			r := adapter.RemoveAll()
+			Expect(r.Get("deletedCount").Int()).Should(Equal(6))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(0))
+			Expect(adapter.Insert(payload.New(M{"name": "John"})).Get("id").Int()).Should(Equal(7))
+		})
+	})
+
+	Describe("Find advanced queries / filters", func() {
+		var adapter *Adapter
+		BeforeEach(func() {
+			adapter = connect("advancedFilters", M{})
+			adapter.Insert(payload.New(M{"name": "Jackson", "email": "Jackson@five.com", "age": 5, "letter": "J"}))
+			adapter.Insert(payload.New(M{"name": "Michael", "email": "michael@five.com", "age": 35, "letter": "M"}))
+			adapter.Insert(payload.New(M{"name": "Mario", "email": "mario@silva.com", "age": 37, "letter": "M"}))
+			adapter.Insert(payload.New(M{"name": "Anderson", "email": "Zabib@ufc.com", "age": 15, "letter": "A"}))
+			adapter.Insert(payload.New(M{"name": "Connor", "email": "connor@ufc.com", "letter": "C"}))
+			adapter.Insert(payload.New(M{"name": "Zabib", "email": "zabib@nmgv.com", "age": 28, "letter": "Z"}))
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		count := func(query M) int {
+			r := adapter.Find(payload.New(M{"query": query}))
+			Expect(r.Error()).Should(BeNil())
+			return r.Len()
+		}
+
+		It("should find people by comparison", func() {
+			Expect(count(M{"age": M{"<": 20}})).Should(Equal(2))
+			Expect(count(M{"age": M{"<=": 28}})).Should(Equal(3))
+			Expect(count(M{"age": M{">": 30}})).Should(Equal(2))
+			Expect(count(M{"age": M{"between": []int{15, 36}}})).Should(Equal(3))
+			Expect(count(M{"letter": M{"between": []string{"B", "M"}}})).Should(Equal(4))
+			Expect(count(M{"letter": M{"not between": []string{"B", "M"}}})).Should(Equal(2))
+		})
+
+		It("should find people by like, in and null", func() {
+			Expect(count(M{"email": M{"like": "%@ufc%"}})).Should(Equal(2))
+			Expect(count(M{"email": M{"like": "%@five.com"}})).Should(Equal(2))
+			Expect(count(M{"age": M{"in": []int{5, 35, 37, 200}}})).Should(Equal(3))
+			Expect(count(M{"letter": M{"in": []string{"M", "J", "Y"}}})).Should(Equal(3))
+			Expect(count(M{"age": "is not null"})).Should(Equal(5))
+			Expect(count(M{"age": "IS NULL"})).Should(Equal(1))
+		})
+
+		It("should not match the records without the field with $ne and $nin, as SQL", func() {
+			Expect(count(M{"age": M{"$ne": 35}})).Should(Equal(4))
+			Expect(count(M{"age": M{"not in": []int{5, 35, 37, 200}}})).Should(Equal(2))
+			Expect(count(M{"letter": M{"<>": "M"}})).Should(Equal(4))
+		})
+
+		It("should find people using the logical operators", func() {
+			Expect(count(M{"$not": M{"letter": "M"}})).Should(Equal(4))
+			Expect(count(M{"age": M{"$exists": false}})).Should(Equal(1))
+			Expect(count(M{"or": []M{{"letter": M{"not between": []string{"B", "M"}}}, {"email": M{"like": "%@ufc%"}}}})).Should(Equal(3))
+			Expect(count(M{"$or": []M{
+				{"age": M{"$gte": 35}},
+				{"$and": []M{{"letter": "A"}, {"age": M{"$lt": 20}}}},
+			}})).Should(Equal(3))
+		})
+
+		It("should fail with native queries and invalid queries", func() {
+			r := adapter.Find(payload.New(M{"nativeQuery": "length(name) > 6"}))
+			Expect(store.IsValidation(r.Error())).Should(BeTrue())
+			r = adapter.Find(payload.New(M{"query": M{"age": M{"$unknown": 1}}}))
+			Expect(store.IsValidation(r.Error())).Should(BeTrue())
+		})
+	})
+
+	It("should filter by nested fields", func() {
+		adapter := connect("nested", M{})
+		defer adapter.Disconnect()
+		adapter.Insert(payload.New(M{"name": "Marie", "address": map[string]interface{}{"city": "Auckland", "number": 10}}))
+		adapter.Insert(payload.New(M{"name": "John", "address": map[string]interface{}{"city": "Wellington", "number": 25}}))
+
+		r := adapter.Find(payload.New(M{"query": M{"address.city": "Auckland"}}))
+		Expect(r.Len()).Should(Equal(1))
+		Expect(r.First().Get("name").String()).Should(Equal("Marie"))
+
+		r = adapter.Find(payload.New(M{"query": M{"address": M{"number": M{"$gt": 20}}}}))
+		Expect(r.Len()).Should(Equal(1))
+		Expect(r.First().Get("name").String()).Should(Equal("John"))
+	})
+
+	Describe("Dates", func() {
+		var adapter *Adapter
+		BeforeEach(func() {
+			adapter = connect("dates", M{})
+			adapter.Insert(payload.New(M{"title": "day after tomorrow", "created": time.Now().Add(time.Hour * 24 * 2)}))
+			adapter.Insert(payload.New(M{"title": "today", "created": time.Now()}))
+			adapter.Insert(payload.New(M{"title": "tomorrow", "created": time.Now().Add(time.Hour * 24)}))
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should restore, order and filter by date", func() {
+			r := adapter.Find(payload.New(M{"sort": "created"}))
+			Expect(r.Array()[0].Get("title").String()).Should(Equal("today"))
+			Expect(r.Array()[2].Get("title").String()).Should(Equal("day after tomorrow"))
+			_, isTime := r.Array()[0].Get("created").Value().(time.Time)
+			Expect(isTime).Should(BeTrue())
+
+			r = adapter.Find(payload.New(M{"sort": "-created"}))
+			Expect(r.Array()[0].Get("title").String()).Should(Equal("day after tomorrow"))
+
+			r = adapter.Find(payload.New(M{"query": M{"created": M{"between": []time.Time{time.Now().Add(time.Hour), time.Now().Add(time.Hour * 25)}}}}))
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("title").String()).Should(Equal("tomorrow"))
+		})
+
+		It("should export and import the records with their ids and dates", func() {
+			var out bytes.Buffer
+			count, err := store.Export(adapter, &out, store.ExportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(count).Should(Equal(3))
+
+			restored := connect("restored", M{})
+			defer restored.Disconnect()
+			result, err := store.Import(restored, bytes.NewReader(out.Bytes()), store.ImportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(result.Inserted).Should(Equal(3))
+			Expect(restored.FindById(payload.New(2)).Get("title").String()).Should(Equal("today"))
+
+			result, err = store.Import(restored, bytes.NewReader(out.Bytes()), store.ImportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(result.Updated).Should(Equal(3))
+			Expect(restored.Count(payload.Empty()).Int()).Should(Equal(3))
+			Expect(restored.Insert(payload.New(M{"title": "next"})).Get("id").Int()).Should(Equal(4))
+		})
+	})
+
+	Describe("Indexes", func() {
+		var adapter *Adapter
+		BeforeEach(func() {
+			adapter = connect("indexed", M{"indexes": []M{
+				{"fields": []string{"email"}, "unique": true, "sparse": true},
+				{"fields": []string{"name", "-age"}},
+				{"fields": []string{"tags"}},
+			}})
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should return the declared indexes", func() {
+			r := adapter.Indexes()
+			Expect(r.Len()).Should(Equal(3))
+			Expect(r.First().Get("name").String()).Should(Equal("email"))
+			Expect(r.First().Get("unique").Bool()).Should(BeTrue())
+			Expect(r.Array()[1].Get("fields").StringArray()).Should(Equal([]string{"name", "-age"}))
+		})
+
+		It("should reject duplicated values of unique indexes", func() {
+			marie := adapter.Insert(payload.New(M{"email": "marie@m.com"}))
+			Expect(marie.Error()).Should(BeNil())
+			r := adapter.Insert(payload.New(M{"email": "marie@m.com"}))
+			Expect(store.IsConflict(r.Error())).Should(BeTrue())
+			Expect(r.Error().(*store.Error).Data["index"]).Should(Equal("email"))
+			Expect(adapter.Insert(payload.New(M{"name": "John"})).Error()).Should(BeNil())
+			Expect(adapter.Insert(payload.New(M{"name": "John"})).Error()).Should(BeNil())
+
+			john := adapter.Insert(payload.New(M{"email": "john@s.com"}))
+			Expect(store.IsConflict(adapter.UpdateById(john.Get("id"), payload.New(M{"email": "marie@m.com"})).Error())).Should(BeTrue())
+			Expect(adapter.UpdateById(marie.Get("id"), payload.New(M{"email": "marie@c.com"})).Error()).Should(BeNil())
+			Expect(adapter.UpdateById(john.Get("id"), payload.New(M{"email": "marie@m.com"})).Error()).Should(BeNil())
+		})
+
+		It("should find the records using the indexes", func() {
+			adapter.Insert(payload.New(M{"name": "John", "age": 25, "tags": []string{"a", "b"}}))
+			adapter.Insert(payload.New(M{"name": "John", "age": 65, "tags": []string{"b"}}))
+			adapter.Insert(payload.New(M{"name": "Marie", "age": 75}))
+
+			Expect(adapter.Count(payload.New(M{"query": M{"name": "John", "age": 65.0}})).Int()).Should(Equal(1))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": "b"}})).Int()).Should(Equal(2))
+			adapter.UpdateMany(payload.New(M{"query": M{"name": "John"}, "update": M{"$pull": M{"tags": "b"}}}))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": "b"}})).Int()).Should(Equal(0))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": "a"}})).Int()).Should(Equal(1))
+
+			adapter.RemoveMany(payload.New(M{"query": M{"tags": "a"}}))
+			Expect(adapter.Count(payload.New(M{"query": M{"name": "John", "age": 25}})).Int()).Should(Equal(0))
+		})
+
+		It("should build new indexes from the existing records", func() {
+			adapter.Insert(payload.New(M{"name": "John", "city": "Auckland"}))
+			adapter.Disconnect()
+			adapter = connect("indexed", M{"indexes": []M{{"fields": []string{"city"}}}})
+			Expect(adapter.Count(payload.New(M{"query": M{"city": "Auckland"}})).Int()).Should(Equal(1))
+		})
+	})
+
+	Describe("Errors", func() {
+		It("should return typed errors", func() {
+			adapter := connect("errors", M{})
+			marie := adapter.Insert(payload.New(M{"name": "Marie"}))
+
+			r := adapter.UpdateById(payload.New(100), payload.New(M{"name": "Nobody"}))
+			Expect(store.IsNotFound(r.Error())).Should(BeTrue())
+			Expect(store.IsNotFound(adapter.RemoveById(payload.New(100)).Error())).Should(BeTrue())
+			Expect(store.IsValidation(adapter.Update(payload.New(M{"name": "Nobody"})).Error())).Should(BeTrue())
+			Expect(store.IsConflict(adapter.Insert(payload.New(M{"id": marie.Get("id").Value()})).Error())).Should(BeTrue())
+			Expect(store.IsValidation(adapter.Insert(payload.New(M{"id": "abc"})).Error())).Should(BeTrue())
+			Expect(store.IsValidation(adapter.Insert(payload.New(M{"id": -1})).Error())).Should(BeTrue())
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(1))
+			Expect(server.Get("errors:seq")).Should(Equal("1"))
+
+			Expect(adapter.Ping()).Should(Succeed())
+			adapter.Disconnect()
+			Expect(store.IsUnavailable(adapter.Ping())).Should(BeTrue())
+			Expect(store.IsUnavailable(adapter.Find(payload.Empty()).Error())).Should(BeTrue())
+		})
+	})
+
+	It("should connect with the URL setting and fail when the server is down", func() {
+		adapter := &Adapter{Table: "users"}
+		adapter.Init(log.WithField("", ""), M{"url": "redis://" + server.Addr()})
+		Expect(adapter.Connect()).Should(Succeed())
+		Expect(adapter.PoolStats()["totalConns"]).Should(BeNumerically(">", 0))
+		server.Close()
+		Expect(store.IsUnavailable(adapter.Ping())).Should(BeTrue())
+		Expect(store.IsUnavailable(adapter.Find(payload.Empty()).Error())).Should(BeTrue())
+		adapter.Disconnect()
+
+		down := &Adapter{URL: "redis://" + server.Addr(), Table: "users"}
+		down.Init(log.WithField("", ""), M{})
+		Expect(store.IsUnavailable(down.Connect())).Should(BeTrue())
+	})
+
+	It("should run the commands with the context", func() {
+		adapter := connect("users", M{})
+		defer adapter.Disconnect()
+		ctx, cancel := context.WithCancel(context.Background())
+		bound := adapter.WithContext(ctx)
+		Expect(bound.Insert(payload.New(M{"name": "Marie"})).Error()).Should(BeNil())
+		cancel()
+		Expect(store.IsTimeout(bound.Find(payload.Empty()).Error())).Should(BeTrue())
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(1))
+	})
+
+	Describe("TTL", func() {
+		It("should expire the records after the TTL of the adapter", func() {
+			adapter := &Adapter{URL: "redis://" + server.Addr(), Table: "sessions", TTL: time.Minute}
+			adapter.Init(log.WithField("", ""), M{"indexes": []M{{"fields": []string{"token"}, "unique": true}}})
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+			marie := adapter.Insert(payload.New(M{"token": "abc"}))
+			Expect(server.TTL("sessions:1")).Should(Equal(time.Minute))
+
+			server.FastForward(30 * time.Second)
+			adapter.UpdateById(marie.Get("id"), payload.New(M{"name": "Marie"}))
+			Expect(server.TTL("sessions:1")).Should(Equal(30 * time.Second))
+
+			server.FastForward(31 * time.Second)
+			Expect(adapter.FindById(marie.Get("id")).Exists()).Should(BeFalse())
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(0))
+			Expect(server.Exists("sessions:ids")).Should(BeFalse())
+			Expect(adapter.Insert(payload.New(M{"token": "abc"})).Error()).Should(BeNil())
+		})
+
+		It("should set and remove the ttl of a record with the ttl field", func() {
+			adapter := connect("sessions", M{})
+			defer adapter.Disconnect()
+			r := adapter.Insert(payload.New(M{"token": "abc", "$ttl": 10}))
+			Expect(r.Get("$ttl").Exists()).Should(BeFalse())
+			Expect(server.TTL("sessions:1")).Should(Equal(10 * time.Second))
+			adapter.Insert(payload.New(M{"token": "def"}))
+			Expect(server.TTL("sessions:2")).Should(Equal(time.Duration(0)))
+
+			r = adapter.UpdateById(payload.New(2), payload.New(M{"$ttl": 20}))
+			Expect(r.Get("token").String()).Should(Equal("def"))
+			Expect(server.TTL("sessions:2")).Should(Equal(20 * time.Second))
+			adapter.UpdateById(payload.New(1), payload.New(M{"$ttl": 0}))
+			Expect(server.TTL("sessions:1")).Should(Equal(time.Duration(0)))
+
+			server.FastForward(21 * time.Second)
+			r = adapter.Find(payload.Empty())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("token").String()).Should(Equal("abc"))
+			Expect(store.IsValidation(adapter.Insert(payload.New(M{"$ttl": "soon"})).Error())).Should(BeTrue())
+		})
+
+		It("should expire the records with the ttl indexes", func() {
+			adapter := connect("events", M{"indexes": []M{{"fields": []string{"created"}, "ttl": 3600}}})
+			defer adapter.Disconnect()
+			adapter.Insert(payload.New(M{"title": "old", "created": time.Now().Add(-30 * time.Minute)}))
+			adapter.Insert(payload.New(M{"title": "new", "created": time.Now()}))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(2))
+
+			server.FastForward(31 * time.Minute)
+			r := adapter.Find(payload.Empty())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("title").String()).Should(Equal("new"))
+		})
+	})
+
+	It("should use a prefix and keys per tenant", func() {
+		adapter := &Adapter{URL: "redis://" + server.Addr(), Table: "users", Prefix: "app:"}
+		adapter.Init(log.WithField("", ""), M{})
+		Expect(adapter.Connect()).Should(Succeed())
+		defer adapter.Disconnect()
+		acme := adapter.ForTenant("acme")
+		Expect(acme.Connect()).Should(Succeed())
+		defer acme.Disconnect()
+		acme.Insert(payload.New(M{"name": "Marie"}))
+		Expect(acme.Count(payload.Empty()).Int()).Should(Equal(1))
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(0))
+		Expect(server.Exists("app:users_acme:1")).Should(BeTrue())
+	})
+})
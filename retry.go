@@ -0,0 +1,203 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryOptions are the options of ConnectWithRetry and of the reconnect loop of the connectRetry setting.
+type RetryOptions struct {
+	// Retries is the number of connect attempts after the first one fails. -1 retries until stopped. Default: 0
+	Retries int
+	// Delay is the wait before the first retry. It is multiplied by Factor at each retry, up to MaxDelay. Default: 500ms
+	Delay    time.Duration
+	MaxDelay time.Duration
+	Factor   float64
+	// CheckInterval is the interval to ping the adapter and reconnect when the connection drops. Default: 0 (disabled)
+	CheckInterval time.Duration
+}
+
+var defaultRetryOptions = RetryOptions{
+	Delay:    500 * time.Millisecond,
+	MaxDelay: 30 * time.Second,
+	Factor:   2,
+}
+
+// ErrConnectStopped is returned by ConnectWithRetry when it is stopped before connecting.
+var ErrConnectStopped = errors.New("Connect stopped before the adapter was connected!")
+
+// durationSetting returns a time.Duration or the number of milliseconds as a duration.
+func durationSetting(value interface{}) (time.Duration, bool) {
+	switch duration := value.(type) {
+	case time.Duration:
+		return duration, true
+	case int:
+		return time.Duration(duration) * time.Millisecond, true
+	case float64:
+		return time.Duration(duration * float64(time.Millisecond)), true
+	}
+	return 0, false
+}
+
+// ParseRetryOptions returns the options of the connectRetry setting: true (retry until connected) or a map with
+// retries, delay, maxDelay, factor and checkInterval. Durations are time.Duration or milliseconds.
+func ParseRetryOptions(settings map[string]interface{}) RetryOptions {
+	opts := defaultRetryOptions
+	switch setting := settings["connectRetry"].(type) {
+	case bool:
+		if setting {
+			opts.Retries = -1
+		}
+	case RetryOptions:
+		opts = setting
+	case map[string]interface{}:
+		config := payload.New(setting)
+		if config.Get("retries").Exists() {
+			opts.Retries = config.Get("retries").Int()
+		}
+		if config.Get("factor").Exists() {
+			opts.Factor = config.Get("factor").Float()
+		}
+		if delay, ok := durationSetting(setting["delay"]); ok {
+			opts.Delay = delay
+		}
+		if delay, ok := durationSetting(setting["maxDelay"]); ok {
+			opts.MaxDelay = delay
+		}
+		if interval, ok := durationSetting(setting["checkInterval"]); ok {
+			opts.CheckInterval = interval
+		}
+	}
+	return opts
+}
+
+// backoff returns the wait before the retry (starting at 0).
+func (opts RetryOptions) backoff(retry int) time.Duration {
+	delay := opts.Delay
+	if delay <= 0 {
+		delay = defaultRetryOptions.Delay
+	}
+	factor := opts.Factor
+	if factor < 1 {
+		factor = defaultRetryOptions.Factor
+	}
+	for i := 0; i < retry; i++ {
+		delay = time.Duration(float64(delay) * factor)
+		if opts.MaxDelay > 0 && delay >= opts.MaxDelay {
+			return opts.MaxDelay
+		}
+	}
+	return delay
+}
+
+// ConnectWithRetry connects the adapter, retrying with exponential backoff when Connect fails.
+// Returns the last error when all retries fail, or ErrConnectStopped when stop is closed.
+func ConnectWithRetry(adapter Adapter, opts RetryOptions, logger *log.Entry, stop chan bool) error {
+	err := adapter.Connect()
+	if err == nil {
+		return nil
+	}
+	return retryConnect(adapter, opts, logger, stop, err)
+}
+
+// retryConnect retries Connect after the first attempt failed with err.
+func retryConnect(adapter Adapter, opts RetryOptions, logger *log.Entry, stop chan bool, err error) error {
+	for retry := 0; opts.Retries < 0 || retry < opts.Retries; retry++ {
+		delay := opts.backoff(retry)
+		logger.Warn("Could not connect the adapter - error: ", err, " - retrying in ", delay)
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return ErrConnectStopped
+		}
+		if err = adapter.Connect(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// connectAdapter connects the adapter of the mixin and marks the service ready. The first attempt blocks,
+// the retries and the reconnect loop (CheckInterval) run in the background until stop is closed.
+// connected is called once, when the adapter is connected for the first time.
+func connectAdapter(adapter Adapter, opts RetryOptions, status *health, logger *log.Entry, stop chan bool, connected func()) {
+	err := adapter.Connect()
+	if err == nil {
+		status.setReady(true)
+		connected()
+	} else {
+		status.fail(err)
+		logger.Error("Could not connect the adapter - error: ", err)
+	}
+	if (err == nil || opts.Retries == 0) && opts.CheckInterval <= 0 {
+		return
+	}
+	go func() {
+		if err != nil && opts.Retries != 0 {
+			if err = retryConnect(adapter, opts, logger, stop, err); err == nil {
+				status.setReady(true)
+				connected()
+			} else if err != ErrConnectStopped {
+				status.fail(err)
+				logger.Error("Could not connect the adapter after ", opts.Retries, " retries - error: ", err)
+			}
+		}
+		if opts.CheckInterval > 0 {
+			keepConnected(adapter, opts, status, logger, stop, connected)
+		}
+	}()
+}
+
+// keepConnected pings the adapter every CheckInterval. When the ping fails the service is not ready
+// and the adapter reconnects, retrying until connected or stopped.
+func keepConnected(adapter Adapter, opts RetryOptions, status *health, logger *log.Entry, stop chan bool, connected func()) {
+	ticker := time.NewTicker(opts.CheckInterval)
+	defer ticker.Stop()
+	opts.Retries = -1
+	wasReady := status.isReady()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		var err error
+		if status.isReady() {
+			if err = Ping(adapter); err == nil {
+				continue
+			}
+			logger.Error("Adapter connection lost - error: ", err, " - reconnecting")
+			status.fail(err)
+			status.setReady(false)
+			adapter.Disconnect()
+		} else if err = adapter.Connect(); err == nil {
+			status.setReady(true)
+		}
+		if err != nil {
+			if err = retryConnect(adapter, opts, logger, stop, err); err != nil {
+				return
+			}
+			status.setReady(true)
+		}
+		logger.Info("Adapter connected!")
+		if !wasReady {
+			wasReady = true
+			connected()
+		}
+	}
+}
+
+// requireReady fails the adapter operations while the adapter is not connected,
+// instead of waiting for the connection in each operation.
+func requireReady(status *health) Middleware {
+	return func(call *Call, next Next) moleculer.Payload {
+		if call.Operation != "Connect" && call.Operation != "Disconnect" && !status.isReady() {
+			return payload.New(NewError(CodeUnavailable, "Adapter not connected!"))
+		}
+		return next(call)
+	}
+}
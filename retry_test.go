@@ -0,0 +1,119 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/moleculer-go/moleculer/payload"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// flakyAdapter fails Connect while failures > 0 and Ping while down is true.
+type flakyAdapter struct {
+	*MemoryAdapter
+	mutex    sync.Mutex
+	failures int
+	attempts int
+	down     bool
+}
+
+func (adapter *flakyAdapter) Connect() error {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+	adapter.attempts++
+	if adapter.failures > 0 {
+		adapter.failures--
+		return errors.New("connection refused")
+	}
+	adapter.down = false
+	return adapter.MemoryAdapter.Connect()
+}
+
+func (adapter *flakyAdapter) Ping() error {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+	if adapter.down {
+		return errors.New("connection lost")
+	}
+	return nil
+}
+
+func (adapter *flakyAdapter) getAttempts() int {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+	return adapter.attempts
+}
+
+var _ = Describe("Connect retry", func() {
+	logger := log.WithField("test", "retry")
+
+	newFlaky := func(failures int) *flakyAdapter {
+		adapter := &flakyAdapter{MemoryAdapter: &MemoryAdapter{Table: "user"}, failures: failures}
+		adapter.Init(logger, M{})
+		return adapter
+	}
+
+	It("should parse the connectRetry setting", func() {
+		Expect(ParseRetryOptions(M{}).Retries).Should(Equal(0))
+		Expect(ParseRetryOptions(M{"connectRetry": true}).Retries).Should(Equal(-1))
+		opts := ParseRetryOptions(M{"connectRetry": M{"retries": 5, "delay": 100, "maxDelay": time.Second, "checkInterval": 2000}})
+		Expect(opts).Should(Equal(RetryOptions{
+			Retries:       5,
+			Delay:         100 * time.Millisecond,
+			MaxDelay:      time.Second,
+			Factor:        2,
+			CheckInterval: 2 * time.Second,
+		}))
+		Expect(opts.backoff(0)).Should(Equal(100 * time.Millisecond))
+		Expect(opts.backoff(2)).Should(Equal(400 * time.Millisecond))
+		Expect(opts.backoff(10)).Should(Equal(time.Second))
+
+		opts = ParseRetryOptions(M{"connectRetry": M{"delay": 0.5, "maxDelay": 2.5}})
+		Expect(opts.Delay).Should(Equal(500 * time.Microsecond))
+		Expect(opts.MaxDelay).Should(Equal(2500 * time.Microsecond))
+	})
+
+	It("should retry Connect with backoff", func() {
+		opts := RetryOptions{Retries: 3, Delay: time.Millisecond}
+		adapter := newFlaky(2)
+		Expect(ConnectWithRetry(adapter, opts, logger, nil)).Should(Succeed())
+		Expect(adapter.getAttempts()).Should(Equal(3))
+
+		adapter = newFlaky(5)
+		err := ConnectWithRetry(adapter, opts, logger, nil)
+		Expect(err.Error()).Should(Equal("connection refused"))
+		Expect(adapter.getAttempts()).Should(Equal(4))
+
+		stop := make(chan bool)
+		close(stop)
+		adapter = newFlaky(5)
+		Expect(ConnectWithRetry(adapter, RetryOptions{Retries: -1, Delay: time.Hour}, logger, stop)).Should(Equal(ErrConnectStopped))
+	})
+
+	It("should not be ready until connected and reconnect when the connection drops", func() {
+		adapter := newFlaky(2)
+		status := &health{}
+		wrapped := Wrap(adapter, requireReady(status))
+		stop := make(chan bool)
+		defer close(stop)
+		connected := make(chan bool, 10)
+		opts := RetryOptions{Retries: -1, Delay: 5 * time.Millisecond, CheckInterval: 5 * time.Millisecond}
+
+		connectAdapter(wrapped, opts, status, logger, stop, func() { connected <- true })
+		Expect(status.isReady()).Should(BeFalse())
+		Expect(wrapped.Count(payload.Empty()).Error().Error()).Should(Equal("Adapter not connected!"))
+		Eventually(status.isReady).Should(BeTrue())
+		Expect(wrapped.Count(payload.Empty()).Int()).Should(Equal(0))
+
+		adapter.mutex.Lock()
+		adapter.down, adapter.failures = true, 1
+		adapter.mutex.Unlock()
+		Eventually(func() int { return adapter.getAttempts() }).Should(BeNumerically(">=", 5))
+		Eventually(status.isReady).Should(BeTrue())
+		Expect(status.check(wrapped)["lastError"]).Should(Equal("connection lost"))
+		Expect(len(connected)).Should(Equal(1))
+	})
+})
@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"crawshaw.io/sqlite"
+	"github.com/moleculer-go/store"
+)
+
+// typedError returns the SQLite error as a *store.Error: constraint errors are conflicts,
+// busy and locked databases are unavailable and interrupted statements are timeouts.
+func typedError(err error, msgs ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	code := store.ErrorCode(err)
+	if code == store.CodeInternal {
+		switch sqlite.ErrCode(err) & 0xff {
+		case sqlite.SQLITE_CONSTRAINT:
+			code = store.CodeConflict
+		case sqlite.SQLITE_BUSY, sqlite.SQLITE_LOCKED, sqlite.SQLITE_CANTOPEN:
+			code = store.CodeUnavailable
+		case sqlite.SQLITE_INTERRUPT:
+			code = store.CodeTimeout
+		}
+	}
+	return store.WrapError(code, err, msgs...)
+}
@@ -0,0 +1,27 @@
+package sqlite
+
+import (
+	"sync/atomic"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/moleculer-go/store"
+)
+
+// Ping runs SELECT 1 on a connection of the pool.
+func (a *Adapter) Ping() error {
+	if a.pool == nil {
+		return store.NewError(store.CodeUnavailable, "SQLite adapter not connected!")
+	}
+	return a.withConn("Error on ping", func(conn *sqlite.Conn) error {
+		return sqlitex.Exec(conn, "SELECT 1;", nil)
+	})
+}
+
+// PoolStats returns the size of the pool and the connections in use.
+func (a *Adapter) PoolStats() map[string]interface{} {
+	return map[string]interface{}{
+		"size":  a.PoolSize,
+		"inUse": atomic.LoadInt64(a.connInUse),
+	}
+}
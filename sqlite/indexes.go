@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"strings"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+)
+
+// indexStatement returns the CREATE INDEX statement of the index.
+// Sparse indexes are partial indexes of the rows where all fields are not null.
+func (a *Adapter) indexStatement(index store.Index) (string, error) {
+	columns := []string{}
+	conditions := []string{}
+	for _, field := range index.Fields {
+		column, err := a.filterColumn(field.Name)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, column+" IS NOT NULL")
+		if field.Descending {
+			column = column + " DESC"
+		}
+		columns = append(columns, column)
+	}
+	create := "CREATE INDEX IF NOT EXISTS "
+	if index.Unique {
+		create = "CREATE UNIQUE INDEX IF NOT EXISTS "
+	}
+	statement := create + a.Table + "_" + index.Name + " ON " + a.Table + " (" + strings.Join(columns, ", ") + ")"
+	if index.Sparse {
+		statement = statement + " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return statement + ";", nil
+}
+
+// createIndexes creates the indexes declared in the indexes setting.
+func (a *Adapter) createIndexes() error {
+	indexes, err := store.ParseIndexes(a.settings)
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	statements := []string{}
+	for _, index := range indexes {
+		if index.TTL > 0 {
+			a.log.Warn("SQLite adapter ", a.Table, " - index ", index.Name, ": ttl is not supported by the SQLite adapter.")
+		}
+		statement, err := a.indexStatement(index)
+		if err != nil {
+			return err
+		}
+		statements = append(statements, statement)
+	}
+	return a.withConn("Error on create indexes", func(conn *sqlite.Conn) error {
+		for _, statement := range statements {
+			a.log.Debug(statement)
+			if err := sqlitex.ExecTransient(conn, statement, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Indexes returns the indexes of the table, from PRAGMA index_list and index_xinfo.
+func (a *Adapter) Indexes() moleculer.Payload {
+	list := []map[string]interface{}{}
+	err := a.withConn("Error reading indexes", func(conn *sqlite.Conn) error {
+		err := sqlitex.ExecTransient(conn, "PRAGMA index_list("+a.Table+");", func(stmt *sqlite.Stmt) error {
+			list = append(list, map[string]interface{}{
+				"name":   stmt.GetText("name"),
+				"unique": stmt.GetInt64("unique") == 1,
+				"sparse": stmt.GetInt64("partial") == 1,
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, index := range list {
+			fields := []string{}
+			err := sqlitex.ExecTransient(conn, "PRAGMA index_xinfo("+index["name"].(string)+");", func(stmt *sqlite.Stmt) error {
+				if stmt.GetInt64("key") == 0 {
+					return nil
+				}
+				field := stmt.GetText("name")
+				if field == "" {
+					field = "<expression>"
+				}
+				if stmt.GetInt64("desc") == 1 {
+					field = "-" + field
+				}
+				fields = append(fields, field)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			index["fields"] = fields
+		}
+		return nil
+	})
+	if err != nil {
+		return payload.New(typedError(err))
+	}
+	return payload.New(list)
+}
@@ -0,0 +1,301 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// Migration is a versioned schema change of the adapter table.
+// Pending migrations run on Connect in version order, each one in a transaction,
+// and are recorded in the _migrations table, so each version runs only once.
+//
+//	Migrations: []sqlite.Migration{
+//		{Version: 1, Description: "rename mail", Steps: []sqlite.MigrationStep{sqlite.RenameColumn("mail", "email")}},
+//		{Version: 2, Description: "age as integer", Steps: []sqlite.MigrationStep{sqlite.ChangeColumnType("age", "integer")}},
+//	}
+type Migration struct {
+	Version     int
+	Description string
+	Steps       []MigrationStep
+}
+
+// MigrationStep changes the schema or the data of the table.
+type MigrationStep func(conn *sqlite.Conn, table string) error
+
+var migrationsTable = "_migrations"
+
+// columnInfo is a column of an existing table, from PRAGMA table_info.
+type columnInfo struct {
+	name       string
+	columnType string
+	pk         bool
+}
+
+// RenameColumn renames the column, keeping its values.
+func RenameColumn(from, to string) MigrationStep {
+	return func(conn *sqlite.Conn, table string) error {
+		return sqlitex.ExecTransient(conn, "ALTER TABLE "+table+" RENAME COLUMN "+from+" TO "+to+";", nil)
+	}
+}
+
+// ChangeColumnType changes the type of the column (same types as Column.Type), rebuilding the table.
+// Existing values are converted with CAST.
+func ChangeColumnType(name, columnType string) MigrationStep {
+	return func(conn *sqlite.Conn, table string) error {
+		columns, err := tableInfo(conn, table)
+		if err != nil {
+			return err
+		}
+		selects := []string{}
+		found := false
+		for i, c := range columns {
+			if c.name == name {
+				columns[i].columnType = dbType(columnType)
+				selects = append(selects, "CAST("+c.name+" AS "+columns[i].columnType+")")
+				found = true
+			} else {
+				selects = append(selects, c.name)
+			}
+		}
+		if !found {
+			return errors.New("Column not found: " + name)
+		}
+		return rebuildTable(conn, table, columns, selects)
+	}
+}
+
+// DropColumn removes the column and its values, rebuilding the table.
+func DropColumn(name string) MigrationStep {
+	return func(conn *sqlite.Conn, table string) error {
+		columns, err := tableInfo(conn, table)
+		if err != nil {
+			return err
+		}
+		kept := []columnInfo{}
+		selects := []string{}
+		for _, c := range columns {
+			if c.name != name {
+				kept = append(kept, c)
+				selects = append(selects, c.name)
+			}
+		}
+		if len(kept) == len(columns) {
+			return errors.New("Column not found: " + name)
+		}
+		return rebuildTable(conn, table, kept, selects)
+	}
+}
+
+// Exec runs the SQL statement. {table} is replaced by the table name.
+func Exec(statement string) MigrationStep {
+	return func(conn *sqlite.Conn, table string) error {
+		return sqlitex.ExecTransient(conn, strings.Replace(statement, "{table}", table, -1), nil)
+	}
+}
+
+// tableInfo returns the columns of the table.
+func tableInfo(conn *sqlite.Conn, table string) ([]columnInfo, error) {
+	columns := []columnInfo{}
+	err := sqlitex.ExecTransient(conn, "PRAGMA table_info("+table+");", func(stmt *sqlite.Stmt) error {
+		columns = append(columns, columnInfo{stmt.GetText("name"), stmt.GetText("type"), stmt.GetInt64("pk") > 0})
+		return nil
+	})
+	return columns, err
+}
+
+// rebuildTable creates a new table with the columns, copies the data using the select expressions and replaces the table.
+func rebuildTable(conn *sqlite.Conn, table string, columns []columnInfo, selects []string) error {
+	definitions := []string{}
+	names := []string{}
+	for _, c := range columns {
+		definition := strings.TrimSpace(c.name + " " + c.columnType)
+		if c.pk && strings.ToUpper(c.columnType) == "INTEGER" {
+			definition = c.name + " INTEGER PRIMARY KEY AUTOINCREMENT"
+		} else if c.pk {
+			definition = definition + " PRIMARY KEY"
+		}
+		definitions = append(definitions, definition)
+		names = append(names, c.name)
+	}
+	rebuilt := table + "_rebuild"
+	statements := []string{
+		"CREATE TABLE " + rebuilt + " (" + strings.Join(definitions, ", ") + ");",
+		"INSERT INTO " + rebuilt + " (" + strings.Join(names, ", ") + ") SELECT " + strings.Join(selects, ", ") + " FROM " + table + ";",
+		"DROP TABLE " + table + ";",
+		"ALTER TABLE " + rebuilt + " RENAME TO " + table + ";",
+	}
+	for _, statement := range statements {
+		if err := sqlitex.ExecTransient(conn, statement, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withConn runs fn with a connection from the pool.
+func (a *Adapter) withConn(msg string, fn func(conn *sqlite.Conn) error) error {
+	resChan := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError(msg, resChan)
+		conn := a.getConn()
+		if conn == nil {
+			resChan <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+		if err := fn(conn); err != nil {
+			resChan <- payload.New(typedError(err))
+			return
+		}
+		resChan <- payload.Empty()
+	}()
+	p := <-resChan
+	if p.IsError() {
+		return p.Error()
+	}
+	return nil
+}
+
+// tableExists checks if the adapter table exists in the database.
+func (a *Adapter) tableExists() (bool, error) {
+	exists := false
+	err := a.withConn("Error checking table", func(conn *sqlite.Conn) error {
+		return sqlitex.Exec(conn, "SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?;", func(stmt *sqlite.Stmt) error {
+			exists = true
+			return nil
+		}, a.Table)
+	})
+	return exists, err
+}
+
+// migrate runs the pending migrations and adds the declared columns missing in the table.
+// When the table was just created (existed is false) the migrations are only recorded as applied.
+func (a *Adapter) migrate(existed bool) error {
+	return a.withConn("Error on migrate", func(conn *sqlite.Conn) (err error) {
+		defer sqlitex.Save(conn)(&err)
+
+		create := "CREATE TABLE IF NOT EXISTS " + migrationsTable + " (id INTEGER PRIMARY KEY AUTOINCREMENT, tbl TEXT, version INTEGER, description TEXT, applied TEXT);"
+		if err = sqlitex.ExecTransient(conn, create, nil); err != nil {
+			return err
+		}
+		applied := map[int]bool{}
+		err = sqlitex.Exec(conn, "SELECT version FROM "+migrationsTable+" WHERE tbl = ? AND version > 0;", func(stmt *sqlite.Stmt) error {
+			applied[int(stmt.GetInt64("version"))] = true
+			return nil
+		}, a.Table)
+		if err != nil {
+			return err
+		}
+
+		migrations := append([]Migration{}, a.Migrations...)
+		sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+		for _, migration := range migrations {
+			if applied[migration.Version] {
+				continue
+			}
+			if existed {
+				a.log.Info("SQLite adapter ", a.Table, " - running migration: ", migration.Version, " ", migration.Description)
+				for _, step := range migration.Steps {
+					if err = step(conn, a.Table); err != nil {
+						return errors.New(fmt.Sprint("Migration ", migration.Version, " failed - error: ", err))
+					}
+				}
+			}
+			if err = a.recordMigration(conn, migration.Version, migration.Description); err != nil {
+				return err
+			}
+		}
+
+		columns, err := tableInfo(conn, a.Table)
+		if err != nil {
+			return err
+		}
+		for _, column := range a.Columns {
+			if hasColumnInfo(column.Name, columns) {
+				continue
+			}
+			a.log.Info("SQLite adapter ", a.Table, " - adding column: ", column.Name)
+			if err = sqlitex.ExecTransient(conn, "ALTER TABLE "+a.Table+" ADD COLUMN "+columnDefinition(column)+";", nil); err != nil {
+				return err
+			}
+			if err = a.recordMigration(conn, 0, "add column "+column.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a *Adapter) recordMigration(conn *sqlite.Conn, version int, description string) error {
+	insert := "INSERT INTO " + migrationsTable + " (tbl, version, description, applied) VALUES (?, ?, ?, ?);"
+	return sqlitex.Exec(conn, insert, nil, a.Table, version, description, time.Now().UTC().Format(ISO8601))
+}
+
+func hasColumnInfo(name string, columns []columnInfo) bool {
+	for _, c := range columns {
+		if strings.EqualFold(c.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// AppliedMigrations returns the migrations recorded for the table, in the order they were applied.
+func (a *Adapter) AppliedMigrations() moleculer.Payload {
+	list := []map[string]interface{}{}
+	err := a.withConn("Error reading migrations", func(conn *sqlite.Conn) error {
+		query := "SELECT version, description, applied FROM " + migrationsTable + " WHERE tbl = ? ORDER BY id;"
+		return sqlitex.Exec(conn, query, func(stmt *sqlite.Stmt) error {
+			list = append(list, map[string]interface{}{
+				"version":     stmt.GetInt64("version"),
+				"description": stmt.GetText("description"),
+				"applied":     stmt.GetText("applied"),
+			})
+			return nil
+		}, a.Table)
+	})
+	if err != nil {
+		return payload.New(typedError(err))
+	}
+	return payload.New(list)
+}
+
+// columnTypes maps the SQLite types to the Column types.
+var columnTypes = map[string]string{
+	"TEXT":    "string",
+	"INTEGER": "integer",
+	"REAL":    "float",
+}
+
+// LoadColumns reads the columns of the table, after Connect. Used to open a table without declaring its Columns (e.g. in the store CLI).
+// TEXT columns are loaded as string, so map, list and date values are returned as text.
+func (a *Adapter) LoadColumns() error {
+	var columns []columnInfo
+	err := a.withConn("Error reading columns", func(conn *sqlite.Conn) (err error) {
+		columns, err = tableInfo(conn, a.Table)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	a.Columns = []Column{}
+	for _, c := range columns {
+		if c.name == a.idField {
+			continue
+		}
+		columnType, known := columnTypes[strings.ToUpper(c.columnType)]
+		if !known {
+			columnType = strings.ToLower(c.columnType)
+		}
+		a.Columns = append(a.Columns, Column{Name: c.name, Type: columnType})
+	}
+	return nil
+}
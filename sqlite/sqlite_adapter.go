@@ -1,11 +1,14 @@
 package sqlite
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"strconv"
@@ -14,6 +17,8 @@ import (
 	"github.com/moleculer-go/moleculer/serializer"
 
 	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/dsl"
 
 	"crawshaw.io/sqlite"
 	"crawshaw.io/sqlite/sqlitex"
@@ -40,10 +45,12 @@ type Adapter struct {
 	// ColName can be used to modify/translate column names
 	// from what is passed in the params
 	ColName func(string) string
+	// Migrations are applied on Connect. See Migration.
+	Migrations []Migration
 
 	pool                 *sqlitex.Pool
 	waitForPoolLimit     time.Duration
-	connInUse            int
+	connInUse            *int64
 	waitConnectionsLimit time.Duration
 
 	connected bool
@@ -54,6 +61,8 @@ type Adapter struct {
 	idField    string
 	idColumn   *Column
 	serializer serializer.Serializer
+	// ctx is the context of the operations. See WithContext.
+	ctx context.Context
 }
 
 func (a *Adapter) Init(log *log.Entry, settings map[string]interface{}) {
@@ -70,12 +79,39 @@ func (a *Adapter) Init(log *log.Entry, settings map[string]interface{}) {
 	if a.PoolSize == 0 {
 		a.PoolSize = 1
 	}
+	if a.connInUse == nil {
+		a.connInUse = new(int64)
+	}
 	a.waitForPoolLimit = time.Millisecond * 500
 	a.waitConnectionsLimit = time.Second * 2
 	a.loadSettings(a.settings)
 	a.serializer = serializer.CreateJSONSerializer(a.log)
 }
 
+// WithContext returns the adapter with the operations bound to the context: waiting for a pooled connection
+// and the running statements are interrupted when the context is done.
+func (a *Adapter) WithContext(ctx context.Context) store.Adapter {
+	bound := *a
+	bound.ctx = ctx
+	return &bound
+}
+
+// ForTenant returns an adapter for the tenant, using its own table (e.g. users_acme).
+func (a *Adapter) ForTenant(tenant string) store.Adapter {
+	scoped := &Adapter{
+		URI:        a.URI,
+		Flags:      a.Flags,
+		PoolSize:   a.PoolSize,
+		Timeout:    a.Timeout,
+		Table:      a.Table + "_" + tenant,
+		Columns:    a.Columns,
+		ColName:    a.ColName,
+		Migrations: a.Migrations,
+	}
+	scoped.Init(a.log, a.settings)
+	return scoped
+}
+
 var pools map[string]*sqlitex.Pool
 var poolsMutex = &sync.Mutex{}
 
@@ -112,13 +148,39 @@ func (a *Adapter) Connect() error {
 		return errors.New(fmt.Sprint("Could not connect to SQLite - error: ", err))
 	}
 	a.pool = pool
+	if err := a.setupTable(); err != nil {
+		// close the pool, so Connect can be retried
+		a.pool = nil
+		pool.Close()
+		return err
+	}
+	a.log.Info("SQLite adapter " + a.Table + " connected!")
+	a.connected = true
+	return nil
+}
+
+// setupTable creates and migrates the table and its indexes.
+func (a *Adapter) setupTable() error {
+	existed, err := a.tableExists()
+	if err != nil {
+		a.log.Error("Could not check table - error: ", err)
+		return errors.New(fmt.Sprint("Could not check table - error: ", err))
+	}
 	err = a.createTable()
 	if err != nil {
 		a.log.Error("Could not create table - error: ", err)
 		return errors.New(fmt.Sprint("Could not create table - error: ", err))
 	}
-	a.log.Info("SQLite adapter " + a.Table + " connected!")
-	a.connected = true
+	err = a.migrate(existed)
+	if err != nil {
+		a.log.Error("Could not migrate table - error: ", err)
+		return errors.New(fmt.Sprint("Could not migrate table - error: ", err))
+	}
+	err = a.createIndexes()
+	if err != nil {
+		a.log.Error("Could not create indexes - error: ", err)
+		return errors.New(fmt.Sprint("Could not create indexes - error: ", err))
+	}
 	return nil
 }
 
@@ -126,11 +188,11 @@ func (a *Adapter) Connect() error {
 func (a *Adapter) waitConnections() error {
 	start := time.Now()
 	for {
-		if a.connInUse == 0 {
+		if atomic.LoadInt64(a.connInUse) == 0 {
 			return nil
 		}
 		if a.waitConnectionsLimit != 0 && time.Since(start) >= a.waitConnectionsLimit {
-			return errors.New("waitConnections() timeout! There are still " + strconv.Itoa(a.connInUse) + " connections in use.")
+			return errors.New("waitConnections() timeout! There are still " + strconv.FormatInt(atomic.LoadInt64(a.connInUse), 10) + " connections in use.")
 		}
 		time.Sleep(time.Microsecond)
 	}
@@ -162,20 +224,24 @@ func (a *Adapter) Disconnect() error {
 }
 
 func noConnectionError() moleculer.Payload {
-	return payload.Error("No connection availble!. Did you call a.Connect() ?")
+	return payload.New(store.NewError(store.CodeUnavailable, "No connection availble!. Did you call a.Connect() ?"))
 }
 
 func (a *Adapter) catchConnError(msg string, resChan chan moleculer.Payload) {
 	if err := recover(); err != nil {
 		stackTrace := string(debug.Stack())
 		a.log.Error("SQLite adapter Error - message: ", msg, " - error: ", err, " stack track: ", stackTrace)
-		resChan <- payload.New(err)
+		if e, ok := err.(error); ok {
+			resChan <- payload.New(typedError(e))
+			return
+		}
+		resChan <- payload.New(store.NewError(store.CodeInternal, msg, " - error: ", err))
 	}
 }
 
 func (a *Adapter) returnConn(conn *sqlite.Conn) {
 	a.pool.Put(conn)
-	a.connInUse = a.connInUse - 1
+	atomic.AddInt64(a.connInUse, -1)
 }
 
 // getConn fetch a connection from the pool
@@ -184,7 +250,7 @@ func (a *Adapter) returnConn(conn *sqlite.Conn) {
 func (a *Adapter) getConn() *sqlite.Conn {
 	if a.pool == nil {
 		if a.waitForPoolLimit == 0 {
-			panic("Adapter not connected!")
+			panic(store.NewError(store.CodeUnavailable, "Adapter not connected!"))
 		}
 		start := time.Now()
 		for {
@@ -197,25 +263,59 @@ func (a *Adapter) getConn() *sqlite.Conn {
 			time.Sleep(time.Microsecond)
 		}
 	}
-	a.connInUse = a.connInUse + 1
-	return a.pool.Get(nil)
+	atomic.AddInt64(a.connInUse, 1)
+	conn := a.pool.Get(a.ctx)
+	if conn == nil {
+		atomic.AddInt64(a.connInUse, -1)
+	}
+	return conn
 }
 
 // updatePairs generate the update pairs (one list of columns and one of values) used for update statement.
-func (a *Adapter) updatePairs(param moleculer.Payload) ([]string, []interface{}) {
+// Update operators are translated to SQL expressions, examples:
+// {"$inc": {"visits": 1}} -> visits = COALESCE(visits, 0) + ?
+// {"$push": {"tags": "new"}} -> tags = tags || '||' || ? (for []string and []int columns)
+func (a *Adapter) updatePairs(param moleculer.Payload) ([]string, []interface{}, error) {
 	columns := []string{}
 	values := []interface{}{}
-	param.ForEach(func(key interface{}, value moleculer.Payload) bool {
-		col, ok := key.(string)
-		if !ok {
-			a.log.Error("extractFields() key must be string! - key: ", key)
-			return false
+	ops, err := dsl.ParseUpdate(param)
+	if err != nil {
+		a.log.Error("updatePairs() invalid update - error: ", err)
+		return nil, nil, store.WrapError(store.CodeValidation, err)
+	}
+	for _, op := range ops {
+		col := a.ColName(op.Field)
+		switch op.Operator {
+		case dsl.Unset:
+			columns = append(columns, col+" = NULL")
+		case dsl.Inc:
+			columns = append(columns, col+" = COALESCE("+col+", 0) + ?")
+			values = append(values, op.Value)
+		case dsl.Push:
+			columns = append(columns, col+" = CASE WHEN "+col+" IS NULL OR "+col+" = '' THEN ? ELSE "+col+" || '"+listSeparator+"' || ? END")
+			values = append(values, fmt.Sprint(op.Value), fmt.Sprint(op.Value))
+		case dsl.Pull:
+			columns = append(columns, col+" = "+pullExpression(col))
+			values = append(values, fmt.Sprint(op.Value))
+		default:
+			value := a.transformIn(op.Field, op.Value)
+			if value == nil {
+				value = op.Value
+			}
+			columns = append(columns, col+" = ?")
+			values = append(values, value)
 		}
-		columns = append(columns, a.ColName(col)+" = ?")
-		values = append(values, value.Value())
-		return true
-	})
-	return columns, values
+	}
+	return columns, values, nil
+}
+
+// pullExpression returns the SQL expression removing all items equal to ? from the list column.
+// Each item is wrapped by char(1) and char(2), so adjacent duplicates are separate matches of REPLACE,
+// and the markers are replaced back by the list separator. e.g. a||a||b -> \1a\2\1a\2\1b\2 -> \1b\2 -> b
+func pullExpression(col string) string {
+	wrapped := "char(1) || REPLACE(" + col + ", '" + listSeparator + "', char(2) || char(1)) || char(2)"
+	pulled := "REPLACE(" + wrapped + ", char(1) || ? || char(2), '')"
+	return "REPLACE(REPLACE(REPLACE(" + pulled + ", char(2) || char(1), '" + listSeparator + "'), char(1), ''), char(2), '')"
 }
 
 // insertFields will parse the payload and extract the column names with
@@ -284,15 +384,19 @@ func (a *Adapter) loadSettings(settings map[string]interface{}) {
 func (a *Adapter) columnsDefinition() []string {
 	columns := []string{a.idField + " INTEGER PRIMARY KEY AUTOINCREMENT"}
 	for _, c := range a.Columns {
-		def := c.Name
-		if c.Type != "" {
-			def = def + " " + dbType(c.Type)
-		}
-		columns = append(columns, def)
+		columns = append(columns, columnDefinition(c))
 	}
 	return columns
 }
 
+// columnDefinition return the definition of the column. e.g. name TEXT
+func columnDefinition(c Column) string {
+	if c.Type != "" {
+		return c.Name + " " + dbType(c.Type)
+	}
+	return c.Name
+}
+
 func (a *Adapter) createTable() error {
 	resChan := make(chan moleculer.Payload, 1)
 	go func() {
@@ -308,7 +412,7 @@ func (a *Adapter) createTable() error {
 
 		err := sqlitex.ExecTransient(conn, create, nil)
 		if err != nil {
-			resChan <- payload.New(err)
+			resChan <- payload.New(typedError(err))
 		}
 		a.log.Debug("table " + a.Table + " created !!!")
 		resChan <- payload.Empty()
@@ -357,7 +461,7 @@ func (a *Adapter) FindAndUpdate(param moleculer.Payload) moleculer.Payload {
 		for _, item := range originals.Array() {
 			id := item.Get(a.idField)
 			if err := a.updateById(conn, id, update); err != nil {
-				result = append(result, payload.New(err))
+				result = append(result, payload.New(typedError(err)))
 			} else {
 				filter := payload.New(map[string]interface{}{
 					"query": map[string]interface{}{a.idField: id.Value()},
@@ -374,7 +478,7 @@ func (a *Adapter) FindAndUpdate(param moleculer.Payload) moleculer.Payload {
 func (a *Adapter) Update(params moleculer.Payload) moleculer.Payload {
 	id := params.Get("id")
 	if !id.Exists() {
-		return payload.Error("Cannot update record without id")
+		return payload.New(store.NewError(store.CodeValidation, "Cannot update record without id"))
 	}
 	return a.UpdateById(id, params.Remove("id"))
 }
@@ -390,7 +494,7 @@ func (a *Adapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
 		}
 		defer a.returnConn(conn)
 		if err := a.updateById(conn, id, update); err != nil {
-			results <- payload.New(err)
+			results <- payload.New(typedError(err))
 			return
 		}
 		results <- a.findById(conn, id)
@@ -398,6 +502,46 @@ func (a *Adapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
 	return <-results
 }
 
+// UpdateMany update all records matching the query with a single UPDATE stmt.
+func (a *Adapter) UpdateMany(param moleculer.Payload) moleculer.Payload {
+	resChan := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError("Error on update many", resChan)
+		conn := a.getConn()
+		if conn == nil {
+			resChan <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+
+		changes, values, err := a.updatePairs(param.Get("update"))
+		if err != nil {
+			resChan <- payload.New(typedError(err))
+			return
+		}
+		where, whereValues, err := a.findWhere(param)
+		if err != nil {
+			resChan <- payload.New(typedError(err))
+			return
+		}
+		updtStmt := "UPDATE " + a.Table + " SET " + strings.Join(changes, ", ")
+		if where != "" {
+			updtStmt = updtStmt + " WHERE " + where
+		}
+		updtStmt = updtStmt + " ;"
+		values = append(values, whereValues...)
+		a.log.Debug(updtStmt, " - values: ", values)
+		if err := sqlitex.Exec(conn, updtStmt, nil, values...); err != nil {
+			a.log.Error("Error on update many: ", err)
+			resChan <- payload.New(typedError(err))
+			return
+		}
+		modifiedCount := conn.Changes()
+		resChan <- payload.New(map[string]int{"modifiedCount": modifiedCount})
+	}()
+	return <-resChan
+}
+
 func (a *Adapter) Insert(param moleculer.Payload) moleculer.Payload {
 	resChan := make(chan moleculer.Payload, 1)
 	go func() {
@@ -415,7 +559,7 @@ func (a *Adapter) Insert(param moleculer.Payload) moleculer.Payload {
 		a.log.Debug("values: ", values)
 		if err := sqlitex.Exec(conn, insert, nil, values...); err != nil {
 			a.log.Error("Error on insert: ", err, " - values: ", values)
-			resChan <- payload.New(err)
+			resChan <- payload.New(typedError(err))
 			return
 		}
 		resChan <- param.Add(a.idField, conn.LastInsertRowID())
@@ -438,7 +582,41 @@ func (a *Adapter) RemoveAll() moleculer.Payload {
 		a.log.Debug(delete)
 		if err := sqlitex.Exec(conn, delete, nil); err != nil {
 			a.log.Error("Error on delete: ", err)
-			resChan <- payload.New(err)
+			resChan <- payload.New(typedError(err))
+			return
+		}
+		deletedCount := conn.Changes()
+		resChan <- payload.New(map[string]int{"deletedCount": deletedCount})
+	}()
+	return <-resChan
+}
+
+// RemoveMany remove all records matching the query with a single DELETE stmt.
+func (a *Adapter) RemoveMany(param moleculer.Payload) moleculer.Payload {
+	resChan := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError("Error on remove many", resChan)
+		conn := a.getConn()
+		if conn == nil {
+			resChan <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+
+		where, values, err := a.findWhere(param)
+		if err != nil {
+			resChan <- payload.New(typedError(err))
+			return
+		}
+		delete := "DELETE FROM " + a.Table
+		if where != "" {
+			delete = delete + " WHERE " + where
+		}
+		delete = delete + " ;"
+		a.log.Debug(delete, " - values: ", values)
+		if err := sqlitex.Exec(conn, delete, nil, values...); err != nil {
+			a.log.Error("Error on delete: ", err)
+			resChan <- payload.New(typedError(err))
 			return
 		}
 		deletedCount := conn.Changes()
@@ -462,10 +640,14 @@ func (a *Adapter) RemoveById(id moleculer.Payload) moleculer.Payload {
 		a.log.Debug(delete)
 		if err := sqlitex.Exec(conn, delete, nil); err != nil {
 			a.log.Error("Error on delete: ", err)
-			resChan <- payload.New(err)
+			resChan <- payload.New(typedError(err))
 			return
 		}
 		deletedCount := conn.Changes()
+		if deletedCount == 0 {
+			resChan <- payload.New(store.NewError(store.CodeNotFound, "Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()}))
+			return
+		}
 		resChan <- payload.New(map[string]int{"deletedCount": deletedCount})
 	}()
 	return <-resChan
@@ -498,7 +680,7 @@ func (a *Adapter) FindByIds(ids moleculer.Payload) moleculer.Payload {
 		}
 		defer a.returnConn(conn)
 		if !ids.IsArray() {
-			resChan <- payload.Error("FindByIds() only support lists!")
+			resChan <- payload.New(store.NewError(store.CodeValidation, "FindByIds() only support lists!"))
 			return
 		}
 		list := make([]moleculer.Payload, ids.Len())
@@ -533,12 +715,18 @@ func (a *Adapter) Count(param moleculer.Payload) moleculer.Payload {
 }
 
 func (a *Adapter) updateById(conn *sqlite.Conn, id, update moleculer.Payload) error {
-	changes, values := a.updatePairs(update)
+	changes, values, err := a.updatePairs(update)
+	if err != nil {
+		return err
+	}
 	updtStmt := "UPDATE " + a.Table + " SET " + strings.Join(changes, ", ") + " WHERE id=" + id.String() + ";"
 	a.log.Debug(updtStmt, " - values: ", values)
 	if err := sqlitex.Exec(conn, updtStmt, nil, values...); err != nil {
 		a.log.Error("Error on update: ", err)
-		return err
+		return typedError(err)
+	}
+	if conn.Changes() == 0 {
+		return store.NewError(store.CodeNotFound, "Could not find record with id: ", id.String()).WithData(map[string]interface{}{"id": id.Value()})
 	}
 	a.log.Debug("update done.")
 	return nil
@@ -660,7 +848,11 @@ func (a *Adapter) query(conn *sqlite.Conn, fields []string, param moleculer.Payl
 	limit, offset, sort := resolveFindOptions(param)
 
 	rows := []moleculer.Payload{}
-	where := a.findWhere(param)
+	where, values, err := a.findWhere(param)
+	if err != nil {
+		a.log.Error("Error on select: ", err)
+		return payload.New(typedError(err))
+	}
 	selec := "SELECT " + strings.Join(fields, ", ") + " FROM " + a.Table
 	if where != "" {
 		selec = selec + " WHERE " + where
@@ -676,13 +868,13 @@ func (a *Adapter) query(conn *sqlite.Conn, fields []string, param moleculer.Payl
 	}
 	selec = selec + " ;"
 
-	a.log.Trace(selec)
+	a.log.Trace(selec, " - values: ", values)
 	if err := sqlitex.Exec(conn, selec, func(stmt *sqlite.Stmt) error {
 		rows = append(rows, mapRow(fields, stmt))
 		return nil
-	}); err != nil {
+	}, values...); err != nil {
 		a.log.Error("Error on select: ", err)
-		return payload.New(err)
+		return payload.New(typedError(err))
 	}
 	a.log.Trace("rows: ", rows)
 	return payload.New(rows)
@@ -847,149 +1039,204 @@ func (a *Adapter) columnType(field string) (r string) {
 	return r
 }
 
-//betweenValues prepare the values for the operator "between" sql stmt -> between  A and B
-func (a *Adapter) betweenValues(field string, values moleculer.Payload) (r string) {
-	pair := values.Array()
-	return a.wrapValue(field, pair[0]) + " AND " + a.wrapValue(field, pair[1])
+func isTime(p moleculer.Payload) bool {
+	_, valid := p.Value().(time.Time)
+	return valid
 }
 
-//inValues prepare the values for the operator "in" sql stmt -> in (A, B, C)
-func (a *Adapter) inValues(field string, values moleculer.Payload) (r string) {
-	items := []string{}
-	values.ForEach(func(key interface{}, item moleculer.Payload) bool {
-		items = append(items, a.wrapValue(field, item))
-		return true
-	})
-	return "(" + strings.Join(items, ",") + ")"
+var sqlOperators = map[string]string{
+	dsl.Eq:  "=",
+	dsl.Ne:  "<>",
+	dsl.Gt:  ">",
+	dsl.Gte: ">=",
+	dsl.Lt:  "<",
+	dsl.Lte: "<=",
 }
 
-func (a *Adapter) orValues(values moleculer.Payload) (r string) {
-	pairs := []string{}
-	values.ForEach(func(idx interface{}, query moleculer.Payload) bool {
-		for _, pair := range a.filterPairs(query) {
-			pairs = append(pairs, pair)
-		}
-		return true
-	})
-	return strings.Join(pairs, " OR ")
-}
+var validPath = regexp.MustCompile("^[A-Za-z0-9_]+(\\.[A-Za-z0-9_]+)*$")
 
-//expressionValue when the filter clause is an expression, this function will
-//return the operator and the values formated for SQLStmts
-func (a *Adapter) expressionValue(field string, expression moleculer.Payload) (rField, value, operation string) {
-	rField = field
-	if strings.ToLower(field) == "or" {
-		rField = ""
-		value = a.orValues(expression)
-	} else {
-		expression.ForEach(func(key interface{}, item moleculer.Payload) bool {
-			operation = strings.ToLower(key.(string))
-			if operation == "between" || operation == "not between" {
-				value = a.betweenValues(field, item)
-			} else if operation == "in" || operation == "not in" {
-				value = a.inValues(field, item)
-			} else {
-				value = a.wrapValue(field, item)
-			}
-			return false
-		})
+//filterColumn return the column (or expression) used to filter the field.
+//nested paths of map columns use json_extract. example: address.city -> json_extract(address, '$.city')
+func (a *Adapter) filterColumn(field string) (string, error) {
+	if field == a.idField || hasColumn(field, a.Columns) {
+		return field, nil
+	}
+	parts := strings.SplitN(field, ".", 2)
+	if len(parts) == 2 && validPath.MatchString(field) {
+		c := findColumn(parts[0], a.Columns)
+		if c != nil && c.Type == "map" {
+			return "json_extract(" + c.Name + ", '$." + parts[1] + "')", nil
+		}
 	}
-	return rField, value, operation
+	return "", store.NewError(store.CodeValidation, "Invalid query field: "+field).WithData(map[string]interface{}{"field": field})
 }
 
-//valueAndOperator return the value properly formated for SQL Stmt and the operator to be used in the where clause.
-func (a *Adapter) valueAndOperator(field string, expression moleculer.Payload) (pair string) {
-	operation := "="
-	value := ""
-	if expression.IsMap() || expression.IsArray() {
-		field, value, operation = a.expressionValue(field, expression)
-	} else {
-		value = a.wrapValue(field, expression)
+//filterValue prepare the value to be bound in the where clause, based on the column type.
+func (a *Adapter) filterValue(field string, value interface{}) interface{} {
+	p := payload.New(value)
+	if isTime(p) {
+		return value.(time.Time).UTC().Format(ISO8601)
 	}
-	if a.isExpression(expression.String()) {
-		operation = " "
+	switch a.columnType(field) {
+	case "NUMBER", "REAL":
+		return p.Float()
+	case "INTEGER":
+		return p.Int64()
+	case "TEXT":
+		return p.String()
 	}
-	return strings.TrimSpace(field + " " + operation + " " + value)
+	return value
 }
 
-func (a *Adapter) isExpression(value string) bool {
-	value = strings.ToUpper(value)
-	return value == "IS NOT NULL" || value == "IS NULL"
+//filterValues prepare a list of values to be bound in the where clause.
+func (a *Adapter) filterValues(field string, value interface{}) []interface{} {
+	list, _ := value.([]interface{})
+	values := []interface{}{}
+	for _, item := range list {
+		values = append(values, a.filterValue(field, item))
+	}
+	return values
 }
 
-func (a *Adapter) wrapValue(field string, value moleculer.Payload) (r string) {
-	if a.isExpression(value.String()) {
-		return strings.ToUpper(value.String())
+//conditionClause translates a query condition to SQL. example: age > ?
+func (a *Adapter) conditionClause(c dsl.Condition) (string, []interface{}, error) {
+	column, err := a.filterColumn(c.Field)
+	if err != nil {
+		return "", nil, err
+	}
+	if isListColumn(findColumn(c.Field, a.Columns)) {
+		switch c.Operator {
+		case dsl.Eq, dsl.Ne, dsl.In, dsl.Nin:
+			return listClause(column, c)
+		}
+	}
+	switch c.Operator {
+	case dsl.Exists:
+		if exists, _ := c.Value.(bool); exists {
+			return column + " IS NOT NULL", nil, nil
+		}
+		return column + " IS NULL", nil, nil
+	case dsl.In, dsl.Nin:
+		values := a.filterValues(c.Field, c.Value)
+		operator := " IN "
+		if c.Operator == dsl.Nin {
+			operator = " NOT IN "
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		return column + operator + "(" + marks + ")", values, nil
+	case dsl.Between:
+		values := a.filterValues(c.Field, c.Value)
+		if len(values) != 2 {
+			return "", nil, store.NewError(store.CodeValidation, "Query operator $between requires a list with two values")
+		}
+		return column + " BETWEEN ? AND ?", values, nil
+	case dsl.Like:
+		return column + " LIKE ?", []interface{}{c.Value}, nil
+	}
+	operator, valid := sqlOperators[c.Operator]
+	if !valid {
+		return "", nil, store.NewError(store.CodeValidation, "Invalid query operator: "+c.Operator)
 	}
+	return column + " " + operator + " ?", []interface{}{a.filterValue(c.Field, c.Value)}, nil
+}
 
-	cType := a.columnType(field)
-	if cType == "TEXT" && isTime(value) {
-		t, _ := value.Value().(time.Time)
-		return "'" + t.UTC().Format(ISO8601) + "'"
+func isListColumn(c *Column) bool {
+	return c != nil && (c.Type == "[]string" || c.Type == "[]int" || c.Type == "[]integer")
+}
+
+//listClause matches any item of a list column. example: instr('||' || tags || '||', '||' || ? || '||') > 0
+func listClause(column string, c dsl.Condition) (string, []interface{}, error) {
+	items := []interface{}{c.Value}
+	if c.Operator == dsl.In || c.Operator == dsl.Nin {
+		items, _ = c.Value.([]interface{})
 	}
-	if cType == "TEXT" || cType == "" {
-		return "'" + value.String() + "'"
+	clauses := []string{}
+	values := []interface{}{}
+	for _, item := range items {
+		clauses = append(clauses, "instr('"+listSeparator+"' || "+column+" || '"+listSeparator+"', '"+listSeparator+"' || ? || '"+listSeparator+"') > 0")
+		values = append(values, fmt.Sprint(item))
 	}
-	if cType == "NUMBER" {
-		return "'" + strconv.FormatFloat(value.Float(), 'f', 6, 64) + "'"
+	clause := "0"
+	if len(clauses) > 0 {
+		clause = "(" + strings.Join(clauses, " OR ") + ")"
 	}
-	if cType == "INTEGER" {
-		return fmt.Sprint(value.Int64())
+	if c.Operator == dsl.Ne || c.Operator == dsl.Nin {
+		return "(" + column + " IS NOT NULL AND NOT " + clause + ")", values, nil
 	}
-	return r
+	return clause, values, nil
 }
 
-func isTime(p moleculer.Payload) bool {
-	_, valid := p.Value().(time.Time)
-	return valid
+//filterClause translates the query to a SQL where clause and the values to be bound.
+func (a *Adapter) filterClause(node dsl.Node) (string, []interface{}, error) {
+	switch n := node.(type) {
+	case dsl.Condition:
+		return a.conditionClause(n)
+	case dsl.Negation:
+		clause, values, err := a.filterClause(n.Node)
+		if err != nil {
+			return "", nil, err
+		}
+		// NULL comparisons are false, so the records without the field match the negation, as in Mongo.
+		return "NOT coalesce(" + clause + ", 0)", values, nil
+	case dsl.Logical:
+		if len(n.Nodes) == 0 {
+			if n.Operator == dsl.Or {
+				return "0", nil, nil
+			}
+			return "1", nil, nil
+		}
+		clauses := []string{}
+		values := []interface{}{}
+		for _, child := range n.Nodes {
+			clause, childValues, err := a.filterClause(child)
+			if err != nil {
+				return "", nil, err
+			}
+			clauses = append(clauses, clause)
+			values = append(values, childValues...)
+		}
+		separator := " AND "
+		if n.Operator == dsl.Or {
+			separator = " OR "
+		}
+		return "(" + strings.Join(clauses, separator) + ")", values, nil
+	}
+	return "", nil, store.NewError(store.CodeValidation, "Invalid query")
 }
 
-//filterPairs create the where clause filter pairs: example. userName = 'John'
-//uses a mongo-esq style for advanced filters, examples:
+//findWhere create the where clause for the query, nativeQuery and search params.
+//the query uses the portable query language (see package dsl), examples:
 // "query": M{
 // 	"age": M{
-// 		">": 60,
+// 		"$gt": 60,
 // 	},
 // },
 //will result in:
-// where age > 60
-func (a *Adapter) filterPairs(query moleculer.Payload) (pairs []string) {
-	query.ForEach(func(key interface{}, item moleculer.Payload) bool {
-		field := key.(string)
-		pair := a.valueAndOperator(field, item)
-		pairs = append(pairs, pair)
-		return true
-	})
-	return pairs
-}
-
-func (a *Adapter) updateWhere(params moleculer.Payload) string {
-	where := ""
-	queryPairs := a.filterPairs(params)
-	if len(queryPairs) > 0 {
-		where = strings.Join(queryPairs, " AND ")
+// where age > ?
+//nativeQuery is a raw SQL expression added to the where clause, so it is only accepted from Go code (the actions reject it).
+func (a *Adapter) findWhere(params moleculer.Payload) (string, []interface{}, error) {
+	node, err := dsl.Parse(params.Get("query"))
+	if err != nil {
+		return "", nil, store.WrapError(store.CodeValidation, err)
 	}
-	return where
-}
-
-func (a *Adapter) findWhere(params moleculer.Payload) string {
-	query := payload.Empty()
-	if params.Get("query").Exists() {
-		query = params.Get("query")
+	clauses := []string{}
+	values := []interface{}{}
+	if logical, ok := node.(dsl.Logical); !ok || len(logical.Nodes) > 0 {
+		clause, queryValues, err := a.filterClause(node)
+		if err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, clause)
+		values = append(values, queryValues...)
 	}
-	where := ""
-	queryPairs := a.filterPairs(query)
-	if len(queryPairs) > 0 {
-		where = strings.Join(queryPairs, " AND ")
+	if params.Get("nativeQuery").Exists() {
+		clauses = append(clauses, "("+params.Get("nativeQuery").String()+")")
 	}
 	searchPairs := a.parseSearchFields(params)
 	if len(searchPairs) > 0 {
-		if where != "" {
-			where = where + " AND "
-		}
-		where = where + "(" + strings.Join(searchPairs, " OR ") + ")"
+		clauses = append(clauses, "("+strings.Join(searchPairs, " OR ")+")")
 	}
-	return where
+	return strings.Join(clauses, " AND "), values, nil
 }
 
 func (a *Adapter) parseSearchFields(params moleculer.Payload) (pairs []string) {
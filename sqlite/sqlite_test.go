@@ -1,7 +1,11 @@
 package sqlite
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"time"
 
 	"crawshaw.io/sqlite"
@@ -10,6 +14,7 @@ import (
 	"github.com/moleculer-go/moleculer"
 
 	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
@@ -306,6 +311,97 @@ var _ = Describe("Sqlite", func() {
 		})
 	})
 
+	Describe("Update operators", func() {
+		var adapter Adapter
+		BeforeEach(func() {
+			adapter = Adapter{
+				URI:      "file:memory:?mode=memory",
+				Flags:    0,
+				PoolSize: 1,
+				Table:    "operators",
+				Columns: []Column{
+					{
+						Name: "name",
+						Type: "string",
+					},
+					{
+						Name: "visits",
+						Type: "integer",
+					},
+					{
+						Name: "tags",
+						Type: "[]string",
+					},
+				},
+			}
+			log.SetLevel(logLevel)
+			adapter.Init(log.WithField("", ""), M{})
+			adapter.Connect()
+			adapter.Insert(payload.New(M{
+				"name":   "Marie",
+				"visits": 1,
+				"tags":   []string{"a", "b"},
+			}))
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should $inc a column", func() {
+			r := adapter.UpdateById(payload.New(1), payload.New(M{"$inc": M{"visits": 2}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("visits").Int()).Should(Equal(3))
+		})
+
+		It("should $push and $pull values of a []string column", func() {
+			r := adapter.UpdateById(payload.New(1), payload.New(M{"$push": M{"tags": "c"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("tags").StringArray()).Should(Equal([]string{"a", "b", "c"}))
+
+			r = adapter.UpdateById(payload.New(1), payload.New(M{"$pull": M{"tags": "b"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("tags").StringArray()).Should(Equal([]string{"a", "c"}))
+		})
+
+		It("should $pull all occurrences of a value, as adjacent duplicates", func() {
+			adapter.UpdateById(payload.New(1), payload.New(M{"tags": []string{"a", "a", "b", "a", "a", "a"}}))
+			r := adapter.UpdateById(payload.New(1), payload.New(M{"$pull": M{"tags": "a"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("tags").StringArray()).Should(Equal([]string{"b"}))
+
+			r = adapter.UpdateById(payload.New(1), payload.New(M{"$pull": M{"tags": "b"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": "b"}})).Int()).Should(Equal(0))
+		})
+
+		It("should $unset a column and mix operators with plain fields", func() {
+			r := adapter.UpdateById(payload.New(1), payload.New(M{
+				"name":   "Marie Claire",
+				"$unset": []string{"visits"},
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("name").String()).Should(Equal("Marie Claire"))
+			Expect(r.Get("visits").Exists()).Should(BeFalse())
+		})
+
+		It("should match items of a []string column", func() {
+			adapter.Insert(payload.New(M{"name": "John", "tags": []string{"b", "c"}}))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": "b"}})).Int()).Should(Equal(2))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": M{"$in": []string{"a", "x"}}}})).Int()).Should(Equal(1))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": M{"$ne": "c"}}})).Int()).Should(Equal(1))
+			Expect(adapter.Count(payload.New(M{"query": M{"tags": M{"$nin": []string{"a", "b"}}}})).Int()).Should(Equal(0))
+		})
+
+		It("should apply operators on findAndUpdate", func() {
+			r := adapter.FindAndUpdate(payload.New(M{
+				"query":  M{"name": "Marie"},
+				"update": M{"$inc": M{"visits": 10}},
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.First().Get("visits").Int()).Should(Equal(11))
+		})
+	})
+
 	Describe("Find options", func() {
 
 		var adapter Adapter
@@ -465,6 +561,29 @@ var _ = Describe("Sqlite", func() {
 			Expect(r.Int()).Should(Equal(6))
 		})
 
+		It("should UpdateMany records matching the query", func() {
+			r := adapter.UpdateMany(payload.New(map[string]interface{}{
+				"query":  M{"name": M{"in": []string{"Mario", "Zabib"}}},
+				"update": M{"email": "changed@mail.com"},
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+
+			r = adapter.Count(payload.New(M{"query": M{"email": "changed@mail.com"}}))
+			Expect(r.Int()).Should(Equal(2))
+		})
+
+		It("should RemoveMany records matching the query", func() {
+			r := adapter.RemoveMany(payload.New(map[string]interface{}{
+				"query": M{"name": M{"like": "M%"}},
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+
+			r = adapter.Count(payload.Empty())
+			Expect(r.Int()).Should(Equal(4))
+		})
+
 		It("should RemoveAll remove all records", func() {
 			r := adapter.Count(payload.Empty())
 			Expect(r.Int()).Should(Equal(6))
@@ -682,6 +801,102 @@ var _ = Describe("Sqlite", func() {
 			Expect(r.Len()).Should(Equal(1))
 		})
 
+		It("should find people using the query language operators", func() {
+			r := adapter.Find(payload.New(M{"query": M{"age": M{"$ne": 35}}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(4))
+
+			r = adapter.Find(payload.New(M{"query": M{"$not": M{"letter": "M"}}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(4))
+
+			r = adapter.Find(payload.New(M{"query": M{"age": M{"$not": M{"$lt": 20}}}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(4))
+
+			r = adapter.Find(payload.New(M{"query": M{"age": M{"$exists": false}}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+
+			r = adapter.Find(payload.New(M{"query": M{"$or": []M{
+				M{"age": M{"$gte": 35}},
+				M{"$and": []M{
+					M{"letter": "A"},
+					M{"age": M{"$lt": 20}},
+				}},
+			}}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(3))
+		})
+
+		It("should find people using a native query", func() {
+			r := adapter.Find(payload.New(M{"nativeQuery": "length(name) > 6"}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(3))
+
+			r = adapter.Find(payload.New(M{
+				"query":       M{"letter": "M"},
+				"nativeQuery": "length(name) > 6",
+			}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("name").String()).Should(Equal("Michael"))
+		})
+
+		It("should fail with fields that are not columns", func() {
+			r := adapter.Find(payload.New(M{"query": M{"password": "1234"}}))
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("Invalid query field: password"))
+		})
+
+	})
+
+	Describe("Nested fields", func() {
+		var adapter Adapter
+		BeforeEach(func() {
+			adapter = Adapter{
+				URI:      "file:memory:?mode=memory",
+				Flags:    0,
+				PoolSize: 1,
+				Table:    "nested",
+				Columns: []Column{
+					{
+						Name: "name",
+						Type: "string",
+					},
+					{
+						Name: "address",
+						Type: "map",
+					},
+				},
+			}
+			log.SetLevel(logLevel)
+			adapter.Init(log.WithField("", ""), M{})
+			adapter.Connect()
+			adapter.Insert(payload.New(M{
+				"name":    "Marie",
+				"address": map[string]interface{}{"city": "Auckland", "number": 10},
+			}))
+			adapter.Insert(payload.New(M{
+				"name":    "John",
+				"address": map[string]interface{}{"city": "Wellington", "number": 25},
+			}))
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should filter by nested fields of map columns", func() {
+			r := adapter.Find(payload.New(M{"query": M{"address.city": "Auckland"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("name").String()).Should(Equal("Marie"))
+
+			r = adapter.Find(payload.New(M{"query": M{"address": M{"number": M{"$gt": 20}}}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("name").String()).Should(Equal("John"))
+		})
 	})
 
 	Describe("Date and Datetime", func() {
@@ -775,5 +990,232 @@ var _ = Describe("Sqlite", func() {
 			Expect(r.Array()[0].Get("title").String()).Should(Equal("day after tomorrow"))
 
 		})
+
+		It("should export and import the records with their dates", func() {
+			var out bytes.Buffer
+			count, err := store.Export(&adapter, &out, store.ExportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(count).Should(Equal(3))
+
+			restored := Adapter{URI: "file:memory:?mode=memory", Table: "dates", Columns: adapter.Columns}
+			restored.Init(log.WithField("", ""), M{})
+			Expect(restored.Connect()).Should(Succeed())
+			defer restored.Disconnect()
+			result, err := store.Import(&restored, bytes.NewReader(out.Bytes()), store.ImportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(result.Inserted).Should(Equal(3))
+
+			r := restored.Find(payload.New(M{"sort": "created"}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Array()[0].Get("title").String()).Should(Equal("today"))
+			Expect(r.Array()[2].Get("title").String()).Should(Equal("day after tomorrow"))
+			Expect(isTime(r.Array()[0].Get("created"))).Should(BeTrue())
+
+			result, err = store.Import(&restored, bytes.NewReader(out.Bytes()), store.ImportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(result.Updated).Should(Equal(3))
+			Expect(restored.Count(payload.Empty()).Int()).Should(Equal(3))
+		})
+	})
+
+	Describe("Migrations", func() {
+		var uri string
+		var dir string
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "migrations")
+			must(err)
+			uri = "file:" + filepath.Join(dir, "store.db")
+			log.SetLevel(logLevel)
+		})
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		connect := func(columns []Column, migrations []Migration) *Adapter {
+			adapter := &Adapter{
+				URI:        uri,
+				PoolSize:   1,
+				Table:      "people",
+				Columns:    columns,
+				Migrations: migrations,
+			}
+			adapter.Init(log.WithField("", ""), M{})
+			Expect(adapter.Connect()).Should(Succeed())
+			return adapter
+		}
+
+		It("should add the new columns and run the pending migrations once", func() {
+			adapter := connect([]Column{{"name", "string"}, {"mail", "string"}, {"age", "string"}}, nil)
+			adapter.Insert(payload.New(M{"name": "Marie", "mail": "marie@m.com", "age": "75"}))
+			Expect(adapter.Disconnect()).Should(Succeed())
+
+			columns := []Column{{"name", "string"}, {"email", "string"}, {"age", "integer"}, {"city", "string"}}
+			migrations := []Migration{
+				{Version: 2, Description: "age as integer", Steps: []MigrationStep{ChangeColumnType("age", "integer")}},
+				{Version: 1, Description: "rename mail", Steps: []MigrationStep{RenameColumn("mail", "email")}},
+			}
+			adapter = connect(columns, migrations)
+			r := adapter.FindById(payload.New(1))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("email").String()).Should(Equal("marie@m.com"))
+			Expect(r.Get("age").Int()).Should(Equal(75))
+
+			r = adapter.UpdateById(payload.New(1), payload.New(M{"city": "Paris"}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("city").String()).Should(Equal("Paris"))
+
+			applied := adapter.AppliedMigrations()
+			Expect(applied.Error()).Should(BeNil())
+			Expect(applied.Len()).Should(Equal(3))
+			Expect(applied.Array()[0].Get("version").Int()).Should(Equal(1))
+			Expect(applied.Array()[1].Get("version").Int()).Should(Equal(2))
+			Expect(applied.Array()[2].Get("description").String()).Should(Equal("add column city"))
+			Expect(adapter.Disconnect()).Should(Succeed())
+
+			adapter = connect(columns, migrations)
+			Expect(adapter.AppliedMigrations().Len()).Should(Equal(3))
+			Expect(adapter.FindById(payload.New(1)).Get("city").String()).Should(Equal("Paris"))
+			Expect(adapter.Disconnect()).Should(Succeed())
+		})
+
+		It("should only record the migrations of a new table", func() {
+			adapter := connect([]Column{{"name", "string"}}, []Migration{
+				{Version: 1, Description: "rename mail", Steps: []MigrationStep{RenameColumn("mail", "email")}},
+			})
+			applied := adapter.AppliedMigrations()
+			Expect(applied.Len()).Should(Equal(1))
+			Expect(applied.First().Get("description").String()).Should(Equal("rename mail"))
+			Expect(adapter.Disconnect()).Should(Succeed())
+		})
+
+		It("should fail to connect when a migration fails", func() {
+			adapter := connect([]Column{{"name", "string"}}, nil)
+			Expect(adapter.Disconnect()).Should(Succeed())
+
+			adapter = &Adapter{
+				URI:   uri,
+				Table: "people",
+				Migrations: []Migration{
+					{Version: 1, Steps: []MigrationStep{DropColumn("mail")}},
+				},
+			}
+			adapter.Init(log.WithField("", ""), M{})
+			err := adapter.Connect()
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("Column not found: mail"))
+		})
+	})
+
+	Describe("Indexes", func() {
+		var adapter Adapter
+		BeforeEach(func() {
+			adapter = Adapter{
+				URI:   "file:memory:?mode=memory",
+				Table: "indexed",
+				Columns: []Column{
+					{Name: "email", Type: "string"},
+					{Name: "name", Type: "string"},
+					{Name: "age", Type: "integer"},
+					{Name: "address", Type: "map"},
+				},
+			}
+			log.SetLevel(logLevel)
+			adapter.Init(log.WithField("", ""), M{"indexes": []M{
+				{"fields": []string{"email"}, "unique": true, "sparse": true},
+				{"fields": []string{"name", "-age"}},
+				{"fields": []string{"address.city"}},
+			}})
+			Expect(adapter.Connect()).Should(Succeed())
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should create the indexes declared in the settings", func() {
+			r := adapter.Indexes()
+			Expect(r.Error()).Should(BeNil())
+			indexes := map[string]moleculer.Payload{}
+			r.ForEach(func(_ interface{}, index moleculer.Payload) bool {
+				indexes[index.Get("name").String()] = index
+				return true
+			})
+			Expect(indexes["indexed_email"].Get("unique").Bool()).Should(BeTrue())
+			Expect(indexes["indexed_email"].Get("sparse").Bool()).Should(BeTrue())
+			Expect(indexes["indexed_name_age"].Get("fields").StringArray()).Should(Equal([]string{"name", "-age"}))
+			Expect(indexes["indexed_address_city"].Get("fields").StringArray()).Should(Equal([]string{"<expression>"}))
+		})
+
+		It("should reject duplicated values of unique indexes", func() {
+			Expect(adapter.Insert(payload.New(M{"email": "marie@m.com"})).Error()).Should(BeNil())
+			Expect(adapter.Insert(payload.New(M{"email": "marie@m.com"})).IsError()).Should(BeTrue())
+			Expect(adapter.Insert(payload.New(M{"name": "John"})).Error()).Should(BeNil())
+			Expect(adapter.Insert(payload.New(M{"name": "John"})).Error()).Should(BeNil())
+		})
+
+		It("should fail to connect with indexes of unknown fields", func() {
+			invalid := Adapter{URI: "file:memory:?mode=memory", Table: "invalid"}
+			invalid.Init(log.WithField("", ""), M{"indexes": []M{{"fields": []string{"email"}}}})
+			err := invalid.Connect()
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("Invalid query field: email"))
+		})
+	})
+
+	Describe("Errors", func() {
+		var adapter Adapter
+		BeforeEach(func() {
+			adapter = Adapter{
+				URI:     "file:memory:?mode=memory",
+				Table:   "errors",
+				Columns: []Column{{Name: "email", Type: "string"}, {Name: "name", Type: "string"}},
+			}
+			log.SetLevel(logLevel)
+			adapter.Init(log.WithField("", ""), M{"indexes": []M{{"fields": []string{"email"}, "unique": true}}})
+			Expect(adapter.Connect()).Should(Succeed())
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should return typed errors", func() {
+			marie := adapter.Insert(payload.New(M{"email": "marie@m.com", "name": "Marie"}))
+			Expect(marie.Error()).Should(BeNil())
+			r := adapter.Insert(payload.New(M{"email": "marie@m.com"}))
+			Expect(store.ErrorCode(r.Error())).Should(Equal(store.CodeConflict))
+
+			r = adapter.Find(payload.New(M{"query": M{"password": "1234"}}))
+			Expect(store.ErrorCode(r.Error())).Should(Equal(store.CodeValidation))
+			Expect(r.Error().(*store.Error).Data).Should(Equal(map[string]interface{}{"field": "password"}))
+
+			r = adapter.UpdateById(payload.New(100), payload.New(M{"name": "Nobody"}))
+			Expect(store.IsNotFound(r.Error())).Should(BeTrue())
+			Expect(store.IsNotFound(adapter.RemoveById(payload.New(100)).Error())).Should(BeTrue())
+			Expect(adapter.RemoveById(marie.Get("id")).Get("deletedCount").Int()).Should(Equal(1))
+
+			adapter.Disconnect()
+			Expect(store.IsUnavailable(adapter.Ping())).Should(BeTrue())
+		})
+	})
+
+	Describe("Health", func() {
+		It("should ping the database and report the pool usage", func() {
+			adapter := Adapter{
+				URI:      "file:memory:?mode=memory",
+				Table:    "health",
+				PoolSize: 2,
+				Columns:  []Column{{Name: "name", Type: "string"}},
+			}
+			log.SetLevel(logLevel)
+			adapter.Init(log.WithField("", ""), M{})
+			Expect(adapter.Ping()).ShouldNot(Succeed())
+
+			Expect(adapter.Connect()).Should(Succeed())
+			Expect(adapter.Ping()).Should(Succeed())
+			Expect(adapter.PoolStats()).Should(Equal(map[string]interface{}{"size": 2, "inUse": int64(0)}))
+
+			adapter.Disconnect()
+			Expect(adapter.Ping()).ShouldNot(Succeed())
+		})
 	})
 })
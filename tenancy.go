@@ -0,0 +1,329 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store/dsl"
+	log "github.com/sirupsen/logrus"
+)
+
+// TenantAdapter is implemented by adapters that can store each tenant in its own table/collection/index.
+// ForTenant returns a copy of the adapter (already initialized) for the tenant, that is not connected yet.
+type TenantAdapter interface {
+	ForTenant(tenant string) Adapter
+}
+
+var defaultTenantField = "tenantId"
+var validTenant = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// tenancyConfig is parsed from the tenancy setting:
+// "tenancy": true -> use defaults
+// "tenancy": map[string]interface{}{
+//	"field":    "tenantId", // record field with the tenant. Default: tenantId
+//	"metaKey":  "tenantId", // ctx.Meta key with the tenant. Default: same as field
+//	"required": true,       // reject calls without a tenant. Default: true
+//	"perTable": false,      // one table/collection per tenant. Default: false
+// }
+type tenancyConfig struct {
+	field    string
+	metaKey  string
+	required bool
+	perTable bool
+}
+
+// parseTenancy returns nil when the tenancy setting is not enabled.
+func parseTenancy(settings map[string]interface{}) *tenancyConfig {
+	value, exists := settings["tenancy"]
+	if !exists || value == nil {
+		return nil
+	}
+	config := &tenancyConfig{field: defaultTenantField, required: true}
+	switch setting := value.(type) {
+	case bool:
+		if !setting {
+			return nil
+		}
+	case map[string]interface{}:
+		if field, ok := setting["field"].(string); ok && field != "" {
+			config.field = field
+		}
+		if metaKey, ok := setting["metaKey"].(string); ok {
+			config.metaKey = metaKey
+		}
+		if required, ok := setting["required"].(bool); ok {
+			config.required = required
+		}
+		if perTable, ok := setting["perTable"].(bool); ok {
+			config.perTable = perTable
+		}
+	default:
+		return nil
+	}
+	if config.metaKey == "" {
+		config.metaKey = config.field
+	}
+	return config
+}
+
+// tenantAdapters keeps the connected adapters of each tenant, when using one table per tenant.
+type tenantAdapters struct {
+	mutex    sync.Mutex
+	adapters map[string]Adapter
+}
+
+// get returns the adapter for the tenant, connecting it on first use.
+func (t *tenantAdapters) get(adapter Adapter, tenant string) (Adapter, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if existing, ok := t.adapters[tenant]; ok {
+		return existing, nil
+	}
+	tenantAdapter, ok := adapter.(TenantAdapter)
+	if !ok {
+		return nil, NewError(CodeValidation, "tenancy perTable is not supported by this adapter")
+	}
+	scoped := tenantAdapter.ForTenant(tenant)
+	if scoped == nil {
+		return nil, NewError(CodeValidation, "tenancy perTable is not supported by this adapter")
+	}
+	if err := scoped.Connect(); err != nil {
+		return nil, WrapError(CodeUnavailable, err, "Could not connect adapter for tenant: ", tenant, " - error: ")
+	}
+	if t.adapters == nil {
+		t.adapters = map[string]Adapter{}
+	}
+	t.adapters[tenant] = scoped
+	return scoped, nil
+}
+
+// disconnect disconnects all tenant adapters.
+func (t *tenantAdapters) disconnect(logger *log.Entry) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for tenant, adapter := range t.adapters {
+		if err := adapter.Disconnect(); err != nil {
+			logger.Error("Could not disconnect adapter for tenant: ", tenant, " - error: ", err)
+		}
+	}
+	t.adapters = nil
+}
+
+// resolveAdapter returns the adapter for the tenant in ctx.Meta.
+// When tenancy is not enabled it returns the adapter itself.
+func resolveAdapter(ctx moleculer.Context, adapter Adapter, instance *moleculer.ServiceSchema, tenants *tenantAdapters) (Adapter, error) {
+	if instance == nil {
+		return adapter, nil
+	}
+	config := parseTenancy(instance.Settings)
+	if config == nil {
+		return adapter, nil
+	}
+	tenant := payload.New(nil)
+	if meta := ctx.Meta(); meta != nil {
+		tenant = meta.Get(config.metaKey)
+	}
+	if !tenant.Exists() || tenant.String() == "" {
+		if config.required {
+			return nil, NewError(CodeValidation, "tenant required! ctx.Meta."+config.metaKey+" is missing.")
+		}
+		return adapter, nil
+	}
+	if config.perTable {
+		if !validTenant.MatchString(tenant.String()) {
+			return nil, NewError(CodeValidation, "Invalid tenant: "+tenant.String())
+		}
+		return tenants.get(adapter, tenant.String())
+	}
+	return &tenantScope{adapter, config.field, tenant.Value()}, nil
+}
+
+// tenantScope wraps an adapter restricting all operations to the records of one tenant.
+type tenantScope struct {
+	adapter Adapter
+	field   string
+	tenant  interface{}
+}
+
+func (t *tenantScope) Init(logger *log.Entry, settings map[string]interface{}) {
+	t.adapter.Init(logger, settings)
+}
+
+func (t *tenantScope) Connect() error {
+	return t.adapter.Connect()
+}
+
+func (t *tenantScope) Disconnect() error {
+	return t.adapter.Disconnect()
+}
+
+// WithContext returns the scope with the adapter bound to the context. See ContextAdapter.
+func (t *tenantScope) WithContext(ctx context.Context) Adapter {
+	return &tenantScope{WithContext(t.adapter, ctx), t.field, t.tenant}
+}
+
+// scope adds the tenant condition to the query of the params.
+// Native queries are rejected, since the tenant condition can't be added to them.
+func (t *tenantScope) scope(params moleculer.Payload) (moleculer.Payload, error) {
+	if params == nil {
+		params = payload.Empty()
+	}
+	if params.Get("nativeQuery").Exists() {
+		return nil, NewError(CodeValidation, "nativeQuery is not supported with tenancy!")
+	}
+	filter := map[string]interface{}{t.field: t.tenant}
+	query := params.Get("query")
+	if query.Exists() {
+		filter = map[string]interface{}{"$and": []interface{}{query.Value(), filter}}
+	}
+	return params.Add("query", filter), nil
+}
+
+// owns checks if the record belongs to the tenant.
+func (t *tenantScope) owns(record moleculer.Payload) bool {
+	return record != nil && !record.IsError() && record.Exists() &&
+		record.Get(t.field).Exists() && record.Get(t.field).String() == fmt.Sprint(t.tenant)
+}
+
+// checkUpdate rejects updates that change the tenant field.
+func (t *tenantScope) checkUpdate(update moleculer.Payload) error {
+	if update == nil || !update.Exists() {
+		return nil
+	}
+	ops, err := dsl.ParseUpdate(update)
+	if err != nil {
+		return WrapError(CodeValidation, err)
+	}
+	for _, op := range ops {
+		if op.Field == t.field {
+			return NewError(CodeValidation, "tenant field "+t.field+" can't be updated!").WithData(map[string]interface{}{"field": t.field})
+		}
+	}
+	return nil
+}
+
+// checkOwner returns an error when the record of the id does not belong to the tenant.
+func (t *tenantScope) checkOwner(id moleculer.Payload) moleculer.Payload {
+	if !t.owns(t.adapter.FindById(id)) {
+		return payload.New(NewError(CodeNotFound, "Could not find record with id: ", id.String(), " for the tenant.").WithData(map[string]interface{}{"id": id.Value()}))
+	}
+	return nil
+}
+
+func (t *tenantScope) Find(params moleculer.Payload) moleculer.Payload {
+	scoped, err := t.scope(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return t.adapter.Find(scoped)
+}
+
+func (t *tenantScope) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	if err := t.checkUpdate(params.Get("update")); err != nil {
+		return payload.New(err)
+	}
+	scoped, err := t.scope(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return t.adapter.FindAndUpdate(scoped)
+}
+
+func (t *tenantScope) FindOne(params moleculer.Payload) moleculer.Payload {
+	scoped, err := t.scope(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return t.adapter.FindOne(scoped)
+}
+
+// FindById returns an empty payload when the record belongs to another tenant.
+func (t *tenantScope) FindById(id moleculer.Payload) moleculer.Payload {
+	record := t.adapter.FindById(id)
+	if record.IsError() || t.owns(record) {
+		return record
+	}
+	return payload.New(nil)
+}
+
+// FindByIds only returns the records of the tenant.
+func (t *tenantScope) FindByIds(ids moleculer.Payload) moleculer.Payload {
+	result := t.adapter.FindByIds(ids)
+	if result.IsError() {
+		return result
+	}
+	list := []moleculer.Payload{}
+	result.ForEach(func(_ interface{}, record moleculer.Payload) bool {
+		if t.owns(record) {
+			list = append(list, record)
+		}
+		return true
+	})
+	return payload.New(list)
+}
+
+func (t *tenantScope) Count(params moleculer.Payload) moleculer.Payload {
+	scoped, err := t.scope(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return t.adapter.Count(scoped)
+}
+
+// Insert stamps the tenant on the record.
+func (t *tenantScope) Insert(params moleculer.Payload) moleculer.Payload {
+	return t.adapter.Insert(params.Add(t.field, t.tenant))
+}
+
+func (t *tenantScope) Update(params moleculer.Payload) moleculer.Payload {
+	return t.UpdateById(params.Get("id"), params.Remove("id"))
+}
+
+func (t *tenantScope) UpdateById(id, update moleculer.Payload) moleculer.Payload {
+	if err := t.checkUpdate(update); err != nil {
+		return payload.New(err)
+	}
+	if rejected := t.checkOwner(id); rejected != nil {
+		return rejected
+	}
+	return t.adapter.UpdateById(id, update)
+}
+
+func (t *tenantScope) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	if err := t.checkUpdate(params.Get("update")); err != nil {
+		return payload.New(err)
+	}
+	scoped, err := t.scope(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return t.adapter.UpdateMany(scoped)
+}
+
+func (t *tenantScope) RemoveById(id moleculer.Payload) moleculer.Payload {
+	if rejected := t.checkOwner(id); rejected != nil {
+		return rejected
+	}
+	return t.adapter.RemoveById(id)
+}
+
+func (t *tenantScope) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	scoped, err := t.scope(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return t.adapter.RemoveMany(scoped)
+}
+
+// RemoveAll only removes the records of the tenant.
+func (t *tenantScope) RemoveAll() moleculer.Payload {
+	scoped, err := t.scope(payload.Empty())
+	if err != nil {
+		return payload.New(err)
+	}
+	return t.adapter.RemoveMany(scoped)
+}
@@ -0,0 +1,117 @@
+package typed
+
+import (
+	"errors"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// Caller calls moleculer actions. Implemented by moleculer.Context and the service broker.
+type Caller interface {
+	Call(actionName string, params interface{}, opts ...moleculer.Options) chan moleculer.Payload
+}
+
+// Client is a typed API to call the actions of a remote store service.
+//
+//	users, err := typed.NewClient(ctx, "users", User{})
+//	user := User{}
+//	err = users.Get(id, &user)
+type Client struct {
+	Mapper  *Mapper
+	caller  Caller
+	service string
+}
+
+// NewClient creates a client for the store service, for the struct type of the model.
+func NewClient(caller Caller, service string, model interface{}) (*Client, error) {
+	mapper, err := MapperOf(model)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{mapper, caller, service}, nil
+}
+
+func (c *Client) call(action string, params interface{}) moleculer.Payload {
+	return <-c.caller.Call(c.service+"."+action, params)
+}
+
+// Find calls the find action and fills out (e.g. *[]User) with the result.
+func (c *Client) Find(params moleculer.Payload, out interface{}) error {
+	return c.Mapper.FromList(c.call("find", paramsOrEmpty(params)), out)
+}
+
+// List calls the list action, fills out (e.g. *[]User) with the rows of the page and returns the total of records.
+func (c *Client) List(params moleculer.Payload, out interface{}) (int, error) {
+	result := c.call("list", paramsOrEmpty(params))
+	if result.IsError() {
+		return 0, result.Error()
+	}
+	if err := c.Mapper.FromList(result.Get("rows"), out); err != nil {
+		return 0, err
+	}
+	return result.Get("total").Int(), nil
+}
+
+// Get calls the get action and fills out (e.g. *User) with the record of the id.
+func (c *Client) Get(id interface{}, out interface{}) error {
+	result := c.call("get", payload.Empty().Add("id", id))
+	if result.IsError() {
+		return result.Error()
+	}
+	if !result.Exists() {
+		return ErrNotFound
+	}
+	return c.Mapper.FromPayload(result, out)
+}
+
+// Count calls the count action.
+func (c *Client) Count(params moleculer.Payload) (int, error) {
+	result := c.call("count", paramsOrEmpty(params))
+	if result.IsError() {
+		return 0, result.Error()
+	}
+	return result.Int(), nil
+}
+
+// Create calls the create action with the record (e.g. *User) and sets its id.
+func (c *Client) Create(record interface{}) error {
+	values, err := c.Mapper.ToPayload(record)
+	if err != nil {
+		return err
+	}
+	result := c.call("create", values)
+	if result.IsError() {
+		return result.Error()
+	}
+	if name := c.Mapper.IDField(); name != "" && result.Get(name).Exists() {
+		return c.Mapper.SetID(record, result.Get(name).Value())
+	}
+	return nil
+}
+
+// Update calls the update action with all fields of the record. Empty omitempty fields are saved empty.
+func (c *Client) Update(record interface{}) error {
+	id, hasID := c.Mapper.ID(record)
+	if !hasID {
+		return errors.New("typed client can't update a record without id")
+	}
+	values, err := c.Mapper.UpdateMap(record)
+	if err != nil {
+		return err
+	}
+	result := c.call("update", payload.New(values).Add("id", id))
+	if result.IsError() {
+		return result.Error()
+	}
+	return nil
+}
+
+// Remove calls the remove action for the id.
+func (c *Client) Remove(id interface{}) error {
+	result := c.call("remove", payload.Empty().Add("id", id))
+	if result.IsError() {
+		return result.Error()
+	}
+	return nil
+}
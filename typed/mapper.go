@@ -0,0 +1,469 @@
+// Package typed maps Go structs to store records and offers a typed repository
+// on top of a store.Adapter and a typed client for remote store services.
+package typed
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// field is a struct field mapped to a record field.
+type field struct {
+	index     []int
+	name      string
+	id        bool
+	omitempty bool
+}
+
+// Mapper converts a struct type to records and back, using the store tags:
+//
+//	type User struct {
+//		ID     string   `store:"id,id"`
+//		Name   string   `store:"name"`
+//		Tags   []string `store:"tags,omitempty"`
+//		Secret string   `store:"-"`
+//	}
+//
+// Fields without a tag use the field name with the first letter in lower case.
+// The id field is the one with the id option, or the one named id.
+type Mapper struct {
+	Type   reflect.Type
+	fields []field
+	id     *field
+}
+
+var mappers = sync.Map{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// MapperOf returns the mapper for the type of the model (a struct or a pointer to a struct).
+func MapperOf(model interface{}) (*Mapper, error) {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New(fmt.Sprint("typed mapper requires a struct - model: ", model))
+	}
+	return mapperOfType(t), nil
+}
+
+func mapperOfType(t reflect.Type) *Mapper {
+	if m, ok := mappers.Load(t); ok {
+		return m.(*Mapper)
+	}
+	m := &Mapper{Type: t, fields: structFields(t, nil)}
+	for i := range m.fields {
+		if m.fields[i].id {
+			m.id = &m.fields[i]
+			break
+		}
+	}
+	if m.id == nil {
+		for i := range m.fields {
+			if m.fields[i].name == "id" {
+				m.fields[i].id = true
+				m.id = &m.fields[i]
+				break
+			}
+		}
+	}
+	mappers.Store(t, m)
+	return m
+}
+
+// structFields returns the mapped fields of the struct. Embedded structs without tag are flattened.
+func structFields(t reflect.Type, index []int) []field {
+	fields := []field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("store")
+		if tag == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, structFields(sf.Type, fieldIndex)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		f := field{index: fieldIndex, name: parts[0]}
+		if f.name == "" {
+			f.name = defaultName(sf.Name)
+		}
+		for _, option := range parts[1:] {
+			switch strings.TrimSpace(option) {
+			case "id":
+				f.id = true
+			case "omitempty":
+				f.omitempty = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// defaultName lower cases the first letter of the field name. Upper case names (e.g. ID) are all lower cased.
+func defaultName(name string) string {
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// IDField returns the record field name of the id. Returns "" when the struct has no id field.
+func (m *Mapper) IDField() string {
+	if m.id == nil {
+		return ""
+	}
+	return m.id.name
+}
+
+// structValue returns the struct value of the record, which can be the struct or a pointer to it.
+func (m *Mapper) structValue(record interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(record)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() == reflect.Ptr {
+		return v, errors.New(fmt.Sprint("typed mapper of ", m.Type, " can't map a nil record"))
+	}
+	if v.Type() != m.Type {
+		return v, errors.New(fmt.Sprint("typed mapper of ", m.Type, " can't map ", v.Type()))
+	}
+	return v, nil
+}
+
+// ID returns the id of the record. Returns false when the id is not set.
+func (m *Mapper) ID(record interface{}) (interface{}, bool) {
+	v, err := m.structValue(record)
+	if err != nil || m.id == nil {
+		return nil, false
+	}
+	value := v.FieldByIndex(m.id.index)
+	if isEmpty(value) {
+		return nil, false
+	}
+	return value.Interface(), true
+}
+
+// SetID sets the id of the record. Record must be a pointer.
+func (m *Mapper) SetID(record interface{}, id interface{}) error {
+	if m.id == nil {
+		return nil
+	}
+	v := reflect.ValueOf(record)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("typed mapper SetID requires a pointer to the record")
+	}
+	if _, err := m.structValue(record); err != nil {
+		return err
+	}
+	return assign(v.Elem().FieldByIndex(m.id.index), id)
+}
+
+// ToMap converts the record to a map. Empty ids and empty omitempty fields are not included.
+func (m *Mapper) ToMap(record interface{}) (map[string]interface{}, error) {
+	return m.toMap(record, false)
+}
+
+// UpdateMap converts the record to the map of an update: all fields but the id, including
+// the empty omitempty fields, so the update clears them.
+func (m *Mapper) UpdateMap(record interface{}) (map[string]interface{}, error) {
+	return m.toMap(record, true)
+}
+
+func (m *Mapper) toMap(record interface{}, update bool) (map[string]interface{}, error) {
+	v, err := m.structValue(record)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	for _, f := range m.fields {
+		value := v.FieldByIndex(f.index)
+		if f.id && (update || isEmpty(value)) || !update && f.omitempty && isEmpty(value) {
+			continue
+		}
+		result[f.name] = toValue(value)
+	}
+	return result, nil
+}
+
+// ToPayload converts the record to a payload.
+func (m *Mapper) ToPayload(record interface{}) (moleculer.Payload, error) {
+	values, err := m.ToMap(record)
+	if err != nil {
+		return nil, err
+	}
+	return payload.New(values), nil
+}
+
+// FromPayload fills the struct pointed by out with the values of the payload.
+func (m *Mapper) FromPayload(p moleculer.Payload, out interface{}) error {
+	if p.IsError() {
+		return p.Error()
+	}
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != m.Type {
+		return errors.New(fmt.Sprint("typed mapper FromPayload requires a *", m.Type, " - out: ", v.Type()))
+	}
+	return m.fromValue(v.Elem(), reflect.ValueOf(p.Value()))
+}
+
+// FromList fills the slice pointed by out (e.g. *[]User or *[]*User) with the records of the payload list.
+func (m *Mapper) FromList(p moleculer.Payload, out interface{}) error {
+	if p.IsError() {
+		return p.Error()
+	}
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return errors.New(fmt.Sprint("typed mapper FromList requires a pointer to a slice - out: ", v.Type()))
+	}
+	elemType := v.Elem().Type().Elem()
+	if elemType != m.Type && !(elemType.Kind() == reflect.Ptr && elemType.Elem() == m.Type) {
+		return errors.New(fmt.Sprint("typed mapper of ", m.Type, " can't fill ", v.Elem().Type()))
+	}
+	list := reflect.MakeSlice(v.Elem().Type(), 0, p.Len())
+	for _, item := range p.Array() {
+		record := reflect.New(m.Type)
+		if err := m.fromValue(record.Elem(), reflect.ValueOf(item.Value())); err != nil {
+			return err
+		}
+		if elemType.Kind() == reflect.Ptr {
+			list = reflect.Append(list, record)
+		} else {
+			list = reflect.Append(list, record.Elem())
+		}
+	}
+	v.Elem().Set(list)
+	return nil
+}
+
+// fromValue fills the struct with the values of the map.
+func (m *Mapper) fromValue(out reflect.Value, values reflect.Value) error {
+	values = unwrap(values)
+	if values.Kind() != reflect.Map {
+		return errors.New(fmt.Sprint("typed mapper of ", m.Type, " requires a map - value: ", values))
+	}
+	for _, f := range m.fields {
+		key := reflect.ValueOf(f.name)
+		if values.Type().Key() != key.Type() {
+			key = key.Convert(values.Type().Key())
+		}
+		value := values.MapIndex(key)
+		if !value.IsValid() {
+			continue
+		}
+		if err := assign(out.FieldByIndex(f.index), value.Interface()); err != nil {
+			return errors.New("field " + f.name + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// unwrap returns the value inside interfaces and payloads.
+func unwrap(v reflect.Value) reflect.Value {
+	for v.IsValid() {
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			v = v.Elem()
+			continue
+		}
+		if p, ok := v.Interface().(moleculer.Payload); ok && v.Kind() != reflect.Map {
+			v = reflect.ValueOf(p.Value())
+			continue
+		}
+		break
+	}
+	return v
+}
+
+// isEmpty checks if the value is empty, same as encoding/json omitempty.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// toValue converts the field value to a record value. Nested structs become maps.
+func toValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return toValue(v.Elem())
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface()
+		}
+		values, _ := mapperOfType(v.Type()).ToMap(v.Interface())
+		return values
+	case reflect.Slice:
+		elemKind := v.Type().Elem().Kind()
+		if elemKind == reflect.Struct && v.Type().Elem() != timeType || elemKind == reflect.Ptr {
+			list := make([]interface{}, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				list[i] = toValue(v.Index(i))
+			}
+			return list
+		}
+	}
+	return v.Interface()
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.000", "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// assign sets the value to dst, converting the types returned by the adapters
+// (e.g. int64 ids to strings, strings to time.Time and maps to structs).
+func assign(dst reflect.Value, value interface{}) error {
+	src := unwrap(reflect.ValueOf(value))
+	if !src.IsValid() {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(dst.Type().Elem())
+		if err := assign(ptr.Elem(), src.Interface()); err != nil {
+			return err
+		}
+		dst.Set(ptr)
+		return nil
+	case reflect.String:
+		if hex, ok := src.Interface().(interface{ Hex() string }); ok {
+			dst.SetString(hex.Hex())
+			return nil
+		}
+		if src.Kind() == reflect.String || isNumber(src.Kind()) || src.Kind() == reflect.Bool {
+			dst.SetString(fmt.Sprint(src.Interface()))
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if src.Kind() == reflect.String {
+			i, err := strconv.ParseInt(src.String(), 10, 64)
+			if err != nil {
+				return err
+			}
+			dst.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if src.Kind() == reflect.String {
+			i, err := strconv.ParseUint(src.String(), 10, 64)
+			if err != nil {
+				return err
+			}
+			dst.SetUint(i)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if src.Kind() == reflect.String {
+			f, err := strconv.ParseFloat(src.String(), 64)
+			if err != nil {
+				return err
+			}
+			dst.SetFloat(f)
+			return nil
+		}
+	case reflect.Bool:
+		if src.Kind() == reflect.String {
+			dst.SetBool(src.String() == "1" || strings.ToLower(src.String()) == "true")
+			return nil
+		}
+		if isNumber(src.Kind()) {
+			dst.SetBool(src.Convert(reflect.TypeOf(float64(0))).Float() != 0)
+			return nil
+		}
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			if withTime, ok := src.Interface().(interface{ Time() time.Time }); ok {
+				dst.Set(reflect.ValueOf(withTime.Time()))
+				return nil
+			}
+			if src.Kind() == reflect.String {
+				t, err := parseTime(src.String())
+				if err != nil {
+					return err
+				}
+				dst.Set(reflect.ValueOf(t))
+				return nil
+			}
+			break
+		}
+		return mapperOfType(dst.Type()).fromValue(dst, src)
+	case reflect.Slice:
+		if src.Kind() == reflect.Slice || src.Kind() == reflect.Array {
+			list := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+			for i := 0; i < src.Len(); i++ {
+				if err := assign(list.Index(i), src.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+			dst.Set(list)
+			return nil
+		}
+	case reflect.Map:
+		if src.Kind() == reflect.Map {
+			m := reflect.MakeMapWithSize(dst.Type(), src.Len())
+			for _, key := range src.MapKeys() {
+				k := reflect.New(dst.Type().Key()).Elem()
+				if err := assign(k, key.Interface()); err != nil {
+					return err
+				}
+				v := reflect.New(dst.Type().Elem()).Elem()
+				if err := assign(v, src.MapIndex(key).Interface()); err != nil {
+					return err
+				}
+				m.SetMapIndex(k, v)
+			}
+			dst.Set(m)
+			return nil
+		}
+	}
+	if isNumber(dst.Kind()) && isNumber(src.Kind()) {
+		dst.Set(src.Convert(dst.Type()))
+		return nil
+	}
+	return errors.New(fmt.Sprint("can't assign ", src.Type(), " to ", dst.Type()))
+}
@@ -0,0 +1,119 @@
+package typed
+
+import (
+	"time"
+
+	"github.com/moleculer-go/moleculer/payload"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type Address struct {
+	City   string `store:"city"`
+	Number int    `store:"number,omitempty"`
+}
+
+type Audit struct {
+	Created time.Time `store:"created"`
+}
+
+type User struct {
+	Audit
+	ID       string   `store:"id,id"`
+	Name     string   `store:"name"`
+	Age      int      `store:"age,omitempty"`
+	Tags     []string `store:"tags,omitempty"`
+	Address  *Address `store:"address,omitempty"`
+	Password string   `store:"-"`
+	Active   bool
+}
+
+var _ = Describe("Mapper", func() {
+
+	mapper, _ := MapperOf(User{})
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	It("should require a struct", func() {
+		_, err := MapperOf("user")
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("should use the tags for names, id and omitempty", func() {
+		Expect(mapper.IDField()).Should(Equal("id"))
+		values, err := mapper.ToMap(&User{
+			Audit:    Audit{created},
+			Name:     "John",
+			Address:  &Address{City: "Auckland"},
+			Password: "secret",
+		})
+		Expect(err).Should(BeNil())
+		Expect(values).Should(Equal(map[string]interface{}{
+			"created": created,
+			"name":    "John",
+			"address": map[string]interface{}{"city": "Auckland"},
+			"active":  false,
+		}))
+	})
+
+	It("should include the empty omitempty fields, without the id, in updates", func() {
+		values, err := mapper.UpdateMap(&User{ID: "1", Name: "John"})
+		Expect(err).Should(BeNil())
+		Expect(values).Should(HaveKeyWithValue("age", 0))
+		Expect(values).Should(HaveKeyWithValue("address", BeNil()))
+		Expect(values).Should(HaveKey("tags"))
+		Expect(values).ShouldNot(HaveKey("id"))
+	})
+
+	It("should fail with nil records", func() {
+		_, err := mapper.ToMap(nil)
+		Expect(err).ShouldNot(BeNil())
+		_, err = mapper.ToMap((*User)(nil))
+		Expect(err).ShouldNot(BeNil())
+		_, hasID := mapper.ID(nil)
+		Expect(hasID).Should(BeFalse())
+	})
+
+	It("should convert the values returned by the adapters", func() {
+		user := User{}
+		err := mapper.FromPayload(payload.New(map[string]interface{}{
+			"id":       int64(10),
+			"name":     "John",
+			"age":      float64(25),
+			"tags":     []interface{}{"admin", "dev"},
+			"created":  "2020-01-02 03:04:05.000",
+			"address":  map[string]interface{}{"city": "Auckland", "number": int64(10)},
+			"active":   "1",
+			"password": "ignored",
+		}), &user)
+		Expect(err).Should(BeNil())
+		Expect(user).Should(Equal(User{
+			Audit:   Audit{created},
+			ID:      "10",
+			Name:    "John",
+			Age:     25,
+			Tags:    []string{"admin", "dev"},
+			Address: &Address{"Auckland", 10},
+			Active:  true,
+		}))
+	})
+
+	It("should fill lists of structs and pointers", func() {
+		list := payload.New([]map[string]interface{}{{"name": "John"}, {"name": "Marie"}})
+		users := []User{}
+		Expect(mapper.FromList(list, &users)).Should(Succeed())
+		Expect(len(users)).Should(Equal(2))
+		Expect(users[1].Name).Should(Equal("Marie"))
+
+		pointers := []*User{}
+		Expect(mapper.FromList(list, &pointers)).Should(Succeed())
+		Expect(pointers[0].Name).Should(Equal("John"))
+
+		Expect(mapper.FromList(list, &[]Address{})).ShouldNot(Succeed())
+	})
+
+	It("should fail when the value can't be converted", func() {
+		user := User{}
+		err := mapper.FromPayload(payload.New(map[string]interface{}{"age": "old"}), &user)
+		Expect(err).ShouldNot(BeNil())
+	})
+})
@@ -0,0 +1,120 @@
+package typed
+
+import (
+	"errors"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+)
+
+// ErrNotFound is returned by Get and FindOne when there is no matching record. Its code is store.CodeNotFound.
+var ErrNotFound error = store.NewError(store.CodeNotFound, "record not found")
+
+// Repository is a typed API on top of a store adapter, for the struct type of the model.
+//
+//	users, err := typed.NewRepository(adapter, User{})
+//	err = users.Insert(&User{Name: "John"})
+//	list := []User{}
+//	err = users.Find(q.Where(q.Field("name").Eq("John")).Params(), &list)
+type Repository struct {
+	Mapper  *Mapper
+	adapter store.Adapter
+}
+
+// NewRepository creates a repository for the struct type of the model.
+func NewRepository(adapter store.Adapter, model interface{}) (*Repository, error) {
+	mapper, err := MapperOf(model)
+	if err != nil {
+		return nil, err
+	}
+	return &Repository{mapper, adapter}, nil
+}
+
+func paramsOrEmpty(params moleculer.Payload) moleculer.Payload {
+	if params == nil {
+		return payload.Empty()
+	}
+	return params
+}
+
+// Find fills out (e.g. *[]User) with the records matching the params.
+// Params are the same as the find action (query, search, sort, limit...).
+func (r *Repository) Find(params moleculer.Payload, out interface{}) error {
+	return r.Mapper.FromList(r.adapter.Find(paramsOrEmpty(params)), out)
+}
+
+// FindOne fills out (e.g. *User) with the first record matching the params.
+func (r *Repository) FindOne(params moleculer.Payload, out interface{}) error {
+	result := r.adapter.Find(paramsOrEmpty(params).Add("limit", 1))
+	if result.IsError() {
+		return result.Error()
+	}
+	if result.Len() == 0 {
+		return ErrNotFound
+	}
+	return r.Mapper.FromPayload(result.First(), out)
+}
+
+// Get fills out (e.g. *User) with the record of the id.
+func (r *Repository) Get(id interface{}, out interface{}) error {
+	result := r.adapter.FindById(payload.New(id))
+	if result.IsError() {
+		return result.Error()
+	}
+	if !result.Exists() {
+		return ErrNotFound
+	}
+	return r.Mapper.FromPayload(result, out)
+}
+
+// Count returns the number of records matching the params.
+func (r *Repository) Count(params moleculer.Payload) (int, error) {
+	result := r.adapter.Count(paramsOrEmpty(params))
+	if result.IsError() {
+		return 0, result.Error()
+	}
+	return result.Int(), nil
+}
+
+// Insert inserts the record (e.g. *User) and sets its id.
+func (r *Repository) Insert(record interface{}) error {
+	values, err := r.Mapper.ToPayload(record)
+	if err != nil {
+		return err
+	}
+	result := r.adapter.Insert(values)
+	if result.IsError() {
+		return result.Error()
+	}
+	if name := r.Mapper.IDField(); name != "" && result.Get(name).Exists() {
+		return r.Mapper.SetID(record, result.Get(name).Value())
+	}
+	return nil
+}
+
+// Update saves all fields of the record, using its id. Empty omitempty fields are saved empty.
+func (r *Repository) Update(record interface{}) error {
+	id, hasID := r.Mapper.ID(record)
+	if !hasID {
+		return errors.New("typed repository can't update a record without id")
+	}
+	values, err := r.Mapper.UpdateMap(record)
+	if err != nil {
+		return err
+	}
+	result := r.adapter.UpdateById(payload.New(id), payload.New(values))
+	if result.IsError() {
+		return result.Error()
+	}
+	return nil
+}
+
+// Remove removes the record of the id.
+func (r *Repository) Remove(id interface{}) error {
+	result := r.adapter.RemoveById(payload.New(id))
+	if result.IsError() {
+		return result.Error()
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package typed
+
+import (
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/q"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// adapterCaller calls the adapter directly, in place of the store service actions.
+type adapterCaller struct {
+	adapter store.Adapter
+}
+
+func (c adapterCaller) Call(actionName string, params interface{}, opts ...moleculer.Options) chan moleculer.Payload {
+	p := payload.New(params)
+	result := make(chan moleculer.Payload, 1)
+	switch actionName {
+	case "users.find":
+		result <- c.adapter.Find(p)
+	case "users.list":
+		result <- payload.New(map[string]interface{}{"rows": c.adapter.Find(p), "total": c.adapter.Count(p)})
+	case "users.count":
+		result <- c.adapter.Count(p)
+	case "users.get":
+		result <- c.adapter.FindById(p.Get("id"))
+	case "users.create":
+		result <- c.adapter.Insert(p)
+	case "users.update":
+		result <- c.adapter.UpdateById(p.Get("id"), p.Remove("id"))
+	case "users.remove":
+		result <- c.adapter.RemoveById(p.Get("id"))
+	default:
+		result <- payload.Error("action not found: ", actionName)
+	}
+	return result
+}
+
+func newAdapter() *store.MemoryAdapter {
+	adapter := &store.MemoryAdapter{Table: "users"}
+	adapter.Init(log.WithField("", ""), map[string]interface{}{})
+	adapter.Connect()
+	return adapter
+}
+
+var _ = Describe("Repository", func() {
+
+	var adapter *store.MemoryAdapter
+	var users *Repository
+	BeforeEach(func() {
+		adapter = newAdapter()
+		users, _ = NewRepository(adapter, User{})
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+
+	It("should insert, get, update and remove structs", func() {
+		john := User{Name: "John", Age: 25, Tags: []string{"admin"}}
+		Expect(users.Insert(&john)).Should(Succeed())
+		Expect(john.ID).ShouldNot(BeEmpty())
+
+		found := User{}
+		Expect(users.Get(john.ID, &found)).Should(Succeed())
+		Expect(found.Name).Should(Equal("John"))
+		Expect(found.Tags).Should(Equal([]string{"admin"}))
+
+		found.Age = 26
+		Expect(users.Update(&found)).Should(Succeed())
+		Expect(users.Get(john.ID, &found)).Should(Succeed())
+		Expect(found.Age).Should(Equal(26))
+
+		found.Tags = nil
+		found.Age = 0
+		Expect(users.Update(&found)).Should(Succeed())
+		cleared := User{}
+		Expect(users.Get(john.ID, &cleared)).Should(Succeed())
+		Expect(cleared.Tags).Should(BeEmpty())
+		Expect(cleared.Age).Should(Equal(0))
+		Expect(users.Update(nil)).ShouldNot(Succeed())
+
+		Expect(users.Remove(john.ID)).Should(Succeed())
+		Expect(users.Get(john.ID, &found)).Should(Equal(ErrNotFound))
+		Expect(users.Update(&User{Name: "no id"})).ShouldNot(Succeed())
+	})
+
+	It("should find and count structs", func() {
+		users.Insert(&User{Name: "John", Age: 25})
+		users.Insert(&User{Name: "Marie", Age: 75})
+		users.Insert(&User{Name: "Peter", Age: 13})
+
+		list := []User{}
+		Expect(users.Find(q.Where(q.Field("age").Gt(20)).Params(), &list)).Should(Succeed())
+		Expect(len(list)).Should(Equal(2))
+
+		one := User{}
+		Expect(users.FindOne(q.Where(q.Field("name").Eq("Peter")).Params(), &one)).Should(Succeed())
+		Expect(one.Age).Should(Equal(13))
+		Expect(users.FindOne(q.Where(q.Field("name").Eq("Nobody")).Params(), &one)).Should(Equal(ErrNotFound))
+
+		count, err := users.Count(nil)
+		Expect(err).Should(BeNil())
+		Expect(count).Should(Equal(3))
+	})
+})
+
+var _ = Describe("Client", func() {
+
+	var adapter *store.MemoryAdapter
+	var users *Client
+	BeforeEach(func() {
+		adapter = newAdapter()
+		users, _ = NewClient(adapterCaller{adapter}, "users", User{})
+	})
+	AfterEach(func() {
+		adapter.Disconnect()
+	})
+
+	It("should call the store actions with structs", func() {
+		marie := User{Name: "Marie", Age: 75}
+		Expect(users.Create(&marie)).Should(Succeed())
+		Expect(marie.ID).ShouldNot(BeEmpty())
+		Expect(users.Create(&User{Name: "Peter", Age: 13})).Should(Succeed())
+
+		found := User{}
+		Expect(users.Get(marie.ID, &found)).Should(Succeed())
+		Expect(found.Name).Should(Equal("Marie"))
+
+		found.Name = "Marie Claire"
+		Expect(users.Update(&found)).Should(Succeed())
+
+		list := []User{}
+		Expect(users.Find(q.Where(q.Field("name").Like("marie%")).Params(), &list)).Should(Succeed())
+		Expect(len(list)).Should(Equal(1))
+		Expect(list[0].Name).Should(Equal("Marie Claire"))
+
+		total, err := users.List(nil, &list)
+		Expect(err).Should(BeNil())
+		Expect(total).Should(Equal(2))
+
+		Expect(users.Remove(marie.ID)).Should(Succeed())
+		count, err := users.Count(nil)
+		Expect(err).Should(BeNil())
+		Expect(count).Should(Equal(1))
+	})
+})
@@ -0,0 +1,13 @@
+package typed
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTyped(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Typed Suite")
+}